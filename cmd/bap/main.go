@@ -2,12 +2,10 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
-	"math/big"
 	"os"
 	"strconv"
 	"strings"
@@ -16,7 +14,9 @@ import (
 
 	_ "time/tzdata"
 
+	"betsandpedestres/internal/accounts"
 	"betsandpedestres/internal/auth"
+	"betsandpedestres/internal/coins"
 	"betsandpedestres/internal/config"
 	"betsandpedestres/internal/db"
 	"betsandpedestres/internal/telegram"
@@ -40,6 +40,8 @@ func main() {
 		userCmd(os.Args[2:])
 	case "gift":
 		giftCmd(os.Args[2:])
+	case "currency":
+		currencyCmd(os.Args[2:])
 	default:
 		usage()
 		os.Exit(2)
@@ -51,14 +53,21 @@ func usage() {
 
 Usage:
   bap user create <username> [-display "<name>"] [-role user|moderator|admin] [-config config.yaml] [-db postgres://...]
-  bap gift user <username> <amount> [-note "text"] [-config config.yaml] [-db postgres://...]
-  bap gift all <amount>             [-note "text"] [-config config.yaml] [-db postgres://...]
+  bap user merge <from> <into> [-config config.yaml] [-db postgres://...]
+  bap user disable-2fa <username> [-config config.yaml] [-db postgres://...]
+  bap gift user <username> <amount> [-note "text"] [-idempotency-key KEY] [-config config.yaml] [-db postgres://...]
+  bap gift all <amount>             [-note "text"] [-idempotency-key KEY] [-config config.yaml] [-db postgres://...]
+  bap currency rescale <decimals>   [-config config.yaml] [-db postgres://...]
 
 Examples:
   bap user create alice
   bap user create bob -display "Bob Builder" -role moderator -config ./config.yaml
+  bap user merge alice2 alice
+  bap user disable-2fa alice
   bap gift user alice 100 -note "welcome bonus"
-  bap gift all 25 -note "launch airdrop"`)
+  bap gift all 25 -note "launch airdrop"
+  bap gift all 10 -note "weekly drop" -idempotency-key drop-2025-w14
+  bap currency rescale 2`)
 }
 
 func userCmd(args []string) {
@@ -69,6 +78,10 @@ func userCmd(args []string) {
 	switch args[0] {
 	case "create":
 		userCreate(args[1:])
+	case "merge":
+		userMerge(args[1:])
+	case "disable-2fa":
+		userDisable2FA(args[1:])
 	default:
 		usage()
 		os.Exit(2)
@@ -117,6 +130,12 @@ func userCreate(args []string) {
 	// set JWT secret to ensure auth helpers are ready if you reuse them later
 	auth.SetSecret(cfg.Security.JWTSecret)
 
+	// The house account is created by accounts.EnsureHouseAccount, never by hand.
+	if config.IsReservedUsername(cfg.Accounts.ReservedUsernames, username) {
+		fmt.Printf("username %q is reserved\n", username)
+		os.Exit(2)
+	}
+
 	// DB pool
 	appURL, err := resolveDBURL(cfg, *dbOverride)
 	if err != nil {
@@ -124,7 +143,7 @@ func userCreate(args []string) {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
-	pool, err := db.NewPool(ctx, appURL)
+	pool, err := db.NewPool(ctx, appURL, poolOptions(cfg))
 	if err != nil {
 		log.Fatalf("db connect: %v", err)
 	}
@@ -155,6 +174,253 @@ func userCreate(args []string) {
 	fmt.Printf("ok: user created\n  id: %s\n  username: %s\n  role: %s\n", u.ID, u.Username, u.Role)
 }
 
+func userMerge(args []string) {
+	fs := flag.NewFlagSet("user merge", flag.ExitOnError)
+	fs.Init("user merge", flag.ExitOnError)
+	var (
+		cfgPath    = fs.String("config", "config.yaml", "path to config file")
+		dbOverride = fs.String("db", "", "override database connection URL")
+	)
+	_ = fs.Parse(reorderArgs(args))
+
+	rest := fs.Args()
+	if len(rest) < 2 {
+		fmt.Println("usage: bap user merge <from> <into> [-config config.yaml]")
+		os.Exit(2)
+	}
+	from := strings.TrimSpace(rest[0])
+	into := strings.TrimSpace(rest[1])
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	auth.SetSecret(cfg.Security.JWTSecret)
+	coins.SetDecimals(cfg.Currency.Decimals)
+
+	appURL, err := resolveDBURL(cfg, *dbOverride)
+	if err != nil {
+		log.Fatalf("db url: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	pool, err := db.NewPool(ctx, appURL, poolOptions(cfg))
+	if err != nil {
+		log.Fatalf("db connect: %v", err)
+	}
+	defer pool.Close()
+	checkDecimals(ctx, pool, cfg)
+
+	moved, err := mergeUsers(ctx, pool, from, into)
+	if err != nil {
+		log.Fatalf("merge users: %v", err)
+	}
+	fmt.Printf("ok: merged %s into %s (moved %s PiedPièce(s)); %s is now disabled\n", from, into, coins.Format(moved), from)
+}
+
+// userDisable2FA turns off two-factor login for a user locked out of their
+// authenticator app and recovery codes.
+func userDisable2FA(args []string) {
+	fs := flag.NewFlagSet("user disable-2fa", flag.ExitOnError)
+	fs.Init("user disable-2fa", flag.ExitOnError)
+	var (
+		cfgPath    = fs.String("config", "config.yaml", "path to config file")
+		dbOverride = fs.String("db", "", "override database connection URL")
+	)
+	_ = fs.Parse(reorderArgs(args))
+
+	rest := fs.Args()
+	if len(rest) < 1 {
+		fmt.Println("usage: bap user disable-2fa <username> [-config config.yaml]")
+		os.Exit(2)
+	}
+	username := strings.TrimSpace(rest[0])
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	appURL, err := resolveDBURL(cfg, *dbOverride)
+	if err != nil {
+		log.Fatalf("db url: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pool, err := db.NewPool(ctx, appURL, poolOptions(cfg))
+	if err != nil {
+		log.Fatalf("db connect: %v", err)
+	}
+	defer pool.Close()
+
+	var wasOn bool
+	err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		var userID string
+		if err := tx.QueryRow(ctx, `
+			select id::text, totp_secret is not null from users where username = $1
+		`, username).Scan(&userID, &wasOn); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `update users set totp_secret = null, totp_last_step = null where id = $1::uuid`, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `delete from totp_recovery_codes where user_id = $1::uuid`, userID)
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Fatalf("no user named %q", username)
+	}
+	if err != nil {
+		log.Fatalf("disable 2fa: %v", err)
+	}
+	if !wasOn {
+		fmt.Printf("ok: %s did not have two-factor login enabled\n", username)
+		return
+	}
+	fmt.Printf("ok: two-factor login disabled for %s; they can log in with their password and enroll again\n", username)
+}
+
+// mergeUsers moves everything owned by `from` onto `into` in a single
+// transaction and disables `from`. The wallet balance is moved with a
+// TRANSFER ledger transaction (the ledger is append-only, so `from`'s past
+// entries stay on its own wallet); bets, wagers, comments, reactions and votes
+// are reassigned. Rows that would collide with one `into` already has (same
+// reaction or vote) keep `into`'s version.
+func mergeUsers(ctx context.Context, pool *pgxpool.Pool, from, into string) (int64, error) {
+	if strings.EqualFold(from, into) {
+		return 0, errors.New("cannot merge a user into itself")
+	}
+	if strings.EqualFold(from, accounts.HouseUsername) || strings.EqualFold(into, accounts.HouseUsername) {
+		return 0, errors.New("cannot merge the house account")
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	lookup := func(username string) (userID, accID string, err error) {
+		err = tx.QueryRow(ctx, `
+			select u.id, a.id
+			from users u
+			join accounts a on a.user_id = u.id and a.is_default
+			where u.username = $1 and u.disabled_at is null
+			for update of u
+		`, username).Scan(&userID, &accID)
+		if err == pgx.ErrNoRows {
+			return "", "", fmt.Errorf("user %q not found or already disabled", username)
+		}
+		return userID, accID, err
+	}
+	fromID, fromAcc, err := lookup(from)
+	if err != nil {
+		return 0, err
+	}
+	intoID, intoAcc, err := lookup(into)
+	if err != nil {
+		return 0, err
+	}
+
+	var balance int64
+	if err := tx.QueryRow(ctx, `
+		select coalesce(sum(delta),0)::bigint from ledger_entries where account_id = $1
+	`, fromAcc).Scan(&balance); err != nil {
+		return 0, err
+	}
+	if balance < 0 {
+		return 0, fmt.Errorf("%s has a negative balance (%s); refusing to merge", from, coins.Format(balance))
+	}
+	if balance > 0 {
+		var txID string
+		if err := tx.QueryRow(ctx,
+			`insert into transactions (reason, bet_id, note) values ('TRANSFER', null, $1) returning id`,
+			fmt.Sprintf("account merge: %s -> %s", from, into)).Scan(&txID); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(ctx, `insert into ledger_entries (tx_id, account_id, delta) values ($1,$2,$3)`,
+			txID, fromAcc, -balance); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(ctx, `insert into ledger_entries (tx_id, account_id, delta) values ($1,$2,$3)`,
+			txID, intoAcc, balance); err != nil {
+			return 0, err
+		}
+	}
+
+	stmts := []string{
+		`update bets set creator_user_id = $2 where creator_user_id = $1`,
+		`update bet_edits set editor_user_id = $2 where editor_user_id = $1`,
+		`update announcements set created_by = $2 where created_by = $1`,
+		`update wagers set user_id = $2 where user_id = $1`,
+		`update comments set user_id = $2 where user_id = $1`,
+		`delete from comment_reactions f using comment_reactions i
+		   where f.user_id = $1 and i.user_id = $2 and i.comment_id = f.comment_id`,
+		`update comment_reactions set user_id = $2 where user_id = $1`,
+		`delete from moderator_votes f using moderator_votes i
+		   where f.moderator_id = $1 and i.moderator_id = $2 and i.bet_id = f.bet_id`,
+		`update moderator_votes set moderator_id = $2 where moderator_id = $1`,
+		`delete from bet_resolution_votes f using bet_resolution_votes i
+		   where f.user_id = $1 and i.user_id = $2 and i.bet_id = f.bet_id`,
+		`update bet_resolution_votes set user_id = $2 where user_id = $1`,
+		`delete from resolution_suggestions f using resolution_suggestions i
+		   where f.user_id = $1 and i.user_id = $2 and i.bet_id = f.bet_id`,
+		`update resolution_suggestions set user_id = $2 where user_id = $1`,
+		`delete from bet_subscriptions f using bet_subscriptions i
+		   where f.user_id = $1 and i.user_id = $2 and i.bet_id = f.bet_id`,
+		`update bet_subscriptions set user_id = $2 where user_id = $1`,
+		`delete from bet_resolvers f using bet_resolvers i
+		   where f.user_id = $1 and i.user_id = $2 and i.bet_id = f.bet_id`,
+		`update bet_resolvers set user_id = $2 where user_id = $1`,
+		`delete from bet_invitations f using bet_invitations i
+		   where f.user_id = $1 and i.user_id = $2 and i.bet_id = f.bet_id`,
+		`update bet_invitations set user_id = $2 where user_id = $1`,
+		`update bet_invitations set invited_by = $2 where invited_by = $1`,
+		`delete from user_achievements f using user_achievements i
+		   where f.user_id = $1 and i.user_id = $2 and i.key = f.key`,
+		`update user_achievements set user_id = $2 where user_id = $1`,
+		`delete from user_follows
+		   where (follower_id = $1 and followee_id = $2) or (follower_id = $2 and followee_id = $1)`,
+		`delete from user_follows f using user_follows i
+		   where f.follower_id = $1 and i.follower_id = $2 and i.followee_id = f.followee_id`,
+		`update user_follows set follower_id = $2 where follower_id = $1`,
+		`delete from user_follows f using user_follows i
+		   where f.followee_id = $1 and i.followee_id = $2 and i.follower_id = f.follower_id`,
+		`update user_follows set followee_id = $2 where followee_id = $1`,
+		`update admin_actions set admin_user_id = $2 where admin_user_id = $1`,
+		`update admin_actions set target_user_id = $2 where target_user_id = $1`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.Exec(ctx, q, fromID, intoID); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `delete from password_recoveries where user_id = $1`, fromID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `delete from totp_recovery_codes where user_id = $1`, fromID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `delete from sessions where user_id = $1`, fromID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `
+		update users
+		set disabled_at = now(), role = 'unverified', telegram_chat_id = null, telegram_notify = false, email = null
+		where id = $1
+	`, fromID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
 func promptPassword(prompt string) string {
 	fmt.Fprint(os.Stderr, prompt)
 	b, err := term.ReadPassword(int(syscall.Stdin))
@@ -216,26 +482,29 @@ func giftUserCmd(args []string) {
 		cfgPath    = fs.String("config", "config.yaml", "path to config file")
 		dbOverride = fs.String("db", "", "override database connection URL")
 		note       = fs.String("note", "", "optional note for the transaction")
+		idempKey   = fs.String("idempotency-key", "", "skip the gift if one with this key was already applied")
 	)
 	_ = fs.Parse(reorderArgs(args))
 
 	rest := fs.Args()
 	if len(rest) < 2 {
-		fmt.Println("usage: bap gift user <username> <amount> [-note \"...\"] [-config config.yaml]")
+		fmt.Println("usage: bap gift user <username> <amount> [-note \"...\"] [-idempotency-key KEY] [-config config.yaml]")
 		os.Exit(2)
 	}
 	username := strings.TrimSpace(rest[0])
-	amount, err := strconv.ParseInt(rest[1], 10, 64)
-	if err != nil || amount <= 0 {
-		fmt.Println("amount must be a positive integer")
-		os.Exit(2)
-	}
 
 	cfg, err := config.Load(*cfgPath)
 	if err != nil {
 		log.Fatalf("config: %v", err)
 	}
 	auth.SetSecret(cfg.Security.JWTSecret)
+	coins.SetDecimals(cfg.Currency.Decimals)
+
+	amount, err := coins.Parse(rest[1])
+	if err != nil || amount <= 0 {
+		fmt.Println("amount must be a positive number")
+		os.Exit(2)
+	}
 
 	appURL, err := resolveDBURL(cfg, *dbOverride)
 	if err != nil {
@@ -245,16 +514,21 @@ func giftUserCmd(args []string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	pool, err := db.NewPool(ctx, appURL)
+	pool, err := db.NewPool(ctx, appURL, poolOptions(cfg))
 	if err != nil {
 		log.Fatalf("db connect: %v", err)
 	}
 	defer pool.Close()
+	checkDecimals(ctx, pool, cfg)
 
-	if err := giftToSingleUser(ctx, pool, username, amount, *note); err != nil {
+	if err := giftToSingleUser(ctx, pool, username, amount, *note, *idempKey); err != nil {
+		if errors.Is(err, accounts.ErrAlreadyApplied) {
+			fmt.Printf("already applied: a gift with key %q exists, nothing done\n", *idempKey)
+			return
+		}
 		log.Fatalf("gift user: %v", err)
 	}
-	fmt.Printf("ok: gifted %d PiedPièce(s) to %s\n", amount, username)
+	fmt.Printf("ok: gifted %s PiedPièce(s) to %s\n", coins.Format(amount), username)
 }
 
 func giftAllCmd(args []string) {
@@ -264,17 +538,13 @@ func giftAllCmd(args []string) {
 		cfgPath    = fs.String("config", "config.yaml", "path to config file")
 		dbOverride = fs.String("db", "", "override database connection URL")
 		note       = fs.String("note", "", "optional note for the transaction")
+		idempKey   = fs.String("idempotency-key", "", "skip the gift if one with this key was already applied")
 	)
 	_ = fs.Parse(reorderArgs(args))
 
 	rest := fs.Args()
 	if len(rest) < 1 {
-		fmt.Println("usage: bap gift all <amount> [-note \"...\"] [-config config.yaml]")
-		os.Exit(2)
-	}
-	amount, err := strconv.ParseInt(rest[0], 10, 64)
-	if err != nil || amount <= 0 {
-		fmt.Println("amount must be a positive integer")
+		fmt.Println("usage: bap gift all <amount> [-note \"...\"] [-idempotency-key KEY] [-config config.yaml]")
 		os.Exit(2)
 	}
 
@@ -283,6 +553,13 @@ func giftAllCmd(args []string) {
 		log.Fatalf("config: %v", err)
 	}
 	auth.SetSecret(cfg.Security.JWTSecret)
+	coins.SetDecimals(cfg.Currency.Decimals)
+
+	amount, err := coins.Parse(rest[0])
+	if err != nil || amount <= 0 {
+		fmt.Println("amount must be a positive number")
+		os.Exit(2)
+	}
 
 	appURL, err := resolveDBURL(cfg, *dbOverride)
 	if err != nil {
@@ -292,53 +569,112 @@ func giftAllCmd(args []string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	pool, err := db.NewPool(ctx, appURL)
+	pool, err := db.NewPool(ctx, appURL, poolOptions(cfg))
 	if err != nil {
 		log.Fatalf("db connect: %v", err)
 	}
 	defer pool.Close()
+	checkDecimals(ctx, pool, cfg)
 
-	n, err := giftToAllUsers(ctx, pool, amount, *note)
+	n, err := giftToAllUsers(ctx, pool, amount, *note, *idempKey)
+	if errors.Is(err, accounts.ErrAlreadyApplied) {
+		fmt.Printf("already applied: a gift with key %q exists, nothing done\n", *idempKey)
+		return
+	}
 	if err != nil {
 		log.Fatalf("gift all: %v", err)
 	}
-	fmt.Printf("ok: gifted %d PiedPièce(s) to each of %d user(s)\n", amount, n)
+	fmt.Printf("ok: gifted %s PiedPièce(s) to each of %d user(s)\n", coins.Format(amount), n)
 
 	if cfg.Telegram.BotToken != "" && cfg.Telegram.GroupChatID != "" {
 		notifier := telegram.New(pool, cfg.Telegram.BotToken, cfg.Telegram.GroupChatID)
 		ctxNotify, cancelNotify := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancelNotify()
-		msg := fmt.Sprintf("🪂 ALERT AIRDROP ! 🚨\n\nA gift of %d PiedPièces 🦶 was granted to everyone\n\nGo spend it all ! 🎰🎲", amount)
+		msg := fmt.Sprintf("🪂 ALERT AIRDROP ! 🚨\n\nA gift of %s PiedPièces 🦶 was granted to everyone\n\nGo spend it all ! 🎰🎲", coins.Format(amount))
 		notifier.NotifyGroup(ctxNotify, msg)
 	}
 }
 
-const houseUsername = "house"
+func currencyCmd(args []string) {
+	if len(args) < 1 || args[0] != "rescale" {
+		usage()
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("currency rescale", flag.ExitOnError)
+	fs.Init("currency rescale", flag.ExitOnError)
+	var (
+		cfgPath    = fs.String("config", "config.yaml", "path to config file")
+		dbOverride = fs.String("db", "", "override database connection URL")
+	)
+	_ = fs.Parse(reorderArgs(args[1:]))
 
-func giftToSingleUser(ctx context.Context, pool *pgxpool.Pool, username string, amount int64, note string) error {
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	rest := fs.Args()
+	if len(rest) < 1 {
+		fmt.Println("usage: bap currency rescale <decimals> [-config config.yaml]")
+		os.Exit(2)
+	}
+	to, err := strconv.Atoi(rest[0])
+	if err != nil || to < 1 || to > 4 {
+		fmt.Println("decimals must be between 1 and 4")
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	appURL, err := resolveDBURL(cfg, *dbOverride)
+	if err != nil {
+		log.Fatalf("db url: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	tx, err := pool.Begin(ctx)
+	pool, err := db.NewPool(ctx, appURL, poolOptions(cfg))
 	if err != nil {
+		log.Fatalf("db connect: %v", err)
+	}
+	defer pool.Close()
+
+	var from int
+	err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		var err error
+		from, err = accounts.RescaleDecimals(ctx, tx, to)
 		return err
+	})
+	if err != nil {
+		log.Fatalf("rescale: %v", err)
 	}
-	defer tx.Rollback(ctx)
+	fmt.Printf("ok: rescaled the ledger from %d to %d decimals; set currency.decimals: %d before starting the app\n", from, to, to)
+}
+
+// checkDecimals refuses to touch amounts when the configured decimals do
+// not match the ledger's.
+func checkDecimals(ctx context.Context, pool *pgxpool.Pool, cfg *config.Config) {
+	if err := accounts.CheckDecimals(ctx, pool, cfg.Currency.Decimals); err != nil {
+		log.Fatalf("currency: %v", err)
+	}
+}
+
+func giftToSingleUser(ctx context.Context, pool *pgxpool.Pool, username string, amount int64, note, idempKey string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
 
-	// Ensure house user and get its default account
-	houseAccID, err := ensureHouseAccount(ctx, tx)
+	tx, err := pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("house account: %w", err)
+		return err
 	}
+	defer tx.Rollback(ctx)
 
 	// Get recipient default account
-	var targetUserID, targetAccID string
+	var targetAccID string
 	err = tx.QueryRow(ctx, `
-		select u.id, a.id
+		select a.id
 		from users u
 		join accounts a on a.user_id = u.id and a.is_default
 		where u.username = $1
-	`, username).Scan(&targetUserID, &targetAccID)
+	`, username).Scan(&targetAccID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return fmt.Errorf("user %q not found", username)
@@ -346,28 +682,13 @@ func giftToSingleUser(ctx context.Context, pool *pgxpool.Pool, username string,
 		return err
 	}
 
-	// Create transaction
-	var txID string
-	if err := tx.QueryRow(ctx,
-		`insert into transactions (reason, bet_id, note) values ('GIFT', null, $1) returning id`, note).
-		Scan(&txID); err != nil {
-		return err
-	}
-
-	// Balanced entries: house -> target
-	if _, err := tx.Exec(ctx, `insert into ledger_entries (tx_id, account_id, delta) values ($1,$2,$3)`,
-		txID, houseAccID, -amount); err != nil {
-		return err
+	if err := accounts.GiftFromHouseOnce(ctx, tx, targetAccID, amount, note, idempKey); err != nil {
+		return fmt.Errorf("gift: %w", err)
 	}
-	if _, err := tx.Exec(ctx, `insert into ledger_entries (tx_id, account_id, delta) values ($1,$2,$3)`,
-		txID, targetAccID, amount); err != nil {
-		return err
-	}
-
 	return tx.Commit(ctx)
 }
 
-func giftToAllUsers(ctx context.Context, pool *pgxpool.Pool, amount int64, note string) (int, error) {
+func giftToAllUsers(ctx context.Context, pool *pgxpool.Pool, amount int64, note, idempKey string) (int, error) {
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
@@ -377,117 +698,43 @@ func giftToAllUsers(ctx context.Context, pool *pgxpool.Pool, amount int64, note
 	}
 	defer tx.Rollback(ctx)
 
-	houseAccID, err := ensureHouseAccount(ctx, tx)
+	houseAccID, err := accounts.EnsureHouseAccount(ctx, tx)
 	if err != nil {
 		return 0, fmt.Errorf("house account: %w", err)
 	}
 
-	// List all user default accounts, excluding house
-	rows, err := tx.Query(ctx, `
-		select u.id, a.id
-		from users u
-		join accounts a on a.user_id = u.id and a.is_default
-		where u.username <> $1
-	`, houseUsername)
+	// Create single transaction with many entries
+	txID, err := accounts.InsertTransaction(ctx, tx, "GIFT", note, idempKey)
 	if err != nil {
 		return 0, err
 	}
-	defer rows.Close()
 
-	type pair struct{ userID, accID string }
-	var recips []pair
-	for rows.Next() {
-		var p pair
-		if err := rows.Scan(&p.userID, &p.accID); err != nil {
-			return 0, err
-		}
-		recips = append(recips, p)
-	}
-	if err := rows.Err(); err != nil {
+	// Recipients credit: one statement for every default wallet but the house's.
+	tag, err := tx.Exec(ctx, `
+		insert into ledger_entries (tx_id, account_id, delta)
+		select $1, a.id, $2
+		from users u
+		join accounts a on a.user_id = u.id and a.is_default
+		where u.username <> $3 and u.disabled_at is null
+	`, txID, amount, accounts.HouseUsername)
+	if err != nil {
 		return 0, err
 	}
-	if len(recips) == 0 {
+	n := int(tag.RowsAffected())
+	if n == 0 {
 		return 0, fmt.Errorf("no recipients (only house exists?)")
 	}
 
-	total := amount * int64(len(recips))
-
-	// Create single transaction with many entries
-	var txID string
-	if err := tx.QueryRow(ctx,
-		`insert into transactions (reason, bet_id, note) values ('GIFT', null, $1) returning id`, note).
-		Scan(&txID); err != nil {
-		return 0, err
-	}
-
-	// House debit (negative)
+	// House debit (negative), balancing the credits above
 	if _, err := tx.Exec(ctx, `insert into ledger_entries (tx_id, account_id, delta) values ($1,$2,$3)`,
-		txID, houseAccID, -total); err != nil {
+		txID, houseAccID, -amount*int64(n)); err != nil {
 		return 0, err
 	}
-	// Recipients credit
-	for _, p := range recips {
-		if _, err := tx.Exec(ctx, `insert into ledger_entries (tx_id, account_id, delta) values ($1,$2,$3)`,
-			txID, p.accID, amount); err != nil {
-			return 0, err
-		}
-	}
 
 	if err := tx.Commit(ctx); err != nil {
 		return 0, err
 	}
-	return len(recips), nil
-}
-
-func ensureHouseAccount(ctx context.Context, tx pgx.Tx) (accountID string, err error) {
-	// Check if house exists
-	var houseID string
-	err = tx.QueryRow(ctx, `select id from users where username=$1`, houseUsername).Scan(&houseID)
-	if err == pgx.ErrNoRows {
-		// Create house user with random password (not meant for login)
-		pw := randomPassword(24)
-		hash, err := auth.HashPassword(pw)
-		if err != nil {
-			return "", err
-		}
-
-		err = tx.QueryRow(ctx, `
-			insert into users (username, display_name, password_hash, role)
-			values ($1, $2, $3, 'admin')
-			returning id
-		`, houseUsername, "House", hash).Scan(&houseID)
-		if err != nil {
-			return "", err
-		}
-	} else if err != nil {
-		return "", err
-	}
-
-	// Get its default wallet account (trigger should have created it)
-	err = tx.QueryRow(ctx, `
-		select id from accounts where user_id = $1 and is_default
-	`, houseID).Scan(&accountID)
-	if err == pgx.ErrNoRows {
-		// Create explicitly if trigger didn’t (defensive)
-		err = tx.QueryRow(ctx, `
-			insert into accounts (user_id, name, is_default) values ($1, $2, true)
-			returning id
-		`, houseID, "wallet:"+houseUsername).Scan(&accountID)
-	}
-	return accountID, err
-}
-
-func randomPassword(n int) string {
-	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	if n <= 0 {
-		n = 24
-	}
-	var b = make([]byte, n)
-	for i := 0; i < n; i++ {
-		idxBig, _ := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
-		b[i] = alphabet[idxBig.Int64()]
-	}
-	return string(b)
+	return n, nil
 }
 
 func resolveDBURL(cfg *config.Config, override string) (string, error) {
@@ -497,6 +744,14 @@ func resolveDBURL(cfg *config.Config, override string) (string, error) {
 	return cfg.Database.AppURL()
 }
 
+func poolOptions(cfg *config.Config) db.Options {
+	return db.Options{
+		StatementTimeout: cfg.Database.StatementTimeout,
+		QueryExecMode:    cfg.Database.QueryExecMode,
+		PgBouncer:        cfg.Database.PgBouncer,
+	}
+}
+
 func reorderArgs(args []string) []string {
 	var flags []string
 	var positional []string
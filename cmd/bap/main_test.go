@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/dbtest"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testWager records a wager of amount by uid on the first option of betID,
+// moving the stake to the bet's escrow like the wager handler does.
+func testWager(t *testing.T, pool *pgxpool.Pool, uid, walletID, betID, escrowID string, amount int64) {
+	t.Helper()
+	ctx := context.Background()
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		var txID string
+		if err := tx.QueryRow(ctx, `insert into transactions (reason, bet_id) values ('BET', $1::uuid) returning id::text`, betID).Scan(&txID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			insert into ledger_entries (tx_id, account_id, delta) values ($1, $2::uuid, $3), ($1, $4::uuid, -$3)
+		`, txID, escrowID, amount, walletID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			insert into wagers (bet_id, user_id, option_id, amount, idempotency_key)
+			select $1::uuid, $2::uuid, o.id, $3, gen_random_uuid()::text
+			from bet_options o where o.bet_id = $1::uuid and o.position = 1
+		`, betID, uid, amount)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("wager: %v", err)
+	}
+}
+
+// testBet creates an open bet by creatorID and its escrow account.
+func testBet(t *testing.T, pool *pgxpool.Pool, creatorID string) (betID, escrowID string) {
+	t.Helper()
+	ctx := context.Background()
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, `insert into bets (creator_user_id, title) values ($1::uuid, 'Merge test') returning id::text`, creatorID).Scan(&betID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `insert into bet_options (bet_id, label, position) values ($1::uuid, 'Yes', 1), ($1::uuid, 'No', 2)`, betID); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			insert into accounts (bet_id, name, is_default) values ($1::uuid, $2, true) returning id::text
+		`, betID, accounts.EscrowName(betID)).Scan(&escrowID)
+	})
+	if err != nil {
+		t.Fatalf("bet: %v", err)
+	}
+	return betID, escrowID
+}
+
+func TestMergeUsersPreservesTotals(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	alice, aliceWallet := dbtest.User(t, pool, "alice", "")
+	bob, bobWallet := dbtest.User(t, pool, "bob", "")
+	_, carolWallet := dbtest.User(t, pool, "carol", "")
+	dbtest.Fund(t, pool, aliceWallet, 70)
+	dbtest.Fund(t, pool, bobWallet, 30)
+	dbtest.Fund(t, pool, carolWallet, 10)
+	house := dbtest.HouseWallet(t, pool)
+
+	betID, escrow := testBet(t, pool, alice)
+	testWager(t, pool, alice, aliceWallet, betID, escrow, 20)
+	testWager(t, pool, bob, bobWallet, betID, escrow, 5)
+
+	// totals is every balance that must survive the merge, with alice and
+	// bob counted together.
+	totals := func() map[string]int64 {
+		var users, stakes int64
+		if err := pool.QueryRow(ctx, `select coalesce(sum(balance), 0)::bigint from user_balances where username <> 'house'`).Scan(&users); err != nil {
+			t.Fatal(err)
+		}
+		if err := pool.QueryRow(ctx, `select coalesce(sum(amount), 0)::bigint from wagers where bet_id = $1::uuid`, betID).Scan(&stakes); err != nil {
+			t.Fatal(err)
+		}
+		return map[string]int64{
+			"users":       users,
+			"alice + bob": dbtest.Balance(t, pool, aliceWallet) + dbtest.Balance(t, pool, bobWallet),
+			"carol":       dbtest.Balance(t, pool, carolWallet),
+			"house":       dbtest.Balance(t, pool, house),
+			"escrow":      dbtest.Balance(t, pool, escrow),
+			"stakes":      stakes,
+		}
+	}
+	before := totals()
+
+	moved, err := mergeUsers(ctx, pool, "alice", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 50 {
+		t.Errorf("moved %d, want 50", moved)
+	}
+
+	for k, v := range totals() {
+		if v != before[k] {
+			t.Errorf("%s: %d after the merge, was %d", k, v, before[k])
+		}
+	}
+	if got := dbtest.Balance(t, pool, aliceWallet); got != 0 {
+		t.Errorf("alice's wallet holds %d after the merge", got)
+	}
+	var aliceWagers, bobStakes int64
+	var creator string
+	var disabled bool
+	if err := pool.QueryRow(ctx, `
+		select (select count(*) from wagers where user_id = $1::uuid),
+		       (select coalesce(sum(amount), 0)::bigint from wagers where user_id = $2::uuid),
+		       (select creator_user_id::text from bets where id = $3::uuid),
+		       (select disabled_at is not null from users where id = $1::uuid)
+	`, alice, bob, betID).Scan(&aliceWagers, &bobStakes, &creator, &disabled); err != nil {
+		t.Fatal(err)
+	}
+	if aliceWagers != 0 || bobStakes != 25 || creator != bob || !disabled {
+		t.Errorf("after the merge: alice has %d wagers, bob stakes %d, creator %s (bob %s), alice disabled %v", aliceWagers, bobStakes, creator, bob, disabled)
+	}
+}
+
+func TestMergeUsersRefuses(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	_, aliceWallet := dbtest.User(t, pool, "alice", "")
+	dbtest.User(t, pool, "bob", "")
+	dbtest.Fund(t, pool, aliceWallet, 10)
+	dbtest.HouseWallet(t, pool)
+
+	for _, c := range []struct{ from, into string }{
+		{"alice", "alice"},
+		{"alice", "ALICE"},
+		{"alice", accounts.HouseUsername},
+		{accounts.HouseUsername, "bob"},
+		{"alice", "nobody"},
+		{"nobody", "bob"},
+	} {
+		if _, err := mergeUsers(ctx, pool, c.from, c.into); err == nil {
+			t.Errorf("merge %s into %s succeeded", c.from, c.into)
+		}
+	}
+	if got := dbtest.Balance(t, pool, aliceWallet); got != 10 {
+		t.Errorf("alice's balance = %d after refused merges, want 10", got)
+	}
+
+	if _, err := mergeUsers(ctx, pool, "alice", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mergeUsers(ctx, pool, "alice", "bob"); err == nil {
+		t.Error("merging a disabled user again succeeded")
+	}
+}
+
+func TestGiftIdempotencyKey(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	_, aliceWallet := dbtest.User(t, pool, "alice", "")
+	_, bobWallet := dbtest.User(t, pool, "bob", "")
+
+	for run := 1; run <= 2; run++ {
+		n, err := giftToAllUsers(ctx, pool, 10, "weekly drop", "drop-w14")
+		if run == 1 && (err != nil || n != 2) {
+			t.Fatalf("first airdrop: %d users, %v", n, err)
+		}
+		if run == 2 && !errors.Is(err, accounts.ErrAlreadyApplied) {
+			t.Fatalf("second airdrop: err = %v, want ErrAlreadyApplied", err)
+		}
+		err = giftToSingleUser(ctx, pool, "alice", 5, "bonus", "bonus-alice")
+		if run == 1 && err != nil {
+			t.Fatalf("first gift: %v", err)
+		}
+		if run == 2 && !errors.Is(err, accounts.ErrAlreadyApplied) {
+			t.Fatalf("second gift: err = %v, want ErrAlreadyApplied", err)
+		}
+	}
+	if got := dbtest.Balance(t, pool, aliceWallet); got != 15 {
+		t.Errorf("alice's balance = %d, want 15", got)
+	}
+	if got := dbtest.Balance(t, pool, bobWallet); got != 10 {
+		t.Errorf("bob's balance = %d, want 10", got)
+	}
+
+	// Without a key, every run applies.
+	for range 2 {
+		if err := giftToSingleUser(ctx, pool, "bob", 1, "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := dbtest.Balance(t, pool, bobWallet); got != 12 {
+		t.Errorf("bob's balance = %d after two unkeyed gifts, want 12", got)
+	}
+}
+
+// BenchmarkGiftToAllUsers measures an airdrop to 1000 wallets, which credits
+// every recipient with a single insert.
+func BenchmarkGiftToAllUsers(b *testing.B) {
+	pool := dbtest.New(b)
+	ctx := context.Background()
+	dbtest.HouseWallet(b, pool)
+	const users = 1000
+	if _, err := pool.Exec(ctx, `
+		insert into users (username, display_name, password_hash)
+		select 'user' || i, 'user' || i, 'x' from generate_series(1, $1::int) i
+	`, users); err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; b.Loop(); i++ {
+		n, err := giftToAllUsers(ctx, pool, 1, "bench", "bench-"+strconv.Itoa(i))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if n != users {
+			b.Fatalf("gifted %d users, want %d", n, users)
+		}
+	}
+}
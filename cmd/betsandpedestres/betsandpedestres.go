@@ -16,13 +16,21 @@ import (
 
 	_ "time/tzdata"
 
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/announcements"
 	"betsandpedestres/internal/auth"
+	"betsandpedestres/internal/captcha"
+	"betsandpedestres/internal/coins"
 	"betsandpedestres/internal/config"
 	"betsandpedestres/internal/db"
 	"betsandpedestres/internal/dbinit"
 	apphttp "betsandpedestres/internal/http"
+	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/jobs"
 	"betsandpedestres/internal/logging"
+	"betsandpedestres/internal/settings"
 	"betsandpedestres/internal/telegram"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func main() {
@@ -39,24 +47,43 @@ func main() {
 
 	if err != nil {
 		slog.Warn("Could not get `config.yaml` file. Will run with default values")
-		slog.Warn("The JWT secret will be defined to a default value. This is a security risk in production.")
 	}
-
-	pgURL, err := cfg.Database.AppURL()
-	if err != nil {
-		panic(err)
+	// Anyone knowing the secret can forge sessions, so a guessable one is
+	// fatal unless the operator opts in (local development).
+	if weak := cfg.WeakJWTSecret(); weak != "" {
+		if os.Getenv("BAP_ALLOW_INSECURE_SECRET") != "1" {
+			slog.Error("security.jwt_secret", "err", weak,
+				"fix", "set a random secret of at least 32 bytes (e.g. openssl rand -hex 32), or BAP_ALLOW_INSECURE_SECRET=1 for development")
+			os.Exit(1)
+		}
+		slog.Warn("security.jwt_secret: INSECURE SECRET ALLOWED BY BAP_ALLOW_INSECURE_SECRET, never do this in production", "err", weak)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 	defer cancel()
 
-	if err := dbinit.EnsureDatabaseAndMigrate(ctx, pgURL, cfg.Database.Name, cfg.Database.User); err != nil {
+	migrateURL, err := cfg.Database.MigrationURL()
+	if err != nil {
+		panic(err)
+	}
+	if err := dbinit.EnsureDatabaseAndMigrate(ctx, migrateURL, cfg.Database.Name, cfg.Database.User); err != nil {
 		log.Fatalf("db init failed: %v", err)
 	}
+	// Hot read paths query user_balances_mv, which must be populated before serving.
+	// The refresh runs over its own pgx connection with prepared statements,
+	// so it takes the migration URL, which bypasses PgBouncer.
+	if err := dbinit.RefreshBalancesMatView(ctx, migrateURL); err != nil {
+		log.Fatalf("balances refresh failed: %v", err)
+	}
 
 	log.Println("database ensured and migrated")
 
 	auth.SetSecret(cfg.Security.JWTSecret)
+	coins.SetDecimals(cfg.Currency.Decimals)
+	if err := middleware.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		slog.Error("http.trusted_proxies", "err", err)
+		os.Exit(1)
+	}
 
 	appURL, err := cfg.Database.AppURL()
 	if err != nil {
@@ -65,16 +92,50 @@ func main() {
 	}
 	ctxpool, cancelpool := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancelpool()
-	pool, err := db.NewPool(ctxpool, appURL)
+	poolOpts := db.Options{
+		StatementTimeout: cfg.Database.StatementTimeout,
+		QueryExecMode:    cfg.Database.QueryExecMode,
+		PgBouncer:        cfg.Database.PgBouncer,
+
+		SlowQueryThreshold: cfg.Database.SlowQueryThreshold,
+	}
+	pool, err := db.NewPool(ctxpool, appURL, poolOpts)
 	if err != nil {
 		slog.Error("db.pool", "err", err)
 		os.Exit(1)
 	}
 	defer pool.Close()
 
+	readPool := pool
+	if cfg.Database.ReplicaURL != "" {
+		readPool, err = db.NewPool(ctxpool, cfg.Database.ReplicaURL, poolOpts)
+		if err != nil {
+			slog.Error("db.replica_pool", "err", err)
+			os.Exit(1)
+		}
+		defer readPool.Close()
+	}
+
+	if err := accounts.CheckDecimals(ctxpool, pool, cfg.Currency.Decimals); err != nil {
+		slog.Error("currency.decimals", "err", err)
+		os.Exit(1)
+	}
+	settings.Use(pool)
+	announcements.Use(pool)
+	ensureBootstrapAdmins(ctxpool, pool, cfg.BootstrapAdmins)
+	if cfg.Session.Backend == auth.BackendDB {
+		auth.UseDBSessions(pool)
+	}
 	apphttp.SetVersion(readVersionFile("VERSION"))
+	apphttp.SetBranding(cfg.Site.Name, cfg.Site.Tagline, cfg.Site.FooterHTML)
+	captchaProvider, err := captcha.New(cfg.Captcha.Provider, cfg.Captcha.SiteKey, cfg.Captcha.Secret)
+	if err != nil {
+		slog.Error("captcha", "err", err)
+		os.Exit(1)
+	}
+	apphttp.SetCaptcha(captchaProvider)
 
-	mux, err := apphttp.NewMux(pool, cfg)
+	mux, err := apphttp.NewMux(pool, readPool, cfg)
 	if err != nil {
 		slog.Error("Coulnd't parse templates", "err", err)
 		os.Exit(1)
@@ -82,18 +143,48 @@ func main() {
 	rootCtx, rootCancel := context.WithCancel(context.Background())
 	defer rootCancel()
 
-	if cfg.Telegram.BotToken != "" {
+	scheduler := jobs.NewScheduler()
+	scheduler.Add(jobs.PruneExpiredRecoveries(pool))
+	scheduler.Add(jobs.PruneWagerNonces(pool))
+	if cfg.Bets.ArchiveAfter > 0 {
+		scheduler.Add(jobs.ArchiveClosedBets(pool, cfg.Bets.ArchiveAfter))
+	}
+	if cfg.Session.Backend == auth.BackendDB {
+		scheduler.Add(jobs.PruneExpiredSessions(pool))
+	}
+	scheduler.Add(jobs.AuditEscrowAccounts(pool, cfg.Maintenance.ArchiveEscrow))
+	scheduler.Add(jobs.RefreshBalances(migrateURL, cfg.Maintenance.BalancesRefreshInterval))
+	notifier := apphttp.NewNotifier(pool, cfg)
+	scheduler.Add(jobs.NotifyDeadlineReached(pool, notifier, cfg.BaseURL))
+	scheduler.Add(jobs.AwardAchievements(pool, notifier))
+	if cfg.Maintenance.HouseAlertThreshold < 0 {
+		scheduler.Add(jobs.WatchHouseBalance(pool, notifier, cfg.Maintenance.HouseAlertThreshold*coins.Unit()))
+	}
+	if cfg.Maintenance.StuckBetAlertAfter > 0 {
+		scheduler.Add(jobs.WatchStuckBets(pool, notifier, cfg.BaseURL, cfg.Maintenance.StuckBetAlertAfter))
+	}
+	go scheduler.Run(rootCtx)
+
+	switch {
+	case cfg.Telegram.BotToken == "":
+	case cfg.Telegram.Mode == "webhook":
+		hookURL := strings.TrimRight(cfg.BaseURL, "/") + "/telegram/webhook/" + cfg.Telegram.WebhookSecret
+		if err := telegram.SetWebhook(rootCtx, cfg.Telegram.BotToken, hookURL, cfg.Telegram.WebhookSecret); err != nil {
+			slog.Error("telegram.set_webhook", "err", err)
+		}
+	default:
 		if poller := telegram.NewPoller(pool, cfg.Telegram.BotToken); poller != nil {
 			go poller.Run(rootCtx)
 		}
 	}
 	srv := &http.Server{
-		Addr:         cfg.HTTP.Address,
-		Handler:      apphttp.WithStandardMiddleware(mux),
-		BaseContext:  func(l net.Listener) context.Context { return rootCtx },
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:              cfg.HTTP.Address,
+		Handler:           apphttp.WithStandardMiddleware(mux, cfg),
+		BaseContext:       func(l net.Listener) context.Context { return rootCtx },
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
 	}
 
 	serverErr := make(chan error, 1)
@@ -131,6 +222,8 @@ func main() {
 	}
 
 	slog.Info("http.stopped")
+	apphttp.FlushNotifications()
+	slog.Info("notifications.flushed")
 	st := pool.Stat()
 	slog.Info("pgxpool.stats",
 		"total", st.TotalConns(),
@@ -145,6 +238,28 @@ func main() {
 	slog.Info("pool.closed")
 }
 
+// ensureBootstrapAdmins creates the configured admins that don't exist yet.
+// Failing to create one is fatal: the operator asked for it explicitly.
+func ensureBootstrapAdmins(ctx context.Context, pool *pgxpool.Pool, admins []config.BootstrapAdmin) {
+	for _, a := range admins {
+		created, err := accounts.EnsureAdmin(ctx, pool, a.Username, a.DisplayName, os.Getenv(a.PasswordEnv))
+		if errors.Is(err, accounts.ErrNoAdminPassword) {
+			slog.Error("bootstrap_admin.no_password: set the password environment variable to create this admin",
+				"username", a.Username, "password_env", a.PasswordEnv)
+			os.Exit(1)
+		}
+		if err != nil {
+			slog.Error("bootstrap_admin", "username", a.Username, "err", err)
+			os.Exit(1)
+		}
+		if !created {
+			slog.Info("bootstrap_admin.exists", "username", a.Username)
+			continue
+		}
+		slog.Info("bootstrap_admin.created", "username", a.Username)
+	}
+}
+
 func readVersionFile(path string) string {
 	tryPaths := []string{path}
 	if exe, err := os.Executable(); err == nil {
@@ -0,0 +1,48 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+
+	"betsandpedestres/internal/auth"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNoAdminPassword is returned by EnsureAdmin when the admin has to be
+// created but no password was given.
+var ErrNoAdminPassword = errors.New("no password for new admin")
+
+// EnsureAdmin creates an admin named username with the given password unless
+// a user by that name already exists, in which case nothing is changed and
+// the password is not needed. created reports whether a new user was inserted.
+func EnsureAdmin(ctx context.Context, pool *pgxpool.Pool, username, displayName, password string) (created bool, err error) {
+	var exists bool
+	if err := pool.QueryRow(ctx, `select exists (select 1 from users where username = $1)`, username).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+	if password == "" {
+		return false, ErrNoAdminPassword
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return false, err
+	}
+	if displayName == "" {
+		displayName = username
+	}
+	var id string
+	err = pool.QueryRow(ctx, `
+		insert into users (username, display_name, password_hash, role)
+		values ($1, $2, $3, 'admin')
+		on conflict (username) do nothing
+		returning id
+	`, username, displayName, hash).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,163 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DecimalsSetting is the app_settings key recording how many decimals the
+// ledger's minor units were written with.
+const DecimalsSetting = "currency.decimals"
+
+// ErrDecimalsMismatch is returned by CheckDecimals when the configured
+// currency.decimals differs from the one the ledger was written with.
+var ErrDecimalsMismatch = errors.New("currency.decimals does not match the ledger")
+
+// CheckDecimals compares configured with the decimals recorded for this
+// database, recording them on first use. A database with ledger entries but
+// no record predates the setting and was written in whole PiedPièces (0).
+//
+// Changing the decimals reinterprets every stored integer, so a mismatch is
+// an error rather than a warning; RescaleDecimals converts the data.
+func CheckDecimals(ctx context.Context, pool *pgxpool.Pool, configured int) error {
+	stored, ok, err := StoredDecimals(ctx, pool)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		var used bool
+		if err := pool.QueryRow(ctx, `select exists (select 1 from ledger_entries)`).Scan(&used); err != nil {
+			return err
+		}
+		record := configured
+		if used {
+			record = 0
+		}
+		if _, err := pool.Exec(ctx, `
+			insert into app_settings (key, value) values ($1, $2)
+			on conflict (key) do nothing
+		`, DecimalsSetting, strconv.Itoa(record)); err != nil {
+			return err
+		}
+		// Another instance may have recorded it first.
+		if stored, _, err = StoredDecimals(ctx, pool); err != nil {
+			return err
+		}
+	}
+	if stored != configured {
+		return fmt.Errorf("%w: configured %d, ledger written with %d; set currency.decimals back to %d or run `bap currency rescale %d`",
+			ErrDecimalsMismatch, configured, stored, stored, configured)
+	}
+	return nil
+}
+
+// querier is satisfied by both *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// StoredDecimals returns the decimals recorded for this database; ok is
+// false when none were recorded yet.
+func StoredDecimals(ctx context.Context, q querier) (decimals int, ok bool, err error) {
+	var raw string
+	err = q.QueryRow(ctx, `select value from app_settings where key = $1`, DecimalsSetting).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	decimals, err = strconv.Atoi(raw)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: bad stored value %q", DecimalsSetting, raw)
+	}
+	return decimals, true, nil
+}
+
+// RescaleDecimals converts the database from the recorded decimals to to,
+// which must be larger: every amount is multiplied by 10^(to-from).
+//
+// The ledger is append-only, so balances are scaled by one ADJUST
+// transaction crediting every account (wallets and escrows) with
+// balance*(factor-1), balanced by the house, whose own balance ends up
+// scaled the same way. Wager amounts, bet wager limits and rakes are updated
+// in place. Past ledger lines keep their original integers and therefore
+// read factor times smaller afterwards. Run it with the app stopped.
+func RescaleDecimals(ctx context.Context, tx pgx.Tx, to int) (from int, err error) {
+	from, ok, err := StoredDecimals(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		from = 0
+	}
+	if to <= from {
+		return from, fmt.Errorf("can only add decimals: the ledger already has %d", from)
+	}
+	factor := int64(1)
+	for i := from; i < to; i++ {
+		factor *= 10
+	}
+
+	houseAccID, err := EnsureHouseAccount(ctx, tx)
+	if err != nil {
+		return from, err
+	}
+	if err := rescaleBalances(ctx, tx, houseAccID, factor, fmt.Sprintf("currency rescale from %d to %d decimals", from, to)); err != nil {
+		return from, err
+	}
+	for _, stmt := range []string{
+		`update wagers set amount = amount * $1`,
+		`update bets set rake = rake * $1, min_wager = min_wager * $1, max_wager = max_wager * $1`,
+	} {
+		if _, err := tx.Exec(ctx, stmt, factor); err != nil {
+			return from, err
+		}
+	}
+	_, err = tx.Exec(ctx, `
+		insert into app_settings (key, value) values ($1, $2)
+		on conflict (key) do update set value = excluded.value, updated_at = now()
+	`, DecimalsSetting, strconv.Itoa(to))
+	return from, err
+}
+
+// rescaleBalances multiplies every account balance by factor with a single
+// ADJUST transaction; it records nothing when all balances are zero.
+func rescaleBalances(ctx context.Context, tx pgx.Tx, houseAccID string, factor int64, note string) error {
+	var nonZero bool
+	if err := tx.QueryRow(ctx, `
+		select exists (
+			select 1 from ledger_entries where account_id <> $1::uuid
+			group by account_id having sum(delta) <> 0
+		)
+	`, houseAccID).Scan(&nonZero); err != nil || !nonZero {
+		return err
+	}
+	txID, err := InsertTransaction(ctx, tx, "ADJUST", note, "")
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		insert into ledger_entries (tx_id, account_id, delta)
+		select $1, account_id, sum(delta) * ($2 - 1)
+		from ledger_entries
+		where account_id <> $3::uuid
+		group by account_id
+		having sum(delta) <> 0
+	`, txID, factor, houseAccID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		insert into ledger_entries (tx_id, account_id, delta)
+		select $1, $2::uuid, -sum(delta)
+		from ledger_entries
+		where tx_id = $1
+		having sum(delta) <> 0
+	`, txID, houseAccID)
+	return err
+}
@@ -0,0 +1,84 @@
+package accounts_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/dbtest"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestCheckDecimals(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fresh database records the configured value", func(t *testing.T) {
+		pool := dbtest.New(t)
+		if err := accounts.CheckDecimals(ctx, pool, 2); err != nil {
+			t.Fatal(err)
+		}
+		if err := accounts.CheckDecimals(ctx, pool, 2); err != nil {
+			t.Fatalf("second start: %v", err)
+		}
+		if err := accounts.CheckDecimals(ctx, pool, 0); !errors.Is(err, accounts.ErrDecimalsMismatch) {
+			t.Fatalf("changed decimals: err = %v, want ErrDecimalsMismatch", err)
+		}
+	})
+
+	t.Run("existing ledger was written with 0", func(t *testing.T) {
+		pool := dbtest.New(t)
+		_, wallet := dbtest.User(t, pool, "alice", "")
+		dbtest.Fund(t, pool, wallet, 10)
+		if err := accounts.CheckDecimals(ctx, pool, 2); !errors.Is(err, accounts.ErrDecimalsMismatch) {
+			t.Fatalf("err = %v, want ErrDecimalsMismatch", err)
+		}
+		if err := accounts.CheckDecimals(ctx, pool, 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestRescaleDecimals(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	_, alice := dbtest.User(t, pool, "alice", "")
+	_, bob := dbtest.User(t, pool, "bob", "")
+	dbtest.Fund(t, pool, alice, 12)
+	dbtest.Fund(t, pool, bob, 3)
+	house := dbtest.HouseWallet(t, pool)
+	if err := accounts.CheckDecimals(ctx, pool, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	rescale := func(to int) (int, error) {
+		var from int
+		err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+			var err error
+			from, err = accounts.RescaleDecimals(ctx, tx, to)
+			return err
+		})
+		return from, err
+	}
+	from, err := rescale(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 0 {
+		t.Errorf("from = %d, want 0", from)
+	}
+	for _, c := range []struct {
+		account string
+		want    int64
+	}{{alice, 1200}, {bob, 300}, {house, -1500}} {
+		if got := dbtest.Balance(t, pool, c.account); got != c.want {
+			t.Errorf("balance of %s = %d, want %d", c.account, got, c.want)
+		}
+	}
+	if err := accounts.CheckDecimals(ctx, pool, 2); err != nil {
+		t.Fatalf("after rescale: %v", err)
+	}
+	if _, err := rescale(1); err == nil {
+		t.Error("rescaling to fewer decimals succeeded")
+	}
+}
@@ -0,0 +1,50 @@
+package accounts
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Display name length bounds, in runes, after cleaning.
+const (
+	MinDisplayNameLen = 2
+	MaxDisplayNameLen = 64
+)
+
+var (
+	ErrDisplayNameShort = errors.New("display name too short")
+	ErrDisplayNameLong  = errors.New("display name too long")
+)
+
+// CleanDisplayName drops control, bidi-override and zero-width characters
+// (which let a name render like someone else's) and collapses whitespace,
+// then checks the length. Over-long names are rejected, not truncated.
+func CleanDisplayName(name string) (string, error) {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || isInvisibleFormat(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+	switch n := utf8.RuneCountInString(name); {
+	case n < MinDisplayNameLen:
+		return name, ErrDisplayNameShort
+	case n > MaxDisplayNameLen:
+		return name, ErrDisplayNameLong
+	}
+	return name, nil
+}
+
+func isInvisibleFormat(r rune) bool {
+	switch {
+	case r >= 0x200B && r <= 0x200F, // zero-width space/joiners, LRM, RLM
+		r >= 0x202A && r <= 0x202E, // bidi embeddings and overrides
+		r >= 0x2066 && r <= 0x2069, // bidi isolates
+		r == 0x061C, r == 0xFEFF:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package accounts_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"betsandpedestres/internal/accounts"
+)
+
+func TestCleanDisplayName(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr error
+	}{
+		{"plain", "Alice", "Alice", nil},
+		{"whitespace collapsed", "  Alice \t\n Liddell ", "Alice Liddell", nil},
+		{"no-break spaces", "Alice\u00a0\u00a0Liddell", "Alice Liddell", nil},
+		{"accents kept", "Zoë Ça", "Zoë Ça", nil},
+
+		// Impersonation: invisible characters must not make a name that
+		// renders like someone else's compare differently.
+		{"zero-width space", "Ali\u200bce", "Alice", nil},
+		{"zero-width joiners", "\u200dAl\u200cice", "Alice", nil},
+		{"byte order mark", "\ufeffAlice", "Alice", nil},
+		{"directional marks", "\u200eAlice\u200f", "Alice", nil},
+		{"right-to-left override", "\u202eecilA", "ecilA", nil},
+		{"bidi embedding", "\u202aAlice\u202c", "Alice", nil},
+		{"bidi isolates", "\u2066Alice\u2069\u2067", "Alice", nil},
+		{"arabic letter mark", "Alice\u061c", "Alice", nil},
+		{"control characters", "Ali\x00ce\x1b", "Alice", nil},
+		{"invisible only", "\u200b\u202e\u200b", "", accounts.ErrDisplayNameShort},
+
+		// Length is counted in runes after cleaning; long names are refused,
+		// never truncated.
+		{"one rune", "A", "A", accounts.ErrDisplayNameShort},
+		{"padded one rune", " \u200bA\u200b ", "A", accounts.ErrDisplayNameShort},
+		{"two runes", "Al", "Al", nil},
+		{"max runes", strings.Repeat("é", accounts.MaxDisplayNameLen), strings.Repeat("é", accounts.MaxDisplayNameLen), nil},
+		{"max runes once cleaned", strings.Repeat("a\u200b", accounts.MaxDisplayNameLen), strings.Repeat("a", accounts.MaxDisplayNameLen), nil},
+		{"one rune over", strings.Repeat("é", accounts.MaxDisplayNameLen+1), strings.Repeat("é", accounts.MaxDisplayNameLen+1), accounts.ErrDisplayNameLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := accounts.CleanDisplayName(tt.in)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("CleanDisplayName(%q) = %q, %v, want %q, %v", tt.in, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
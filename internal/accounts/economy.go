@@ -0,0 +1,27 @@
+package accounts
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Economy summarizes the money supply.
+type Economy struct {
+	HouseBalance int64 `json:"house_balance"`
+	// Circulating is the sum of positive user wallet balances (house excluded).
+	Circulating int64 `json:"circulating"`
+}
+
+// LoadEconomy computes the house balance and circulating supply from
+// user_balances.
+func LoadEconomy(ctx context.Context, db *pgxpool.Pool) (Economy, error) {
+	var e Economy
+	err := db.QueryRow(ctx, `
+		select
+		  coalesce(sum(balance) filter (where username = $1), 0)::bigint,
+		  coalesce(sum(balance) filter (where username <> $1 and balance > 0), 0)::bigint
+		from user_balances
+	`, HouseUsername).Scan(&e.HouseBalance, &e.Circulating)
+	return e, err
+}
@@ -0,0 +1,113 @@
+package accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+
+	"betsandpedestres/internal/auth"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrAlreadyApplied is returned when a transaction with the same
+// idempotency key was recorded before. The surrounding tx must be rolled
+// back.
+var ErrAlreadyApplied = errors.New("transaction already applied")
+
+// EnsureHouseAccount returns the house's default wallet, creating the house
+// user (with an unusable random password) on first use.
+func EnsureHouseAccount(ctx context.Context, tx pgx.Tx) (accountID string, err error) {
+	var houseID string
+	err = tx.QueryRow(ctx, `select id from users where username=$1`, HouseUsername).Scan(&houseID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		hash, err := auth.HashPassword(randomPassword(24))
+		if err != nil {
+			return "", err
+		}
+		err = tx.QueryRow(ctx, `
+			insert into users (username, display_name, password_hash, role)
+			values ($1, $2, $3, 'admin')
+			returning id
+		`, HouseUsername, "House", hash).Scan(&houseID)
+		if err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+
+	// The create_default_wallet trigger should have made it; be defensive.
+	err = tx.QueryRow(ctx, `
+		select id from accounts where user_id = $1 and is_default
+	`, houseID).Scan(&accountID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = tx.QueryRow(ctx, `
+			insert into accounts (user_id, name, is_default) values ($1, $2, true)
+			returning id
+		`, houseID, WalletName(HouseUsername)).Scan(&accountID)
+	}
+	return accountID, err
+}
+
+// GiftFromHouse moves amount from the house wallet to accountID as a single
+// GIFT transaction, so it shows up in the recipient's history.
+func GiftFromHouse(ctx context.Context, tx pgx.Tx, accountID string, amount int64, note string) error {
+	return moveFromHouse(ctx, tx, "GIFT", accountID, amount, note, "")
+}
+
+// GiftFromHouseOnce is GiftFromHouse guarded by idempotencyKey: a second
+// call with the same key returns ErrAlreadyApplied instead of paying again.
+func GiftFromHouseOnce(ctx context.Context, tx pgx.Tx, accountID string, amount int64, note, idempotencyKey string) error {
+	return moveFromHouse(ctx, tx, "GIFT", accountID, amount, note, idempotencyKey)
+}
+
+// AdjustFromHouse records an admin correction of delta (negative to debit)
+// between the house wallet and accountID as an ADJUST transaction. Callers
+// are responsible for checking the user's balance before debiting.
+func AdjustFromHouse(ctx context.Context, tx pgx.Tx, accountID string, delta int64, note string) error {
+	return moveFromHouse(ctx, tx, "ADJUST", accountID, delta, note, "")
+}
+
+// InsertTransaction records a transaction header with no bet and returns
+// its id. A non-empty idempotencyKey that was used before yields
+// ErrAlreadyApplied.
+func InsertTransaction(ctx context.Context, tx pgx.Tx, reason, note, idempotencyKey string) (string, error) {
+	var txID string
+	err := tx.QueryRow(ctx, `
+		insert into transactions (reason, bet_id, note, idempotency_key)
+		values ($1::tx_reason, null, $2, nullif($3,''))
+		returning id
+	`, reason, note, idempotencyKey).Scan(&txID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "uq_tx_idempotency" {
+		return "", ErrAlreadyApplied
+	}
+	return txID, err
+}
+
+func moveFromHouse(ctx context.Context, tx pgx.Tx, reason, accountID string, amount int64, note, idempotencyKey string) error {
+	houseAccID, err := EnsureHouseAccount(ctx, tx)
+	if err != nil {
+		return err
+	}
+	txID, err := InsertTransaction(ctx, tx, reason, note, idempotencyKey)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		insert into ledger_entries (tx_id, account_id, delta) values ($1,$2,$3), ($1,$4,$5)
+	`, txID, houseAccID, -amount, accountID, amount)
+	return err
+}
+
+func randomPassword(n int) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		idx, _ := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		b[i] = alphabet[idx.Int64()]
+	}
+	return string(b)
+}
@@ -0,0 +1,21 @@
+// Package accounts holds the naming scheme of ledger accounts.
+//
+// Account names are informational: lookups go through accounts.user_id /
+// accounts.bet_id. They still have to stay consistent with the
+// `create_default_wallet` trigger, which names new wallets "wallet:<username>".
+package accounts
+
+const (
+	KindWallet = "wallet"
+	KindEscrow = "escrow"
+)
+
+// WalletName is the name of a user's default wallet account.
+func WalletName(username string) string { return KindWallet + ":" + username }
+
+// EscrowName is the name of the escrow account holding a bet's wagers.
+func EscrowName(betID string) string { return KindEscrow + ":" + betID }
+
+// HouseUsername owns the treasury wallet funding gifts and absorbing
+// unclaimed escrows. It is the only wallet allowed to go negative.
+const HouseUsername = "house"
@@ -0,0 +1,32 @@
+package accounts_test
+
+import (
+	"context"
+	"testing"
+
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/dbtest"
+)
+
+func TestAccountNames(t *testing.T) {
+	if got := accounts.WalletName("alice"); got != "wallet:alice" {
+		t.Errorf("WalletName = %q", got)
+	}
+	if got := accounts.EscrowName("0b0e3c1e-0000-4000-8000-000000000001"); got != "escrow:0b0e3c1e-0000-4000-8000-000000000001" {
+		t.Errorf("EscrowName = %q", got)
+	}
+}
+
+// TestWalletNameMatchesTrigger checks that WalletName agrees with the
+// create_default_wallet trigger naming new users' wallets.
+func TestWalletNameMatchesTrigger(t *testing.T) {
+	pool := dbtest.New(t)
+	_, wallet := dbtest.User(t, pool, "alice", "")
+	var name string
+	if err := pool.QueryRow(context.Background(), `select name from accounts where id = $1::uuid`, wallet).Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if want := accounts.WalletName("alice"); name != want {
+		t.Errorf("trigger named the wallet %q, WalletName says %q", name, want)
+	}
+}
@@ -0,0 +1,156 @@
+// Package achievements awards badges computed from wager, bet and ledger
+// history. Rules are registered with Register; Award stores newly earned
+// ones in user_achievements and tells the users.
+package achievements
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Querier is the read access a rule needs.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// Rule is one achievement. Holders returns the ids of every user who
+// currently meets it; users keep an achievement once awarded.
+type Rule interface {
+	Key() string // stable identifier stored in user_achievements
+	Emoji() string
+	Title() string
+	Description() string
+	Holders(ctx context.Context, q Querier) ([]string, error)
+}
+
+var (
+	mu    sync.RWMutex
+	rules []Rule
+)
+
+// Register adds a rule. Keys must be unique; a duplicate replaces the
+// earlier rule.
+func Register(r Rule) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, existing := range rules {
+		if existing.Key() == r.Key() {
+			rules[i] = r
+			return
+		}
+	}
+	rules = append(rules, r)
+}
+
+// Rules returns the registered rules in registration order.
+func Rules() []Rule {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Rule(nil), rules...)
+}
+
+func lookup(key string) Rule {
+	for _, r := range Rules() {
+		if r.Key() == key {
+			return r
+		}
+	}
+	return nil
+}
+
+// Unlocked is an achievement a user holds, for display.
+type Unlocked struct {
+	Key         string
+	Emoji       string
+	Title       string
+	Description string
+	AwardedAt   time.Time
+}
+
+// Award evaluates every rule and stores the achievements users do not hold
+// yet, notifying each user. The very first run (empty table) backfills
+// history silently so existing users are not flooded with messages.
+func Award(ctx context.Context, db *pgxpool.Pool, notifier notify.Notifier) (int, error) {
+	var backfill bool
+	if err := db.QueryRow(ctx, `select not exists (select 1 from user_achievements)`).Scan(&backfill); err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, r := range Rules() {
+		holders, err := r.Holders(ctx, db)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", r.Key(), err)
+		}
+		if len(holders) == 0 {
+			continue
+		}
+		rows, err := db.Query(ctx, `
+			insert into user_achievements (user_id, key)
+			select u.id, $2
+			from users u
+			where u.id = any($1::uuid[]) and u.username <> $3 and u.disabled_at is null
+			on conflict do nothing
+			returning user_id::text
+		`, holders, r.Key(), accounts.HouseUsername)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", r.Key(), err)
+		}
+		var awarded []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return total, err
+			}
+			awarded = append(awarded, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return total, err
+		}
+		total += len(awarded)
+		if backfill || notifier == nil {
+			continue
+		}
+		msg := fmt.Sprintf("🏆 Achievement unlocked: %s %s\n%s", r.Emoji(), r.Title(), r.Description())
+		for _, id := range awarded {
+			notifier.NotifyUser(ctx, id, msg)
+		}
+	}
+	return total, nil
+}
+
+// ForUser lists the achievements userID holds, newest first. Keys of rules
+// no longer registered are shown by key.
+func ForUser(ctx context.Context, q Querier, userID string) ([]Unlocked, error) {
+	rows, err := q.Query(ctx, `
+		select key, awarded_at from user_achievements
+		where user_id = $1::uuid
+		order by awarded_at desc, key
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Unlocked
+	for rows.Next() {
+		var u Unlocked
+		if err := rows.Scan(&u.Key, &u.AwardedAt); err != nil {
+			return nil, err
+		}
+		u.Title = u.Key
+		if r := lookup(u.Key); r != nil {
+			u.Emoji, u.Title, u.Description = r.Emoji(), r.Title(), r.Description()
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
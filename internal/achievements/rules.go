@@ -0,0 +1,109 @@
+package achievements
+
+import (
+	"context"
+
+	"betsandpedestres/internal/coins"
+)
+
+// sqlRule is a Rule whose holders are the user ids returned by a query.
+// Args are evaluated at each run so they can depend on startup settings
+// such as the currency unit.
+type sqlRule struct {
+	key, emoji, title, desc string
+	query                   string
+	args                    func() []any
+}
+
+func (r sqlRule) Key() string         { return r.key }
+func (r sqlRule) Emoji() string       { return r.emoji }
+func (r sqlRule) Title() string       { return r.title }
+func (r sqlRule) Description() string { return r.desc }
+
+func (r sqlRule) Holders(ctx context.Context, q Querier) ([]string, error) {
+	var args []any
+	if r.args != nil {
+		args = r.args()
+	}
+	rows, err := q.Query(ctx, r.query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Thresholds in whole PiedPièces.
+const (
+	highRollerStake = 100
+	jackpotPayout   = 500
+	winsForSharp    = 10
+	streakDays      = 7
+)
+
+func init() {
+	Register(sqlRule{
+		key: "first_wager", emoji: "🎲", title: "First wager",
+		desc:  "Placed your first wager.",
+		query: `select distinct user_id::text from wagers`,
+	})
+	Register(sqlRule{
+		key: "first_bet", emoji: "📝", title: "Bookmaker",
+		desc:  "Created your first bet.",
+		query: `select distinct creator_user_id::text from bets`,
+	})
+	Register(sqlRule{
+		key: "ten_wins", emoji: "🎯", title: "Sharp",
+		desc: "Backed the winning outcome on 10 bets.",
+		query: `
+			select w.user_id::text
+			from wagers w
+			join bets b on b.id = w.bet_id
+			where b.status <> 'open' and b.resolution_option_id = w.option_id
+			group by w.user_id
+			having count(distinct w.bet_id) >= $1`,
+		args: func() []any { return []any{winsForSharp} },
+	})
+	Register(sqlRule{
+		key: "high_roller", emoji: "💰", title: "High roller",
+		desc:  "Staked 100 PiedPièces or more in a single wager.",
+		query: `select distinct user_id::text from wagers where amount >= $1`,
+		args:  func() []any { return []any{highRollerStake * coins.Unit()} },
+	})
+	Register(sqlRule{
+		key: "jackpot", emoji: "🏆", title: "Jackpot",
+		desc: "Won a payout of 500 PiedPièces or more on a single bet.",
+		query: `
+			select distinct a.user_id::text
+			from transactions t
+			join ledger_entries e on e.tx_id = t.id
+			join accounts a on a.id = e.account_id
+			where t.reason = 'BET' and t.note = 'payout'
+			  and a.user_id is not null and e.delta >= $1`,
+		args: func() []any { return []any{jackpotPayout * coins.Unit()} },
+	})
+	Register(sqlRule{
+		key: "streak_7", emoji: "🔥", title: "On fire",
+		desc: "Wagered on 7 days in a row.",
+		query: `
+			with d as (
+			  select distinct user_id, (created_at at time zone 'utc')::date as day
+			  from wagers
+			), g as (
+			  select user_id, day - (row_number() over (partition by user_id order by day))::int as grp
+			  from d
+			)
+			select distinct user_id::text from g
+			group by user_id, grp
+			having count(*) >= $1`,
+		args: func() []any { return []any{streakDays} },
+	})
+}
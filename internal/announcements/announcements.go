@@ -0,0 +1,181 @@
+// Package announcements stores the site-wide banners admins post ("ledger
+// maintenance tonight"). Like settings, the active ones are cached
+// process-wide and reloaded at most every cacheTTL.
+package announcements
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const cacheTTL = 10 * time.Second
+
+// MaxMessageLen matches the check constraint on announcements.message.
+const MaxMessageLen = 500
+
+// Severities, from least to most alarming.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+var (
+	ErrInvalidMessage  = errors.New("message must be 1 to 500 characters")
+	ErrInvalidSeverity = errors.New("severity must be info, warning or critical")
+)
+
+// Announcement is one banner.
+type Announcement struct {
+	ID        string
+	Message   string
+	Severity  string
+	Active    bool
+	ExpiresAt *time.Time
+	CreatedAt time.Time
+}
+
+// Live reports whether the banner should be shown at now.
+func (a Announcement) Live(now time.Time) bool {
+	return a.Active && (a.ExpiresAt == nil || a.ExpiresAt.After(now))
+}
+
+var (
+	mu     sync.Mutex
+	pool   *pgxpool.Pool
+	live   []Announcement
+	loaded time.Time
+)
+
+// Use sets the pool announcements are read from and written to. Until it
+// is called, Active returns nothing.
+func Use(db *pgxpool.Pool) {
+	mu.Lock()
+	pool = db
+	loaded = time.Time{}
+	mu.Unlock()
+}
+
+// Invalidate drops the cache; the next Active reloads from the database.
+func Invalidate() {
+	mu.Lock()
+	loaded = time.Time{}
+	mu.Unlock()
+}
+
+// Active returns the banners to show right now, newest first.
+func Active(ctx context.Context) []Announcement {
+	mu.Lock()
+	defer mu.Unlock()
+	if pool == nil {
+		return nil
+	}
+	if time.Since(loaded) >= cacheTTL {
+		next, err := query(ctx, pool, `where active and (expires_at is null or expires_at > now())`)
+		if err != nil {
+			// Keep the previous banners rather than flapping.
+			slog.Warn("announcements.reload", "err", err)
+		} else {
+			live, loaded = next, time.Now()
+		}
+	}
+	now := time.Now()
+	out := make([]Announcement, 0, len(live))
+	for _, a := range live {
+		if a.Live(now) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// All lists every announcement, newest first, for the admin page.
+func All(ctx context.Context, db *pgxpool.Pool) ([]Announcement, error) {
+	return query(ctx, db, ``)
+}
+
+func query(ctx context.Context, db *pgxpool.Pool, where string) ([]Announcement, error) {
+	rows, err := db.Query(ctx, `
+		select id::text, message, severity, active, expires_at, created_at
+		from announcements `+where+`
+		order by created_at desc
+		limit 100
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Announcement
+	for rows.Next() {
+		var a Announcement
+		if err := rows.Scan(&a.ID, &a.Message, &a.Severity, &a.Active, &a.ExpiresAt, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// Validate normalizes and checks a message and severity.
+func Validate(message, severity string) (string, string, error) {
+	message = strings.TrimSpace(message)
+	if message == "" || len([]rune(message)) > MaxMessageLen {
+		return "", "", ErrInvalidMessage
+	}
+	switch severity {
+	case "":
+		severity = SeverityInfo
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return "", "", ErrInvalidSeverity
+	}
+	return message, severity, nil
+}
+
+// Create posts a new active announcement.
+func Create(ctx context.Context, db *pgxpool.Pool, adminID, message, severity string, expiresAt *time.Time) error {
+	message, severity, err := Validate(message, severity)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, `
+		insert into announcements (message, severity, expires_at, created_by)
+		values ($1, $2, $3, nullif($4,'')::uuid)
+	`, message, severity, expiresAt, adminID)
+	if err == nil {
+		Invalidate()
+	}
+	return err
+}
+
+// Update rewrites an announcement.
+func Update(ctx context.Context, db *pgxpool.Pool, id, message, severity string, active bool, expiresAt *time.Time) error {
+	message, severity, err := Validate(message, severity)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, `
+		update announcements
+		set message = $2, severity = $3, active = $4, expires_at = $5, updated_at = now()
+		where id = $1::uuid
+	`, id, message, severity, active, expiresAt)
+	if err == nil {
+		Invalidate()
+	}
+	return err
+}
+
+// Delete removes an announcement.
+func Delete(ctx context.Context, db *pgxpool.Pool, id string) error {
+	_, err := db.Exec(ctx, `delete from announcements where id = $1::uuid`, id)
+	if err == nil {
+		Invalidate()
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+// Package apperr carries an HTTP status and a user-safe message with domain
+// errors, so handlers return errors instead of switching on sentinels to pick
+// a status code.
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Error is a domain error. Message is shown to the user; Err, if any, is the
+// underlying cause and is only logged. Code is a stable identifier for API
+// clients; WriteJSON derives one from Status when it is empty.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// New returns an error with no underlying cause; suitable for sentinels
+// compared with errors.Is.
+func New(status int, message string) *Error {
+	return &Error{Status: status, Message: message}
+}
+
+// Coded returns an error with a machine-readable code for JSON endpoints.
+func Coded(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Wrap attaches a status and user-safe message to err.
+func Wrap(status int, message string, err error) *Error {
+	return &Error{Status: status, Message: message, Err: err}
+}
+
+func BadRequest(message string) *Error { return New(http.StatusBadRequest, message) }
+func Forbidden(message string) *Error  { return New(http.StatusForbidden, message) }
+func Conflict(message string) *Error   { return New(http.StatusConflict, message) }
+
+// Internal wraps an unexpected error (typically from the database).
+func Internal(message string, err error) *Error {
+	return Wrap(http.StatusInternalServerError, message, err)
+}
+
+// Write sends err as a plain-text error response. Errors that are not an
+// *Error become a 500 with a generic message. Server errors are logged.
+func Write(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal("internal error", err)
+	}
+	if e.Status >= 500 {
+		slog.Error("http.error", "status", e.Status, "msg", e.Message, "err", e.Err)
+	}
+	http.Error(w, e.Message, e.Status)
+}
+
+// WriteJSON is Write for JSON endpoints: the body is
+// {"error": message, "code": code}.
+func WriteJSON(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal("internal error", err)
+	}
+	if e.Status >= 500 {
+		slog.Error("http.error", "status", e.Status, "msg", e.Message, "err", e.Err)
+	}
+	code := e.Code
+	if code == "" {
+		code = strings.ToLower(strings.ReplaceAll(http.StatusText(e.Status), " ", "_"))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}{e.Message, code})
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// HistoryDB is the database access the password history needs; satisfied by
+// *pgxpool.Pool and pgx.Tx.
+type HistoryDB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// RecentlyUsed reports whether pw matches one of the last n passwords of
+// userID, the current one included. n <= 0 disables the check.
+func RecentlyUsed(ctx context.Context, db HistoryDB, userID, pw string, n int) (bool, error) {
+	if n <= 0 {
+		return false, nil
+	}
+	rows, err := db.Query(ctx, `
+		select password_hash from users where id = $1::uuid
+		union all
+		(select password_hash from password_history
+		 where user_id = $1::uuid
+		 order by replaced_at desc, id desc
+		 limit $2)
+	`, userID, n-1)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	var hashes []string
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			return false, err
+		}
+		hashes = append(hashes, h)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	for _, h := range hashes {
+		if CheckPassword(pw, h) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetPassword stores newHash as the password of userID. With n > 0 the
+// replaced hash is remembered and history older than the last n passwords
+// is pruned.
+func SetPassword(ctx context.Context, db HistoryDB, userID, newHash string, n int) error {
+	if n > 0 {
+		if _, err := db.Exec(ctx, `
+			insert into password_history (user_id, password_hash)
+			select id, password_hash from users where id = $1::uuid
+		`, userID); err != nil {
+			return err
+		}
+		if _, err := db.Exec(ctx, `
+			delete from password_history
+			where user_id = $1::uuid and id not in (
+			  select id from password_history where user_id = $1::uuid
+			  order by replaced_at desc, id desc
+			  limit $2)
+		`, userID, n-1); err != nil {
+			return err
+		}
+	}
+	_, err := db.Exec(ctx, `update users set password_hash = $2 where id = $1::uuid`, userID, newHash)
+	return err
+}
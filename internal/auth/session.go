@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Session backends selectable with session.backend.
+const (
+	BackendJWT = "jwt" // signed, stateless tokens; cannot be revoked
+	BackendDB  = "db"  // random tokens looked up in the sessions table
+)
+
+// SessionTTL is how long a login stays valid with either backend.
+const SessionTTL = 72 * time.Hour
+
+var errInvalidSession = errors.New("invalid session")
+
+// sessionDB is set by UseDBSessions; nil means the JWT backend.
+var sessionDB *pgxpool.Pool
+
+// UseDBSessions switches session cookies to opaque tokens stored in db.
+// Call once at startup, before serving.
+func UseDBSessions(db *pgxpool.Pool) {
+	sessionDB = db
+}
+
+// NewSession starts a session for userID and returns the cookie value.
+func NewSession(ctx context.Context, userID string) (string, error) {
+	if sessionDB == nil {
+		return IssueToken(userID)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	if _, err := sessionDB.Exec(ctx, `
+		insert into sessions (token_hash, user_id, expires_at) values ($1, $2::uuid, $3)
+	`, hashSessionToken(token), userID, time.Now().Add(SessionTTL)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// SessionUser returns the user a session cookie belongs to. DB sessions of
+// disabled users are rejected.
+func SessionUser(ctx context.Context, token string) (string, error) {
+	if sessionDB == nil {
+		return ParseToken(token)
+	}
+	var uid string
+	err := sessionDB.QueryRow(ctx, `
+		select s.user_id::text
+		from sessions s
+		join users u on u.id = s.user_id
+		where s.token_hash = $1 and s.expires_at > now() and u.disabled_at is null
+	`, hashSessionToken(token)).Scan(&uid)
+	if err != nil {
+		return "", errInvalidSession
+	}
+	return uid, nil
+}
+
+// EndSession revokes a session. JWT sessions cannot be revoked server-side;
+// for them this is a no-op and only clearing the cookie logs out.
+func EndSession(ctx context.Context, token string) error {
+	if sessionDB == nil || token == "" {
+		return nil
+	}
+	_, err := sessionDB.Exec(ctx, `delete from sessions where token_hash = $1`, hashSessionToken(token))
+	return err
+}
+
+// EndUserSessions revokes every DB session of userID, except the one with
+// cookie value keep (may be empty). No-op with the JWT backend.
+func EndUserSessions(ctx context.Context, userID, keep string) error {
+	if sessionDB == nil {
+		return nil
+	}
+	_, err := sessionDB.Exec(ctx, `
+		delete from sessions where user_id = $1::uuid and token_hash <> $2
+	`, userID, hashSessionToken(keep))
+	return err
+}
+
+func hashSessionToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+)
+
+// TOTP follows RFC 6238 with the parameters every authenticator app
+// defaults to: HMAC-SHA1, 6 digits, 30 second steps.
+const (
+	totpDigits = otp.DigitsSix
+	totpPeriod = 30
+	// totpSkew is how many steps either side of now are accepted, to absorb
+	// clock drift and slow typing.
+	totpSkew = 1
+	// totpSecretBytes is the RFC 4226 recommended key size.
+	totpSecretBytes = 20
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret returns a random base32 shared secret.
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// ValidTOTPSecret reports whether s decodes to a full-size secret, as
+// NewTOTPSecret makes them.
+func ValidTOTPSecret(s string) bool {
+	key, err := totpEncoding.DecodeString(s)
+	return err == nil && len(key) == totpSecretBytes
+}
+
+// TOTPURI is the otpauth:// URI authenticator apps import (usually as a QR
+// code); the secret can also be typed in by hand. It is built here rather
+// than with totp.Generate, whose encoder leaves "&" in the issuer unescaped.
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("digits", totpDigits.String())
+	q.Set("period", strconv.Itoa(totpPeriod))
+	// Some apps show "+" literally; spell spaces the path way.
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(q.Encode(), "+", "%20")
+}
+
+// CheckTOTP reports whether code is valid for secret within totpSkew steps
+// of now, and the time step it matched. Callers store the step and refuse
+// steps at or below it, so a code cannot be replayed.
+func CheckTOTP(secret, code string, now time.Time) (step int64, ok bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits.Length() {
+		return 0, false
+	}
+	opts := hotp.ValidateOpts{Digits: totpDigits, Algorithm: otp.AlgorithmSHA1}
+	current := now.Unix() / totpPeriod
+	for s := current - totpSkew; s <= current+totpSkew; s++ {
+		if ok, err := hotp.ValidateCustom(code, uint64(s), secret, opts); err == nil && ok {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// NewRecoveryCodes returns n single-use codes like "k3f9-x2mq-7b" to show
+// the user once; only their HashRecoveryCode digests are stored.
+func NewRecoveryCodes(n int) ([]string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	codes := make([]string, n)
+	buf := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		for j, b := range buf {
+			if j == 4 || j == 8 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(alphabet[int(b)%len(alphabet)])
+		}
+		codes[i] = sb.String()
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode digests a recovery code, ignoring case, spaces and
+// dashes. The codes carry ~50 random bits, so a fast hash is enough.
+func HashRecoveryCode(code string) string {
+	norm := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(norm))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+// rfcSecret is the RFC 6238 appendix B SHA-1 key, "12345678901234567890".
+var rfcSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCheckTOTPVectors(t *testing.T) {
+	// RFC 6238 appendix B, truncated to 6 digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		now := time.Unix(tt.unix, 0)
+		step, ok := CheckTOTP(rfcSecret, tt.code, now)
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("CheckTOTP(%s at %d) = %d, %v, want step %d", tt.code, tt.unix, step, ok, tt.unix/totpPeriod)
+		}
+	}
+}
+
+func TestCheckTOTPWindow(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_015, 0) // mid-step
+	current := now.Unix() / totpPeriod
+	tests := []struct {
+		name   string
+		offset int64 // steps from now
+		ok     bool
+	}{
+		{"current", 0, true},
+		{"previous step", -1, true},
+		{"next step", 1, true},
+		{"two steps old", -2, false},
+		{"two steps ahead", 2, false},
+	}
+	for _, tt := range tests {
+		code, err := totp.GenerateCode(secret, now.Add(time.Duration(tt.offset*totpPeriod)*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := CheckTOTP(secret, code, now)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+		}
+		if ok && step != current+tt.offset {
+			t.Errorf("%s: step = %d, want %d", tt.name, step, current+tt.offset)
+		}
+	}
+}
+
+func TestCheckTOTPMalformed(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "abcdef"} {
+		if _, ok := CheckTOTP(rfcSecret, code, now); ok {
+			t.Errorf("code %q accepted", code)
+		}
+	}
+	if _, ok := CheckTOTP(" 287082 ", "287082", now); ok {
+		t.Error("malformed secret accepted")
+	}
+	if _, ok := CheckTOTP(rfcSecret, " 287082 ", now); !ok {
+		t.Error("surrounding spaces rejected")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ValidTOTPSecret(secret) {
+		t.Fatalf("NewTOTPSecret made an invalid secret %q", secret)
+	}
+	raw := TOTPURI("Bets & Pedestres", "alice", secret)
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Scheme != "otpauth" || u.Host != "totp" || !strings.HasSuffix(u.Path, ":alice") {
+		t.Errorf("URI = %s", raw)
+	}
+	if q.Get("secret") != secret || q.Get("issuer") != "Bets & Pedestres" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("URI query = %v", q)
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	codes, err := NewRecoveryCodes(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range codes {
+		want := HashRecoveryCode(c)
+		for _, variant := range []string{strings.ToUpper(c), strings.ReplaceAll(c, "-", ""), " " + strings.ReplaceAll(c, "-", " ") + " "} {
+			if got := HashRecoveryCode(variant); got != want {
+				t.Errorf("%q and %q hash differently", c, variant)
+			}
+		}
+	}
+	if codes[0] == codes[1] {
+		t.Errorf("duplicate recovery codes %v", codes)
+	}
+}
@@ -0,0 +1,109 @@
+// Package coins converts PiedPièce amounts between the integer minor units
+// stored in the ledger and their human-readable decimal form.
+//
+// The ledger always stores int64 minor units. With Decimals = 2, an amount of
+// 1250 is displayed as "12.50". Changing the decimals of an existing instance
+// would reinterpret every stored integer (100 PiedPièces would become 1.00),
+// so the decimals the ledger was written with are recorded in the database
+// and startup refuses a mismatch (accounts.CheckDecimals). `bap currency
+// rescale` converts the data to more decimals (accounts.RescaleDecimals).
+package coins
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const maxDecimals = 4
+
+var decimals = 0
+
+var errInvalidAmount = errors.New("invalid amount")
+
+// SetDecimals configures the number of fractional digits. Call once at startup.
+func SetDecimals(n int) {
+	if n < 0 {
+		n = 0
+	}
+	if n > maxDecimals {
+		n = maxDecimals
+	}
+	decimals = n
+}
+
+func Decimals() int { return decimals }
+
+// Unit is the number of minor units in one whole PiedPièce.
+func Unit() int64 {
+	u := int64(1)
+	for i := 0; i < decimals; i++ {
+		u *= 10
+	}
+	return u
+}
+
+// Step is the smallest representable amount, formatted for HTML number inputs.
+func Step() string {
+	return Format(1)
+}
+
+// Format renders minor units as a decimal string, e.g. 1250 -> "12.50".
+func Format(v int64) string {
+	neg := v < 0
+	u := uint64(v)
+	if neg {
+		u = uint64(-v)
+	}
+	s := strconv.FormatUint(u, 10)
+	if decimals > 0 {
+		if len(s) <= decimals {
+			s = strings.Repeat("0", decimals-len(s)+1) + s
+		}
+		s = s[:len(s)-decimals] + "." + s[len(s)-decimals:]
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// Parse reads a user-entered amount ("12", "12.5", "12,50") into minor units.
+// Amounts with more fractional digits than configured are rejected.
+func Parse(s string) (int64, error) {
+	s = strings.TrimSpace(strings.ReplaceAll(s, ",", "."))
+	if s == "" {
+		return 0, errInvalidAmount
+	}
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if hasFrac && (frac == "" || len(frac) > decimals) {
+		return 0, errInvalidAmount
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if strings.HasPrefix(whole, "+") || strings.HasPrefix(frac, "-") || strings.HasPrefix(frac, "+") {
+		return 0, errInvalidAmount
+	}
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, errInvalidAmount
+	}
+	frac += strings.Repeat("0", decimals-len(frac))
+	var f int64
+	if frac != "" {
+		if f, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return 0, errInvalidAmount
+		}
+	}
+	// w*unit ± f must fit: f < unit, but w*unit alone can be in range while
+	// adding f overflows.
+	unit := Unit()
+	if limit := (1<<63 - 1 - f) / unit; w > limit || w < -limit {
+		return 0, errInvalidAmount
+	}
+	if strings.HasPrefix(whole, "-") {
+		return w*unit - f, nil
+	}
+	return w*unit + f, nil
+}
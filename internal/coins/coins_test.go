@@ -0,0 +1,112 @@
+package coins
+
+import "testing"
+
+func withDecimals(t *testing.T, n int) {
+	t.Helper()
+	old := decimals
+	SetDecimals(n)
+	t.Cleanup(func() { SetDecimals(old) })
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		decimals int
+		in       string
+		want     int64
+		wantErr  bool
+	}{
+		{0, "12", 12, false},
+		{0, " 12 ", 12, false},
+		{0, "-3", -3, false},
+		{0, "0", 0, false},
+		{0, "12.5", 0, true},
+		{0, "12.", 0, true},
+		{0, "", 0, true},
+		{0, "abc", 0, true},
+		{0, "+5", 0, true},
+		{0, "9223372036854775807", 1<<63 - 1, false},
+		{0, "9223372036854775808", 0, true},
+		{0, "-9223372036854775807", -(1<<63 - 1), false},
+		{0, "-9223372036854775808", 0, true},
+
+		{2, "12", 1200, false},
+		{2, "12.5", 1250, false},
+		{2, "12,50", 1250, false},
+		{2, ".05", 5, false},
+		{2, "-0.5", -50, false},
+		{2, "-1.25", -125, false},
+		{2, "1.255", 0, true},
+		{2, "1.-5", 0, true},
+		{2, "1.+5", 0, true},
+		{2, "-", 0, true},
+		// max = 92233720368547758.07: the whole part fits but adding the
+		// fraction would overflow.
+		{2, "92233720368547758.07", 1<<63 - 1, false},
+		{2, "92233720368547758.08", 0, true},
+		{2, "92233720368547758.99", 0, true},
+		{2, "92233720368547759", 0, true},
+		{2, "-92233720368547758.07", -(1<<63 - 1), false},
+		{2, "-92233720368547758.08", 0, true},
+
+		{4, "1", 10000, false},
+		{4, "0.0001", 1, false},
+		{4, "3.14159", 0, true},
+		{4, "922337203685477.5807", 1<<63 - 1, false},
+		{4, "922337203685477.5808", 0, true},
+	}
+	for _, tt := range tests {
+		withDecimals(t, tt.decimals)
+		got, err := Parse(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("decimals %d: Parse(%q) = %d, want error", tt.decimals, tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("decimals %d: Parse(%q) = %d, %v, want %d", tt.decimals, tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		decimals int
+		in       int64
+		want     string
+	}{
+		{0, 0, "0"},
+		{0, 12, "12"},
+		{0, -12, "-12"},
+		{2, 0, "0.00"},
+		{2, 5, "0.05"},
+		{2, 50, "0.50"},
+		{2, 1250, "12.50"},
+		{2, -5, "-0.05"},
+		{2, -1250, "-12.50"},
+		{2, 1<<63 - 1, "92233720368547758.07"},
+		{2, -1 << 63, "-92233720368547758.08"},
+		{4, 1, "0.0001"},
+		{4, 31416, "3.1416"},
+	}
+	for _, tt := range tests {
+		withDecimals(t, tt.decimals)
+		if got := Format(tt.in); got != tt.want {
+			t.Errorf("decimals %d: Format(%d) = %q, want %q", tt.decimals, tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestFormatParseRoundTrip checks that Parse reads back what Format writes.
+func TestFormatParseRoundTrip(t *testing.T) {
+	for d := 0; d <= maxDecimals; d++ {
+		withDecimals(t, d)
+		for _, v := range []int64{0, 1, -1, 7, 100, -12345, 1<<63 - 1, -(1<<63 - 1)} {
+			got, err := Parse(Format(v))
+			if err != nil || got != v {
+				t.Errorf("decimals %d: Parse(Format(%d)) = %d, %v", d, v, got, err)
+			}
+		}
+	}
+}
@@ -2,25 +2,136 @@ package config
 
 import (
 	"errors"
+	"net/mail"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/accounts"
 )
 
 type Moderation struct {
 	Quorum int `yaml:"quorum"`
+	// QuorumFraction, when > 0, replaces the fixed Quorum with this share
+	// of the active moderators and admins (rounded up, at least 1), counted
+	// each time a vote is cast.
+	QuorumFraction float64 `yaml:"quorum_fraction"`
+	// PublicVotes shows every user who voted for which outcome during
+	// resolution. When false only moderators and admins see the breakdown.
+	PublicVotes bool `yaml:"public_votes"`
+	// RakeBps is the house's cut of a resolved bet's escrow, in basis
+	// points (100 = 1%), taken before winners are paid. 0 disables it.
+	RakeBps int `yaml:"rake_bps"`
+	// SuggestionThreshold is how many bettors must suggest the same outcome
+	// before the bet's resolvers are notified. Negative disables it.
+	SuggestionThreshold int `yaml:"suggestion_threshold"`
+	// MinWager and MaxWager bound a single wager (in PiedPièces) on bets
+	// that don't set their own limits. 0 means no bound.
+	MinWager int64 `yaml:"min_wager"`
+	MaxWager int64 `yaml:"max_wager"`
+}
+
+// Values of Bets.CreatorIncentive.
+const (
+	CreatorIncentiveNone   = "none"
+	CreatorIncentiveReward = "reward"
+	CreatorIncentiveFee    = "fee"
+)
+
+// DefaultJWTSecret is the placeholder secret used when none is configured.
+// The server refuses to start with it unless explicitly allowed.
+const DefaultJWTSecret = "change-me"
+
+// MinJWTSecretLen is the shortest JWT secret accepted without the
+// insecure-secret escape hatch.
+const MinJWTSecretLen = 32
+
+// WeakJWTSecret explains why the configured JWT secret is unsafe to run
+// with, or returns "" when it looks fine.
+func (c *Config) WeakJWTSecret() string {
+	switch {
+	case c.Security.JWTSecret == DefaultJWTSecret:
+		return "security.jwt_secret is the default value"
+	case len(c.Security.JWTSecret) < MinJWTSecretLen:
+		return "security.jwt_secret is shorter than " + strconv.Itoa(MinJWTSecretLen) + " bytes"
+	}
+	return ""
+}
+
+// BootstrapAdmin is an admin account created at startup when missing.
+type BootstrapAdmin struct {
+	Username    string `yaml:"username"`
+	DisplayName string `yaml:"display_name"` // defaults to Username
+	// PasswordEnv names the environment variable holding the initial
+	// password. Startup fails if the admin has to be created and the
+	// variable is unset or empty.
+	PasswordEnv string `yaml:"password_env"`
 }
 
 type TelegramConfig struct {
 	BotToken    string `yaml:"bot_token"`
 	GroupChatID string `yaml:"group_chat_id"`
+	// BotUsername (without the @) is linked from profiles, with a deep link
+	// that registers the user's chat in one tap.
+	BotUsername string `yaml:"bot_username"`
+
+	// WagerBatchWindow groups wagers placed on the same bet within this
+	// window into a single group message. Negative sends one per wager.
+	WagerBatchWindow time.Duration `yaml:"wager_batch_window"`
+
+	// Mode is how the bot receives commands: "polling" long-polls
+	// getUpdates, "webhook" has Telegram push them to
+	// base_url/telegram/webhook/<webhook_secret>.
+	Mode          string `yaml:"mode"`
+	WebhookSecret string `yaml:"webhook_secret"`
+}
+
+// EmailConfig is the SMTP relay for email notifications, sent alongside
+// Telegram to users who saved an address on their profile. An empty
+// SMTPHost disables them.
+type EmailConfig struct {
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+	From         string `yaml:"from"`
+	// GroupAddress receives what Telegram posts to the group chat.
+	GroupAddress string `yaml:"group_address"`
+}
+
+// HTTPConfig tunes the HTTP server. Streaming endpoints (exports) may need a
+// longer write_timeout than the default, since it bounds the whole response.
+type HTTPConfig struct {
+	Address           string        `yaml:"address"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+
+	// MaxInFlight caps concurrently served requests; extra ones get a 503.
+	// Negative disables the cap.
+	MaxInFlight int `yaml:"max_in_flight"`
+
+	// TrustedProxies lists the CIDRs (or bare IPs) of reverse proxies whose
+	// X-Forwarded-For / X-Real-IP headers are believed.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// MetricsEnabled serves Prometheus metrics on GET /metrics. Leave it off
+	// unless the endpoint is firewalled from the public.
+	MetricsEnabled bool `yaml:"metrics_enabled"`
+}
+
+// RateLimit allows Limit requests per Window for a given key.
+type RateLimit struct {
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
 }
 
 type Config struct {
 	BaseURL string `yaml:"base_url"`
 
-	HTTP struct {
-		Address string `yaml:"address"`
-	} `yaml:"http"`
+	HTTP HTTPConfig `yaml:"http"`
 
 	Database DatabaseConfig `yaml:"database"`
 
@@ -33,8 +144,132 @@ type Config struct {
 		JWTSecret string `yaml:"jwt_secret"`
 	} `yaml:"security"`
 
+	Captcha struct {
+		// Provider is "hcaptcha" or "turnstile"; empty disables the check on
+		// registration and recovery requests.
+		Provider string `yaml:"provider"`
+		SiteKey  string `yaml:"site_key"`
+		Secret   string `yaml:"secret"`
+	} `yaml:"captcha"`
+
+	Session struct {
+		// Backend stores logins as signed JWT cookies ("jwt", stateless) or as
+		// random tokens in the sessions table ("db"), which can be revoked
+		// instantly at the cost of a lookup per request.
+		Backend string `yaml:"backend"`
+		// APICSRF hands browsers a readable csrf_token cookie next to the
+		// HttpOnly session and requires it back in X-CSRF-Token on /api/v1
+		// writes (double-submit), so a same-site SPA can call the JSON API.
+		APICSRF bool `yaml:"api_csrf"`
+	} `yaml:"session"`
+
+	RateLimits struct {
+		Wager RateLimit `yaml:"wager"` // keyed per user and per client IP
+	} `yaml:"rate_limits"`
+
+	Maintenance struct {
+		// Mode forces maintenance mode on: every non-admin request gets a 503.
+		// Admins can also toggle it at runtime (runtime setting "maintenance").
+		Mode bool `yaml:"mode"`
+		// ArchiveEscrow flags zero-balance escrow accounts of settled bets as archived.
+		ArchiveEscrow bool `yaml:"archive_escrow"`
+		// HouseAlertThreshold notifies admins once the house balance drops
+		// below it (in PiedPièces, must be <= 0). 0 disables the alert.
+		HouseAlertThreshold int64 `yaml:"house_alert_threshold"`
+		// StuckBetAlertAfter pings admins about bets whose moderators
+		// disagreed this long ago and that still await an override.
+		// Negative disables the alert.
+		StuckBetAlertAfter time.Duration `yaml:"stuck_bet_alert_after"`
+		// BalancesRefreshInterval is how often user_balances_mv is refreshed.
+		// Display-only reads (header, hall of fame) use it and may lag by this
+		// much; anything that moves money reads the live user_balances view.
+		BalancesRefreshInterval time.Duration `yaml:"balances_refresh_interval"`
+	} `yaml:"maintenance"`
+
+	Accounts struct {
+		// ReservedUsernames cannot be registered by users, whether through the
+		// signup form or `bap user create`. Matching is case-insensitive. The
+		// house account's username is always reserved and need not be listed.
+		ReservedUsernames []string `yaml:"reserved_usernames"`
+		// WelcomeBonus is gifted by the house (in PiedPièces) the first time
+		// an admin approves an unverified account. 0 disables it.
+		WelcomeBonus int64 `yaml:"welcome_bonus"`
+		// DailyStipend is gifted by the house (in PiedPièces) on a user's
+		// first home page visit of each UTC day. 0 disables it.
+		DailyStipend int64 `yaml:"daily_stipend"`
+		// PasswordHistory rejects a new password matching any of the user's
+		// last N passwords, the current one included. 0 disables it; at most
+		// 20, as each remembered hash costs a bcrypt comparison.
+		PasswordHistory int `yaml:"password_history"`
+		// MinAccountAge is how long after signup an account may create bets
+		// and wager. Moderators and admins are exempt. 0 disables it.
+		MinAccountAge time.Duration `yaml:"min_account_age"`
+		// UniqueDisplayNames rejects a display name change matching another
+		// user's display name (case-insensitively), so nobody can pose as
+		// someone else in bettor lists.
+		UniqueDisplayNames bool `yaml:"unique_display_names"`
+	} `yaml:"accounts"`
+
+	Currency struct {
+		// Decimals is the number of fractional digits of a PiedPièce (0-4).
+		// The ledger stores integer minor units, so this must be chosen before
+		// the first transaction: changing it later rescales every balance.
+		Decimals int `yaml:"decimals"`
+	} `yaml:"currency"`
+
+	Bets struct {
+		// MaxDeadlineHorizon is how far in the future a bet deadline may be set.
+		MaxDeadlineHorizon time.Duration `yaml:"max_deadline_horizon"`
+		// ArchiveAfter archives bets closed for longer than this: they leave
+		// the default feed and leaderboards (?archived=1 lists them). 0 disables it.
+		ArchiveAfter time.Duration `yaml:"archive_after"`
+		// TitleCaseOptions stores outcome labels in title case ("yes" -> "Yes").
+		TitleCaseOptions bool `yaml:"title_case_options"`
+		// MaxComments caps the comments (replies included) on one bet and
+		// MaxRepliesPerComment the direct replies to one comment. Moderators
+		// and admins are exempt. 0 means no limit.
+		MaxComments          int `yaml:"max_comments"`
+		MaxRepliesPerComment int `yaml:"max_replies_per_comment"`
+		// WagerCooldown keeps a new bet closed to wagers for this long after
+		// it is created, so everyone gets to see it first. 0 disables it.
+		WagerCooldown time.Duration `yaml:"wager_cooldown"`
+		// CreatorIncentive is "none", "reward" or "fee". A reward of
+		// CreatorIncentiveAmount PiedPièces is paid by the house to a bet's
+		// creator when it resolves with at least CreatorRewardMinBettors
+		// other bettors, capped at the rake the house took from that bet; a
+		// fee of that amount is charged to the creator, and paid to the
+		// house, when the bet is created.
+		CreatorIncentive        string `yaml:"creator_incentive"`
+		CreatorIncentiveAmount  int64  `yaml:"creator_incentive_amount"`
+		CreatorRewardMinBettors int    `yaml:"creator_reward_min_bettors"`
+	} `yaml:"bets"`
+
+	Site struct {
+		// PublicBrowsing lets logged-out visitors read the bet feed and bet
+		// pages. Wagering, commenting and reacting still require an account.
+		PublicBrowsing bool `yaml:"public_browsing"`
+		// Indexable invites search engines to crawl the feed and bet pages
+		// (robots.txt + sitemap.xml). Otherwise every response is noindex.
+		// Requires public_browsing and base_url.
+		Indexable bool `yaml:"indexable"`
+
+		// Branding shown in the header, page titles and welcome page.
+		Name    string `yaml:"name"`
+		Tagline string `yaml:"tagline"`
+		// FooterHTML is rendered verbatim at the bottom of every page.
+		FooterHTML string `yaml:"footer_html"`
+		// AvatarPalette lists the #rrggbb background colors of generated
+		// avatars; each username is hashed onto one of them.
+		AvatarPalette []string `yaml:"avatar_palette"`
+	} `yaml:"site"`
+
 	Moderation Moderation     `yaml:"moderation"`
 	Telegram   TelegramConfig `yaml:"telegram"`
+	Email      EmailConfig    `yaml:"email"`
+
+	// BootstrapAdmins are created with the admin role at startup if no user
+	// has their username yet; existing users are left untouched.
+	BootstrapAdmins []BootstrapAdmin `yaml:"bootstrap_admins"`
 }
 
 type DatabaseConfig struct {
@@ -45,12 +280,50 @@ type DatabaseConfig struct {
 	Password string `yaml:"password"`
 	Name     string `yaml:"name"`
 	SSLMode  string `yaml:"sslmode"` // e.g. "disable" | "require"
+
+	// StatementTimeout is enforced server-side on every pooled connection.
+	StatementTimeout time.Duration `yaml:"statement_timeout"`
+
+	// SlowQueryThreshold logs queries running at least this long (SQL only,
+	// arguments are never logged). Negative disables it.
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold"`
+
+	// QueryExecMode is pgx's default_query_exec_mode: cache_statement
+	// (default), cache_describe, describe_exec, exec or simple_protocol.
+	QueryExecMode string `yaml:"query_exec_mode"`
+
+	// PgBouncer adapts the pool to a PgBouncer in transaction pooling mode:
+	// no server-side prepared statements and no session-level SETs.
+	// Migrations hold an advisory lock across statements and may CREATE
+	// DATABASE, and the balances refresh opens its own pgx connection in
+	// cache_statement mode, so both run over DirectURL, which must bypass
+	// the pooler.
+	PgBouncer bool   `yaml:"pgbouncer"`
+	DirectURL string `yaml:"direct_url"`
+
+	// ReplicaURL optionally points at a read replica serving the read-only,
+	// high-traffic pages (home, hall of fame, transactions). Those pages may
+	// then lag the primary by the replication delay.
+	ReplicaURL string `yaml:"replica_url"`
 }
 
 func (c *Config) Defaults() {
 	if c.HTTP.Address == "" {
 		c.HTTP.Address = ":8080"
 	}
+	if c.HTTP.ReadTimeout == 0 {
+		c.HTTP.ReadTimeout = 10 * time.Second
+	}
+	if c.HTTP.ReadHeaderTimeout == 0 {
+		// Short on purpose: bounds how long a client may trickle headers (Slowloris).
+		c.HTTP.ReadHeaderTimeout = 5 * time.Second
+	}
+	if c.HTTP.WriteTimeout == 0 {
+		c.HTTP.WriteTimeout = 15 * time.Second
+	}
+	if c.HTTP.IdleTimeout == 0 {
+		c.HTTP.IdleTimeout = 60 * time.Second
+	}
 	if c.Logging.Level == "" {
 		c.Logging.Level = "info"
 	}
@@ -75,12 +348,127 @@ func (c *Config) Defaults() {
 	if c.Database.SSLMode == "" {
 		c.Database.SSLMode = "disable"
 	}
+	if c.Database.SlowQueryThreshold == 0 {
+		c.Database.SlowQueryThreshold = 500 * time.Millisecond
+	}
+	if c.Database.StatementTimeout == 0 {
+		c.Database.StatementTimeout = 30 * time.Second
+	}
 	if c.Security.JWTSecret == "" {
-		c.Security.JWTSecret = "change-me"
+		c.Security.JWTSecret = DefaultJWTSecret
+	}
+	if c.Session.Backend == "" {
+		c.Session.Backend = "jwt"
+	}
+	if c.HTTP.MaxInFlight == 0 {
+		c.HTTP.MaxInFlight = 100
+	}
+	if c.RateLimits.Wager.Limit == 0 {
+		c.RateLimits.Wager.Limit = 20
+	}
+	if c.RateLimits.Wager.Window == 0 {
+		c.RateLimits.Wager.Window = time.Minute
+	}
+	if c.Bets.CreatorIncentive == "" {
+		c.Bets.CreatorIncentive = CreatorIncentiveNone
+	}
+	if c.Bets.CreatorRewardMinBettors == 0 {
+		c.Bets.CreatorRewardMinBettors = 2
+	}
+	if c.Maintenance.StuckBetAlertAfter == 0 {
+		c.Maintenance.StuckBetAlertAfter = 48 * time.Hour
+	}
+	if c.Maintenance.BalancesRefreshInterval <= 0 {
+		c.Maintenance.BalancesRefreshInterval = 30 * time.Second
+	}
+	if c.Bets.MaxDeadlineHorizon == 0 {
+		c.Bets.MaxDeadlineHorizon = 365 * 24 * time.Hour
 	}
 	if c.Moderation.Quorum == 0 {
 		c.Moderation.Quorum = 2
 	}
+	if c.Moderation.SuggestionThreshold == 0 {
+		c.Moderation.SuggestionThreshold = 3
+	}
+	if c.Email.SMTPPort == 0 {
+		c.Email.SMTPPort = 587
+	}
+	c.Telegram.BotUsername = strings.TrimPrefix(strings.TrimSpace(c.Telegram.BotUsername), "@")
+	if c.Telegram.BotUsername == "" {
+		c.Telegram.BotUsername = "betsandpedestres_bot"
+	}
+	if c.Telegram.Mode == "" {
+		c.Telegram.Mode = "polling"
+	}
+	if c.Telegram.WagerBatchWindow == 0 {
+		c.Telegram.WagerBatchWindow = 30 * time.Second
+	}
+	if c.Site.Name == "" {
+		c.Site.Name = "Bets & Pedestres"
+	}
+	if c.Site.Tagline == "" {
+		c.Site.Tagline = c.Site.Name + " lets you create friendly prediction markets with transparent escrows and community-driven resolutions."
+	}
+	if len(c.Site.AvatarPalette) == 0 {
+		c.Site.AvatarPalette = []string{"#7c3aed", "#2563eb", "#0891b2", "#059669", "#65a30d", "#d97706", "#dc2626", "#db2777"}
+	}
+	if c.Accounts.ReservedUsernames == nil {
+		c.Accounts.ReservedUsernames = []string{"admin", "system", "api"}
+	}
+}
+
+// validWebhookSecret checks the charset Telegram allows for secret_token,
+// with a floor on length since it is the webhook's only credential.
+func validWebhookSecret(s string) bool {
+	if len(s) < 16 || len(s) > 256 {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// validBotUsername follows Telegram's rules: 5-32 letters, digits or _.
+func validBotUsername(s string) bool {
+	if len(s) < 5 || len(s) > 32 {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func isHexColor(s string) bool {
+	if len(s) != 7 || s[0] != '#' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// IsReservedUsername reports whether name is the house account's or in the
+// reserved list.
+func IsReservedUsername(reserved []string, name string) bool {
+	name = strings.TrimSpace(name)
+	if strings.EqualFold(name, accounts.HouseUsername) {
+		return true
+	}
+	for _, r := range reserved {
+		if strings.EqualFold(strings.TrimSpace(r), name) {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *Config) Validate() error {
@@ -94,6 +482,136 @@ func (c *Config) Validate() error {
 	if c.Moderation.Quorum <= 0 {
 		errs = append(errs, "moderation.quorum must be >= 1")
 	}
+	if c.Moderation.QuorumFraction < 0 || c.Moderation.QuorumFraction > 1 {
+		errs = append(errs, "moderation.quorum_fraction must be between 0 and 1")
+	}
+	if c.Moderation.RakeBps < 0 || c.Moderation.RakeBps >= 10000 {
+		errs = append(errs, "moderation.rake_bps must be between 0 and 9999")
+	}
+	if c.Moderation.MinWager < 0 || c.Moderation.MaxWager < 0 {
+		errs = append(errs, "moderation.min_wager and moderation.max_wager must not be negative")
+	} else if c.Moderation.MaxWager > 0 && c.Moderation.MinWager > c.Moderation.MaxWager {
+		errs = append(errs, "moderation.min_wager must not exceed moderation.max_wager")
+	}
+	if c.Bets.ArchiveAfter < 0 {
+		errs = append(errs, "bets.archive_after must not be negative")
+	}
+	if c.Bets.WagerCooldown < 0 {
+		errs = append(errs, "bets.wager_cooldown must not be negative")
+	}
+	switch c.Bets.CreatorIncentive {
+	case CreatorIncentiveNone:
+	case CreatorIncentiveReward, CreatorIncentiveFee:
+		if c.Bets.CreatorIncentiveAmount <= 0 {
+			errs = append(errs, "bets.creator_incentive_amount must be > 0 when bets.creator_incentive is "+c.Bets.CreatorIncentive)
+		}
+		if c.Bets.CreatorIncentive == CreatorIncentiveReward && c.Moderation.RakeBps == 0 {
+			errs = append(errs, "bets.creator_incentive reward is funded by the rake: set moderation.rake_bps")
+		}
+	default:
+		errs = append(errs, "bets.creator_incentive must be none, reward or fee")
+	}
+	if c.Bets.CreatorRewardMinBettors < 0 {
+		errs = append(errs, "bets.creator_reward_min_bettors must not be negative")
+	}
+	if c.Bets.MaxDeadlineHorizon < 0 {
+		errs = append(errs, "bets.max_deadline_horizon must not be negative")
+	}
+	if c.Bets.MaxComments < 0 || c.Bets.MaxRepliesPerComment < 0 {
+		errs = append(errs, "bets.max_comments and bets.max_replies_per_comment must not be negative")
+	}
+	if c.Maintenance.HouseAlertThreshold > 0 {
+		errs = append(errs, "maintenance.house_alert_threshold must be <= 0")
+	}
+	if c.Site.Indexable && (!c.Site.PublicBrowsing || c.BaseURL == "") {
+		errs = append(errs, "site.indexable requires site.public_browsing and base_url")
+	}
+	for _, col := range c.Site.AvatarPalette {
+		if !isHexColor(col) {
+			errs = append(errs, "site.avatar_palette entries must be #rrggbb colors, got "+strconv.Quote(col))
+		}
+	}
+	switch c.Captcha.Provider {
+	case "":
+	case "hcaptcha", "turnstile":
+		if c.Captcha.SiteKey == "" || c.Captcha.Secret == "" {
+			errs = append(errs, "captcha.site_key and captcha.secret are required with captcha.provider")
+		}
+	default:
+		errs = append(errs, "captcha.provider must be empty, hcaptcha or turnstile")
+	}
+	for _, a := range c.BootstrapAdmins {
+		switch {
+		case strings.TrimSpace(a.Username) == "":
+			errs = append(errs, "bootstrap_admins entries need a username")
+		case IsReservedUsername(c.Accounts.ReservedUsernames, a.Username):
+			errs = append(errs, "bootstrap_admins: username "+strconv.Quote(a.Username)+" is reserved")
+		case strings.TrimSpace(a.PasswordEnv) == "":
+			errs = append(errs, "bootstrap_admins: "+strconv.Quote(a.Username)+" needs a password_env")
+		}
+	}
+	if !validBotUsername(c.Telegram.BotUsername) {
+		errs = append(errs, "telegram.bot_username must be 5-32 letters, digits or _")
+	}
+	switch c.Telegram.Mode {
+	case "polling":
+	case "webhook":
+		if c.BaseURL == "" || !validWebhookSecret(c.Telegram.WebhookSecret) {
+			errs = append(errs, "telegram.mode webhook requires base_url and a webhook_secret of 16-256 letters, digits, _ or -")
+		}
+	default:
+		errs = append(errs, "telegram.mode must be polling or webhook")
+	}
+	if c.Email.SMTPHost != "" {
+		if _, err := mail.ParseAddress(c.Email.From); err != nil {
+			errs = append(errs, "email.from must be a valid address when email.smtp_host is set")
+		}
+		if c.Email.GroupAddress != "" {
+			if _, err := mail.ParseAddress(c.Email.GroupAddress); err != nil {
+				errs = append(errs, "email.group_address must be a valid address")
+			}
+		}
+	}
+	switch c.Session.Backend {
+	case "jwt", "db":
+	default:
+		errs = append(errs, "session.backend must be jwt or db")
+	}
+	if c.Accounts.WelcomeBonus < 0 {
+		errs = append(errs, "accounts.welcome_bonus must not be negative")
+	}
+	if c.Accounts.PasswordHistory < 0 || c.Accounts.PasswordHistory > 20 {
+		errs = append(errs, "accounts.password_history must be between 0 and 20")
+	}
+	if c.Accounts.MinAccountAge < 0 {
+		errs = append(errs, "accounts.min_account_age must not be negative")
+	}
+	if c.Accounts.DailyStipend < 0 {
+		errs = append(errs, "accounts.daily_stipend must not be negative")
+	}
+	if c.Currency.Decimals < 0 || c.Currency.Decimals > 4 {
+		errs = append(errs, "currency.decimals must be between 0 and 4")
+	}
+	switch c.Database.QueryExecMode {
+	case "", "cache_statement", "cache_describe", "describe_exec", "exec", "simple_protocol":
+	default:
+		errs = append(errs, "database.query_exec_mode must be one of cache_statement|cache_describe|describe_exec|exec|simple_protocol")
+	}
+	if c.Database.PgBouncer {
+		if c.Database.DirectURL == "" {
+			errs = append(errs, "database.direct_url is required when database.pgbouncer is set")
+		}
+		switch c.Database.QueryExecMode {
+		case "cache_statement", "cache_describe":
+			errs = append(errs, "database.query_exec_mode "+c.Database.QueryExecMode+" cannot be used with database.pgbouncer")
+		}
+	}
+	if c.Database.StatementTimeout < 0 {
+		errs = append(errs, "database.statement_timeout must not be negative")
+	}
+	if c.HTTP.ReadTimeout < 0 || c.HTTP.ReadHeaderTimeout < 0 || c.HTTP.WriteTimeout < 0 || c.HTTP.IdleTimeout < 0 {
+		errs = append(errs, "http timeouts must not be negative")
+	}
 	if len(errs) > 0 {
 		return errors.New(joinErrs(errs))
 	}
@@ -111,6 +629,15 @@ func joinErrs(es []string) string {
 	return out
 }
 
+// MigrationURL returns the URL used for schema migrations and the balances
+// refresh: DirectURL when set (bypassing any pooler), AppURL otherwise.
+func (d *DatabaseConfig) MigrationURL() (string, error) {
+	if d.DirectURL != "" {
+		return d.DirectURL, nil
+	}
+	return d.AppURL()
+}
+
 // AppURL returns a postgres connection URL for the application DB.
 func (d *DatabaseConfig) AppURL() (string, error) {
 	if d.URL != "" {
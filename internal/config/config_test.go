@@ -0,0 +1,47 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBootstrapAdminsNeedPasswordEnv(t *testing.T) {
+	const base = "database:\n  url: postgres://bap@localhost/bap\n"
+	tests := []struct {
+		name    string
+		admins  string
+		wantErr string
+	}{
+		{"none", "bootstrap_admins: []\n", ""},
+		{"with env", "bootstrap_admins:\n  - username: alice\n    password_env: BAP_ALICE_PASSWORD\n", ""},
+		{"missing env", "bootstrap_admins:\n  - username: alice\n", `"alice" needs a password_env`},
+		{"blank env", "bootstrap_admins:\n  - username: alice\n    password_env: \"  \"\n", `"alice" needs a password_env`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromReader(strings.NewReader(base + tt.admins))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHouseUsernameAlwaysReserved(t *testing.T) {
+	const base = "database:\n  url: postgres://bap@localhost/bap\naccounts:\n  reserved_usernames: [admin]\n"
+	cfg, err := FromReader(strings.NewReader(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"house", " House ", "admin"} {
+		if !IsReservedUsername(cfg.Accounts.ReservedUsernames, name) {
+			t.Errorf("%q is not reserved", name)
+		}
+	}
+	if IsReservedUsername(cfg.Accounts.ReservedUsernames, "alice") {
+		t.Error(`"alice" is reserved`)
+	}
+}
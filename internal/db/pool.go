@@ -5,10 +5,58 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewPool(ctx context.Context, url string) (*pgxpool.Pool, error) {
+// Options tunes connections opened by NewPool. The zero value keeps the
+// server defaults.
+type Options struct {
+	// StatementTimeout aborts any statement (including lock waits) running
+	// longer than this, server-side. Context deadlines only cancel the client
+	// side of a query; this bounds it at the database too. 0 disables it.
+	StatementTimeout time.Duration
+
+	// QueryExecMode overrides pgx's default_query_exec_mode (see
+	// ParseQueryExecMode). Empty keeps pgx's default, cache_statement.
+	QueryExecMode string
+
+	// PgBouncer targets a PgBouncer in transaction pooling mode: defaults the
+	// exec mode to simple_protocol and skips the session-level
+	// statement_timeout, which would not stick to the backend serving the
+	// next transaction. Set statement_timeout on the database role instead
+	// (`alter role ... set statement_timeout = '30s'`).
+	PgBouncer bool
+
+	// SlowQueryThreshold logs (slog, db.slow_query) every query taking at
+	// least this long, with its SQL but not its arguments. 0 disables it.
+	SlowQueryThreshold time.Duration
+}
+
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// ParseQueryExecMode maps a config value to a pgx exec mode.
+//
+// cache_statement (the default) prepares each distinct query once per
+// connection, which suits this app's many small repeated queries. Behind
+// PgBouncer in transaction pooling mode, server-side prepared statements
+// end up on whichever backend served the transaction and break: use
+// exec or simple_protocol there (or describe_exec, at one extra round-trip).
+func ParseQueryExecMode(s string) (pgx.QueryExecMode, error) {
+	m, ok := queryExecModes[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown query exec mode %q", s)
+	}
+	return m, nil
+}
+
+func NewPool(ctx context.Context, url string, opts Options) (*pgxpool.Pool, error) {
 	cfg, err := pgxpool.ParseConfig(url)
 	if err != nil {
 		return nil, fmt.Errorf("parse pg url: %w", err)
@@ -19,6 +67,29 @@ func NewPool(ctx context.Context, url string) (*pgxpool.Pool, error) {
 	cfg.MaxConnLifetime = 30 * time.Minute
 	cfg.HealthCheckPeriod = 30 * time.Second
 
+	if opts.PgBouncer && opts.QueryExecMode == "" {
+		opts.QueryExecMode = "simple_protocol"
+	}
+	if opts.QueryExecMode != "" {
+		mode, err := ParseQueryExecMode(opts.QueryExecMode)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ConnConfig.DefaultQueryExecMode = mode
+	}
+
+	if opts.SlowQueryThreshold > 0 {
+		cfg.ConnConfig.Tracer = slowQueryTracer{threshold: opts.SlowQueryThreshold}
+	}
+
+	if opts.StatementTimeout > 0 && !opts.PgBouncer {
+		stmt := fmt.Sprintf("set statement_timeout = %d", opts.StatementTimeout.Milliseconds())
+		cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			_, err := conn.Exec(ctx, stmt)
+			return err
+		}
+	}
+
 	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("open pool: %w", err)
@@ -0,0 +1,44 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"betsandpedestres/internal/db"
+	"betsandpedestres/internal/dbtest"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestStatementTimeout(t *testing.T) {
+	ctx := context.Background()
+	pool := dbtest.NewWithOptions(t, db.Options{StatementTimeout: 200 * time.Millisecond})
+
+	start := time.Now()
+	_, err := pool.Exec(ctx, `select pg_sleep(10)`)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "57014" {
+		t.Fatalf("slow query: err = %v, want query_canceled (57014)", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("slow query aborted after %v", elapsed)
+	}
+
+	// The connection stays usable for the next query.
+	var one int
+	if err := pool.QueryRow(ctx, `select 1`).Scan(&one); err != nil || one != 1 {
+		t.Fatalf("query after the timeout: %d, %v", one, err)
+	}
+}
+
+func TestStatementTimeoutSkippedForPgBouncer(t *testing.T) {
+	pool := dbtest.NewWithOptions(t, db.Options{StatementTimeout: 200 * time.Millisecond, PgBouncer: true})
+	var timeout string
+	if err := pool.QueryRow(context.Background(), `show statement_timeout`).Scan(&timeout); err != nil {
+		t.Fatal(err)
+	}
+	if timeout == "200ms" {
+		t.Error("statement_timeout was set on a PgBouncer session")
+	}
+}
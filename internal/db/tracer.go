@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// slowQueryTracer logs queries running longer than threshold. Only the
+// parameterized SQL is logged, never argument values.
+type slowQueryTracer struct {
+	threshold time.Duration
+}
+
+type slowQueryKey struct{}
+
+type slowQueryStart struct {
+	sql   string
+	nargs int
+	at    time.Time
+}
+
+func (t slowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, slowQueryKey{}, slowQueryStart{sql: data.SQL, nargs: len(data.Args), at: time.Now()})
+}
+
+func (t slowQueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(slowQueryKey{}).(slowQueryStart)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start.at)
+	if elapsed < t.threshold {
+		return
+	}
+	attrs := []any{
+		"duration_ms", elapsed.Milliseconds(),
+		"sql", compactSQL(start.sql),
+		"args", start.nargs,
+		"rows", data.CommandTag.RowsAffected(),
+	}
+	if data.Err != nil {
+		attrs = append(attrs, "err", data.Err)
+	}
+	slog.Warn("db.slow_query", attrs...)
+}
+
+// compactSQL folds the indentation of multi-line queries onto one line.
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
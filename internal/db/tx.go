@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// retryAttempts bounds how many times WithRetryTx runs fn.
+	retryAttempts = 5
+	// retryBaseDelay is the first backoff; it doubles after each attempt.
+	retryBaseDelay = 10 * time.Millisecond
+)
+
+// WithRetryTx runs fn in a transaction with the given options and commits it.
+// When PostgreSQL aborts the transaction because of contention, either a
+// serialization failure (40001) or a deadlock (40P01), the whole transaction
+// is re-run after a jittered exponential backoff. fn may therefore run
+// several times: it must not have side effects outside tx (notifications,
+// in-memory accumulation across attempts).
+func WithRetryTx(ctx context.Context, pool *pgxpool.Pool, opts pgx.TxOptions, fn func(pgx.Tx) error) error {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := pgx.BeginTxFunc(ctx, pool, opts, fn)
+		if err == nil || !IsRetryable(err) || attempt == retryAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay/2 + rand.N(delay)):
+		}
+		delay *= 2
+	}
+}
+
+// RunSerializable is WithRetryTx at SERIALIZABLE isolation.
+func RunSerializable(ctx context.Context, pool *pgxpool.Pool, fn func(pgx.Tx) error) error {
+	return WithRetryTx(ctx, pool, pgx.TxOptions{IsoLevel: pgx.Serializable}, fn)
+}
+
+// IsRetryable reports whether err aborted a transaction that is safe to re-run.
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
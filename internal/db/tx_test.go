@@ -0,0 +1,103 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"betsandpedestres/internal/db"
+	"betsandpedestres/internal/dbtest"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsRetryable(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "40001"}, true},
+		{&pgconn.PgError{Code: "40P01"}, true},
+		{fmt.Errorf("wager: %w", &pgconn.PgError{Code: "40001"}), true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{errors.New("40001"), false},
+		{nil, false},
+	} {
+		if got := db.IsRetryable(c.err); got != c.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+// TestWithRetryTxConcurrent runs serializable read-modify-write transactions
+// that all read before any of them writes, so every one but the first to
+// commit hits a serialization failure and must be retried.
+func TestWithRetryTxConcurrent(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx, `create table counter (n int not null); insert into counter values (0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 4
+	var attempts atomic.Int32
+	var read sync.WaitGroup
+	read.Add(workers)
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			first := true
+			errs <- db.RunSerializable(ctx, pool, func(tx pgx.Tx) error {
+				attempts.Add(1)
+				var n int
+				if err := tx.QueryRow(ctx, `select n from counter`).Scan(&n); err != nil {
+					return err
+				}
+				if first {
+					first = false
+					read.Done()
+					read.Wait()
+				}
+				_, err := tx.Exec(ctx, `update counter set n = $1`, n+1)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("transaction failed: %v", err)
+		}
+	}
+
+	var n int
+	if err := pool.QueryRow(ctx, `select n from counter`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != workers {
+		t.Errorf("counter = %d, want %d: an increment was lost", n, workers)
+	}
+	if got := attempts.Load(); got <= workers {
+		t.Errorf("%d attempts for %d transactions: no conflict was retried", got, workers)
+	}
+}
+
+func TestWithRetryTxDoesNotRetryOtherErrors(t *testing.T) {
+	pool := dbtest.New(t)
+	calls := 0
+	boom := errors.New("boom")
+	err := db.RunSerializable(context.Background(), pool, func(pgx.Tx) error {
+		calls++
+		return boom
+	})
+	if !errors.Is(err, boom) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want boom after 1", err, calls)
+	}
+}
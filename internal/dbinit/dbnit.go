@@ -129,7 +129,8 @@ func EnsureDatabaseAndMigrate(ctx context.Context, adminConn, targetDB, owner st
 }
 
 // RefreshBalancesMatView triggers a concurrent refresh of the cached balances MV.
-// Call this from a maintenance job after bursts of ledger activity.
+// The MV is created empty and a concurrent refresh requires a populated view,
+// so the first call does a plain (locking) refresh instead.
 func RefreshBalancesMatView(ctx context.Context, targetConn string) error {
 	conn, err := pgx.Connect(ctx, targetConn)
 	if err != nil {
@@ -141,8 +142,17 @@ func RefreshBalancesMatView(ctx context.Context, targetConn string) error {
 		}
 	}()
 
+	var populated bool
+	if err := conn.QueryRow(ctx, `select ispopulated from pg_matviews where matviewname = 'user_balances_mv'`).Scan(&populated); err != nil {
+		return fmt.Errorf("inspect MV: %w", err)
+	}
+	stmt := `refresh materialized view concurrently user_balances_mv`
+	if !populated {
+		stmt = `refresh materialized view user_balances_mv`
+	}
+
 	// Must be outside a transaction; pgx starts statements autocommit by default.
-	if _, err := conn.Exec(ctx, `refresh materialized view concurrently user_balances_mv`); err != nil {
+	if _, err := conn.Exec(ctx, stmt); err != nil {
 		return fmt.Errorf("refresh MV: %w", err)
 	}
 	return nil
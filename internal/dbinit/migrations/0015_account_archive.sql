@@ -0,0 +1,3 @@
+-- Escrow accounts of settled bets can be flagged as archived once they net to zero.
+alter table accounts
+  add column if not exists archived_at timestamptz;
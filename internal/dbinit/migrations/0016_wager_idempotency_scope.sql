@@ -0,0 +1,4 @@
+-- Idempotency keys dedupe retries of the same wager submission. Scope them to
+-- the bet as well, so a client reusing a key on another bet isn't swallowed.
+drop index if exists uq_wager_user_idemp;
+create unique index if not exists uq_wager_user_bet_idemp on wagers (user_id, bet_id, idempotency_key);
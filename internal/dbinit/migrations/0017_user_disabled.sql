@@ -0,0 +1,3 @@
+-- Disabled users (e.g. merged into another account) can no longer log in.
+alter table users
+  add column if not exists disabled_at timestamptz;
@@ -0,0 +1,13 @@
+-- Home feed: every variant filters on bets.status and orders by created_at
+-- (default) or deadline, with id as tie-breaker. With these the planner can
+-- walk the index in order and stop at LIMIT instead of sorting every open bet.
+create index if not exists idx_bets_status_created on bets(status, created_at desc, id desc);
+create index if not exists idx_bets_status_deadline on bets(status, deadline, id);
+
+-- Superseded by the two composites above (status is their leading column).
+drop index if exists idx_bets_status;
+
+-- "Bets I'm in" / "bets I'm not in" filters probe wagers by (bet_id, user_id).
+-- wagers(bet_id), wagers(option_id) and bet_resolution_votes(bet_id) already
+-- exist (0001, 0003, 0007).
+create index if not exists idx_wagers_bet_user on wagers(bet_id, user_id);
@@ -0,0 +1,7 @@
+-- Set once bettors have been told a bet reached its deadline.
+alter table bets
+  add column if not exists notified_deadline boolean not null default false;
+
+-- Existing bets already past their deadline should not trigger a late burst.
+update bets set notified_deadline = true
+where deadline is not null and deadline <= now();
@@ -0,0 +1,7 @@
+-- Optional per-bet allowlist of moderators who may vote on the outcome.
+-- A bet without rows here can be resolved by any moderator.
+create table if not exists bet_resolvers (
+  bet_id   uuid not null references bets(id) on delete cascade,
+  user_id  uuid not null references users(id) on delete cascade,
+  primary key (bet_id, user_id)
+);
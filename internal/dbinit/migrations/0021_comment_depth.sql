@@ -0,0 +1,18 @@
+-- Reply depth, fixed at insert time: 0 for top-level comments, parent + 1
+-- for replies. Lets the server cap threads without walking the chain.
+alter table comments
+  add column if not exists depth int not null default 0;
+
+with recursive tree as (
+  select id, 0 as depth
+  from comments
+  where parent_comment_id is null
+  union all
+  select c.id, t.depth + 1
+  from comments c
+  join tree t on c.parent_comment_id = t.id
+)
+update comments c
+set depth = tree.depth
+from tree
+where tree.id = c.id and c.depth <> tree.depth;
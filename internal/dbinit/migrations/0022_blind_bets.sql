@@ -0,0 +1,4 @@
+-- Blind bets hide per-option stakes and bettors from non-moderators until
+-- the bet is resolved; only the participant count is public meanwhile.
+alter table bets
+  add column if not exists blind boolean not null default false;
@@ -0,0 +1,7 @@
+-- One row per user and UTC day a daily stipend was paid; the primary key
+-- makes concurrent claims for the same day collapse into one.
+create table if not exists daily_stipends (
+  user_id  uuid not null references users(id) on delete cascade,
+  day      date not null,
+  primary key (user_id, day)
+);
@@ -0,0 +1,8 @@
+-- Runtime settings changed by admins without a restart (e.g. maintenance
+-- mode). Values are text; callers parse them.
+create table if not exists app_settings (
+  key         text primary key,
+  value       text not null,
+  updated_at  timestamptz not null default now(),
+  updated_by  uuid references users(id) on delete set null
+);
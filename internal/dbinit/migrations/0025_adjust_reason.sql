@@ -0,0 +1 @@
+ALTER TYPE tx_reason ADD VALUE IF NOT EXISTS 'ADJUST';
@@ -0,0 +1,2 @@
+-- Keyset pagination for /api/v1/transactions walks (created_at, id) newest first.
+create index if not exists idx_tx_created_id on transactions(created_at desc, id desc);
@@ -0,0 +1,18 @@
+do $$
+begin
+  if not exists (select 1 from pg_type where typname = 'bet_visibility') then
+    create type bet_visibility as enum ('public', 'unlisted', 'private');
+  end if;
+end$$;
+
+alter table bets add column if not exists visibility bet_visibility not null default 'public';
+
+-- Users allowed to see and wager on a private bet, besides its creator.
+create table if not exists bet_invitations (
+  bet_id      uuid not null references bets(id) on delete cascade,
+  user_id     uuid not null references users(id) on delete cascade,
+  invited_by  uuid references users(id) on delete set null,
+  created_at  timestamptz not null default now(),
+  primary key (bet_id, user_id)
+);
+create index if not exists idx_bet_invitations_user on bet_invitations(user_id);
@@ -0,0 +1,3 @@
+-- Optional caller-supplied key so scripted gifts can be safely re-run.
+alter table transactions add column if not exists idempotency_key text;
+create unique index if not exists uq_tx_idempotency on transactions (idempotency_key) where idempotency_key is not null;
@@ -0,0 +1,8 @@
+-- Achievements unlocked per user; rules live in internal/achievements and
+-- are identified by key.
+create table if not exists user_achievements (
+  user_id     uuid not null references users(id) on delete cascade,
+  key         text not null,
+  awarded_at  timestamptz not null default now(),
+  primary key (user_id, key)
+);
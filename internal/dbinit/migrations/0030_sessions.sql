@@ -0,0 +1,11 @@
+-- Opaque session tokens for session.backend = db. Only the SHA-256 of the
+-- cookie value is stored.
+create table if not exists sessions (
+  token_hash  bytea primary key,
+  user_id     uuid not null references users(id) on delete cascade,
+  created_at  timestamptz not null default now(),
+  expires_at  timestamptz not null
+);
+
+create index if not exists idx_sessions_user on sessions(user_id);
+create index if not exists idx_sessions_expires on sessions(expires_at);
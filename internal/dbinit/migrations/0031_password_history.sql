@@ -0,0 +1,10 @@
+-- Previous password hashes, kept only when accounts.password_history > 0 so
+-- users cannot cycle back to a recent password.
+create table if not exists password_history (
+  id            bigserial primary key,
+  user_id       uuid not null references users(id) on delete cascade,
+  password_hash text not null,
+  replaced_at   timestamptz not null default now()
+);
+
+create index if not exists idx_password_history_user on password_history(user_id, replaced_at desc);
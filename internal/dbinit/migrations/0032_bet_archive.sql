@@ -0,0 +1,6 @@
+-- Closed bets older than bets.archive_after are flagged archived and left
+-- out of the default feed and leaderboards. Their ledger is untouched.
+alter table bets
+  add column if not exists archived_at timestamptz;
+
+create index if not exists idx_bets_live on bets(status, created_at desc) where archived_at is null;
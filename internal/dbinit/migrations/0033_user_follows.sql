@@ -0,0 +1,11 @@
+-- Users following a creator get notified when that creator opens a public
+-- bet.
+create table if not exists user_follows (
+  follower_id  uuid not null references users(id) on delete cascade,
+  followee_id  uuid not null references users(id) on delete cascade,
+  created_at   timestamptz not null default now(),
+  primary key (follower_id, followee_id),
+  check (follower_id <> followee_id)
+);
+
+create index if not exists idx_user_follows_followee on user_follows(followee_id);
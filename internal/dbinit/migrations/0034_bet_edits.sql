@@ -0,0 +1,11 @@
+-- Audit trail of bets edited by their creator (or an admin) before any
+-- wager; old and new hold the edited fields.
+create table if not exists bet_edits (
+  id              uuid primary key default gen_random_uuid(),
+  bet_id          uuid not null references bets(id) on delete cascade,
+  editor_user_id  uuid not null references users(id) on delete cascade,
+  old             jsonb not null,
+  new             jsonb not null,
+  created_at      timestamptz not null default now()
+);
+create index if not exists idx_bet_edits_bet on bet_edits(bet_id, created_at);
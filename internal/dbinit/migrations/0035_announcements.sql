@@ -0,0 +1,13 @@
+-- Site-wide banners posted by admins. A banner shows while active and
+-- before expires_at (null = until switched off).
+create table if not exists announcements (
+  id          uuid primary key default gen_random_uuid(),
+  message     text not null check (length(message) between 1 and 500),
+  severity    text not null default 'info' check (severity in ('info', 'warning', 'critical')),
+  active      boolean not null default true,
+  expires_at  timestamptz,
+  created_by  uuid references users(id) on delete set null,
+  created_at  timestamptz not null default now(),
+  updated_at  timestamptz not null default now()
+);
+create index if not exists idx_announcements_active on announcements(created_at desc) where active;
@@ -0,0 +1,3 @@
+-- House rake skimmed from the escrow when the bet resolved, so the bet page
+-- shows the split that actually happened even if the rate changes later.
+alter table bets add column if not exists rake bigint not null default 0 check (rake >= 0);
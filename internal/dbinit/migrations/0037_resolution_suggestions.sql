@@ -0,0 +1,14 @@
+-- Non-binding outcome hints from bettors, one per user and bet, shown to
+-- moderators next to the real resolution votes.
+create table if not exists resolution_suggestions (
+  bet_id      uuid not null references bets(id) on delete cascade,
+  user_id     uuid not null references users(id) on delete cascade,
+  option_id   uuid not null references bet_options(id) on delete cascade,
+  created_at  timestamptz not null default now(),
+  updated_at  timestamptz not null default now(),
+  primary key (bet_id, user_id)
+);
+create index if not exists idx_resolution_suggestions_option on resolution_suggestions(option_id);
+
+-- Set once moderators were told an outcome reached the suggestion threshold.
+alter table bets add column if not exists suggestions_alerted_at timestamptz;
@@ -0,0 +1,3 @@
+-- Optional address for email notifications (see internal/notify/email).
+alter table users
+  add column if not exists email text;
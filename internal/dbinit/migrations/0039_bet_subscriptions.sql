@@ -0,0 +1,10 @@
+-- Users subscribed to a bet get a DM for its new wagers, comments and
+-- resolution. Creators, bettors and commenters are subscribed automatically.
+create table if not exists bet_subscriptions (
+  bet_id     uuid not null references bets(id) on delete cascade,
+  user_id    uuid not null references users(id) on delete cascade,
+  created_at timestamptz not null default now(),
+  primary key (bet_id, user_id)
+);
+
+create index if not exists idx_bet_subscriptions_user on bet_subscriptions(user_id);
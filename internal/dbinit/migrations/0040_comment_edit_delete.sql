@@ -0,0 +1,6 @@
+-- Authors can edit their comments (edited_at) and authors or moderators can
+-- delete them. Deletes are soft: the content is blanked and deleted_at set,
+-- so replies keep their parent.
+alter table comments
+  add column if not exists edited_at timestamptz,
+  add column if not exists deleted_at timestamptz;
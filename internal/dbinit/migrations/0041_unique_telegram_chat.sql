@@ -0,0 +1,15 @@
+-- One Telegram chat links to one account, otherwise NotifyUser and password
+-- recovery could reach the wrong person. Where a chat was linked to several
+-- accounts, only the most recently created one keeps it; /register moves the
+-- link from now on.
+update users u
+set telegram_chat_id = null, telegram_notify = false
+where u.telegram_chat_id is not null
+  and exists (
+    select 1 from users o
+    where o.telegram_chat_id = u.telegram_chat_id
+      and (o.created_at, o.id) > (u.created_at, u.id)
+  );
+
+create unique index if not exists uq_users_telegram_chat_id
+  on users(telegram_chat_id) where telegram_chat_id is not null;
@@ -0,0 +1,13 @@
+-- Optional per-bet bounds on a single wager, in minor units. Null falls back
+-- to moderation.min_wager / max_wager.
+alter table bets
+  add column if not exists min_wager bigint check (min_wager > 0),
+  add column if not exists max_wager bigint check (max_wager > 0);
+
+do $$
+begin
+  if not exists (select 1 from pg_constraint where conname = 'bets_wager_limits_order') then
+    alter table bets add constraint bets_wager_limits_order
+      check (min_wager is null or max_wager is null or min_wager <= max_wager);
+  end if;
+end$$;
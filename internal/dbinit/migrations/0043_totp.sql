@@ -0,0 +1,15 @@
+-- Optional TOTP two-factor login. totp_secret (base32) is set once the user
+-- confirmed a first code; totp_last_step is the newest time step accepted,
+-- so a code cannot be replayed.
+alter table users
+  add column if not exists totp_secret text,
+  add column if not exists totp_last_step bigint;
+
+-- Single-use recovery codes, stored as SHA-256 digests.
+create table if not exists totp_recovery_codes (
+  user_id    uuid not null references users(id) on delete cascade,
+  code_hash  text not null,
+  used_at    timestamptz,
+  created_at timestamptz not null default now(),
+  primary key (user_id, code_hash)
+);
@@ -0,0 +1,12 @@
+-- Single-use nonces embedded in wager forms, so a form left open cannot be
+-- submitted after the odds or the balance moved on. They are consumed in the
+-- wager's own transaction and shared by every app instance.
+create table if not exists wager_nonces (
+  nonce      text primary key,
+  user_id    uuid not null references users(id) on delete cascade,
+  bet_id     uuid not null references bets(id) on delete cascade,
+  expires_at timestamptz not null
+);
+
+create index if not exists idx_wager_nonces_scope on wager_nonces (user_id, bet_id, expires_at desc);
+create index if not exists idx_wager_nonces_expires on wager_nonces (expires_at);
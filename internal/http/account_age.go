@@ -0,0 +1,27 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"betsandpedestres/internal/apperr"
+	"betsandpedestres/internal/http/middleware"
+)
+
+// checkAccountAge refuses action (e.g. "create bets") to accounts younger
+// than minAge. Moderators and admins are exempt; minAge <= 0 disables it.
+func checkAccountAge(ctx context.Context, q rowQuerier, userID, role string, minAge time.Duration, action string) error {
+	if minAge <= 0 || role == middleware.RoleModerator || role == middleware.RoleAdmin {
+		return nil
+	}
+	var createdAt time.Time
+	if err := q.QueryRow(ctx, `select created_at from users where id = $1::uuid`, userID).Scan(&createdAt); err != nil {
+		return apperr.Internal("db error", err)
+	}
+	eligible := createdAt.Add(minAge)
+	if !time.Now().Before(eligible) {
+		return nil
+	}
+	return apperr.Forbidden(fmt.Sprintf("new accounts cannot %s yet: try again in %s", action, formatExpiresIn(&eligible)))
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/db"
+	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AdminAdjustHandler lets admins credit or debit a user's wallet against the
+// house (POST amount, note). Debits never take the wallet below zero.
+type AdminAdjustHandler struct {
+	DB       *pgxpool.Pool
+	Notifier notify.Notifier
+}
+
+func (h *AdminAdjustHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	header, role := loadHeader(r.Context(), h.DB, uid)
+	if !header.LoggedIn {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if role != middleware.RoleAdmin {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	target := r.PathValue("username")
+	redirect := func(code, step string, err error) {
+		if err != nil {
+			slog.Warn("admin.adjust.fail", "step", step, "target", target, "err", err)
+		}
+		http.Redirect(w, r, "/profile/"+url.PathEscape(target)+"?adjust="+code, http.StatusSeeOther)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		redirect("invalid", "form", err)
+		return
+	}
+	amount, err := coins.Parse(r.Form.Get("amount"))
+	if err != nil || amount == 0 {
+		redirect("invalid", "amount", err)
+		return
+	}
+	note := strings.TrimSpace(r.Form.Get("note"))
+	if note == "" {
+		redirect("nonote", "note", nil)
+		return
+	}
+	if len([]rune(note)) > 200 {
+		note = string([]rune(note)[:200])
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var targetID, targetName string
+	if err := h.DB.QueryRow(ctx, `
+		select id::text, display_name from users where username = $1
+	`, target).Scan(&targetID, &targetName); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		redirect("error", "target_lookup", err)
+		return
+	}
+
+	err = db.RunSerializable(ctx, h.DB, func(tx pgx.Tx) error {
+		accountID, err := ensureDefaultAccountTx(ctx, tx, targetID, true)
+		if err != nil {
+			return &transferError{"error", "wallet", err}
+		}
+		if amount < 0 {
+			var balance int64
+			err := tx.QueryRow(ctx, `select coalesce(balance,0)::bigint from user_balances where user_id = $1::uuid`, targetID).Scan(&balance)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return &transferError{"error", "balance_lookup", err}
+			}
+			if balance+amount < 0 {
+				return &transferError{"notenough", "balance_check", nil}
+			}
+		}
+		if err := accounts.AdjustFromHouse(ctx, tx, accountID, amount, note); err != nil {
+			return &transferError{"error", "ledger_insert", err}
+		}
+		if _, err := tx.Exec(ctx, `
+			insert into admin_actions (admin_user_id, target_user_id, action, note)
+			values ($1::uuid, $2::uuid, 'balance_adjust', $3)
+		`, uid, targetID, coins.Format(amount)+": "+note); err != nil {
+			return &transferError{"error", "audit", err}
+		}
+		return nil
+	})
+	if err != nil {
+		var te *transferError
+		if errors.As(err, &te) {
+			redirect(te.code, te.step, te.err)
+		} else {
+			redirect("error", "tx_commit", err)
+		}
+		return
+	}
+
+	slog.Info("admin.adjust", "admin", uid, "target", targetID, "amount", amount)
+	verb := "credited"
+	if amount < 0 {
+		verb = "debited"
+	}
+	abs := amount
+	if abs < 0 {
+		abs = -abs
+	}
+	h.Notifier.NotifyUser(ctx, targetID, fmt.Sprintf("An admin %s your wallet with 🦶 %s PiedPièces.\nReason: %s", verb, coins.Format(abs), note))
+	h.Notifier.NotifyAdmins(ctx, fmt.Sprintf("Admin %s %s %s with 🦶 %s PiedPièces.\nReason: %s", header.DisplayName, verb, targetName, coins.Format(abs), note))
+
+	redirect("done", "", nil)
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/announcements"
+	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/web"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AdminAnnouncementsHandler lists (GET) and creates, updates or deletes
+// (POST action=create|update|delete) the site-wide banners.
+type AdminAnnouncementsHandler struct {
+	DB  *pgxpool.Pool
+	TPL *web.Renderer
+}
+
+type adminAnnouncementsContent struct {
+	Title         string
+	Announcements []announcements.Announcement
+	Now           time.Time
+	Status        string // "saved" | "deleted" | "invalid" | "error"
+}
+
+func (h *AdminAnnouncementsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	header, role := loadHeader(r.Context(), h.DB, uid)
+	if !header.LoggedIn {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if role != middleware.RoleAdmin {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if r.Method == http.MethodPost {
+		http.Redirect(w, r, "/admin/announcements?status="+h.apply(ctx, r, uid), http.StatusSeeOther)
+		return
+	}
+
+	list, err := announcements.All(ctx, h.DB)
+	if err != nil {
+		slog.Error("admin.announcements.list", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	content := adminAnnouncementsContent{
+		Title:         "Announcements",
+		Announcements: list,
+		Now:           time.Now(),
+		Status:        r.URL.Query().Get("status"),
+	}
+	page := web.Page[adminAnnouncementsContent]{Header: header, Content: content}
+
+	var buf bytes.Buffer
+	if err := h.TPL.Render(&buf, "admin_announcements", page); err != nil {
+		slog.Error("template error", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// apply performs the posted action and returns the status to show.
+func (h *AdminAnnouncementsHandler) apply(ctx context.Context, r *http.Request, uid string) string {
+	if err := r.ParseForm(); err != nil {
+		return "invalid"
+	}
+	action := r.Form.Get("action")
+	id := strings.TrimSpace(r.Form.Get("id"))
+	if action != "create" && !looksLikeUUID(id) {
+		return "invalid"
+	}
+
+	var err error
+	switch action {
+	case "delete":
+		err = announcements.Delete(ctx, h.DB, id)
+	case "create", "update":
+		message, severity := r.Form.Get("message"), r.Form.Get("severity")
+		if message, severity, err = announcements.Validate(message, severity); err != nil {
+			return "invalid"
+		}
+		expires, perr := parseDeadline(
+			strings.TrimSpace(r.Form.Get("expires_local")),
+			strings.TrimSpace(r.Form.Get("expires_utc")),
+			strings.TrimSpace(r.Form.Get("tz")))
+		if perr != nil {
+			return "invalid"
+		}
+		if action == "create" {
+			err = announcements.Create(ctx, h.DB, uid, message, severity, expires)
+		} else {
+			err = announcements.Update(ctx, h.DB, id, message, severity, r.Form.Get("active") != "", expires)
+		}
+	default:
+		return "invalid"
+	}
+	if err != nil {
+		slog.Error("admin.announcements."+action, "err", err)
+		return "error"
+	}
+	if _, err := h.DB.Exec(ctx, `
+		insert into admin_actions (admin_user_id, action, note) values ($1::uuid, $2, nullif($3,''))
+	`, uid, "announcement_"+action, id); err != nil {
+		slog.Warn("admin.announcements.audit", "err", err)
+	}
+	if action == "delete" {
+		return "deleted"
+	}
+	return "saved"
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/http/middleware"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AdminHouseHandler reports the house balance and circulating supply so
+// admins can tell whether the economy is solvent.
+type AdminHouseHandler struct {
+	DB *pgxpool.Pool
+}
+
+func (h *AdminHouseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	if uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	role, err := middleware.GetUserRole(ctx, h.DB, uid)
+	if err != nil || role != middleware.RoleAdmin {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	eco, err := accounts.LoadEconomy(ctx, h.DB)
+	if err != nil {
+		slog.Error("admin.house.query", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(eco)
+}
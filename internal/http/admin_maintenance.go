@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"betsandpedestres/internal/http/middleware"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AdminMaintenanceHandler reads (GET) or toggles (POST {"enabled": bool})
+// maintenance mode at runtime.
+type AdminMaintenanceHandler struct {
+	DB   *pgxpool.Pool
+	Mode *middleware.Maintenance
+}
+
+type maintenanceState struct {
+	Enabled bool `json:"enabled"`
+	Forced  bool `json:"forced"` // set in config; cannot be turned off here
+}
+
+func (h *AdminMaintenanceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	if uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	role, err := middleware.GetUserRole(ctx, h.DB, uid)
+	if err != nil || role != middleware.RoleAdmin {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		if err := h.Mode.Set(ctx, req.Enabled, uid); err != nil {
+			slog.Error("admin.maintenance.set", "err", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		action := "maintenance_off"
+		if req.Enabled {
+			action = "maintenance_on"
+		}
+		if _, err := h.DB.Exec(ctx, `
+			insert into admin_actions (admin_user_id, action) values ($1::uuid, $2)
+		`, uid, action); err != nil {
+			slog.Warn("admin.maintenance.audit", "err", err)
+		}
+		slog.Info("admin.maintenance", "admin", uid, "enabled", req.Enabled)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(maintenanceState{Enabled: h.Mode.Enabled(ctx), Forced: h.Mode.Forced})
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/jobs"
+	"betsandpedestres/internal/settings"
+	"betsandpedestres/internal/web"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AdminSettingsHandler lists (GET) and edits (POST key, value) the runtime
+// settings registered with settings.Define.
+type AdminSettingsHandler struct {
+	DB  *pgxpool.Pool
+	TPL *web.Renderer
+	// StuckAfter is how long a bet may wait for an admin decision before it
+	// is listed as stuck.
+	StuckAfter time.Duration
+}
+
+type adminSettingsContent struct {
+	Title      string
+	Settings   []settings.Entry
+	Status     string // "saved" | "error"
+	StuckBets  []jobs.StuckBet
+	StuckAfter string
+}
+
+func (h *AdminSettingsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	header, role := loadHeader(r.Context(), h.DB, uid)
+	if !header.LoggedIn {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if role != middleware.RoleAdmin {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		key := strings.TrimSpace(r.Form.Get("key"))
+		value := strings.TrimSpace(r.Form.Get("value"))
+		if err := settings.Set(ctx, key, value, uid); err != nil {
+			slog.Error("admin.settings.set", "key", key, "err", err)
+			http.Redirect(w, r, "/admin/settings?status=error", http.StatusSeeOther)
+			return
+		}
+		if _, err := h.DB.Exec(ctx, `
+			insert into admin_actions (admin_user_id, action, note) values ($1::uuid, 'setting_change', $2)
+		`, uid, key+"="+value); err != nil {
+			slog.Warn("admin.settings.audit", "err", err)
+		}
+		http.Redirect(w, r, "/admin/settings?status=saved", http.StatusSeeOther)
+		return
+	}
+
+	stuckAfter := max(h.StuckAfter, 0)
+	stuck, err := jobs.StuckBets(ctx, h.DB, stuckAfter)
+	if err != nil {
+		slog.Warn("admin.settings.stuck_bets", "err", err)
+	}
+	content := adminSettingsContent{
+		Title:      "Runtime settings",
+		Settings:   settings.All(ctx),
+		Status:     r.URL.Query().Get("status"),
+		StuckBets:  stuck,
+		StuckAfter: jobs.HumanDuration(stuckAfter),
+	}
+	page := web.Page[adminSettingsContent]{Header: header, Content: content}
+
+	var buf bytes.Buffer
+	if err := h.TPL.Render(&buf, "admin_settings", page); err != nil {
+		slog.Error("template error", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
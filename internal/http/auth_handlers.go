@@ -3,10 +3,12 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
+	"betsandpedestres/internal/apperr"
 	"betsandpedestres/internal/auth"
 	"betsandpedestres/internal/http/middleware"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -15,6 +17,8 @@ import (
 type AuthHandler struct {
 	DB           *pgxpool.Pool
 	LoginLimiter *middleware.RateLimiter
+	// CSRFCookie issues the API's double-submit token on login.
+	CSRFCookie bool
 }
 
 func (h *AuthHandler) Routes(mux *http.ServeMux) {
@@ -23,9 +27,22 @@ func (h *AuthHandler) Routes(mux *http.ServeMux) {
 	mux.Handle("GET /api/v1/auth/me", middleware.RequireAuth(http.HandlerFunc(h.Me)))
 }
 
+var (
+	errLoginRateLimited = apperr.Coded(http.StatusTooManyRequests, "rate_limited", "too many attempts")
+	errLoginBadJSON     = apperr.Coded(http.StatusBadRequest, "bad_json", "bad json")
+	errLoginMissing     = apperr.Coded(http.StatusBadRequest, "missing_credentials", "missing credentials")
+	errLoginInvalid     = apperr.Coded(http.StatusUnauthorized, "invalid_credentials", "invalid credentials")
+	errLoginTOTP        = apperr.Coded(http.StatusUnauthorized, "totp_required", "two-factor code required")
+	errLoginTOTPInvalid = apperr.Coded(http.StatusUnauthorized, "invalid_totp", "invalid two-factor code")
+	errMeNotFound       = apperr.Coded(http.StatusNotFound, "user_not_found", "not found")
+)
+
 type loginReq struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// TOTP is the authenticator or recovery code, required once the
+	// password checks out for a user with two-factor login enabled.
+	TOTP string `json:"totp"`
 }
 
 type meResp struct {
@@ -38,18 +55,18 @@ type meResp struct {
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	if h.LoginLimiter != nil {
 		if !h.LoginLimiter.Allow(middleware.ClientIP(r)) {
-			http.Error(w, "too many attempts", http.StatusTooManyRequests)
+			apperr.WriteJSON(w, errLoginRateLimited)
 			return
 		}
 	}
 	var req loginReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad json", http.StatusBadRequest)
+		apperr.WriteJSON(w, errLoginBadJSON)
 		return
 	}
 	req.Username = strings.TrimSpace(req.Username)
 	if req.Username == "" || req.Password == "" {
-		http.Error(w, "missing credentials", http.StatusBadRequest)
+		apperr.WriteJSON(w, errLoginMissing)
 		return
 	}
 
@@ -58,19 +75,35 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	var (
 		id, username, displayName, role, passHash string
+		totpSecret                                *string
 	)
 	err := h.DB.QueryRow(ctx,
-		`select id, username, display_name, role, password_hash
-		 from users where username = $1`, req.Username).
-		Scan(&id, &username, &displayName, &role, &passHash)
+		`select id, username, display_name, role, password_hash, totp_secret
+		 from users where username = $1 and disabled_at is null`, req.Username).
+		Scan(&id, &username, &displayName, &role, &passHash, &totpSecret)
 	if err != nil || !auth.CheckPassword(req.Password, passHash) {
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		apperr.WriteJSON(w, errLoginInvalid)
 		return
 	}
+	if totpSecret != nil {
+		if strings.TrimSpace(req.TOTP) == "" {
+			apperr.WriteJSON(w, errLoginTOTP)
+			return
+		}
+		ok, err := checkSecondFactor(ctx, h.DB, id, *totpSecret, req.TOTP)
+		if err != nil {
+			apperr.WriteJSON(w, apperr.Internal("db error", err))
+			return
+		}
+		if !ok {
+			apperr.WriteJSON(w, errLoginTOTPInvalid)
+			return
+		}
+	}
 
-	token, err := auth.IssueToken(id)
+	token, err := auth.NewSession(ctx, id)
 	if err != nil {
-		http.Error(w, "token error", http.StatusInternalServerError)
+		apperr.WriteJSON(w, apperr.Internal("token error", err))
 		return
 	}
 
@@ -81,12 +114,22 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		HttpOnly: true,
 		Secure:   false,
 		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(72 * time.Hour),
+		Expires:  time.Now().Add(auth.SessionTTL),
 	})
+	if h.CSRFCookie {
+		middleware.IssueCSRFCookie(w)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie("session"); err == nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+		if err := auth.EndSession(ctx, c.Value); err != nil {
+			slog.Error("auth.logout.end_session", "err", err)
+		}
+	}
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session",
 		Value:    "",
@@ -97,6 +140,7 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		Expires:  time.Unix(0, 0),
 		MaxAge:   -1,
 	})
+	middleware.ClearCSRFCookie(w)
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
@@ -110,7 +154,7 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 		`select id, username, display_name, role from users where id = $1`, uid).
 		Scan(&resp.ID, &resp.Username, &resp.DisplayName, &resp.Role)
 	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		apperr.WriteJSON(w, errMeNotFound)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// AvatarHandler renders GET /avatars/{username}.svg: the user's initials
+// on a background picked from Palette by hashing the username. Nothing is
+// looked up, so unknown usernames get an avatar too.
+type AvatarHandler struct {
+	Palette []string
+}
+
+const avatarMaxUsername = 64
+
+// normalizeAvatarUsername trims and lower-cases name so every spelling of a
+// username gets the same avatar. ok is false for names that cannot be a
+// username.
+func normalizeAvatarUsername(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || utf8.RuneCountInString(name) > avatarMaxUsername {
+		return "", false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '_' && r != '-' {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// avatarInitials takes the first letter of up to two parts of name split on
+// . _ and -, or its first two letters when there is a single part.
+func avatarInitials(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '.' || r == '_' || r == '-' })
+	var out []rune
+	switch {
+	case len(parts) >= 2:
+		for _, p := range parts[:2] {
+			r, _ := utf8.DecodeRuneInString(p)
+			out = append(out, r)
+		}
+	case len(parts) == 1:
+		for _, r := range parts[0] {
+			if len(out) == 2 {
+				break
+			}
+			out = append(out, r)
+		}
+	default:
+		out = []rune{'?'}
+	}
+	return strings.ToUpper(string(out))
+}
+
+func avatarHash(s string) uint32 {
+	f := fnv.New32a()
+	_, _ = f.Write([]byte(s))
+	return f.Sum32()
+}
+
+func (h *AvatarHandler) color(name string) string {
+	if len(h.Palette) == 0 {
+		return "#6b7280"
+	}
+	return h.Palette[avatarHash(name)%uint32(len(h.Palette))]
+}
+
+func (h *AvatarHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	raw, found := strings.CutSuffix(file, ".svg")
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	name, ok := normalizeAvatarUsername(raw)
+	if !ok {
+		http.Error(w, "invalid username", http.StatusBadRequest)
+		return
+	}
+
+	var initials strings.Builder
+	_ = xml.EscapeText(&initials, []byte(avatarInitials(name)))
+	color := h.color(name)
+	etag := fmt.Sprintf(`"%08x"`, avatarHash(color+"|"+name))
+
+	w.Header().Set("Cache-Control", "public, max-age=2592000")
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="64" height="64" viewBox="0 0 64 64">`+
+		`<circle cx="32" cy="32" r="32" fill="%s"/>`+
+		`<text x="32" y="32" dy=".35em" text-anchor="middle" font-family="system-ui,sans-serif" font-size="26" font-weight="600" fill="#ffffff">%s</text>`+
+		`</svg>`, color, initials.String())
+}
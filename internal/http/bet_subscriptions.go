@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BetSubscribeHandler subscribes the caller to a bet's activity, or with
+// Subscribe unset drops the subscription.
+type BetSubscribeHandler struct {
+	DB        *pgxpool.Pool
+	Subscribe bool
+}
+
+func (h *BetSubscribeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	if uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	betID := r.PathValue("id")
+	if !looksLikeUUID(betID) {
+		http.NotFound(w, r)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	role, err := middleware.GetUserRole(ctx, h.DB, uid)
+	if err != nil || role == middleware.RoleUnverified {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	isMod := role == middleware.RoleModerator || role == middleware.RoleAdmin
+	if ok, err := canViewBet(ctx, h.DB, betID, uid, isMod); err != nil {
+		slog.Error("subscriptions.access", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	} else if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	note := "subscribed"
+	if h.Subscribe {
+		err = subscribeToBet(ctx, h.DB, betID, uid)
+	} else {
+		note = "unsubscribed"
+		_, err = h.DB.Exec(ctx, `delete from bet_subscriptions where bet_id = $1 and user_id = $2`, betID, uid)
+	}
+	if err != nil {
+		slog.Error("subscriptions.update", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/bets/"+betID+"?note="+note, http.StatusSeeOther)
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// subscribeToBet is idempotent; wagering and commenting call it too.
+func subscribeToBet(ctx context.Context, q execer, betID, uid string) error {
+	_, err := q.Exec(ctx, `
+		insert into bet_subscriptions (bet_id, user_id) values ($1, $2)
+		on conflict do nothing
+	`, betID, uid)
+	return err
+}
+
+// notifyBetSubscribers DMs msg to betID's subscribers except the users in
+// skip. When broadcast is set msg also went out through NotifySubscribers,
+// so users who get every announcement are skipped as well.
+func notifyBetSubscribers(ctx context.Context, db *pgxpool.Pool, n notify.Notifier, betID, msg string, broadcast bool, skip ...string) {
+	if n == nil {
+		return
+	}
+	if skip == nil {
+		skip = []string{}
+	}
+	rows, err := db.Query(ctx, `
+		select s.user_id::text
+		from bet_subscriptions s
+		join users u on u.id = s.user_id
+		where s.bet_id = $1::uuid
+		  and u.disabled_at is null
+		  and not (s.user_id::text = any($2))
+		  and not ($3 and u.telegram_notify and u.telegram_chat_id is not null)
+	`, betID, skip, broadcast)
+	if err != nil {
+		slog.Warn("subscriptions.notify_query", "err", err)
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			slog.Warn("subscriptions.notify_scan", "err", err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	for _, id := range ids {
+		n.NotifyUser(ctx, id, msg)
+	}
+}
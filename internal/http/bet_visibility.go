@@ -0,0 +1,122 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"betsandpedestres/internal/apperr"
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5"
+)
+
+// Bet visibility scopes, stored in bets.visibility.
+const (
+	visibilityPublic   = "public"   // listed in the feed
+	visibilityUnlisted = "unlisted" // anyone with the link, never listed
+	visibilityPrivate  = "private"  // creator and invitees only (moderators may look)
+)
+
+var (
+	errInvalidVisibility = apperr.BadRequest("visibility must be public, unlisted or private")
+	errInvalidInvitee    = apperr.BadRequest("invitees must be existing usernames")
+)
+
+func parseVisibility(raw string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(raw)); v {
+	case "":
+		return visibilityPublic, nil
+	case visibilityPublic, visibilityUnlisted, visibilityPrivate:
+		return v, nil
+	}
+	return "", errInvalidVisibility
+}
+
+// betParticipantSQL is a predicate over the bets row aliased b that holds
+// when the user bound to uidArg (a text placeholder, empty for anonymous) may
+// see and wager on it. Moderators are not included; callers add that.
+func betParticipantSQL(uidArg string) string {
+	return `(b.visibility <> 'private'
+	      or b.creator_user_id = nullif(` + uidArg + `,'')::uuid
+	      or exists (select 1 from bet_invitations bi
+	                 where bi.bet_id = b.id and bi.user_id = nullif(` + uidArg + `,'')::uuid))`
+}
+
+// canViewBet reports whether uid may see betID. Unknown bets are reported
+// as not viewable so callers answer 404 either way.
+func canViewBet(ctx context.Context, q rowQuerier, betID, uid string, isMod bool) (bool, error) {
+	var ok bool
+	err := q.QueryRow(ctx, `
+		select $3 or `+betParticipantSQL("$2")+`
+		from bets b where b.id = $1::uuid
+	`, betID, uid, isMod).Scan(&ok)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return ok, err
+}
+
+// betIsPublic reports whether betID may be announced to the group chat.
+// Lookup errors count as not public.
+func betIsPublic(ctx context.Context, q rowQuerier, betID string) bool {
+	var v string
+	if err := q.QueryRow(ctx, `select visibility::text from bets where id = $1::uuid`, betID).Scan(&v); err != nil {
+		return false
+	}
+	return v == visibilityPublic
+}
+
+// insertInvitations adds usernames to a bet's invite list, failing if any
+// of them does not exist. It returns the ids of users not invited before.
+func insertInvitations(ctx context.Context, tx pgx.Tx, betID, inviterID string, usernames []string) ([]string, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	lowered := make([]string, len(usernames))
+	for i, u := range usernames {
+		lowered[i] = strings.ToLower(u)
+	}
+	var found int
+	if err := tx.QueryRow(ctx, `
+		select count(*)::int from users where lower(username) = any($1::text[])
+	`, lowered).Scan(&found); err != nil {
+		return nil, err
+	}
+	if found != len(lowered) {
+		return nil, errInvalidInvitee
+	}
+	rows, err := tx.Query(ctx, `
+		insert into bet_invitations (bet_id, user_id, invited_by)
+		select $1::uuid, u.id, $2::uuid
+		from users u
+		where lower(u.username) = any($3::text[])
+		on conflict do nothing
+		returning user_id::text
+	`, betID, inviterID, lowered)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var added []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		added = append(added, id)
+	}
+	return added, rows.Err()
+}
+
+// notifyInvitees tells freshly invited users where to find the bet.
+func notifyInvitees(ctx context.Context, n notify.Notifier, userIDs []string, inviter, betTitle, link string) {
+	if n == nil {
+		return
+	}
+	msg := fmt.Sprintf("%s invited you to the bet \"%s\".\n%s", inviter, betTitle, link)
+	for _, id := range userIDs {
+		n.NotifyUser(ctx, id, msg)
+	}
+}
@@ -30,13 +30,30 @@ type betRecord struct {
 	Deadline        *time.Time
 	WinningOption   *string
 	Status          string
+	Blind           bool
+	Participants    int
+	Visibility      string
+	Participant     bool // may wager; see betParticipantSQL
+
+	// Folded into the same query to save round-trips on this hot page.
+	MyVote      *string // only loaded for moderators
+	VotesTotal  int
+	VotesAgree  bool
+	UserBalance int64
+	Rake        int64 // skimmed to the house at resolution
+	HasWagered  bool
+	MySuggest   *string // option the viewer suggested as the outcome
+	CreatedAt   time.Time
+	Subscribed  bool // viewer gets DMs about this bet's activity
+	MinWager    *int64
+	MaxWager    *int64
 }
 
 func (h *BetShowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	uid := middleware.UserID(r)
 
 	header, role := loadHeader(r.Context(), h.DB, uid)
-	if !header.LoggedIn || role == middleware.RoleUnverified {
+	if (!header.LoggedIn && !h.PublicBrowsing) || role == middleware.RoleUnverified {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -57,7 +74,7 @@ func (h *BetShowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	isMod := role == middleware.RoleModerator || role == middleware.RoleAdmin
 	isAdmin := role == middleware.RoleAdmin
 
-	bet, err := h.fetchBet(ctx, betID)
+	bet, err := h.fetchBet(ctx, betID, uid, isMod)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			http.NotFound(w, r)
@@ -67,13 +84,26 @@ func (h *BetShowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		slog.Error("db error", "error", err)
 		return
 	}
+	if !bet.Participant && !isMod {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Suggestions load alongside the rest of the page with their own short
+	// deadline; a slow or failed lookup only drops them.
+	similarCh := make(chan []betCard, 1)
+	go func() {
+		sctx, scancel := context.WithTimeout(ctx, time.Second)
+		defer scancel()
+		similarCh <- h.fetchSimilarBets(sctx, betID, bet.Title, bet.CreatorUsername, isMod)
+	}()
 
 	opts, total, err := h.fetchOptions(ctx, betID)
 	if err != nil {
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
-	myVote, votesTotal, votesAgree := h.voteInfo(ctx, betID, uid, isMod)
+	myVote, votesTotal, votesAgree := bet.MyVote, bet.VotesTotal, bet.VotesAgree
 	var myVoteLabel *string
 	if myVote != nil {
 		for i := range opts {
@@ -91,27 +121,114 @@ func (h *BetShowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	deadlineDefined := bet.Deadline != nil
 	resolutionAllowed := (bet.Deadline == nil || pastDeadline)
 	adminOverrideMode := modeAdmin && isAdmin && !alreadyClosed && waitingAdmin
-	resolutionMode := (modeResolve && isMod && !alreadyClosed && !waitingAdmin) || adminOverrideMode
+
+	resolvers, err := h.fetchResolvers(ctx, betID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	canResolve := isMod && len(resolvers) == 0
+	for _, rv := range resolvers {
+		if isMod && rv.UserID == uid {
+			canResolve = true
+		}
+	}
+	resolutionMode := (modeResolve && canResolve && !alreadyClosed && !waitingAdmin) || adminOverrideMode
+
+	canWager := header.LoggedIn && bet.Participant && !modeResolve && !alreadyClosed && !pastDeadline && votesTotal == 0
+	bettingOpensIn := ""
+	if opensAt := bet.CreatedAt.Add(h.WagerCooldown); !alreadyClosed && time.Now().Before(opensAt) {
+		canWager = false
+		bettingOpensIn = formatExpiresIn(&opensAt)
+	}
 
 	// compute user's max stake
+	minWager, maxWager := h.MinWager, h.MaxWager
+	if bet.MinWager != nil {
+		minWager = *bet.MinWager
+	}
+	if bet.MaxWager != nil {
+		maxWager = *bet.MaxWager
+	}
 	var maxStake int64
 	if header.LoggedIn {
-		maxStake = h.userBalance(ctx, uid)
+		maxStake = bet.UserBalance
+		if maxWager > 0 {
+			maxStake = min(maxStake, maxWager)
+		}
 	}
 
-	canWager := header.LoggedIn && !modeResolve && !alreadyClosed && !pastDeadline && votesTotal == 0
+	winningLabel := winningLabel(opts, bet.WinningOption)
+	payouts := computePayouts(opts, total, bet.Rake, bet.WinningOption, alreadyClosed)
+
+	stakesHidden := bet.Blind && !alreadyClosed && !isMod
+	if stakesHidden {
+		hideStakes(opts)
+		total = 0
+	}
+
+	quorum, err := effectiveQuorum(ctx, h.DB, h.Quorum, h.QuorumFraction)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if len(resolvers) > 0 {
+		quorum = min(quorum, len(resolvers))
+	}
+
+	canEdit := header.LoggedIn && (isAdmin || bet.CreatorUsername == header.Username) &&
+		bet.Status == "open" && bet.WinningOption == nil && bet.Participants == 0 && votesTotal == 0
+	canInvite := header.LoggedIn && !alreadyClosed && (isMod || bet.CreatorUsername == header.Username)
+	var invitees []resolverVM
+	if canInvite || bet.Visibility == visibilityPrivate {
+		invitees, err = h.fetchInvitees(ctx, betID)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var myWagers []myWagerVM
 	if canWager {
-		maxStake = h.userBalance(ctx, uid)
+		myWagers, err = h.fetchMyWagers(ctx, betID, uid)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
 	}
 
-	winningLabel := h.winningLabel(ctx, bet.WinningOption)
-	payouts := h.computePayouts(ctx, betID, bet.WinningOption, alreadyClosed)
+	var votes []resolutionVoteVM
+	if votesTotal > 0 && (isMod || h.PublicVotes) {
+		votes, err = h.fetchVotes(ctx, betID)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Bettors may hint at the outcome once it is due; moderators see the tally.
+	canSuggest := bet.HasWagered && !isMod && !alreadyClosed && (bet.Deadline == nil || bet.Deadline.Before(time.Now()))
+	var suggestions []suggestionVM
+	if isMod && !alreadyClosed {
+		suggestions, err = fetchSuggestionTally(ctx, h.DB, betID)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+	}
+	mySuggestion := ""
+	if bet.MySuggest != nil {
+		mySuggestion = *bet.MySuggest
+	}
 
-	comments, err := h.fetchComments(ctx, betID, uid)
+	comments, commentCount, err := h.fetchComments(ctx, betID, uid, isMod)
 	if err != nil {
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
+	if !header.LoggedIn {
+		readOnlyComments(comments)
+	}
 
 	content := betShowContent{
 		BetID:             betID,
@@ -124,9 +241,24 @@ func (h *BetShowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		TotalStakes:       total,
 		CreatorName:       bet.CreatorName,
 		CreatorUsername:   bet.CreatorUsername,
+		Blind:             bet.Blind,
+		StakesHidden:      stakesHidden,
+		Participants:      bet.Participants,
+		Visibility:        bet.Visibility,
+		Invitees:          invitees,
+		CanInvite:         canInvite,
+		CanEdit:           canEdit,
+		CanSubscribe:      header.LoggedIn && !alreadyClosed,
+		Subscribed:        bet.Subscribed,
+		InviteNotice:      inviteNotice(r.URL.Query().Get("invite")),
 		CanWager:          canWager,
+		BettingOpensIn:    bettingOpensIn,
 		MaxStake:          maxStake,
+		MinWager:          minWager,
+		MaxWager:          maxWager,
 		IdempotencyKey:    randomHex(16),
+		WagerNotice:       wagerNotice(r.URL.Query().Get("note")),
+		MyWagers:          myWagers,
 		ResolutionAllowed: resolutionAllowed,
 
 		IsModerator:         isMod,
@@ -139,16 +271,33 @@ func (h *BetShowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		WaitingForConsensus: waitingConsensus,
 		StatusLabel:         statusLabel,
 		VotesTotal:          votesTotal,
-		Quorum:              h.Quorum,
+		Quorum:              quorum,
 		MyVoteOptionID:      myVote,
 		MyVoteLabel:         myVoteLabel,
 		WinningOptionID:     bet.WinningOption,
 		WinningLabel:        winningLabel,
+		Resolvers:           resolvers,
+		CanResolve:          canResolve,
+		Votes:               votes,
+		CanSuggest:          canSuggest,
+		MySuggestionID:      mySuggestion,
+		Suggestions:         suggestions,
 		Payouts:             payouts,
+		Rake:                bet.Rake,
 		Comments:            comments,
+		CommentCount:        commentCount,
+		Similar:             <-similarCh,
+		CommentNotice:       commentNotice(r.URL.Query().Get("comment")),
+	}
+	if canWager && !alreadyClosed {
+		content.WagerNonce, err = issueWagerNonce(ctx, h.DB, uid, betID)
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
 	}
 
-	page := web.Page[betShowContent]{Header: header, Content: content}
+	page := web.Page[betShowContent]{Header: header, Meta: betMeta(h.BaseURL, betID, bet, opts), Content: content}
 
 	var buf bytes.Buffer
 	if err := h.TPL.Render(&buf, "bet_show", page); err != nil {
@@ -156,10 +305,102 @@ func (h *BetShowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "template error", http.StatusInternalServerError)
 		return
 	}
+	if bet.Visibility != visibilityPublic {
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	_, _ = w.Write(buf.Bytes())
 }
 
+// betMeta builds the link preview of a bet. Values are escaped by the
+// template; only what a logged-out viewer may see goes in.
+func betMeta(baseURL, betID string, bet betRecord, opts []betOptionVM) web.MetaData {
+	desc := ""
+	if bet.Description != nil {
+		desc = strings.Join(strings.Fields(*bet.Description), " ")
+	}
+	if desc == "" {
+		labels := make([]string, 0, len(opts))
+		for _, o := range opts {
+			labels = append(labels, o.Label)
+		}
+		desc = "Outcomes: " + strings.Join(labels, " / ")
+	}
+	return web.MetaData{
+		Title:       bet.Title,
+		Description: truncateRunes(desc, 200),
+		URL:         betLink(baseURL, betID),
+		Image:       assetLink(baseURL, "favicon.png"),
+	}
+}
+
+// readOnlyComments strips the actions a logged-out visitor cannot take.
+func readOnlyComments(list []commentVM) {
+	for i := range list {
+		list[i].CanReply = false
+		list[i].CanReact = false
+		readOnlyComments(list[i].Replies)
+	}
+}
+
+func wagerNotice(code string) string {
+	switch code {
+	case "placed":
+		return "Wager placed."
+	case "already_submitted":
+		return "This wager was already placed; it was not charged twice."
+	case "edited":
+		return "Bet updated."
+	case "options_normalized":
+		return "Some outcome labels were tidied up (spacing, casing or duplicates); they are shown below exactly as saved."
+	case "cancelled":
+		return "Bet cancelled; every wager was refunded."
+	case "suggested":
+		return "Thanks! Your outcome suggestion was passed on to the moderators."
+	case "suggestion_withdrawn":
+		return "Your outcome suggestion was withdrawn."
+	case "withdrawn":
+		return "Wager withdrawn; the stake is back in your wallet."
+	case "subscribed":
+		return "Subscribed: you'll get a message for new wagers, comments and the outcome."
+	case "unsubscribed":
+		return "Unsubscribed from this bet."
+	case "stale_form":
+		return "That wager form was already used or has expired, so nothing was wagered. Check the current stakes and your balance, then try again."
+	}
+	return ""
+}
+
+func inviteNotice(code string) string {
+	switch code {
+	case "sent":
+		return "Invitations sent."
+	case "missing":
+		return "Enter at least one username to invite."
+	case "unknown":
+		return "Some of those usernames do not exist; nobody was invited."
+	case "closed":
+		return "This bet is closed; invitations are no longer possible."
+	case "error":
+		return "Could not send invitations. Try again later."
+	}
+	return ""
+}
+
+func commentNotice(code string) string {
+	switch code {
+	case "too_deep":
+		return "This thread is too deep to reply to (max " + strconv.Itoa(maxCommentDepth) + " levels). Reply higher up instead."
+	case "bet_full":
+		return "This bet has reached its comment limit; no new comments can be posted."
+	case "thread_full":
+		return "This comment has reached its reply limit. Reply elsewhere in the thread instead."
+	case "deleted":
+		return "Comment deleted."
+	}
+	return ""
+}
+
 func gcd64(a, b int64) int64 {
 	if a < 0 {
 		a = -a
@@ -196,14 +437,40 @@ func computeRatio(a, b int64) string {
 	return strconv.FormatInt(a/g, 10) + ":" + strconv.FormatInt(b/g, 10)
 }
 
-func (h *BetShowHandler) fetchBet(ctx context.Context, betID string) (betRecord, error) {
+func (h *BetShowHandler) fetchBet(ctx context.Context, betID, uid string, isMod bool) (betRecord, error) {
 	var rec betRecord
 	err := h.DB.QueryRow(ctx, `
-  select b.title, u.display_name, u.username, b.description, b.external_url, b.deadline, b.resolution_option_id::text, b.status
+  with v as (
+    select option_id, count(*) as c
+    from bet_resolution_votes
+    where bet_id = $1::uuid
+    group by option_id
+  )
+  select b.title, u.display_name, u.username, b.description, b.external_url, b.deadline, b.resolution_option_id::text, b.status,
+         b.blind,
+         (select count(distinct user_id)::int from wagers where bet_id = $1::uuid) as participants,
+         b.visibility::text,
+         `+betParticipantSQL("$2")+` as participant,
+         case when $3 then (
+           select option_id::text from bet_resolution_votes
+           where bet_id = $1::uuid and user_id = nullif($2,'')::uuid
+         ) end as my_vote,
+         (select coalesce(sum(c),0)::int from v) as votes_total,
+         (select count(*) <= 1 from v) as votes_agree,
+         coalesce((select balance from user_balances where user_id = nullif($2,'')::uuid), 0)::bigint as user_balance,
+         b.rake,
+         exists (select 1 from wagers where bet_id = $1::uuid and user_id = nullif($2,'')::uuid) as has_wagered,
+         (select option_id::text from resolution_suggestions
+           where bet_id = $1::uuid and user_id = nullif($2,'')::uuid) as my_suggestion,
+         b.created_at,
+         exists (select 1 from bet_subscriptions where bet_id = $1::uuid and user_id = nullif($2,'')::uuid) as subscribed,
+         b.min_wager,
+         b.max_wager
   from bets b
   join users u on u.id = b.creator_user_id
   where b.id = $1::uuid
-`, betID).Scan(&rec.Title, &rec.CreatorName, &rec.CreatorUsername, &rec.Description, &rec.ExternalURL, &rec.Deadline, &rec.WinningOption, &rec.Status)
+`, betID, uid, isMod).Scan(&rec.Title, &rec.CreatorName, &rec.CreatorUsername, &rec.Description, &rec.ExternalURL, &rec.Deadline, &rec.WinningOption, &rec.Status, &rec.Blind, &rec.Participants, &rec.Visibility, &rec.Participant,
+		&rec.MyVote, &rec.VotesTotal, &rec.VotesAgree, &rec.UserBalance, &rec.Rake, &rec.HasWagered, &rec.MySuggest, &rec.CreatedAt, &rec.Subscribed, &rec.MinWager, &rec.MaxWager)
 	return rec, err
 }
 
@@ -213,11 +480,11 @@ func (h *BetShowHandler) fetchOptions(ctx context.Context, betID string) ([]betO
     bo.id::text,
     bo.label,
     coalesce( (select sum(w3.amount)::bigint from wagers w3 where w3.option_id = bo.id), 0 ) as stakes,
-    coalesce( array_agg(wl.display_name order by wl.amt desc)
+    coalesce( array_agg(wl.display_name order by wl.amt desc, wl.username)
               filter (where wl.display_name is not null), '{}' ) as bettor_names,
-    coalesce( array_agg(wl.username order by wl.amt desc)
+    coalesce( array_agg(wl.username order by wl.amt desc, wl.username)
               filter (where wl.username is not null), '{}' ) as bettor_usernames,
-    coalesce( array_agg(wl.amt        order by wl.amt desc)
+    coalesce( array_agg(wl.amt        order by wl.amt desc, wl.username)
               filter (where wl.display_name is not null), '{}' ) as bettor_amts
   from bet_options bo
   left join lateral (
@@ -226,7 +493,7 @@ func (h *BetShowHandler) fetchOptions(ctx context.Context, betID string) ([]betO
     join users u on u.id = w2.user_id
     where w2.option_id = bo.id
     group by u.display_name, u.username
-    order by amt desc
+    order by amt desc, u.username
   ) wl on true
   where bo.bet_id = $1::uuid
   group by bo.id, bo.label
@@ -277,35 +544,6 @@ func (h *BetShowHandler) fetchOptions(ctx context.Context, betID string) ([]betO
 	return opts, total, nil
 }
 
-func (h *BetShowHandler) voteInfo(ctx context.Context, betID, uid string, isMod bool) (*string, int, bool) {
-	var myVote *string
-	if isMod {
-		_ = h.DB.QueryRow(ctx, `
-        select option_id::text
-        from bet_resolution_votes
-        where bet_id = $1::uuid and user_id = $2::uuid
-    `, betID, uid).Scan(&myVote)
-	}
-	var votesTotal int
-	var votesAgree bool
-	err := h.DB.QueryRow(ctx, `
-    with v as (
-        select option_id, count(*) as c
-        from bet_resolution_votes
-        where bet_id = $1::uuid
-        group by option_id
-    )
-    select coalesce(sum(c),0) as total_votes,
-           case when count(*) <= 1 then true else false end as all_agree
-    from v
-`, betID).Scan(&votesTotal, &votesAgree)
-	if err != nil {
-		votesTotal = 0
-		votesAgree = true
-	}
-	return myVote, votesTotal, votesAgree
-}
-
 func determineStatus(deadline *time.Time, winning *string, status string, votesTotal int, votesAgree bool) (string, bool, bool, bool, bool) {
 	now := time.Now().UTC()
 	pastDeadline := (deadline != nil && deadline.Before(now) && (winning == nil) && status == "open")
@@ -315,6 +553,8 @@ func determineStatus(deadline *time.Time, winning *string, status string, votesT
 
 	statusLabel := "Open"
 	switch {
+	case status == "cancelled":
+		statusLabel = "Cancelled"
 	case alreadyClosed:
 		statusLabel = "Closed"
 	case waitingAdmin:
@@ -327,98 +567,157 @@ func determineStatus(deadline *time.Time, winning *string, status string, votesT
 	return statusLabel, alreadyClosed, pastDeadline, waitingAdmin, waitingConsensus
 }
 
-func (h *BetShowHandler) userBalance(ctx context.Context, uid string) int64 {
-	if uid == "" {
-		return 0
+// hideStakes blanks what a blind bet must not reveal before resolution.
+func hideStakes(opts []betOptionVM) {
+	for i := range opts {
+		opts[i].Stakes = 0
+		opts[i].Bettors = nil
+		opts[i].Ratio = "—"
+		opts[i].Percent = 0
 	}
-	var maxStake int64
-	_ = h.DB.QueryRow(ctx, `select coalesce(balance,0) from user_balances where user_id = $1`, uid).Scan(&maxStake)
-	return maxStake
 }
 
-func (h *BetShowHandler) winningLabel(ctx context.Context, winning *string) *string {
+func winningLabel(opts []betOptionVM, winning *string) *string {
 	if winning == nil {
 		return nil
 	}
-	var lbl string
-	if err := h.DB.QueryRow(ctx, `select label from bet_options where id = $1::uuid`, *winning).Scan(&lbl); err != nil {
-		return nil
+	for i := range opts {
+		if opts[i].ID == *winning {
+			lbl := opts[i].Label
+			return &lbl
+		}
 	}
-	return &lbl
+	return nil
 }
 
-func (h *BetShowHandler) computePayouts(ctx context.Context, betID string, winning *string, alreadyClosed bool) []payoutVM {
+// computePayouts mirrors the split done at resolution time, from the stakes
+// and per-user bettor totals already loaded with the options. rake is what
+// the house kept before the split. Bettors come in the same order as the
+// winners in payOutEscrow, so the rounding remainder lands on the same one.
+func computePayouts(opts []betOptionVM, escrowTotal, rake int64, winning *string, alreadyClosed bool) []payoutVM {
 	if !alreadyClosed || winning == nil {
 		return nil
 	}
-	var escrowTotal int64
-	_ = h.DB.QueryRow(ctx, `
-        select coalesce(sum(amount),0)::bigint from wagers where bet_id = $1::uuid
-    `, betID).Scan(&escrowTotal)
-
-	var winTotal int64
-	_ = h.DB.QueryRow(ctx, `
-        select coalesce(sum(amount),0)::bigint from wagers where bet_id = $1::uuid and option_id = $2::uuid
-    `, betID, *winning).Scan(&winTotal)
-
-	if winTotal == 0 || escrowTotal == 0 {
+	var win *betOptionVM
+	for i := range opts {
+		if opts[i].ID == *winning {
+			win = &opts[i]
+			break
+		}
+	}
+	if win == nil || win.Stakes == 0 || escrowTotal == 0 || len(win.Bettors) == 0 {
 		return nil
 	}
 
-	rowsP, err := h.DB.Query(ctx, `
-            select u.display_name, u.username, sum(w.amount)::bigint
-            from wagers w
-            join users u on u.id = w.user_id
-            where w.bet_id = $1::uuid and w.option_id = $2::uuid
-            group by u.display_name, u.username
-            order by sum(w.amount) desc
-        `, betID, *winning)
-	if err != nil {
-		return nil
+	stakes := make([]int64, len(win.Bettors))
+	for i, b := range win.Bettors {
+		stakes[i] = b.Amount
+	}
+	// The rake actually taken is recorded on the bet; split what is left.
+	_, shares := splitPot(escrowTotal-rake, 0, stakes)
+	payouts := make([]payoutVM, len(win.Bettors))
+	for i, b := range win.Bettors {
+		payouts[i] = payoutVM{Name: b.Name, Username: b.Username, Amount: shares[i]}
 	}
-	defer rowsP.Close()
+	return payouts
+}
 
-	var (
-		tmp []struct {
-			Name     string
-			Username string
-			Amt      int64
+func (h *BetShowHandler) fetchResolvers(ctx context.Context, betID string) ([]resolverVM, error) {
+	rows, err := h.DB.Query(ctx, `
+		select u.id::text, u.display_name, u.username
+		from bet_resolvers br
+		join users u on u.id = br.user_id
+		where br.bet_id = $1::uuid
+		order by u.display_name
+	`, betID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []resolverVM
+	for rows.Next() {
+		var rv resolverVM
+		if err := rows.Scan(&rv.UserID, &rv.Name, &rv.Username); err != nil {
+			return nil, err
 		}
-		payouts []payoutVM
-	)
-	for rowsP.Next() {
-		var (
-			name     string
-			username string
-			amt      int64
-		)
-		if err := rowsP.Scan(&name, &username, &amt); err != nil {
-			return nil
+		out = append(out, rv)
+	}
+	return out, rows.Err()
+}
+
+func (h *BetShowHandler) fetchMyWagers(ctx context.Context, betID, uid string) ([]myWagerVM, error) {
+	rows, err := h.DB.Query(ctx, `
+		select w.id::text, o.label, w.amount, w.created_at
+		from wagers w
+		join bet_options o on o.id = w.option_id
+		where w.bet_id = $1::uuid and w.user_id = $2::uuid
+		order by w.created_at desc
+	`, betID, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []myWagerVM
+	for rows.Next() {
+		var mw myWagerVM
+		if err := rows.Scan(&mw.ID, &mw.OptionLabel, &mw.Amount, &mw.CreatedAt); err != nil {
+			return nil, err
 		}
-		tmp = append(tmp, struct {
-			Name     string
-			Username string
-			Amt      int64
-		}{name, username, amt})
+		out = append(out, mw)
 	}
-	if rowsP.Err() != nil || len(tmp) == 0 {
-		return nil
+	return out, rows.Err()
+}
+
+func (h *BetShowHandler) fetchInvitees(ctx context.Context, betID string) ([]resolverVM, error) {
+	rows, err := h.DB.Query(ctx, `
+		select u.id::text, u.display_name, u.username
+		from bet_invitations bi
+		join users u on u.id = bi.user_id
+		where bi.bet_id = $1::uuid
+		order by u.display_name
+	`, betID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []resolverVM
+	for rows.Next() {
+		var rv resolverVM
+		if err := rows.Scan(&rv.UserID, &rv.Name, &rv.Username); err != nil {
+			return nil, err
+		}
+		out = append(out, rv)
 	}
+	return out, rows.Err()
+}
 
-	var distributed int64
-	for i, t := range tmp {
-		share := (escrowTotal * t.Amt) / winTotal
-		if i == len(tmp)-1 {
-			share = escrowTotal - distributed
-		} else {
-			distributed += share
+func (h *BetShowHandler) fetchVotes(ctx context.Context, betID string) ([]resolutionVoteVM, error) {
+	rows, err := h.DB.Query(ctx, `
+		select u.display_name, u.username, o.label, v.created_at
+		from bet_resolution_votes v
+		join users u on u.id = v.user_id
+		join bet_options o on o.id = v.option_id
+		where v.bet_id = $1::uuid
+		order by v.created_at, u.username
+	`, betID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var votes []resolutionVoteVM
+	for rows.Next() {
+		var v resolutionVoteVM
+		if err := rows.Scan(&v.ModeratorName, &v.ModeratorUsername, &v.OptionLabel, &v.CreatedAt); err != nil {
+			return nil, err
 		}
-		payouts = append(payouts, payoutVM{Name: t.Name, Username: t.Username, Amount: share})
+		votes = append(votes, v)
 	}
-	return payouts
+	return votes, rows.Err()
 }
 
-func (h *BetShowHandler) fetchComments(ctx context.Context, betID, uid string) ([]commentVM, error) {
+// fetchComments returns the comment tree of a bet and the total number of
+// comments, replies included.
+func (h *BetShowHandler) fetchComments(ctx context.Context, betID, uid string, isMod bool) ([]commentVM, int, error) {
 	rows, err := h.DB.Query(ctx, `
 		select
 			c.id::text,
@@ -426,6 +725,9 @@ func (h *BetShowHandler) fetchComments(ctx context.Context, betID, uid string) (
 			c.upvotes,
 			c.downvotes,
 			c.created_at,
+			c.edited_at,
+			c.deleted_at is not null,
+			c.user_id::text = $2,
 			u.display_name,
 			u.username,
 			coalesce(cr.value, 0) as my_reaction,
@@ -438,7 +740,7 @@ func (h *BetShowHandler) fetchComments(ctx context.Context, betID, uid string) (
 		order by (c.upvotes - c.downvotes) desc, c.created_at desc
 	`, betID, uid)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -448,9 +750,12 @@ func (h *BetShowHandler) fetchComments(ctx context.Context, betID, uid string) (
 		var reaction int32
 		var username *string
 		var parent *string
-		if err := rows.Scan(&c.ID, &c.Content, &c.Upvotes, &c.Downvotes, &c.CreatedAt, &c.AuthorName, &username, &reaction, &c.Score, &parent); err != nil {
-			return nil, err
+		var mine bool
+		if err := rows.Scan(&c.ID, &c.Content, &c.Upvotes, &c.Downvotes, &c.CreatedAt, &c.EditedAt, &c.Deleted, &mine, &c.AuthorName, &username, &reaction, &c.Score, &parent); err != nil {
+			return nil, 0, err
 		}
+		c.CanEdit = mine && !c.Deleted
+		c.CanDelete = (mine || isMod) && !c.Deleted
 		c.BetID = betID
 		c.AuthorUsername = username
 		c.MyReaction = int(reaction)
@@ -458,7 +763,7 @@ func (h *BetShowHandler) fetchComments(ctx context.Context, betID, uid string) (
 		comments = append(comments, c)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	root := make([]commentVM, 0, len(comments))
 	children := make(map[string][]commentVM)
@@ -473,11 +778,13 @@ func (h *BetShowHandler) fetchComments(ctx context.Context, betID, uid string) (
 	attach = func(list []commentVM, depth int) []commentVM {
 		for i := range list {
 			list[i].Depth = depth
+			list[i].CanReply = depth < maxCommentDepth
+			list[i].CanReact = !list[i].Deleted
 			if kids, ok := children[list[i].ID]; ok {
 				list[i].Replies = attach(kids, depth+1)
 			}
 		}
 		return list
 	}
-	return attach(root, 0), nil
+	return attach(root, 0), len(comments), nil
 }
@@ -0,0 +1,245 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"betsandpedestres/internal/http/middleware"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BetsAPIHandler serves the bet feed as JSON. It takes the home page's
+// page, size, sort, user, p, exp and archived parameters.
+type BetsAPIHandler struct {
+	DB *pgxpool.Pool // may be a read replica
+}
+
+// BetAPIHandler serves one bet as JSON, with the bet page's access rules.
+type BetAPIHandler struct {
+	Show *BetShowHandler
+}
+
+type apiBetCreator struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+type apiBetOptionSummary struct {
+	Label   string `json:"label"`
+	Percent int    `json:"percent"`
+}
+
+type apiBetSummary struct {
+	ID              string                `json:"id"`
+	Title           string                `json:"title"`
+	Creator         apiBetCreator         `json:"creator"`
+	CreatedAt       time.Time             `json:"created_at"`
+	Deadline        *time.Time            `json:"deadline"`
+	Status          string                `json:"status"`
+	StatusLabel     string                `json:"status_label"`
+	Stakes          int64                 `json:"stakes"`
+	StakesHidden    bool                  `json:"stakes_hidden"`
+	Participants    int64                 `json:"participants"`
+	Comments        int64                 `json:"comments"`
+	WinningOptionID *string               `json:"winning_option_id"`
+	Archived        bool                  `json:"archived"`
+	Options         []apiBetOptionSummary `json:"options"`
+}
+
+type apiBetList struct {
+	Bets []apiBetSummary `json:"bets"`
+	Page int             `json:"page"`
+	Size int             `json:"size"`
+}
+
+type apiBetOption struct {
+	ID      string `json:"id"`
+	Label   string `json:"label"`
+	Stakes  int64  `json:"stakes"`
+	Bettors int    `json:"bettors"`
+	Percent int    `json:"percent"`
+}
+
+type apiBetResolution struct {
+	WinningOptionID *string        `json:"winning_option_id"`
+	WinningLabel    *string        `json:"winning_label"`
+	VotesTotal      int            `json:"votes_total"`
+	VotesAgree      bool           `json:"votes_agree"`
+	Rake            int64          `json:"rake"`
+	Payouts         []apiBetPayout `json:"payouts,omitempty"`
+}
+
+type apiBetPayout struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Amount   int64  `json:"amount"`
+}
+
+type apiBetDetail struct {
+	ID           string           `json:"id"`
+	Title        string           `json:"title"`
+	Description  *string          `json:"description"`
+	ExternalURL  *string          `json:"external_url"`
+	Creator      apiBetCreator    `json:"creator"`
+	Deadline     *time.Time       `json:"deadline"`
+	Visibility   string           `json:"visibility"`
+	Blind        bool             `json:"blind"`
+	Status       string           `json:"status"`
+	StatusLabel  string           `json:"status_label"`
+	TotalEscrow  int64            `json:"total_escrow"`
+	StakesHidden bool             `json:"stakes_hidden"`
+	Participants int              `json:"participants"`
+	Options      []apiBetOption   `json:"options"`
+	Resolution   apiBetResolution `json:"resolution"`
+}
+
+// apiViewerRole returns the caller's role, or writes an error and returns
+// false when the caller is unverified. RequireAuth already ensured a user.
+func apiViewerRole(ctx context.Context, w http.ResponseWriter, db *pgxpool.Pool, uid string) (string, bool) {
+	role, err := middleware.GetUserRole(ctx, db, uid)
+	if err != nil || role == middleware.RoleUnverified {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return "", false
+	}
+	return role, true
+}
+
+func (h *BetsAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	role, ok := apiViewerRole(ctx, w, h.DB, uid)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	lq := parseBetListQuery(q)
+	isMod := role == middleware.RoleModerator || role == middleware.RoleAdmin
+	list, hasNext, err := listBets(ctx, h.DB, uid, isMod, lq)
+	if err != nil {
+		slog.Error("api.bets.list", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	out := apiBetList{Bets: make([]apiBetSummary, 0, len(list)), Page: lq.Page, Size: lq.Size}
+	for _, bc := range list {
+		s := apiBetSummary{
+			ID:              bc.ID,
+			Title:           bc.Title,
+			Creator:         apiBetCreator{Username: bc.CreatorUser, Name: bc.CreatorName},
+			CreatedAt:       bc.CreatedAt,
+			Deadline:        bc.Deadline,
+			Status:          bc.Status,
+			StatusLabel:     bc.StatusLabel,
+			Stakes:          bc.Stakes,
+			StakesHidden:    bc.StakesHidden,
+			Participants:    bc.Participants,
+			Comments:        bc.Comments,
+			WinningOptionID: bc.WinningOption,
+			Archived:        bc.Archived,
+			Options:         make([]apiBetOptionSummary, 0, len(bc.Options)),
+		}
+		for _, o := range bc.Options {
+			s.Options = append(s.Options, apiBetOptionSummary{Label: o.Label, Percent: o.Percent})
+		}
+		out.Bets = append(out.Bets, s)
+	}
+
+	page := listPage{Total: -1}
+	if hasNext {
+		page.Next = withQuery(q, "page", strconv.Itoa(lq.Page+1))
+	}
+	if lq.Page > 1 {
+		page.Prev = withQuery(q, "page", strconv.Itoa(lq.Page-1))
+	}
+	setPaginationHeaders(w, r, page)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func (h *BetAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := h.Show
+	uid := middleware.UserID(r)
+	betID := r.PathValue("id")
+	if !looksLikeUUID(betID) {
+		http.NotFound(w, r)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	role, ok := apiViewerRole(ctx, w, s.DB, uid)
+	if !ok {
+		return
+	}
+	isMod := role == middleware.RoleModerator || role == middleware.RoleAdmin
+	bet, err := s.fetchBet(ctx, betID, uid, isMod)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			http.NotFound(w, r)
+			return
+		}
+		slog.Error("api.bet.fetch", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if !bet.Participant && !isMod {
+		http.NotFound(w, r)
+		return
+	}
+	opts, total, err := s.fetchOptions(ctx, betID)
+	if err != nil {
+		slog.Error("api.bet.options", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	statusLabel, alreadyClosed, _, _, _ := determineStatus(bet.Deadline, bet.WinningOption, bet.Status, bet.VotesTotal, bet.VotesAgree)
+	res := apiBetResolution{
+		WinningOptionID: bet.WinningOption,
+		WinningLabel:    winningLabel(opts, bet.WinningOption),
+		VotesTotal:      bet.VotesTotal,
+		VotesAgree:      bet.VotesAgree,
+		Rake:            bet.Rake,
+	}
+	for _, p := range computePayouts(opts, total, bet.Rake, bet.WinningOption, alreadyClosed) {
+		res.Payouts = append(res.Payouts, apiBetPayout{Username: p.Username, Name: p.Name, Amount: p.Amount})
+	}
+
+	stakesHidden := bet.Blind && !alreadyClosed && !isMod
+	if stakesHidden {
+		hideStakes(opts)
+		total = 0
+	}
+	out := apiBetDetail{
+		ID:           betID,
+		Title:        bet.Title,
+		Description:  bet.Description,
+		ExternalURL:  bet.ExternalURL,
+		Creator:      apiBetCreator{Username: bet.CreatorUsername, Name: bet.CreatorName},
+		Deadline:     bet.Deadline,
+		Visibility:   bet.Visibility,
+		Blind:        bet.Blind,
+		Status:       bet.Status,
+		StatusLabel:  statusLabel,
+		TotalEscrow:  total,
+		StakesHidden: stakesHidden,
+		Participants: bet.Participants,
+		Options:      make([]apiBetOption, 0, len(opts)),
+		Resolution:   res,
+	}
+	for _, o := range opts {
+		out.Options = append(out.Options, apiBetOption{ID: o.ID, Label: o.Label, Stakes: o.Stakes, Bettors: len(o.Bettors), Percent: o.Percent})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
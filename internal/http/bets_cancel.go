@@ -0,0 +1,184 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/apperr"
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/db"
+	"betsandpedestres/internal/http/middleware"
+	"github.com/jackc/pgx/v5"
+)
+
+var errBetAlreadyClosed = apperr.Conflict("bet is already closed")
+
+func (h *BetCancelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	if uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	betID := r.PathValue("id")
+	if !looksLikeUUID(betID) {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	role, err := middleware.GetUserRole(ctx, h.DB, uid)
+	if err != nil || role != middleware.RoleAdmin {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(r.Form.Get("reason"))
+	if len([]rune(reason)) > 200 {
+		http.Error(w, "reason too long", http.StatusBadRequest)
+		return
+	}
+
+	var (
+		betTitle string
+		refunds  []userPayout
+	)
+	// Refunds move money: run serializable like resolution, so a cancel
+	// cannot interleave with a payout on the same bet.
+	err = db.RunSerializable(ctx, h.DB, func(tx pgx.Tx) error {
+		var err error
+		betTitle, refunds, err = cancelBetTx(ctx, tx, uid, betID, reason)
+		return err
+	})
+	if err != nil {
+		var ae *apperr.Error
+		if !errors.As(err, &ae) {
+			err = apperr.Internal("db error", err)
+		}
+		apperr.Write(w, err)
+		return
+	}
+
+	h.sendNotifications(ctx, betID, betTitle, reason, refunds)
+	http.Redirect(w, r, "/bets/"+betID+"?note=cancelled", http.StatusSeeOther)
+}
+
+// cancelBetTx marks an open bet cancelled and hands every bettor back what
+// they staked, all in one balanced transaction. It returns the bet's title
+// and the refund per user.
+func cancelBetTx(ctx context.Context, tx pgx.Tx, adminID, betID, reason string) (string, []userPayout, error) {
+	var (
+		title string
+		open  bool
+	)
+	err := tx.QueryRow(ctx, `
+		select b.title, b.status = 'open' and b.resolution_option_id is null
+		from bets b
+		where b.id = $1
+		for update of b
+	`, betID).Scan(&title, &open)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, apperr.New(http.StatusNotFound, "bet not found")
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if !open {
+		return "", nil, errBetAlreadyClosed
+	}
+
+	rows, err := tx.Query(ctx, `
+	  select w.user_id::text, coalesce(u.display_name, ''), sum(w.amount)::bigint
+	  from wagers w
+	  join users u on u.id = w.user_id
+	  where w.bet_id = $1::uuid
+	  group by w.user_id, u.display_name
+	`, betID)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	var refunds []userPayout
+	for rows.Next() {
+		var p userPayout
+		if err := rows.Scan(&p.UserID, &p.DisplayName, &p.Amount); err != nil {
+			return "", nil, err
+		}
+		refunds = append(refunds, p)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+
+	if len(refunds) > 0 {
+		escrowAcctID, err := ensureBetEscrowAccount(ctx, tx, betID)
+		if err != nil {
+			return "", nil, err
+		}
+		var txID string
+		if err := tx.QueryRow(ctx, `insert into transactions (reason, bet_id, note) values ('BET', $1::uuid, 'bet cancelled – refund') returning id::text`, betID).Scan(&txID); err != nil {
+			return "", nil, err
+		}
+		for _, p := range refunds {
+			var wallet string
+			if err := tx.QueryRow(ctx, `select id::text from accounts where user_id = $1::uuid and is_default`, p.UserID).Scan(&wallet); err != nil {
+				return "", nil, err
+			}
+			// ledger: escrow -> bettor
+			if _, err := tx.Exec(ctx, `
+			  insert into ledger_entries (tx_id, account_id, delta)
+			  values ($1, $2, $4), ($1, $3, $5)
+			`, txID, escrowAcctID, wallet, -p.Amount, p.Amount); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		update bets set status = 'cancelled', resolved_at = now() at time zone 'utc' where id = $1
+	`, betID); err != nil {
+		return "", nil, err
+	}
+	note := "bet " + betID
+	if reason != "" {
+		note += ": " + reason
+	}
+	if _, err := tx.Exec(ctx, `
+		insert into admin_actions (admin_user_id, action, note) values ($1::uuid, 'bet_cancel', $2)
+	`, adminID, note); err != nil {
+		return "", nil, err
+	}
+	return title, refunds, nil
+}
+
+func (h *BetCancelHandler) sendNotifications(ctx context.Context, betID, betTitle, reason string, refunds []userPayout) {
+	if h.Notifier == nil {
+		return
+	}
+	link := betLink(h.BaseURL, betID)
+	why := ""
+	if reason != "" {
+		why = " (" + reason + ")"
+	}
+	var total int64
+	for _, p := range refunds {
+		total += p.Amount
+		h.Notifier.NotifyUser(ctx, p.UserID, fmt.Sprintf("Bet \"%s\" was cancelled by an admin%s. Your 🦶 %s PiedPièces were refunded.\n%s",
+			betTitle, why, coins.Format(p.Amount), link))
+	}
+	if betIsPublic(ctx, h.DB, betID) {
+		msg := fmt.Sprintf("🚫 Bet \"%s\" was cancelled%s. %d bettor(s) refunded, 🦶 %s PiedPièces in total.\n%s",
+			betTitle, why, len(refunds), coins.Format(total), link)
+		h.Notifier.NotifyGroup(ctx, msg)
+		h.Notifier.NotifySubscribers(ctx, msg)
+	}
+}
@@ -8,14 +8,21 @@ import (
 	"html"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/apperr"
+	"betsandpedestres/internal/coins"
 	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/metrics"
 	"betsandpedestres/internal/notify"
 	"betsandpedestres/internal/web"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
 )
 
 func (h *BetNewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -29,7 +36,7 @@ func (h *BetNewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	page := web.Page[betNewContent]{
 		Header:  header,
-		Content: betNewContent{Title: "Create a new bet"},
+		Content: betNewContent{Title: "Create a new bet", CreationFee: h.CreationFee},
 	}
 
 	var buf bytes.Buffer
@@ -45,12 +52,28 @@ type BetCreateHandler struct {
 	DB       *pgxpool.Pool
 	Notifier notify.Notifier
 	BaseURL  string
+
+	// DeadlineHorizon caps how far ahead a deadline may be; 0 means no cap.
+	DeadlineHorizon time.Duration
+	// MinAccountAge is how old an account must be to create bets; 0 is off.
+	MinAccountAge time.Duration
+	// TitleCaseOptions title-cases outcome labels on top of the usual cleanup.
+	TitleCaseOptions bool
+	// CreationFee (minor units) is charged to the creator and paid to the
+	// house with every new bet; 0 is free.
+	CreationFee int64
 }
 
 var (
-	errMissingTitle    = errors.New("title is required")
-	errInvalidOptions  = errors.New("bet must have 2 to 10 distinct outcomes")
-	errInvalidDeadline = errors.New("invalid deadline")
+	errMissingTitle    = apperr.BadRequest("title is required")
+	errInvalidMinWager = apperr.BadRequest("minimum wager must be a positive amount")
+	errInvalidMaxWager = apperr.BadRequest("maximum wager must be a positive amount")
+	errWagerLimitOrder = apperr.BadRequest("minimum wager must not exceed the maximum")
+	errInvalidOptions  = apperr.BadRequest("bet must have 2 to 10 distinct outcomes")
+	errInvalidDeadline = apperr.BadRequest("invalid deadline")
+	errDeadlinePast    = apperr.BadRequest("deadline is in the past; pick a future date")
+	errInvalidResolver = apperr.BadRequest("resolvers must be usernames of moderators")
+	errInvalidURL      = apperr.BadRequest("external link must be an http(s) URL")
 )
 
 type betForm struct {
@@ -59,6 +82,17 @@ type betForm struct {
 	ExternalURL string
 	Deadline    *time.Time
 	Options     []string
+	// OptionsChanged reports that normalization altered or dropped some of
+	// the submitted labels, so the creator can be told.
+	OptionsChanged bool
+	Resolvers      []string // usernames; empty lets any moderator resolve
+	Blind          bool     // hide stakes from non-moderators until resolution
+	Visibility     string   // visibilityPublic, visibilityUnlisted or visibilityPrivate
+	Invitees       []string // usernames to invite; the only outsiders allowed on a private bet
+	// MinWager and MaxWager bound a single wager (minor units); nil falls
+	// back to the site-wide limits.
+	MinWager *int64
+	MaxWager *int64
 }
 
 func (h *BetCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -78,48 +112,60 @@ func (h *BetCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if err := checkAccountAge(ctx, h.DB, uid, role, h.MinAccountAge, "create bets"); err != nil {
+		apperr.Write(w, err)
+		return
+	}
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "bad form", http.StatusBadRequest)
 		return
 	}
 
-	form, err := parseBetForm(r)
+	form, err := parseBetForm(r, h.TitleCaseOptions)
+	if err == nil {
+		err = validateDeadline(form.Deadline, time.Now().UTC(), h.DeadlineHorizon)
+	}
 	if err != nil {
-		switch {
-		case errors.Is(err, errMissingTitle),
-			errors.Is(err, errInvalidOptions),
-			errors.Is(err, errInvalidDeadline):
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		default:
-			http.Error(w, "bad form", http.StatusBadRequest)
-		}
+		apperr.Write(w, err)
 		return
 	}
 
 	ctxCreate, cancelCreate := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancelCreate()
 
-	betID, err := h.createBet(ctxCreate, uid, form)
+	betID, invited, err := h.createBet(ctxCreate, uid, form)
 	if err != nil {
-		slog.Error("create bet error", "error", err)
-		http.Error(w, "db error", http.StatusInternalServerError)
+		var ae *apperr.Error
+		if !errors.As(err, &ae) {
+			err = apperr.Internal("db error", err)
+		}
+		apperr.Write(w, err)
 		return
 	}
+	metrics.BetsCreated.Inc()
 
 	if h.Notifier != nil {
 		link := betLink(h.BaseURL, betID)
-		author := fetchDisplayName(ctx, h.DB, uid)
-		message := formatNewBetGroupMessage(form, author, link)
-		h.Notifier.NotifyGroup(r.Context(), message)
-		h.Notifier.NotifySubscribers(r.Context(), message)
+		if form.Visibility == visibilityPublic {
+			author := fetchDisplayName(ctx, h.DB, uid)
+			message := formatNewBetGroupMessage(form, author, link)
+			h.Notifier.NotifyGroup(r.Context(), message)
+			h.Notifier.NotifySubscribers(r.Context(), message)
+			notifyFollowers(r.Context(), h.DB, h.Notifier, uid, author, form.Title, link)
+		}
 		h.Notifier.NotifyUser(r.Context(), uid, fmt.Sprintf("Your bet \"%s\" is live!\n%s", form.Title, link))
+		notifyInvitees(r.Context(), h.Notifier, invited, fetchDisplayName(ctx, h.DB, uid), form.Title, link)
 	}
 
-	// Redirect to bet page
-	http.Redirect(w, r, "/bets/"+betID, http.StatusSeeOther)
+	// Redirect to bet page, where the labels show up as stored.
+	dest := "/bets/" + betID
+	if form.OptionsChanged {
+		dest += "?note=options_normalized"
+	}
+	http.Redirect(w, r, dest, http.StatusSeeOther)
 }
 
-func parseBetForm(r *http.Request) (betForm, error) {
+func parseBetForm(r *http.Request, titleCaseOptions bool) (betForm, error) {
 	form := betForm{
 		Title:       strings.TrimSpace(r.Form.Get("title")),
 		Description: strings.TrimSpace(r.Form.Get("description")),
@@ -128,12 +174,30 @@ func parseBetForm(r *http.Request) (betForm, error) {
 	if form.Title == "" {
 		return betForm{}, errMissingTitle
 	}
+	if err := validateExternalURL(form.ExternalURL); err != nil {
+		return betForm{}, err
+	}
 
-	opts, err := collectOptions(r.Form["option"])
+	opts, changed, err := collectOptions(r.Form["option"], titleCaseOptions)
 	if err != nil {
 		return betForm{}, err
 	}
-	form.Options = opts
+	form.Options, form.OptionsChanged = opts, changed
+	form.Resolvers = collectResolvers(r.Form.Get("resolvers"))
+	form.Blind = r.Form.Get("blind") != ""
+	if form.Visibility, err = parseVisibility(r.Form.Get("visibility")); err != nil {
+		return betForm{}, err
+	}
+	form.Invitees = collectResolvers(r.Form.Get("invitees"))
+	if form.MinWager, err = parseWagerLimit(r.Form.Get("min_wager"), errInvalidMinWager); err != nil {
+		return betForm{}, err
+	}
+	if form.MaxWager, err = parseWagerLimit(r.Form.Get("max_wager"), errInvalidMaxWager); err != nil {
+		return betForm{}, err
+	}
+	if form.MinWager != nil && form.MaxWager != nil && *form.MinWager > *form.MaxWager {
+		return betForm{}, errWagerLimitOrder
+	}
 
 	deadlineLocal := strings.TrimSpace(r.Form.Get("deadline_local"))
 	deadlineUTC := strings.TrimSpace(r.Form.Get("deadline_utc"))
@@ -146,25 +210,97 @@ func parseBetForm(r *http.Request) (betForm, error) {
 	return form, nil
 }
 
-func collectOptions(raw []string) ([]string, error) {
-	opts := make([]string, 0, len(raw))
+// parseWagerLimit reads an optional per-bet wager bound; blank is nil.
+func parseWagerLimit(raw string, invalid error) (*int64, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	v, err := coins.Parse(raw)
+	if err != nil || v <= 0 {
+		return nil, invalid
+	}
+	return &v, nil
+}
+
+func collectOptions(raw []string, titleCase bool) (opts []string, changed bool, err error) {
+	opts = make([]string, 0, len(raw))
 	seen := make(map[string]struct{}, len(raw))
 	for _, o := range raw {
-		o = strings.TrimSpace(o)
-		if o == "" {
+		label := normalizeOptionLabel(o, titleCase)
+		if label == "" {
 			continue
 		}
-		key := strings.ToLower(o)
+		if label != strings.TrimSpace(o) {
+			changed = true
+		}
+		key := optionFold.String(label)
 		if _, exists := seen[key]; exists {
+			changed = true
 			continue
 		}
 		seen[key] = struct{}{}
-		opts = append(opts, o)
+		opts = append(opts, label)
 	}
 	if len(opts) < 2 || len(opts) > 10 {
-		return nil, errInvalidOptions
+		return nil, false, errInvalidOptions
+	}
+	return opts, changed, nil
+}
+
+var (
+	// optionFold compares labels with full Unicode case folding, so "STRASSE"
+	// and "straße" or "ΣΟΦΟΣ" and "σοφος" count as the same outcome.
+	optionFold  = cases.Fold()
+	optionTitle = cases.Title(language.Und)
+)
+
+// normalizeOptionLabel trims a label and collapses inner whitespace runs to
+// single spaces, optionally title-casing it.
+func normalizeOptionLabel(s string, titleCase bool) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if titleCase {
+		s = optionTitle.String(s)
+	}
+	return s
+}
+
+// validateExternalURL accepts an empty value or an absolute http(s) URL with
+// a host. The link is rendered to every visitor, so other schemes
+// (javascript:, data:, ...) are refused.
+func validateExternalURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errInvalidURL
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return nil
+	}
+	return errInvalidURL
+}
+
+// collectResolvers splits a comma or space separated list of usernames,
+// dropping blanks and duplicates.
+func collectResolvers(raw string) []string {
+	fields := strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' })
+	out := make([]string, 0, len(fields))
+	seen := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		f = strings.TrimPrefix(strings.TrimSpace(f), "@")
+		if f == "" {
+			continue
+		}
+		key := strings.ToLower(f)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, f)
 	}
-	return opts, nil
+	return out
 }
 
 func parseDeadline(localValue, fallbackUTC, tz string) (*time.Time, error) {
@@ -193,6 +329,24 @@ func parseDeadline(localValue, fallbackUTC, tz string) (*time.Time, error) {
 	return nil, errInvalidDeadline
 }
 
+// validateDeadline rejects deadlines that are not after now (the bet could
+// never be wagered on) or further than horizon from now. The forms take
+// their minimum from the server's clock, so a drifting browser clock is
+// caught there rather than tolerated here.
+func validateDeadline(deadline *time.Time, now time.Time, horizon time.Duration) error {
+	if deadline == nil {
+		return nil
+	}
+	if !deadline.After(now) {
+		return errDeadlinePast
+	}
+	if horizon > 0 && deadline.After(now.Add(horizon)) {
+		return apperr.Wrap(http.StatusBadRequest,
+			fmt.Sprintf("deadline must be within %d days", int(horizon.Hours()/24)), errInvalidDeadline)
+	}
+	return nil
+}
+
 func parseLocalDeadline(value, tz string) (time.Time, error) {
 	loc, err := time.LoadLocation(tz)
 	if err != nil {
@@ -214,10 +368,10 @@ func parseLocalDeadline(value, tz string) (time.Time, error) {
 	return time.Time{}, errInvalidDeadline
 }
 
-func (h *BetCreateHandler) createBet(ctx context.Context, uid string, form betForm) (string, error) {
+func (h *BetCreateHandler) createBet(ctx context.Context, uid string, form betForm) (string, []string, error) {
 	tx, err := h.DB.Begin(ctx)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	defer func() {
 		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
@@ -227,15 +381,59 @@ func (h *BetCreateHandler) createBet(ctx context.Context, uid string, form betFo
 
 	betID, err := h.insertBet(ctx, tx, uid, form)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	if err := insertBetOptions(ctx, tx, betID, form.Options); err != nil {
+		return "", nil, err
+	}
+	if err := h.insertResolvers(ctx, tx, betID, form.Resolvers); err != nil {
+		return "", nil, err
+	}
+	invited, err := insertInvitations(ctx, tx, betID, uid, form.Invitees)
+	if err != nil {
+		return "", nil, err
 	}
-	if err := h.insertOptions(ctx, tx, betID, form.Options); err != nil {
-		return "", err
+	if err := subscribeToBet(ctx, tx, betID, uid); err != nil {
+		return "", nil, err
+	}
+	if h.CreationFee > 0 {
+		if err := chargeCreationFee(ctx, tx, betID, uid, h.CreationFee); err != nil {
+			return "", nil, err
+		}
 	}
 	if err := tx.Commit(ctx); err != nil {
-		return "", err
+		return "", nil, err
 	}
-	return betID, nil
+	return betID, invited, nil
+}
+
+// chargeCreationFee moves fee from the creator's wallet to the house, tied
+// to the new bet so it shows up in both ledgers.
+func chargeCreationFee(ctx context.Context, tx pgx.Tx, betID, uid string, fee int64) error {
+	var avail int64
+	if err := tx.QueryRow(ctx, `select coalesce(balance,0) from user_balances where user_id = $1::uuid`, uid).Scan(&avail); err != nil {
+		return err
+	}
+	if fee > avail {
+		return apperr.Forbidden("insufficient balance: creating a bet costs " + coins.Format(fee))
+	}
+	var wallet string
+	if err := tx.QueryRow(ctx, `select id::text from accounts where user_id = $1::uuid and is_default`, uid).Scan(&wallet); err != nil {
+		return err
+	}
+	houseAcct, err := accounts.EnsureHouseAccount(ctx, tx)
+	if err != nil {
+		return err
+	}
+	var txID string
+	if err := tx.QueryRow(ctx, `insert into transactions (reason, bet_id, note) values ('BET', $1::uuid, 'creation fee') returning id::text`, betID).Scan(&txID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+	  insert into ledger_entries (tx_id, account_id, delta)
+	  values ($1, $2, $4), ($1, $3, $5)
+	`, txID, wallet, houseAcct, -fee, fee)
+	return err
 }
 
 func fetchDisplayName(ctx context.Context, db *pgxpool.Pool, uid string) string {
@@ -296,21 +494,48 @@ func truncateRunes(s string, max int) string {
 func (h *BetCreateHandler) insertBet(ctx context.Context, tx pgx.Tx, uid string, form betForm) (string, error) {
 	var betID string
 	err := tx.QueryRow(ctx, `
-		insert into bets (creator_user_id, title, description, external_url, deadline)
-		values ($1, $2, $3, nullif($4,''), $5)
+		insert into bets (creator_user_id, title, description, external_url, deadline, blind, visibility, min_wager, max_wager)
+		values ($1, $2, $3, nullif($4,''), $5, $6, $7::bet_visibility, $8, $9)
 		returning id::text
-	`, uid, form.Title, nullIfEmpty(form.Description), form.ExternalURL, form.Deadline).Scan(&betID)
+	`, uid, form.Title, nullIfEmpty(form.Description), form.ExternalURL, form.Deadline, form.Blind, form.Visibility, form.MinWager, form.MaxWager).Scan(&betID)
 	return betID, err
 }
 
-func (h *BetCreateHandler) insertOptions(ctx context.Context, tx pgx.Tx, betID string, opts []string) error {
-	for i, label := range opts {
-		if _, err := tx.Exec(ctx, `
-			insert into bet_options (bet_id, label, position)
-			values ($1, $2, $3)
-		`, betID, label, i+1); err != nil {
-			return err
-		}
+func insertBetOptions(ctx context.Context, tx pgx.Tx, betID string, opts []string) error {
+	// Single round-trip; positions follow the order of opts (1-based).
+	_, err := tx.Exec(ctx, `
+		insert into bet_options (bet_id, label, position)
+		select $1, t.label, t.ord
+		from unnest($2::text[]) with ordinality as t(label, ord)
+	`, betID, opts)
+	return err
+}
+
+func (h *BetCreateHandler) insertResolvers(ctx context.Context, tx pgx.Tx, betID string, usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+	tag, err := tx.Exec(ctx, `
+		insert into bet_resolvers (bet_id, user_id)
+		select $1, u.id
+		from users u
+		where lower(u.username) = any($2::text[])
+		  and u.role in ('moderator', 'admin')
+		  and u.disabled_at is null
+	`, betID, lowerAll(usernames))
+	if err != nil {
+		return err
+	}
+	if int(tag.RowsAffected()) != len(usernames) {
+		return errInvalidResolver
 	}
 	return nil
 }
+
+func lowerAll(in []string) []string {
+	out := make([]string, len(in))
+	for i, s := range in {
+		out[i] = strings.ToLower(s)
+	}
+	return out
+}
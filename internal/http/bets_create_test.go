@@ -0,0 +1,297 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"betsandpedestres/internal/apperr"
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/dbtest"
+	"betsandpedestres/internal/web"
+)
+
+func TestCreationFee(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	unit := coins.Unit()
+	creator, wallet := dbtest.User(t, pool, "creator", "")
+	dbtest.Fund(t, pool, wallet, 50*unit)
+
+	// Start without a house user, as on an instance that never gifted:
+	// the fee must create it rather than fail.
+	if _, err := pool.Exec(ctx, `update users set username = 'old-house' where username = 'house'`); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &BetCreateHandler{DB: pool, CreationFee: 30 * unit}
+	form := betForm{Title: "Fee", Options: []string{"Yes", "No"}, Visibility: visibilityPublic}
+	if _, _, err := h.createBet(ctx, creator, form); err != nil {
+		t.Fatalf("first bet: %v", err)
+	}
+	house := dbtest.HouseWallet(t, pool)
+	if got := dbtest.Balance(t, pool, wallet); got != 20*unit {
+		t.Errorf("creator balance = %d, want %d", got, 20*unit)
+	}
+	if got := dbtest.Balance(t, pool, house); got != 30*unit {
+		t.Errorf("house balance = %d, want %d", got, 30*unit)
+	}
+
+	// 20 left: the second bet is refused and nothing is written.
+	_, _, err := h.createBet(ctx, creator, form)
+	var ae *apperr.Error
+	if !errors.As(err, &ae) || ae.Status != http.StatusForbidden {
+		t.Fatalf("second bet: err = %v, want 403", err)
+	}
+	var bets int
+	if err := pool.QueryRow(ctx, `select count(*) from bets where creator_user_id = $1::uuid`, creator).Scan(&bets); err != nil {
+		t.Fatal(err)
+	}
+	if bets != 1 {
+		t.Errorf("%d bets created, want 1", bets)
+	}
+	if got := dbtest.Balance(t, pool, wallet); got != 20*unit {
+		t.Errorf("creator balance after refusal = %d, want %d", got, 20*unit)
+	}
+	if got := dbtest.Balance(t, pool, house); got != 30*unit {
+		t.Errorf("house balance after refusal = %d, want %d", got, 30*unit)
+	}
+}
+
+func TestCollectOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         []string
+		titleCase   bool
+		want        []string
+		wantChanged bool
+		wantErr     bool
+	}{
+		{"clean", []string{"Yes", "No"}, false, []string{"Yes", "No"}, false, false},
+		{"blanks dropped", []string{"Yes", "", "  ", "No"}, false, []string{"Yes", "No"}, false, false},
+		{"outer spaces are not a change", []string{" Yes ", "No"}, false, []string{"Yes", "No"}, false, false},
+		{"inner spaces collapsed", []string{"Real  Madrid", "Barça"}, false, []string{"Real Madrid", "Barça"}, true, false},
+		{"duplicate dropped", []string{"Yes", "No", "yes"}, false, []string{"Yes", "No"}, true, false},
+		{"title case", []string{"real madrid", "barça"}, true, []string{"Real Madrid", "Barça"}, true, false},
+		{"one outcome", []string{"Yes"}, false, nil, false, true},
+		{"duplicates leave one outcome", []string{"Yes", "YES"}, false, nil, false, true},
+		{"ten outcomes", []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}, false, []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}, false, false},
+		{"eleven outcomes", []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"}, false, nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := collectOptions(tt.raw, tt.titleCase)
+			if tt.wantErr {
+				if !errors.Is(err, errInvalidOptions) {
+					t.Fatalf("err = %v, want errInvalidOptions", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) || changed != tt.wantChanged {
+				t.Errorf("collectOptions(%q) = %q, %v, want %q, %v", tt.raw, got, changed, tt.want, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestNormalizeOptionLabel(t *testing.T) {
+	tests := []struct {
+		in        string
+		titleCase bool
+		want      string
+	}{
+		{"  Yes  ", false, "Yes"},
+		{"Real \t\n Madrid", false, "Real Madrid"},
+		{"Real\u00a0\u00a0Madrid", false, "Real Madrid"}, // no-break spaces
+		{"\u3000東京\u3000\u3000大阪\u3000", false, "東京 大阪"}, // ideographic spaces
+		{"\u2003Paris\u2009Lyon", false, "Paris Lyon"},   // em and thin spaces
+		{" \t\u00a0 ", false, ""},
+		{"real madrid", true, "Real Madrid"},
+		{"ÉQUIPE  de france", true, "Équipe De France"},
+		{"straße", true, "Straße"},
+		{"ίσως", true, "Ίσως"},
+	}
+	for _, tt := range tests {
+		if got := normalizeOptionLabel(tt.in, tt.titleCase); got != tt.want {
+			t.Errorf("normalizeOptionLabel(%q, %v) = %q, want %q", tt.in, tt.titleCase, got, tt.want)
+		}
+	}
+}
+
+func TestOptionFold(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"STRASSE", "straße", true},
+		{"ΣΟΦΟΣ", "σοφος", true},
+		{"ΣΟΦΟΣ", "σοφοσ", true},
+		{"Ǆ", "ǆ", true},
+		{"ﬁnal", "FINAL", true},
+		{"K", "\u212a", true}, // Kelvin sign
+		{"Yes", "Yes!", false},
+		{"resume", "résumé", false},
+		{"I", "ı", false},
+	}
+	for _, tt := range tests {
+		if same := optionFold.String(tt.a) == optionFold.String(tt.b); same != tt.same {
+			t.Errorf("%q and %q fold alike = %v, want %v", tt.a, tt.b, same, tt.same)
+		}
+	}
+
+	// The folding is what collectOptions deduplicates on.
+	got, changed, err := collectOptions([]string{"Straße", "STRASSE", "ΣΟΦΟΣ", "σοφος"}, false)
+	if err != nil || !changed || !slices.Equal(got, []string{"Straße", "ΣΟΦΟΣ"}) {
+		t.Errorf("collectOptions = %q, %v, %v, want [Straße ΣΟΦΟΣ], true", got, changed, err)
+	}
+}
+
+func TestCollectResolvers(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{"", []string{}},
+		{"alice", []string{"alice"}},
+		{"alice, bob", []string{"alice", "bob"}},
+		{"@alice @bob\ncarol", []string{"alice", "bob", "carol"}},
+		{"alice,,  ,bob,", []string{"alice", "bob"}},
+		{"Alice alice @ALICE", []string{"Alice"}},
+		{"@", []string{}},
+	}
+	for _, tt := range tests {
+		if got := collectResolvers(tt.raw); !slices.Equal(got, tt.want) {
+			t.Errorf("collectResolvers(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestValidateExternalURL(t *testing.T) {
+	tests := []struct {
+		raw string
+		ok  bool
+	}{
+		{"", true},
+		{"https://example.com/match", true},
+		{"http://example.com", true},
+		{"HTTPS://example.com", true},
+		{"javascript:alert(1)", false},
+		{"data:text/html,<script>alert(1)</script>", false},
+		{"ftp://example.com/file", false},
+		{"//example.com/path", false},
+		{"example.com", false},
+		{"https://", false},
+		{"https://exa mple.com", false},
+	}
+	for _, tt := range tests {
+		err := validateExternalURL(tt.raw)
+		if tt.ok && err != nil {
+			t.Errorf("validateExternalURL(%q) = %v, want nil", tt.raw, err)
+		}
+		if !tt.ok && !errors.Is(err, errInvalidURL) {
+			t.Errorf("validateExternalURL(%q) = %v, want errInvalidURL", tt.raw, err)
+		}
+	}
+}
+
+// TestValidateDeadlineBoundary checks that "now" itself is already too late:
+// the server tolerates no clock skew, the form's min attribute does.
+func TestValidateDeadlineBoundary(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name     string
+		deadline time.Time
+		wantPast bool
+	}{
+		{"a second ago", now.Add(-time.Second), true},
+		{"exactly now", now, true},
+		{"just after now", now.Add(time.Nanosecond), false},
+	} {
+		err := validateDeadline(&tt.deadline, now, 0)
+		if tt.wantPast != errors.Is(err, errDeadlinePast) || (!tt.wantPast && err != nil) {
+			t.Errorf("%s: err = %v, want past = %v", tt.name, err, tt.wantPast)
+		}
+	}
+}
+
+// TestDeadlineInputsCarryServerTime checks that the bet forms give the
+// deadline picker the server's clock to compute its min from.
+func TestDeadlineInputsCarryServerTime(t *testing.T) {
+	rend, err := web.NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := web.HeaderData{LoggedIn: true}
+	pages := map[string]any{
+		"bet_new":  web.Page[betNewContent]{Header: header},
+		"bet_edit": web.Page[betEditContent]{Header: header, Content: betEditContent{BetID: "b", Options: []string{"Yes", "No"}}},
+	}
+	serverNow := regexp.MustCompile(`id="deadlineLocal"[^>]*data-server-now="([^"]+)"`)
+	for name, page := range pages {
+		var buf bytes.Buffer
+		before := time.Now().Add(-time.Second)
+		if err := rend.Render(&buf, name, page); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		m := serverNow.FindSubmatch(buf.Bytes())
+		if m == nil {
+			t.Errorf("%s: deadline input lacks data-server-now", name)
+			continue
+		}
+		got, err := time.Parse(time.RFC3339, string(m[1]))
+		if err != nil || got.Before(before.Truncate(time.Second)) || got.After(time.Now()) {
+			t.Errorf("%s: data-server-now = %q, want the current time", name, m[1])
+		}
+	}
+}
+
+func TestValidateDeadline(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	horizon := 30 * 24 * time.Hour
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	tests := []struct {
+		name     string
+		deadline *time.Time
+		horizon  time.Duration
+		wantErr  error
+	}{
+		{"no deadline", nil, horizon, nil},
+		{"past", at(-time.Hour), horizon, errDeadlinePast},
+		{"now", at(0), horizon, errDeadlinePast},
+		{"future", at(time.Hour), horizon, nil},
+		{"at the horizon", at(horizon), horizon, nil},
+		{"past the horizon", at(horizon + time.Second), horizon, errInvalidDeadline},
+		{"no horizon", at(10 * 365 * 24 * time.Hour), 0, nil},
+		{"past without horizon", at(-time.Hour), 0, errDeadlinePast},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDeadline(tt.deadline, now, tt.horizon)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	// The horizon error says how far out deadlines may go.
+	var ae *apperr.Error
+	if err := validateDeadline(at(horizon+time.Hour), now, horizon); !errors.As(err, &ae) || ae.Message != "deadline must be within 30 days" {
+		t.Errorf("past the horizon: err = %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/apperr"
+	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/web"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BetEditHandler lets a bet's creator, or an admin, fix the description,
+// link, deadline and outcomes of an open bet nobody has wagered on yet.
+// Every edit is recorded in bet_edits.
+type BetEditHandler struct {
+	DB  *pgxpool.Pool
+	TPL *web.Renderer
+
+	// DeadlineHorizon and TitleCaseOptions apply as on creation.
+	DeadlineHorizon  time.Duration
+	TitleCaseOptions bool
+}
+
+type betEditContent struct {
+	Title       string
+	BetID       string
+	BetTitle    string
+	Description string
+	ExternalURL string
+	Deadline    *time.Time
+	Options     []string
+}
+
+// betEditable is the state of a bet as far as editing is concerned; the
+// JSON form is what bet_edits stores.
+type betEditable struct {
+	Description string     `json:"description"`
+	ExternalURL string     `json:"external_url"`
+	Deadline    *time.Time `json:"deadline"`
+	Options     []string   `json:"options"`
+}
+
+var (
+	errEditForbidden = apperr.Forbidden("only the bet's creator or an admin can edit it")
+	errEditLocked    = apperr.Conflict("this bet can no longer be edited: it has wagers, resolution votes, or is closed")
+)
+
+func (h *BetEditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	header, role := loadHeader(r.Context(), h.DB, uid)
+	if !header.LoggedIn || role == middleware.RoleUnverified {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	betID := r.PathValue("id")
+	if !looksLikeUUID(betID) {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if r.Method == http.MethodPost {
+		if err := h.update(ctx, r, uid, role == middleware.RoleAdmin, betID); err != nil {
+			var ae *apperr.Error
+			if !errors.As(err, &ae) {
+				err = apperr.Internal("db error", err)
+			}
+			apperr.Write(w, err)
+			return
+		}
+		http.Redirect(w, r, "/bets/"+betID+"?note=edited", http.StatusSeeOther)
+		return
+	}
+
+	var (
+		title string
+		cur   betEditable
+	)
+	err := pgx.BeginFunc(ctx, h.DB, func(tx pgx.Tx) error {
+		var err error
+		title, cur, err = loadEditableBet(ctx, tx, uid, role == middleware.RoleAdmin, betID, false)
+		return err
+	})
+	if err != nil {
+		var ae *apperr.Error
+		if !errors.As(err, &ae) {
+			err = apperr.Internal("db error", err)
+		}
+		apperr.Write(w, err)
+		return
+	}
+
+	page := web.Page[betEditContent]{
+		Header: header,
+		Content: betEditContent{
+			Title:       "Edit bet",
+			BetID:       betID,
+			BetTitle:    title,
+			Description: cur.Description,
+			ExternalURL: cur.ExternalURL,
+			Deadline:    cur.Deadline,
+			Options:     cur.Options,
+		},
+	}
+	var buf bytes.Buffer
+	if err := h.TPL.Render(&buf, "bet_edit", page); err != nil {
+		slog.Error("template error", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (h *BetEditHandler) update(ctx context.Context, r *http.Request, uid string, isAdmin bool, betID string) error {
+	if err := r.ParseForm(); err != nil {
+		return apperr.BadRequest("bad form")
+	}
+	next := betEditable{
+		Description: strings.TrimSpace(r.Form.Get("description")),
+		ExternalURL: strings.TrimSpace(r.Form.Get("external_url")),
+	}
+	if err := validateExternalURL(next.ExternalURL); err != nil {
+		return err
+	}
+	var err error
+	if next.Options, _, err = collectOptions(r.Form["option"], h.TitleCaseOptions); err != nil {
+		return err
+	}
+	next.Deadline, err = parseDeadline(
+		strings.TrimSpace(r.Form.Get("deadline_local")),
+		strings.TrimSpace(r.Form.Get("deadline_utc")),
+		strings.TrimSpace(r.Form.Get("tz")))
+	if err != nil {
+		return err
+	}
+	if err := validateDeadline(next.Deadline, time.Now().UTC(), h.DeadlineHorizon); err != nil {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, h.DB, func(tx pgx.Tx) error {
+		_, cur, err := loadEditableBet(ctx, tx, uid, isAdmin, betID, true)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			update bets
+			set description = $2, external_url = nullif($3, ''), deadline = $4,
+			    notified_deadline = notified_deadline and deadline is not distinct from $4
+			where id = $1
+		`, betID, nullIfEmpty(next.Description), next.ExternalURL, next.Deadline); err != nil {
+			return err
+		}
+		// Option ids only change when the labels do; nothing references
+		// them yet since the bet has no wagers or votes.
+		if !slices.Equal(cur.Options, next.Options) {
+			if _, err := tx.Exec(ctx, `delete from bet_options where bet_id = $1`, betID); err != nil {
+				return err
+			}
+			if err := insertBetOptions(ctx, tx, betID, next.Options); err != nil {
+				return err
+			}
+		}
+		oldJSON, _ := json.Marshal(cur)
+		newJSON, _ := json.Marshal(next)
+		_, err = tx.Exec(ctx, `
+			insert into bet_edits (bet_id, editor_user_id, old, new) values ($1, $2, $3, $4)
+		`, betID, uid, oldJSON, newJSON)
+		return err
+	})
+}
+
+// loadEditableBet returns the bet's title and editable fields, or an
+// apperr when uid may not edit it (anymore). lock takes the bet row for
+// update so the checks hold until the edit commits.
+func loadEditableBet(ctx context.Context, tx pgx.Tx, uid string, isAdmin bool, betID string, lock bool) (string, betEditable, error) {
+	var (
+		title     string
+		creatorID string
+		editable  bool
+		cur       betEditable
+		desc, ext *string
+	)
+	q := `
+		select b.title, b.creator_user_id::text, b.description, b.external_url, b.deadline,
+		       b.status = 'open' and b.resolution_option_id is null
+		       and not exists (select 1 from wagers w where w.bet_id = b.id)
+		       and not exists (select 1 from bet_resolution_votes v where v.bet_id = b.id)
+		from bets b
+		where b.id = $1`
+	if lock {
+		q += ` for update`
+	}
+	err := tx.QueryRow(ctx, q, betID).Scan(&title, &creatorID, &desc, &ext, &cur.Deadline, &editable)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", cur, apperr.New(http.StatusNotFound, "bet not found")
+	}
+	if err != nil {
+		return "", cur, err
+	}
+	if creatorID != uid && !isAdmin {
+		return "", cur, errEditForbidden
+	}
+	if !editable {
+		return "", cur, errEditLocked
+	}
+	if desc != nil {
+		cur.Description = *desc
+	}
+	if ext != nil {
+		cur.ExternalURL = *ext
+	}
+
+	rows, err := tx.Query(ctx, `select label from bet_options where bet_id = $1 order by position`, betID)
+	if err != nil {
+		return "", cur, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			return "", cur, err
+		}
+		cur.Options = append(cur.Options, label)
+	}
+	return title, cur, rows.Err()
+}
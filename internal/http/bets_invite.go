@@ -0,0 +1,103 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BetInviteHandler lets a bet's creator or a moderator invite users
+// (POST usernames) to an open bet. Invitees are notified with the link.
+type BetInviteHandler struct {
+	DB       *pgxpool.Pool
+	Notifier notify.Notifier
+	BaseURL  string
+}
+
+func (h *BetInviteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	if uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	betID := r.PathValue("id")
+	if betID == "" {
+		http.NotFound(w, r)
+		return
+	}
+	redirect := func(code string) {
+		http.Redirect(w, r, "/bets/"+betID+"?invite="+code+"#invite", http.StatusSeeOther)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	role, err := middleware.GetUserRole(ctx, h.DB, uid)
+	if err != nil || role == middleware.RoleUnverified {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	isMod := role == middleware.RoleModerator || role == middleware.RoleAdmin
+
+	var creatorID, title, status string
+	if err := h.DB.QueryRow(ctx, `
+		select creator_user_id::text, title, status from bets where id = $1::uuid
+	`, betID).Scan(&creatorID, &title, &status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		slog.Error("bet.invite.lookup", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if creatorID != uid && !isMod {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if status != "open" {
+		redirect("closed")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	usernames := collectResolvers(r.Form.Get("usernames"))
+	if len(usernames) == 0 {
+		redirect("missing")
+		return
+	}
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		slog.Error("bet.invite.begin", "err", err)
+		redirect("error")
+		return
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	invited, err := insertInvitations(ctx, tx, betID, uid, usernames)
+	if errors.Is(err, errInvalidInvitee) {
+		redirect("unknown")
+		return
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		slog.Error("bet.invite.insert", "err", err)
+		redirect("error")
+		return
+	}
+
+	notifyInvitees(ctx, h.Notifier, invited, fetchDisplayName(ctx, h.DB, uid), title, betLink(h.BaseURL, betID))
+	redirect("sent")
+}
@@ -5,29 +5,42 @@ import (
 	"errors"
 	"fmt"
 	"html"
-	"log/slog"
+	"math"
 	"net/http"
 	"strings"
 	"time"
 
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/apperr"
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/db"
 	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/metrics"
 	"betsandpedestres/internal/notify"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type BetResolveHandler struct {
-	DB       *pgxpool.Pool
-	Quorum   int
-	Notifier notify.Notifier
-	BaseURL  string
+	DB             *pgxpool.Pool
+	Quorum         int
+	QuorumFraction float64
+	RakeBps        int // house cut of the escrow, see config.Moderation
+	// CreatorReward (minor units) is paid by the house to the creator of a
+	// bet that resolves with at least CreatorRewardMinBettors other
+	// bettors, capped at what the house took from the bet. 0 disables it.
+	CreatorReward           int64
+	CreatorRewardMinBettors int
+	Notifier                notify.Notifier
+	BaseURL                 string
 }
 
 var (
-	errMissingFields    = errors.New("missing fields")
-	errInvalidBetOption = errors.New("invalid bet/option")
-	errBetNotOpen       = errors.New("bet not open")
-	errAwaitingAdmin    = errors.New("awaiting admin decision")
+	errMissingFields    = apperr.BadRequest("missing fields")
+	errInvalidBetOption = apperr.BadRequest("invalid bet/option")
+	errBetNotOpen       = apperr.Conflict("bet not open")
+	errAwaitingAdmin    = apperr.Conflict("bet awaiting admin decision")
+	errNotResolver      = apperr.Forbidden("only the designated resolvers can vote on this bet")
 )
 
 type userPayout struct {
@@ -44,6 +57,15 @@ type resolutionNotifications struct {
 	CreatorID         string
 	WinningLabel      string
 	Payouts           []userPayout
+	Bettors           []bettorOutcome // everyone who wagered, house excluded
+}
+
+// bettorOutcome is what one participant staked on a resolved bet and what
+// they got back.
+type bettorOutcome struct {
+	UserID string
+	Staked int64
+	Won    int64
 }
 
 func (h *BetResolveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -58,8 +80,7 @@ func (h *BetResolveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	isMod, err := h.ensureModerator(ctx, uid)
 	if err != nil {
-		slog.Error("db error", "error", err)
-		http.Error(w, "db error", http.StatusInternalServerError)
+		apperr.Write(w, apperr.Internal("db error", err))
 		return
 	}
 	if !isMod {
@@ -69,15 +90,13 @@ func (h *BetResolveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	betID, optionID, adminOverride, err := parseResolutionForm(r)
 	if err != nil {
-		slog.Error("no resultion form possible", "error", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		apperr.Write(w, err)
 		return
 	}
 
 	role, err := middleware.GetUserRole(ctx, h.DB, uid)
 	if err != nil {
-		slog.Error("db error", "error", err)
-		http.Error(w, "db error", http.StatusInternalServerError)
+		apperr.Write(w, apperr.Internal("db error", err))
 		return
 	}
 	isAdmin := role == middleware.RoleAdmin
@@ -88,24 +107,27 @@ func (h *BetResolveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	notes, err := h.processResolution(ctx, uid, betID, optionID, adminOverride)
 	if err != nil {
-		switch {
-		case errors.Is(err, errMissingFields):
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		case errors.Is(err, errInvalidBetOption):
-			slog.Error("invalid bet options", "error", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		case errors.Is(err, errBetNotOpen):
-			slog.Error("bet not open", "error", err)
-			http.Error(w, err.Error(), http.StatusConflict)
-		case errors.Is(err, errAwaitingAdmin):
-			http.Error(w, "bet awaiting admin decision", http.StatusConflict)
-		default:
-			slog.Error("db error", "error", err)
-			http.Error(w, "db error", http.StatusInternalServerError)
+		var ae *apperr.Error
+		if !errors.As(err, &ae) {
+			err = apperr.Internal("db error", err)
 		}
+		apperr.Write(w, err)
 		return
 	}
 
+	if notes.CloseGroupMessage != "" {
+		path := "consensus"
+		if adminOverride {
+			path = "override"
+		}
+		metrics.Resolutions.WithLabelValues(path).Inc()
+	}
+	h.sendNotifications(ctx, betID, notes)
+	http.Redirect(w, r, "/bets/"+betID, http.StatusSeeOther)
+}
+
+// sendNotifications delivers the messages processResolution collected.
+func (h *BetResolveHandler) sendNotifications(ctx context.Context, betID string, notes resolutionNotifications) {
 	if notes.VoteMessage != "" {
 		h.Notifier.NotifyAdmins(ctx, notes.VoteMessage)
 	}
@@ -113,21 +135,85 @@ func (h *BetResolveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.Notifier.NotifyAdmins(ctx, notes.CloseAdminMessage)
 	}
 	link := betLink(h.BaseURL, betID)
-	if notes.CloseGroupMessage != "" {
+	public := betIsPublic(ctx, h.DB, betID)
+	if notes.CloseGroupMessage != "" && public {
 		h.Notifier.NotifyGroup(ctx, notes.CloseGroupMessage)
 		h.Notifier.NotifySubscribers(ctx, notes.CloseGroupMessage)
 	}
-	if notes.CreatorID != "" && notes.WinningLabel != "" {
+	creatorBet := false
+	for _, b := range notes.Bettors {
+		var msg string
+		if b.Won > 0 {
+			msg = fmt.Sprintf("🎉 Bet \"%s\" resolved: %s won. You staked 🦶 %s and received 🦶 %s PiedPièces.\n%s",
+				notes.BetTitle, notes.WinningLabel, coins.Format(b.Staked), coins.Format(b.Won), link)
+		} else {
+			msg = fmt.Sprintf("Bet \"%s\" resolved: %s won. Your 🦶 %s PiedPièces stake is lost.\n%s",
+				notes.BetTitle, notes.WinningLabel, coins.Format(b.Staked), link)
+		}
+		h.Notifier.NotifyUser(ctx, b.UserID, msg)
+		creatorBet = creatorBet || b.UserID == notes.CreatorID
+	}
+	if notes.CreatorID != "" && notes.WinningLabel != "" && !creatorBet {
 		h.Notifier.NotifyUser(ctx, notes.CreatorID, fmt.Sprintf("Your bet \"%s\" resolved. Winner: %s\n%s", notes.BetTitle, notes.WinningLabel, link))
 	}
-	for _, p := range notes.Payouts {
-		h.Notifier.NotifyUser(ctx, p.UserID, fmt.Sprintf("You received 🦶 %d PiedPièces from bet \"%s\".\n%s", p.Amount, notes.BetTitle, link))
+	if notes.CloseGroupMessage != "" {
+		// Everyone else following the bet; bettors and the creator heard above.
+		skip := []string{notes.CreatorID}
+		for _, b := range notes.Bettors {
+			skip = append(skip, b.UserID)
+		}
+		notifyBetSubscribers(ctx, h.DB, h.Notifier, betID, fmt.Sprintf("Bet \"%s\" resolved. Winner: %s\n%s", notes.BetTitle, notes.WinningLabel, link), public, skip...)
 	}
-	http.Redirect(w, r, "/bets/"+betID, http.StatusSeeOther)
 }
 
-func finalizeBetPayout(ctx context.Context, tx pgx.Tx, betID, winningOptionID string) ([]userPayout, error) {
-	var payouts []userPayout
+// fetchBettorOutcomes totals each bettor's stake in one query and pairs it
+// with their payout. The house account is left out: nobody reads its DMs.
+func fetchBettorOutcomes(ctx context.Context, tx pgx.Tx, betID string, payouts []userPayout) ([]bettorOutcome, error) {
+	won := make(map[string]int64, len(payouts))
+	for _, p := range payouts {
+		won[p.UserID] += p.Amount
+	}
+	rows, err := tx.Query(ctx, `
+	  select w.user_id::text, sum(w.amount)::bigint
+	  from wagers w
+	  join users u on u.id = w.user_id
+	  where w.bet_id = $1::uuid and u.username <> $2
+	  group by w.user_id
+	`, betID, accounts.HouseUsername)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []bettorOutcome
+	for rows.Next() {
+		var b bettorOutcome
+		if err := rows.Scan(&b.UserID, &b.Staked); err != nil {
+			return nil, err
+		}
+		b.Won = won[b.UserID]
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// payoutPolicy is the house's take and the creator's reward applied when
+// a bet is settled.
+type payoutPolicy struct {
+	RakeBps                 int
+	CreatorReward           int64
+	CreatorRewardMinBettors int
+}
+
+func (h *BetResolveHandler) payoutPolicy() payoutPolicy {
+	return payoutPolicy{RakeBps: h.RakeBps, CreatorReward: h.CreatorReward, CreatorRewardMinBettors: h.CreatorRewardMinBettors}
+}
+
+// finalizeBetPayout closes the bet on winningOptionID and pays the escrow
+// out to the winners pro rata, after skimming policy.RakeBps basis points to
+// the house. The creator reward, if any, is paid by the house out of what it
+// took from this bet, so winners are paid the same either way and rewards
+// never mint coins.
+func finalizeBetPayout(ctx context.Context, tx pgx.Tx, betID, winningOptionID string, policy payoutPolicy) ([]userPayout, error) {
 	// Mark bet as closed with resolution
 	if _, err := tx.Exec(ctx, `
 	  update bets
@@ -136,11 +222,27 @@ func finalizeBetPayout(ctx context.Context, tx pgx.Tx, betID, winningOptionID st
 	`, betID, winningOptionID); err != nil {
 		return nil, err
 	}
+	payouts, houseTake, err := payOutEscrow(ctx, tx, betID, winningOptionID, policy.RakeBps)
+	if err != nil {
+		return nil, err
+	}
+	if reward := min(policy.CreatorReward, houseTake); reward > 0 {
+		if err := payCreatorReward(ctx, tx, betID, reward, policy.CreatorRewardMinBettors); err != nil {
+			return nil, err
+		}
+	}
+	return payouts, nil
+}
 
+// payOutEscrow empties the bet's escrow: rakeBps basis points to the house,
+// the rest to the winners of winningOptionID (see splitPot), or everything
+// to the house when nobody backed that option. houseTake is what the house
+// received.
+func payOutEscrow(ctx context.Context, tx pgx.Tx, betID, winningOptionID string, rakeBps int) (payouts []userPayout, houseTake int64, err error) {
 	// Get escrow account
 	var escrowAcctID string
 	if err := tx.QueryRow(ctx, `select id::text from accounts where bet_id = $1::uuid`, betID).Scan(&escrowAcctID); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Sum escrow balance (from ledger snapshot via user_balances equivalent for account)
@@ -151,7 +253,7 @@ func finalizeBetPayout(ctx context.Context, tx pgx.Tx, betID, winningOptionID st
 	  from wagers
 	  where bet_id = $1::uuid
 	`, betID).Scan(&escrowTotal); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Winning pot = sum of wagers on winning option
@@ -161,37 +263,32 @@ func finalizeBetPayout(ctx context.Context, tx pgx.Tx, betID, winningOptionID st
 	  from wagers
 	  where bet_id = $1::uuid and option_id = $2::uuid
 	`, betID, winningOptionID).Scan(&winTotal); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// If no winners (winTotal == 0): define policy. We'll transfer back to house.
 	if winTotal == 0 {
 		// send entire escrow to house
-		var houseAcct string
-		if err := tx.QueryRow(ctx, `
-		  select a.id::text
-		  from accounts a
-		  join users u on u.id = a.user_id
-		  where u.username = 'house' and a.is_default
-		  limit 1
-		`).Scan(&houseAcct); err != nil {
-			return nil, err
+		houseAcct, err := accounts.EnsureHouseAccount(ctx, tx)
+		if err != nil {
+			return nil, 0, err
 		}
 		var txID string
 		if err := tx.QueryRow(ctx, `insert into transactions (reason, bet_id, note) values ('BET', $1::uuid, 'no winners – to house') returning id::text`, betID).Scan(&txID); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		outgoing := -escrowTotal
 		if _, err := tx.Exec(ctx, `
 		  insert into ledger_entries (tx_id, account_id, delta)
 		  values ($1, $2, $4), ($1, $3, $5)
 		`, txID, escrowAcctID, houseAcct, outgoing, escrowTotal); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
-		return payouts, nil
+		return payouts, escrowTotal, nil
 	}
 
-	// Compute per-user winning sums
+	// Compute per-user winning sums, biggest stake first; the bet page lists
+	// winners in the same order (see computePayouts).
 	type win struct {
 		UserID      string
 		DisplayName string
@@ -202,10 +299,11 @@ func finalizeBetPayout(ctx context.Context, tx pgx.Tx, betID, winningOptionID st
 	  from wagers w
 	  join users u on u.id = w.user_id
 	  where w.bet_id = $1::uuid and w.option_id = $2::uuid
-	  group by w.user_id, u.display_name
+	  group by w.user_id, u.display_name, u.username
+	  order by sum(w.amount) desc, u.username
 	`, betID, winningOptionID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -213,33 +311,48 @@ func finalizeBetPayout(ctx context.Context, tx pgx.Tx, betID, winningOptionID st
 	for rows.Next() {
 		var w win
 		if err := rows.Scan(&w.UserID, &w.DisplayName, &w.Amount); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		winners = append(winners, w)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Prepare payouts: proportional, with integer rounding; last payout adjusts remainder
 	var txID string
 	if err := tx.QueryRow(ctx, `insert into transactions (reason, bet_id, note) values ('BET', $1::uuid, 'payout') returning id::text`, betID).Scan(&txID); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	var distributed int64
+	stakes := make([]int64, len(winners))
 	for i, w := range winners {
-		share := (escrowTotal * w.Amount) / winTotal
-		if i == len(winners)-1 { // last gets remainder adjustment
-			share = escrowTotal - distributed
-		} else {
-			distributed += share
-		}
+		stakes[i] = w.Amount
+	}
+	rake, shares := splitPot(escrowTotal, rakeBps, stakes)
 
+	// House rake comes off the top, in the same transaction as the payout
+	if rake > 0 {
+		houseAcct, err := accounts.EnsureHouseAccount(ctx, tx)
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, err := tx.Exec(ctx, `
+		  insert into ledger_entries (tx_id, account_id, delta)
+		  values ($1, $2, $4), ($1, $3, $5)
+		`, txID, escrowAcctID, houseAcct, -rake, rake); err != nil {
+			return nil, 0, err
+		}
+		if _, err := tx.Exec(ctx, `update bets set rake = $2 where id = $1::uuid`, betID, rake); err != nil {
+			return nil, 0, err
+		}
+	}
+	for i, w := range winners {
+		share := shares[i]
 		// user default wallet
 		var wallet string
 		if err := tx.QueryRow(ctx, `select id::text from accounts where user_id = $1::uuid and is_default`, w.UserID).Scan(&wallet); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		// ledger: escrow -> winner
 		if share > 0 {
@@ -248,12 +361,69 @@ func finalizeBetPayout(ctx context.Context, tx pgx.Tx, betID, winningOptionID st
 			  insert into ledger_entries (tx_id, account_id, delta)
 			  values ($1, $2, $4), ($1, $3, $5)
 			`, txID, escrowAcctID, wallet, outgoing, share); err != nil {
-				return nil, err
+				return nil, 0, err
 			}
 			payouts = append(payouts, userPayout{UserID: w.UserID, DisplayName: w.DisplayName, Amount: share})
 		}
 	}
-	return payouts, nil
+	return payouts, rake, nil
+}
+
+// splitPot skims rakeBps basis points off escrowTotal for the house, rounded
+// down, and shares the rest between the winners pro rata to their stakes.
+// Shares are rounded down too and the last winner gets the remainder, so
+// rake plus shares is always exactly escrowTotal.
+func splitPot(escrowTotal int64, rakeBps int, stakes []int64) (rake int64, shares []int64) {
+	rake = escrowTotal * int64(rakeBps) / 10000
+	pot := escrowTotal - rake
+	var winTotal int64
+	for _, s := range stakes {
+		winTotal += s
+	}
+	shares = make([]int64, len(stakes))
+	var distributed int64
+	for i, s := range stakes {
+		if i == len(stakes)-1 {
+			shares[i] = pot - distributed
+			break
+		}
+		shares[i] = pot * s / winTotal
+		distributed += shares[i]
+	}
+	return rake, shares
+}
+
+// payCreatorReward pays amount from the house to the bet's creator when at
+// least minBettors users other than the creator (and the house) wagered.
+func payCreatorReward(ctx context.Context, tx pgx.Tx, betID string, amount int64, minBettors int) error {
+	var wallet string
+	var bettors int
+	err := tx.QueryRow(ctx, `
+	  select a.id::text,
+	         (select count(distinct w.user_id)
+	            from wagers w
+	            join users u on u.id = w.user_id
+	           where w.bet_id = b.id and w.user_id <> b.creator_user_id and u.username <> $2)
+	  from bets b
+	  join accounts a on a.user_id = b.creator_user_id and a.is_default
+	  where b.id = $1::uuid
+	`, betID, accounts.HouseUsername).Scan(&wallet, &bettors)
+	if err != nil || bettors < minBettors {
+		return err
+	}
+	houseAcct, err := accounts.EnsureHouseAccount(ctx, tx)
+	if err != nil {
+		return err
+	}
+	var txID string
+	if err := tx.QueryRow(ctx, `insert into transactions (reason, bet_id, note) values ('BET', $1::uuid, 'creator reward') returning id::text`, betID).Scan(&txID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+	  insert into ledger_entries (tx_id, account_id, delta)
+	  values ($1, $2, $4), ($1, $3, $5)
+	`, txID, houseAcct, wallet, -amount, amount)
+	return err
 }
 
 func (h *BetResolveHandler) ensureModerator(ctx context.Context, uid string) (bool, error) {
@@ -263,7 +433,7 @@ func (h *BetResolveHandler) ensureModerator(ctx context.Context, uid string) (bo
 func parseResolutionForm(r *http.Request) (string, string, bool, error) {
 	betID := r.PathValue("id")
 	if err := r.ParseForm(); err != nil {
-		return "", "", false, err
+		return "", "", false, apperr.Wrap(http.StatusBadRequest, "bad form", err)
 	}
 	optionID := strings.TrimSpace(r.Form.Get("option_id"))
 	if betID == "" || optionID == "" {
@@ -274,12 +444,19 @@ func parseResolutionForm(r *http.Request) (string, string, bool, error) {
 }
 
 func (h *BetResolveHandler) processResolution(ctx context.Context, uid, betID, optionID string, adminOverride bool) (resolutionNotifications, error) {
+	var notes resolutionNotifications
+	// Payouts move money: run serializable so concurrent votes or overrides
+	// cannot both settle the bet. On retry everything is recomputed.
+	err := db.RunSerializable(ctx, h.DB, func(tx pgx.Tx) error {
+		var err error
+		notes, err = h.resolveTx(ctx, tx, uid, betID, optionID, adminOverride)
+		return err
+	})
+	return notes, err
+}
+
+func (h *BetResolveHandler) resolveTx(ctx context.Context, tx pgx.Tx, uid, betID, optionID string, adminOverride bool) (resolutionNotifications, error) {
 	notes := resolutionNotifications{}
-	tx, err := h.DB.Begin(ctx)
-	if err != nil {
-		return notes, err
-	}
-	defer tx.Rollback(ctx)
 
 	if err := h.ensureBetOpen(ctx, tx, betID, optionID); err != nil {
 		return notes, err
@@ -298,11 +475,14 @@ func (h *BetResolveHandler) processResolution(ctx context.Context, uid, betID, o
 		notes.BetTitle = betTitle
 		notes.CreatorID = creatorID
 		notes.WinningLabel = optionLabel
-		payouts, err := finalizeBetPayout(ctx, tx, betID, optionID)
+		payouts, err := finalizeBetPayout(ctx, tx, betID, optionID, h.payoutPolicy())
 		if err != nil {
 			return notes, err
 		}
 		notes.Payouts = payouts
+		if notes.Bettors, err = fetchBettorOutcomes(ctx, tx, betID, payouts); err != nil {
+			return notes, err
+		}
 		link := betLink(h.BaseURL, betID)
 		var totalPayout int64
 		for _, p := range payouts {
@@ -310,9 +490,6 @@ func (h *BetResolveHandler) processResolution(ctx context.Context, uid, betID, o
 		}
 		notes.CloseAdminMessage = fmt.Sprintf("Admin %s forced bet '%s'. Winner: %s", actorName, betTitle, optionLabel)
 		notes.CloseGroupMessage = formatGroupResolutionMessage(betTitle, optionLabel, link, payouts, totalPayout)
-		if err := tx.Commit(ctx); err != nil {
-			return notes, err
-		}
 		return notes, nil
 	}
 
@@ -320,6 +497,14 @@ func (h *BetResolveHandler) processResolution(ctx context.Context, uid, betID, o
 		return notes, errAwaitingAdmin
 	}
 
+	resolvers, allowed, err := betResolverAccess(ctx, tx, betID, uid)
+	if err != nil {
+		return notes, err
+	}
+	if !allowed {
+		return notes, errNotResolver
+	}
+
 	moderatorName, betTitle, optionLabel, creatorID, err := h.voteContext(ctx, tx, uid, betID, optionID)
 	if err != nil {
 		return notes, err
@@ -336,7 +521,15 @@ func (h *BetResolveHandler) processResolution(ctx context.Context, uid, betID, o
 	if err != nil {
 		return notes, err
 	}
-	if votes >= h.Quorum && agreed {
+	quorum, err := effectiveQuorum(ctx, tx, h.Quorum, h.QuorumFraction)
+	if err != nil {
+		return notes, err
+	}
+	if resolvers > 0 {
+		// An allowlist smaller than the quorum could never close the bet.
+		quorum = min(quorum, resolvers)
+	}
+	if votes >= quorum && agreed {
 		winOpt, payouts, err := h.finalizeConsensus(ctx, tx, betID)
 		if err != nil {
 			return notes, err
@@ -347,6 +540,9 @@ func (h *BetResolveHandler) processResolution(ctx context.Context, uid, betID, o
 		}
 		notes.WinningLabel = winningLabel
 		notes.Payouts = payouts
+		if notes.Bettors, err = fetchBettorOutcomes(ctx, tx, betID, payouts); err != nil {
+			return notes, err
+		}
 		link := betLink(h.BaseURL, betID)
 		var totalPayout int64
 		for _, payout := range notes.Payouts {
@@ -356,9 +552,6 @@ func (h *BetResolveHandler) processResolution(ctx context.Context, uid, betID, o
 		notes.CloseGroupMessage = formatGroupResolutionMessage(betTitle, winningLabel, link, payouts, totalPayout)
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return notes, err
-	}
 	return notes, nil
 }
 
@@ -369,6 +562,7 @@ func (h *BetResolveHandler) ensureBetOpen(ctx context.Context, tx pgx.Tx, betID,
 	  from bets b
 	  join bet_options o on o.bet_id = b.id
 	  where b.id = $1::uuid and o.id = $2::uuid
+	  for update of b
 	`, betID, optionID).Scan(&open)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -440,12 +634,50 @@ func (h *BetResolveHandler) consensusStatus(ctx context.Context, tx pgx.Tx, betI
 	return votes, agreed, err
 }
 
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// effectiveQuorum returns the number of agreeing votes needed to close a bet:
+// the fixed quorum, or fraction of the active moderators and admins when
+// fraction is set.
+func effectiveQuorum(ctx context.Context, q rowQuerier, fixed int, fraction float64) (int, error) {
+	if fraction <= 0 {
+		return fixed, nil
+	}
+	var mods int
+	if err := q.QueryRow(ctx, `
+	  select count(*) from users
+	  where role in ('moderator', 'admin') and disabled_at is null
+	`).Scan(&mods); err != nil {
+		return 0, err
+	}
+	return max(1, int(math.Ceil(fraction*float64(mods)))), nil
+}
+
+// betResolverAccess returns the size of the bet's resolver allowlist and
+// whether uid may vote: anyone (with the moderator role) when the list is
+// empty, only listed users otherwise.
+func betResolverAccess(ctx context.Context, q rowQuerier, betID, uid string) (int, bool, error) {
+	var n int
+	var listed bool
+	err := q.QueryRow(ctx, `
+	  select count(*), coalesce(bool_or(user_id = $2::uuid), false)
+	  from bet_resolvers
+	  where bet_id = $1::uuid
+	`, betID, uid).Scan(&n, &listed)
+	if err != nil {
+		return 0, false, err
+	}
+	return n, n == 0 || listed, nil
+}
+
 func (h *BetResolveHandler) finalizeConsensus(ctx context.Context, tx pgx.Tx, betID string) (string, []userPayout, error) {
 	winOpt, err := h.consensusWinningOption(ctx, tx, betID)
 	if err != nil {
 		return "", nil, err
 	}
-	payouts, err := finalizeBetPayout(ctx, tx, betID, winOpt)
+	payouts, err := finalizeBetPayout(ctx, tx, betID, winOpt, h.payoutPolicy())
 	if err != nil {
 		return "", nil, err
 	}
@@ -471,7 +703,7 @@ func formatGroupResolutionMessage(betTitle, optionLabel, link string, payouts []
 		header = fmt.Sprintf("Bet resolved: <a href=\"%s\"><strong>%s</strong></a> ! 🎉", safeLink, safeTitle)
 	}
 	winLine := formatWinnerLine(payouts)
-	body := fmt.Sprintf("%s\nThe winning option is: %s\n%s\nTotal payout: 🦶 %d PiedPièces", header, safeOption, winLine, totalPayout)
+	body := fmt.Sprintf("%s\nThe winning option is: %s\n%s\nTotal payout: 🦶 %s PiedPièces", header, safeOption, winLine, coins.Format(totalPayout))
 	return notify.HTMLPrefix + body
 }
 
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"betsandpedestres/internal/apperr"
+	"betsandpedestres/internal/http/middleware"
+)
+
+// maxResolveBatch caps how many bets one batch request may resolve.
+const maxResolveBatch = 50
+
+// BetResolveBatchHandler serves POST /admin/resolve-batch: a moderator casts
+// resolution votes on several bets at once, e.g. when one real-world event
+// settles them all. Each item goes through the single-bet resolution path
+// in its own transaction; the response reports every item's outcome.
+//
+// The body is a JSON array of {"bet_id", "option_id", "admin_override"};
+// admin_override (admins only) forces the outcome like ?admin_override=1.
+type BetResolveBatchHandler struct {
+	Resolve *BetResolveHandler
+}
+
+type resolveBatchItem struct {
+	BetID         string `json:"bet_id"`
+	OptionID      string `json:"option_id"`
+	AdminOverride bool   `json:"admin_override"`
+}
+
+type resolveBatchResult struct {
+	BetID    string `json:"bet_id"`
+	OptionID string `json:"option_id"`
+	OK       bool   `json:"ok"`
+	Status   string `json:"status"` // "voted" | "closed" | "error"
+	Winner   string `json:"winner,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (h *BetResolveBatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	if uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	role, err := middleware.GetUserRole(ctx, h.Resolve.DB, uid)
+	cancel()
+	if err != nil {
+		apperr.Write(w, apperr.Internal("db error", err))
+		return
+	}
+	if role != middleware.RoleModerator && role != middleware.RoleAdmin {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	isAdmin := role == middleware.RoleAdmin
+
+	var items []resolveBatchItem
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&items); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if len(items) == 0 || len(items) > maxResolveBatch {
+		http.Error(w, "expected 1 to 50 resolutions", http.StatusBadRequest)
+		return
+	}
+
+	results := make([]resolveBatchResult, 0, len(items))
+	for _, it := range items {
+		results = append(results, h.resolveOne(r.Context(), uid, isAdmin, it))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Results []resolveBatchResult `json:"results"`
+	}{results})
+}
+
+func (h *BetResolveBatchHandler) resolveOne(parent context.Context, uid string, isAdmin bool, it resolveBatchItem) resolveBatchResult {
+	res := resolveBatchResult{BetID: it.BetID, OptionID: it.OptionID, Status: "error"}
+	if !looksLikeUUID(it.BetID) || !looksLikeUUID(it.OptionID) {
+		res.Error = errInvalidBetOption.Message
+		return res
+	}
+	if it.AdminOverride && !isAdmin {
+		res.Error = "admin_override requires an admin"
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(parent, 10*time.Second)
+	defer cancel()
+	notes, err := h.Resolve.processResolution(ctx, uid, it.BetID, it.OptionID, it.AdminOverride)
+	if err != nil {
+		var ae *apperr.Error
+		if errors.As(err, &ae) && ae.Status < 500 {
+			res.Error = ae.Message
+		} else {
+			slog.Error("bet.resolve_batch", "bet", it.BetID, "err", err)
+			res.Error = "internal error"
+		}
+		return res
+	}
+	h.Resolve.sendNotifications(ctx, it.BetID, notes)
+
+	res.OK = true
+	res.Status = "voted"
+	if notes.WinningLabel != "" {
+		res.Status = "closed"
+		res.Winner = notes.WinningLabel
+	}
+	return res
+}
@@ -0,0 +1,245 @@
+package http
+
+import (
+	"context"
+	"math/rand/v2"
+	"testing"
+
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/dbtest"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestSplitPot(t *testing.T) {
+	tests := []struct {
+		name       string
+		escrow     int64
+		rakeBps    int
+		stakes     []int64
+		wantRake   int64
+		wantShares []int64
+	}{
+		{"single winner takes all", 100, 0, []int64{40}, 0, []int64{100}},
+		{"even split", 100, 0, []int64{10, 10}, 0, []int64{50, 50}},
+		{"remainder to last winner", 100, 0, []int64{1, 1, 1}, 0, []int64{33, 33, 34}},
+		{"rake rounds down", 199, 50, []int64{5}, 0, []int64{199}},
+		{"rake off the top", 1000, 250, []int64{30, 10}, 25, []int64{731, 244}},
+		{"rake and remainder", 101, 1000, []int64{2, 1}, 10, []int64{60, 31}},
+		{"tiny stake rounds to zero", 1000, 0, []int64{1, 999}, 0, []int64{1, 999}},
+		{"dust share", 10, 0, []int64{1, 1000}, 0, []int64{0, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rake, shares := splitPot(tt.escrow, tt.rakeBps, tt.stakes)
+			if rake != tt.wantRake {
+				t.Errorf("rake = %d, want %d", rake, tt.wantRake)
+			}
+			if len(shares) != len(tt.wantShares) {
+				t.Fatalf("shares = %v, want %v", shares, tt.wantShares)
+			}
+			for i := range shares {
+				if shares[i] != tt.wantShares[i] {
+					t.Fatalf("shares = %v, want %v", shares, tt.wantShares)
+				}
+			}
+		})
+	}
+}
+
+// TestSplitPotConservesEscrow checks on random pots that nothing is minted
+// or lost to rounding: rake plus shares is the escrow, and nobody gets a
+// negative amount.
+func TestSplitPotConservesEscrow(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 10000 {
+		stakes := make([]int64, 1+r.IntN(8))
+		var winTotal int64
+		for i := range stakes {
+			stakes[i] = 1 + r.Int64N(1_000_000)
+			winTotal += stakes[i]
+		}
+		escrow := winTotal + r.Int64N(10_000_000)
+		rakeBps := r.IntN(10000)
+
+		rake, shares := splitPot(escrow, rakeBps, stakes)
+		if rake < 0 || rake > escrow*int64(rakeBps)/10000 {
+			t.Fatalf("escrow %d at %d bps: rake %d", escrow, rakeBps, rake)
+		}
+		sum := rake
+		for _, s := range shares {
+			if s < 0 {
+				t.Fatalf("escrow %d, stakes %v: negative share in %v", escrow, stakes, shares)
+			}
+			sum += s
+		}
+		if sum != escrow {
+			t.Fatalf("escrow %d, rake %d, shares %v: paid out %d", escrow, rake, shares, sum)
+		}
+	}
+}
+
+// resolveTestBet pays out betID on optionID the way a resolution would.
+func resolveTestBet(t *testing.T, pool *pgxpool.Pool, betID, optionID string, policy payoutPolicy) []userPayout {
+	t.Helper()
+	ctx := context.Background()
+	var payouts []userPayout
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		var err error
+		payouts, err = finalizeBetPayout(ctx, tx, betID, optionID, policy)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("resolve %s: %v", betID, err)
+	}
+	return payouts
+}
+
+func TestCreatorRewardNeverMints(t *testing.T) {
+	pool := dbtest.New(t)
+	unit := coins.Unit()
+	creator, creatorWallet := dbtest.User(t, pool, "creator", "")
+	alice, aliceWallet := dbtest.User(t, pool, "alice", "")
+	bob, bobWallet := dbtest.User(t, pool, "bob", "")
+	dbtest.Fund(t, pool, aliceWallet, 1000*unit)
+	dbtest.Fund(t, pool, bobWallet, 1000*unit)
+	house := dbtest.HouseWallet(t, pool)
+
+	tests := []struct {
+		name       string
+		policy     payoutPolicy
+		wantReward int64
+	}{
+		// 160 staked at 5% rake: the house takes 8.
+		{"capped at the rake", payoutPolicy{RakeBps: 500, CreatorReward: 50 * unit, CreatorRewardMinBettors: 2}, 8 * unit},
+		{"below the rake", payoutPolicy{RakeBps: 500, CreatorReward: 5 * unit, CreatorRewardMinBettors: 2}, 5 * unit},
+		{"too few bettors", payoutPolicy{RakeBps: 500, CreatorReward: 5 * unit, CreatorRewardMinBettors: 3}, 0},
+		{"no rake", payoutPolicy{CreatorReward: 5 * unit, CreatorRewardMinBettors: 2}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			betID, opts := newTestBet(t, pool, creator, "Yes", "No")
+			placeTestWager(t, pool, alice, betID, opts[0], "100")
+			placeTestWager(t, pool, bob, betID, opts[1], "60")
+			houseBefore := dbtest.Balance(t, pool, house)
+			creatorBefore := dbtest.Balance(t, pool, creatorWallet)
+
+			resolveTestBet(t, pool, betID, opts[0], tt.policy)
+
+			rake := 160 * unit * int64(tt.policy.RakeBps) / 10000
+			if got := dbtest.Balance(t, pool, creatorWallet) - creatorBefore; got != tt.wantReward {
+				t.Errorf("creator got %d, want %d", got, tt.wantReward)
+			}
+			houseGain := dbtest.Balance(t, pool, house) - houseBefore
+			if houseGain < 0 {
+				t.Errorf("house lost %d on the bet", -houseGain)
+			}
+			if houseGain != rake-tt.wantReward {
+				t.Errorf("house gained %d, want rake %d minus reward %d", houseGain, rake, tt.wantReward)
+			}
+		})
+	}
+}
+
+func TestCreatorRewardOnBetWithoutWinners(t *testing.T) {
+	pool := dbtest.New(t)
+	unit := coins.Unit()
+	creator, creatorWallet := dbtest.User(t, pool, "creator", "")
+	alice, aliceWallet := dbtest.User(t, pool, "alice", "")
+	bob, bobWallet := dbtest.User(t, pool, "bob", "")
+	dbtest.Fund(t, pool, aliceWallet, 100*unit)
+	dbtest.Fund(t, pool, bobWallet, 100*unit)
+	house := dbtest.HouseWallet(t, pool)
+
+	betID, opts := newTestBet(t, pool, creator, "Yes", "No", "Maybe")
+	placeTestWager(t, pool, alice, betID, opts[0], "10")
+	placeTestWager(t, pool, bob, betID, opts[1], "20")
+	houseBefore := dbtest.Balance(t, pool, house)
+
+	// Nobody backed "Maybe": the house takes the escrow and funds the reward.
+	resolveTestBet(t, pool, betID, opts[2], payoutPolicy{RakeBps: 100, CreatorReward: 5 * unit, CreatorRewardMinBettors: 2})
+
+	if got := dbtest.Balance(t, pool, creatorWallet); got != 5*unit {
+		t.Errorf("creator balance = %d, want %d", got, 5*unit)
+	}
+	if got := dbtest.Balance(t, pool, house) - houseBefore; got != 25*unit {
+		t.Errorf("house gained %d, want %d", got, 25*unit)
+	}
+}
+
+// TestRakeCreatesMissingHouse resolves raked bets on an instance whose
+// house user does not exist yet: the house must be created, not fail the
+// resolution.
+func TestRakeCreatesMissingHouse(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		winner   int
+		wantTake int64
+	}{
+		{"rake", 0, 3},        // 5% of 60
+		{"no winners", 2, 60}, // nobody backed the third option
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := dbtest.New(t)
+			ctx := context.Background()
+			creator, _ := dbtest.User(t, pool, "creator", "")
+			alice, aliceWallet := dbtest.User(t, pool, "alice", "")
+			bob, bobWallet := dbtest.User(t, pool, "bob", "")
+			dbtest.Fund(t, pool, aliceWallet, 100)
+			dbtest.Fund(t, pool, bobWallet, 100)
+			betID, opts := newTestBet(t, pool, creator, "Yes", "No", "Maybe")
+			placeTestWager(t, pool, alice, betID, opts[0], "40")
+			placeTestWager(t, pool, bob, betID, opts[1], "20")
+			if _, err := pool.Exec(ctx, `update users set username = 'old-house' where username = 'house'`); err != nil {
+				t.Fatal(err)
+			}
+
+			resolveTestBet(t, pool, betID, opts[tt.winner], payoutPolicy{RakeBps: 500})
+
+			if got := dbtest.Balance(t, pool, dbtest.HouseWallet(t, pool)); got != tt.wantTake {
+				t.Errorf("house balance = %d, want %d", got, tt.wantTake)
+			}
+		})
+	}
+}
+
+// TestBetPagePayoutsMatchLedger checks that the payouts shown on the bet page
+// are the ones resolution booked, down to who gets the rounding remainder
+// among winners with equal stakes.
+func TestBetPagePayoutsMatchLedger(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	creator, _ := dbtest.User(t, pool, "creator", "")
+	betID, opts := newTestBet(t, pool, creator, "Yes", "No")
+	for _, name := range []string{"carol", "alice", "dave", "bob"} {
+		uid, wallet := dbtest.User(t, pool, name, "")
+		dbtest.Fund(t, pool, wallet, 100)
+		if name == "dave" {
+			placeTestWager(t, pool, uid, betID, opts[1], "2")
+			continue
+		}
+		placeTestWager(t, pool, uid, betID, opts[0], "1")
+	}
+
+	// Escrow 5, rake 1: the three winners split 4 as 1, 1 and 2.
+	booked := resolveTestBet(t, pool, betID, opts[0], payoutPolicy{RakeBps: 2000})
+
+	h := &BetShowHandler{DB: pool}
+	options, total, err := h.fetchOptions(ctx, betID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rake int64
+	if err := pool.QueryRow(ctx, `select rake from bets where id = $1::uuid`, betID).Scan(&rake); err != nil {
+		t.Fatal(err)
+	}
+	shown := computePayouts(options, total, rake, &opts[0], true)
+	if len(shown) != len(booked) {
+		t.Fatalf("page shows %v, ledger booked %v", shown, booked)
+	}
+	for i := range shown {
+		if shown[i].Name != booked[i].DisplayName || shown[i].Amount != booked[i].Amount {
+			t.Fatalf("page shows %v, ledger booked %v", shown, booked)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/http/middleware"
+	"github.com/jackc/pgx/v5"
+)
+
+// BetResultsHandler exports the outcome of a closed bet as JSON or CSV. It
+// reuses the bet page's queries, so access rules match the page.
+type BetResultsHandler struct {
+	Show   *BetShowHandler
+	Format string // "json" or "csv"
+}
+
+type betResults struct {
+	BetID      string             `json:"bet_id"`
+	Title      string             `json:"title"`
+	Status     string             `json:"status"`
+	Winning    *string            `json:"winning_option"` // label; null when cancelled
+	TotalPot   int64              `json:"total_pot"`
+	Rake       int64              `json:"rake"`
+	Options    []betResultsOption `json:"options"`
+	Payouts    []betResultsPayout `json:"payouts,omitempty"`
+	ExportedAt time.Time          `json:"exported_at"`
+}
+
+type betResultsOption struct {
+	Label   string `json:"label"`
+	Stakes  int64  `json:"stakes"`
+	Bettors int    `json:"bettors"`
+}
+
+type betResultsPayout struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Amount   int64  `json:"amount"`
+}
+
+func (h *BetResultsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := h.Show
+	uid := middleware.UserID(r)
+	header, role := loadHeader(r.Context(), s.DB, uid)
+	if (!header.LoggedIn && !s.PublicBrowsing) || role == middleware.RoleUnverified {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	betID := r.PathValue("id")
+	if betID == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	isMod := role == middleware.RoleModerator || role == middleware.RoleAdmin
+	bet, err := s.fetchBet(ctx, betID, uid, isMod)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			http.NotFound(w, r)
+			return
+		}
+		slog.Error("bets.results.fetch", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if !bet.Participant && !isMod {
+		http.NotFound(w, r)
+		return
+	}
+	_, alreadyClosed, _, _, _ := determineStatus(bet.Deadline, bet.WinningOption, bet.Status, bet.VotesTotal, bet.VotesAgree)
+	if !alreadyClosed {
+		http.Error(w, "results are available once the bet is closed", http.StatusConflict)
+		return
+	}
+
+	opts, total, err := s.fetchOptions(ctx, betID)
+	if err != nil {
+		slog.Error("bets.results.options", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	res := betResults{
+		BetID:      betID,
+		Title:      bet.Title,
+		Status:     bet.Status,
+		Winning:    winningLabel(opts, bet.WinningOption),
+		TotalPot:   total,
+		Rake:       bet.Rake,
+		Options:    make([]betResultsOption, 0, len(opts)),
+		ExportedAt: time.Now().UTC(),
+	}
+	for _, o := range opts {
+		res.Options = append(res.Options, betResultsOption{Label: o.Label, Stakes: o.Stakes, Bettors: len(o.Bettors)})
+	}
+	// Who won what is only for signed-in users; anonymous visitors of a
+	// public site get the totals.
+	if header.LoggedIn {
+		for _, p := range computePayouts(opts, total, bet.Rake, bet.WinningOption, alreadyClosed) {
+			res.Payouts = append(res.Payouts, betResultsPayout{Username: p.Username, Name: p.Name, Amount: p.Amount})
+		}
+	}
+
+	switch h.Format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="bet-`+betID+`-results.csv"`)
+		writeBetResultsCSV(w, res)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	}
+}
+
+// writeBetResultsCSV writes one row per option then one per payout, with
+// amounts formatted like the rest of the site.
+func writeBetResultsCSV(w io.Writer, res betResults) {
+	cw := csv.NewWriter(w)
+	winning := ""
+	if res.Winning != nil {
+		winning = *res.Winning
+	}
+	_ = cw.Write([]string{"kind", "option", "username", "name", "amount", "bettors"})
+	_ = cw.Write([]string{"outcome", csvCell(winning), "", "", coins.Format(res.TotalPot), ""})
+	if res.Rake > 0 {
+		_ = cw.Write([]string{"rake", "", "", "", coins.Format(res.Rake), ""})
+	}
+	for _, o := range res.Options {
+		_ = cw.Write([]string{"option", csvCell(o.Label), "", "", coins.Format(o.Stakes), strconv.Itoa(o.Bettors)})
+	}
+	for _, p := range res.Payouts {
+		_ = cw.Write([]string{"payout", csvCell(winning), csvCell(p.Username), csvCell(p.Name), coins.Format(p.Amount), ""})
+	}
+	cw.Flush()
+}
+
+// csvCell keeps user-written text from being read as a formula by
+// spreadsheet apps.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const similarBetsLimit = 4
+
+// similarStopwords are frequent title words that say nothing about a topic.
+var similarStopwords = map[string]bool{
+	"will": true, "with": true, "that": true, "this": true, "from": true,
+	"than": true, "before": true, "after": true, "more": true, "less": true,
+	"what": true, "when": true, "which": true, "have": true, "been": true,
+}
+
+// titleKeywords returns up to 8 distinct lower-cased words of at least four
+// letters from title, for matching other bet titles.
+func titleKeywords(title string) []string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := map[string]bool{}
+	var out []string
+	for _, w := range words {
+		if utf8.RuneCountInString(w) < 4 || similarStopwords[w] || seen[w] {
+			continue
+		}
+		seen[w] = true
+		out = append(out, w)
+		if len(out) == 8 {
+			break
+		}
+	}
+	return out
+}
+
+// fetchSimilarBets suggests open public bets related to a bet: by the same
+// creator, or sharing title words. It is best-effort: errors are logged and
+// yield no suggestions.
+func (h *BetShowHandler) fetchSimilarBets(ctx context.Context, betID, title, creatorUsername string, isMod bool) []betCard {
+	keywords := titleKeywords(title)
+	if keywords == nil {
+		keywords = []string{}
+	}
+	rows, err := h.DB.Query(ctx, `
+		select b.id::text, b.title, u.display_name, u.username, b.created_at, b.deadline, b.status, b.blind,
+		       coalesce((select sum(w.amount) from wagers w where w.bet_id = b.id), 0)::bigint,
+		       (select count(distinct w.user_id) from wagers w where w.bet_id = b.id)::bigint
+		from bets b
+		join users u on u.id = b.creator_user_id
+		cross join lateral (
+		  select (case when u.username = $2 then 2 else 0 end)
+		       + (select count(*)::int from unnest($3::text[]) as k(word)
+		          where b.title ilike '%' || k.word || '%') as score
+		) s
+		where b.id <> $1::uuid
+		  and b.status = 'open'
+		  and b.visibility = 'public'
+		  and b.archived_at is null
+		  and s.score > 0
+		order by s.score desc, b.created_at desc
+		limit $4
+	`, betID, creatorUsername, keywords, similarBetsLimit)
+	if err != nil {
+		slog.Warn("bet.similar", "err", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var list []betCard
+	for rows.Next() {
+		var bc betCard
+		var blind bool
+		if err := rows.Scan(&bc.ID, &bc.Title, &bc.CreatorName, &bc.CreatorUser, &bc.CreatedAt, &bc.Deadline, &bc.Status, &blind, &bc.Stakes, &bc.Participants); err != nil {
+			slog.Warn("bet.similar.scan", "err", err)
+			return nil
+		}
+		bc.StakesHidden = blind && !isMod
+		if bc.StakesHidden {
+			bc.Stakes = 0
+		}
+		decorateBetCard(&bc)
+		list = append(list, bc)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Warn("bet.similar.rows", "err", err)
+		return nil
+	}
+	return list
+}
@@ -3,24 +3,55 @@ package http
 import (
 	"time"
 
+	"betsandpedestres/internal/http/middleware"
 	"betsandpedestres/internal/notify"
 	"betsandpedestres/internal/web"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type BetNewHandler struct {
-	DB  *pgxpool.Pool
-	TPL *web.Renderer
+	DB          *pgxpool.Pool
+	TPL         *web.Renderer
+	CreationFee int64 // minor units, shown on the form; see BetCreateHandler
 }
 
 type betNewContent struct {
-	Title string
+	Title       string
+	CreationFee int64 // 0 when creating is free
 }
 
 type BetWagerCreateHandler struct {
 	DB       *pgxpool.Pool
 	Notifier notify.Notifier
 	BaseURL  string
+	Limiter  *middleware.RateLimiter
+	// Batcher, when set, coalesces group announcements per bet instead of
+	// sending one message per wager through Notifier.
+	Batcher *notify.Coalescer[wagerEvent]
+	// MinAccountAge is how old an account must be to wager; 0 is off.
+	MinAccountAge time.Duration
+	// Cooldown is how long after creation a bet starts taking wagers.
+	Cooldown time.Duration
+	// MinWager and MaxWager (minor units) bound a single wager on bets
+	// without limits of their own; 0 means no bound.
+	MinWager int64
+	MaxWager int64
+}
+
+// BetWagerCancelHandler lets a bettor withdraw one of their wagers while
+// the bet is still taking them.
+type BetWagerCancelHandler struct {
+	DB       *pgxpool.Pool
+	Notifier notify.Notifier
+	BaseURL  string
+}
+
+// BetCancelHandler lets an admin call off an open bet, refunding every
+// wager.
+type BetCancelHandler struct {
+	DB       *pgxpool.Pool
+	Notifier notify.Notifier
+	BaseURL  string
 }
 
 type bettorVM struct {
@@ -50,10 +81,26 @@ type betShowContent struct {
 	TotalStakes     int64
 	CreatorName     string
 	CreatorUsername string
+	Blind           bool
+	StakesHidden    bool // blind bet viewed by a non-moderator before resolution
+	Participants    int
+	Visibility      string
+	Invitees        []resolverVM // loaded for private bets and for whoever may invite
+	CanInvite       bool
+	CanEdit         bool // creator or admin, while nobody has wagered or voted
+	CanSubscribe    bool
+	Subscribed      bool
+	InviteNotice    string
 
 	CanWager          bool
-	MaxStake          int64 // user's current balance (server-enforced too)
+	BettingOpensIn    string // set while the bet is in its wager cool-down
+	MaxStake          int64  // user's balance, capped by MaxWager (server-enforced too)
+	MinWager          int64  // effective per-wager bounds; 0 means none
+	MaxWager          int64
 	IdempotencyKey    string
+	WagerNonce        string // single-use, only issued when CanWager
+	WagerNotice       string
+	MyWagers          []myWagerVM // only while CanWager
 	ResolutionAllowed bool
 
 	ResolutionMode      bool
@@ -64,7 +111,7 @@ type betShowContent struct {
 	WaitingForConsensus bool
 	WaitingForAdmin     bool
 	AdminOverrideMode   bool
-	StatusLabel         string // "Open" | "Past deadline" | "Resolution in progress" | "Closed"
+	StatusLabel         string // "Open" | "Past deadline" | "Resolution in progress" | "Closed" | "Cancelled"
 	VotesTotal          int
 	Quorum              int
 	MyVoteOptionID      *string
@@ -72,8 +119,42 @@ type betShowContent struct {
 	WinningOptionID     *string
 	WinningLabel        *string
 
-	Payouts  []payoutVM
-	Comments []commentVM
+	Resolvers  []resolverVM // empty when any moderator may resolve
+	CanResolve bool         // moderator allowed by the resolver allowlist
+
+	Votes          []resolutionVoteVM // nil unless the viewer may see who voted for what
+	CanSuggest     bool               // bettor may suggest the outcome
+	MySuggestionID string             // option the viewer suggested, if any
+	Suggestions    []suggestionVM     // bettors' outcome suggestions, for moderators
+	Payouts        []payoutVM
+	Rake           int64 // house cut taken from the pot at resolution
+	Comments       []commentVM
+	CommentCount   int // replies included
+	CommentNotice  string
+
+	Similar []betCard // related open bets, best-effort
+}
+
+type resolverVM struct {
+	UserID   string
+	Name     string
+	Username string
+}
+
+type resolutionVoteVM struct {
+	ModeratorName     string
+	ModeratorUsername string
+	OptionLabel       string
+	CreatedAt         time.Time
+}
+
+// myWagerVM is one of the viewer's own wagers, listed while they may still
+// withdraw it.
+type myWagerVM struct {
+	ID          string
+	OptionLabel string
+	Amount      int64
+	CreatedAt   time.Time
 }
 
 type payoutVM struct {
@@ -91,15 +172,30 @@ type commentVM struct {
 	Upvotes        int
 	Downvotes      int
 	CreatedAt      time.Time
+	EditedAt       *time.Time
+	Deleted        bool // content blanked, kept so replies stay threaded
 	Score          int
 	MyReaction     int
 	ParentID       *string
 	Replies        []commentVM
 	Depth          int
+	CanReply       bool
+	CanReact       bool
+	CanEdit        bool // the viewer wrote it
+	CanDelete      bool // the viewer wrote it or moderates
 }
 
 type BetShowHandler struct {
-	DB     *pgxpool.Pool
-	TPL    *web.Renderer
-	Quorum int
+	DB             *pgxpool.Pool
+	TPL            *web.Renderer
+	PublicBrowsing bool // logged-out visitors may read bet pages
+	BaseURL        string
+	Quorum         int
+	QuorumFraction float64
+	PublicVotes    bool
+	WagerCooldown  time.Duration // see BetWagerCreateHandler.Cooldown
+	// MinWager and MaxWager are the site-wide wager bounds, see
+	// BetWagerCreateHandler.
+	MinWager int64
+	MaxWager int64
 }
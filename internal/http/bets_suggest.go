@@ -0,0 +1,229 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/apperr"
+	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BetSuggestResolutionHandler records a bettor's non-binding guess at a
+// bet's outcome. Moderators see the tally on the bet page; they still
+// resolve through bet_resolution_votes.
+type BetSuggestResolutionHandler struct {
+	DB       *pgxpool.Pool
+	Notifier notify.Notifier
+	BaseURL  string
+	// Threshold is how many matching suggestions alert the resolvers, once
+	// per bet. Negative disables the alert.
+	Threshold int
+}
+
+// suggestionVM is how many bettors suggest one outcome.
+type suggestionVM struct {
+	Label string
+	Count int
+}
+
+// suggestionAlert is what to tell the resolvers once a tally reaches the
+// threshold.
+type suggestionAlert struct {
+	BetTitle string
+	Label    string
+	Count    int
+}
+
+func (h *BetSuggestResolutionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	if uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	betID := r.PathValue("id")
+	if !looksLikeUUID(betID) {
+		http.NotFound(w, r)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	role, err := middleware.GetUserRole(ctx, h.DB, uid)
+	if err != nil || role == middleware.RoleUnverified {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	optionID := strings.TrimSpace(r.Form.Get("option_id"))
+	if optionID != "" && !looksLikeUUID(optionID) {
+		http.Error(w, "bad option", http.StatusBadRequest)
+		return
+	}
+
+	var alert *suggestionAlert
+	err = pgx.BeginFunc(ctx, h.DB, func(tx pgx.Tx) error {
+		var err error
+		alert, err = h.suggestTx(ctx, tx, uid, betID, optionID)
+		return err
+	})
+	if err != nil {
+		var ae *apperr.Error
+		if !errors.As(err, &ae) {
+			err = apperr.Internal("db error", err)
+		}
+		apperr.Write(w, err)
+		return
+	}
+	if alert != nil {
+		notifyBetResolvers(ctx, h.DB, h.Notifier, betID, fmt.Sprintf("%d bettors suggest \"%s\" won \"%s\". Time to resolve?\n%s",
+			alert.Count, alert.Label, alert.BetTitle, betLink(h.BaseURL, betID)))
+	}
+
+	note := "suggested"
+	if optionID == "" {
+		note = "suggestion_withdrawn"
+	}
+	http.Redirect(w, r, "/bets/"+betID+"?note="+note, http.StatusSeeOther)
+}
+
+// suggestTx stores (or, with an empty optionID, drops) uid's suggestion and
+// reports whether the resolvers should now be alerted.
+func (h *BetSuggestResolutionHandler) suggestTx(ctx context.Context, tx pgx.Tx, uid, betID, optionID string) (*suggestionAlert, error) {
+	var (
+		title    string
+		open     bool
+		due      bool
+		alerted  bool
+		hasWager bool
+	)
+	err := tx.QueryRow(ctx, `
+		select b.title,
+		       b.status = 'open' and b.resolution_option_id is null,
+		       b.deadline is null or b.deadline <= now() at time zone 'utc',
+		       b.suggestions_alerted_at is not null,
+		       exists (select 1 from wagers w where w.bet_id = b.id and w.user_id = $2::uuid)
+		from bets b
+		where b.id = $1
+		for update
+	`, betID, uid).Scan(&title, &open, &due, &alerted, &hasWager)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apperr.New(http.StatusNotFound, "bet not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !hasWager {
+		return nil, apperr.Forbidden("only bettors on this bet can suggest its outcome")
+	}
+	if !open {
+		return nil, apperr.Conflict("bet is already closed")
+	}
+	if !due {
+		return nil, apperr.Conflict("outcomes can be suggested once the deadline has passed")
+	}
+
+	if optionID == "" {
+		_, err := tx.Exec(ctx, `delete from resolution_suggestions where bet_id = $1 and user_id = $2`, betID, uid)
+		return nil, err
+	}
+
+	var label string
+	err = tx.QueryRow(ctx, `select label from bet_options where id = $1 and bet_id = $2`, optionID, betID).Scan(&label)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errInvalidBetOption
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		insert into resolution_suggestions (bet_id, user_id, option_id) values ($1, $2, $3)
+		on conflict (bet_id, user_id) do update set option_id = excluded.option_id, updated_at = now()
+	`, betID, uid, optionID); err != nil {
+		return nil, err
+	}
+
+	if h.Threshold <= 0 || alerted {
+		return nil, nil
+	}
+	var n int
+	if err := tx.QueryRow(ctx, `select count(*) from resolution_suggestions where option_id = $1`, optionID).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n < h.Threshold {
+		return nil, nil
+	}
+	if _, err := tx.Exec(ctx, `update bets set suggestions_alerted_at = now() where id = $1`, betID); err != nil {
+		return nil, err
+	}
+	return &suggestionAlert{BetTitle: title, Label: label, Count: n}, nil
+}
+
+// fetchSuggestionTally counts suggestions per outcome, most suggested first.
+func fetchSuggestionTally(ctx context.Context, db *pgxpool.Pool, betID string) ([]suggestionVM, error) {
+	rows, err := db.Query(ctx, `
+		select o.label, count(*)::int
+		from resolution_suggestions s
+		join bet_options o on o.id = s.option_id
+		where s.bet_id = $1::uuid
+		group by o.id, o.label, o.position
+		order by count(*) desc, o.position
+	`, betID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []suggestionVM
+	for rows.Next() {
+		var s suggestionVM
+		if err := rows.Scan(&s.Label, &s.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// notifyBetResolvers messages the bet's designated resolvers, or every
+// moderator and admin when it has none.
+func notifyBetResolvers(ctx context.Context, db *pgxpool.Pool, n notify.Notifier, betID, msg string) {
+	if n == nil {
+		return
+	}
+	rows, err := db.Query(ctx, `
+		select u.id::text
+		from users u
+		where u.disabled_at is null
+		  and u.role in ('moderator', 'admin')
+		  and (not exists (select 1 from bet_resolvers br where br.bet_id = $1::uuid)
+		       or exists (select 1 from bet_resolvers br where br.bet_id = $1::uuid and br.user_id = u.id))
+	`, betID)
+	if err != nil {
+		slog.Warn("suggestions.notify_query", "err", err)
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			slog.Warn("suggestions.notify_scan", "err", err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	for _, id := range ids {
+		n.NotifyUser(ctx, id, msg)
+	}
+}
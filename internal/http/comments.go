@@ -16,10 +16,29 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// maxCommentDepth is the deepest reply allowed; top-level comments are depth 0.
+const maxCommentDepth = 5
+
+// maxCommentLen caps a comment, in runes; longer input is cut off.
+const maxCommentLen = 2000
+
+// clipComment cuts content to maxCommentLen runes.
+func clipComment(content string) string {
+	if runes := []rune(content); len(runes) > maxCommentLen {
+		return string(runes[:maxCommentLen])
+	}
+	return content
+}
+
 type CommentCreateHandler struct {
 	DB       *pgxpool.Pool
 	Notifier notify.Notifier
 	BaseURL  string
+
+	// MaxComments caps the comments on a bet and MaxReplies the direct
+	// replies to a comment; 0 means no limit. Moderators are exempt.
+	MaxComments int
+	MaxReplies  int
 }
 
 func (h *CommentCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -48,6 +67,15 @@ func (h *CommentCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		http.NotFound(w, r)
 		return
 	}
+	isMod := role == middleware.RoleModerator || role == middleware.RoleAdmin
+	if ok, err := canViewBet(ctx, h.DB, betID, uid, isMod); err != nil {
+		slog.Error("comment.access", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	} else if !ok {
+		http.NotFound(w, r)
+		return
+	}
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "bad form", http.StatusBadRequest)
 		return
@@ -57,15 +85,14 @@ func (h *CommentCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		http.Redirect(w, r, "/bets/"+betID+"#comments", http.StatusSeeOther)
 		return
 	}
-	if len([]rune(content)) > 2000 {
-		runes := []rune(content)
-		content = string(runes[:2000])
-	}
+	content = clipComment(content)
 
 	parentID := strings.TrimSpace(r.Form.Get("parent_id"))
+	depth := 0
 	if parentID != "" {
 		var parentBet string
-		if err := h.DB.QueryRow(ctx, `select bet_id::text from comments where id = $1::uuid`, parentID).Scan(&parentBet); err != nil {
+		var parentDepth int
+		if err := h.DB.QueryRow(ctx, `select bet_id::text, depth from comments where id = $1::uuid`, parentID).Scan(&parentBet, &parentDepth); err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				parentID = ""
 			} else {
@@ -75,19 +102,40 @@ func (h *CommentCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 			}
 		} else if parentBet != betID {
 			parentID = ""
+		} else {
+			depth = parentDepth + 1
+		}
+	}
+	if depth > maxCommentDepth {
+		http.Redirect(w, r, "/bets/"+betID+"?comment=too_deep#comments", http.StatusSeeOther)
+		return
+	}
+	if !isMod {
+		code, err := h.checkLimits(ctx, betID, parentID)
+		if err != nil {
+			slog.Error("comment.limits", "err", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if code != "" {
+			http.Redirect(w, r, "/bets/"+betID+"?comment="+code+"#comments", http.StatusSeeOther)
+			return
 		}
 	}
 
 	var commentID string
 	if err := h.DB.QueryRow(ctx, `
-		insert into comments (bet_id, user_id, content, parent_comment_id)
-		values ($1::uuid, $2::uuid, $3, nullif($4,'')::uuid)
+		insert into comments (bet_id, user_id, content, parent_comment_id, depth)
+		values ($1::uuid, $2::uuid, $3, nullif($4,'')::uuid, $5)
 		returning id::text
-	`, betID, uid, content, parentID).Scan(&commentID); err != nil {
+	`, betID, uid, content, parentID, depth).Scan(&commentID); err != nil {
 		slog.Error("comment.insert", "err", err)
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
+	if err := subscribeToBet(ctx, h.DB, betID, uid); err != nil {
+		slog.Warn("comment.subscribe", "err", err)
+	}
 
 	if h.Notifier != nil {
 		go h.notifyComment(ctx, betID, uid, commentID, content)
@@ -96,6 +144,30 @@ func (h *CommentCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, "/bets/"+betID+"#comments", http.StatusSeeOther)
 }
 
+// checkLimits returns the ?comment= code of the first limit a new comment
+// on betID (replying to parentID, if set) would exceed, or "".
+func (h *CommentCreateHandler) checkLimits(ctx context.Context, betID, parentID string) (string, error) {
+	if h.MaxComments > 0 {
+		var n int
+		if err := h.DB.QueryRow(ctx, `select count(*) from comments where bet_id = $1::uuid`, betID).Scan(&n); err != nil {
+			return "", err
+		}
+		if n >= h.MaxComments {
+			return "bet_full", nil
+		}
+	}
+	if h.MaxReplies > 0 && parentID != "" {
+		var n int
+		if err := h.DB.QueryRow(ctx, `select count(*) from comments where parent_comment_id = $1::uuid`, parentID).Scan(&n); err != nil {
+			return "", err
+		}
+		if n >= h.MaxReplies {
+			return "thread_full", nil
+		}
+	}
+	return "", nil
+}
+
 type CommentReactHandler struct {
 	DB *pgxpool.Pool
 }
@@ -150,7 +222,7 @@ func (h *CommentReactHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	defer tx.Rollback(ctx)
 
 	var betID string
-	if err := tx.QueryRow(ctx, `select bet_id::text from comments where id = $1::uuid`, commentID).Scan(&betID); err != nil {
+	if err := tx.QueryRow(ctx, `select bet_id::text from comments where id = $1::uuid and deleted_at is null`, commentID).Scan(&betID); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			http.NotFound(w, r)
 			return
@@ -227,6 +299,174 @@ func (h *CommentReactHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, redirectTarget(r, betID), http.StatusSeeOther)
 }
 
+// CommentEditHandler lets a comment's author rewrite it.
+type CommentEditHandler struct {
+	DB *pgxpool.Pool
+}
+
+// CommentDeleteHandler soft-deletes a comment: its author or a moderator may
+// blank it, replies stay in place.
+type CommentDeleteHandler struct {
+	DB *pgxpool.Pool
+}
+
+// commentTarget is a comment an edit or delete applies to.
+type commentTarget struct {
+	BetID    string
+	AuthorID string
+	Content  string
+	Deleted  bool
+}
+
+// loadCommentTarget fetches the comment named in the path and checks uid may
+// still see its bet. It writes the error response and returns false when the
+// request cannot go on.
+func loadCommentTarget(w http.ResponseWriter, r *http.Request, db *pgxpool.Pool, uid string) (commentTarget, string, bool) {
+	var t commentTarget
+	ctx := r.Context()
+	role, err := middleware.GetUserRole(ctx, db, uid)
+	if err != nil {
+		slog.Error("comment.role", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return t, "", false
+	}
+	if role == middleware.RoleUnverified {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return t, "", false
+	}
+	commentID := r.PathValue("id")
+	if !looksLikeUUID(commentID) {
+		http.NotFound(w, r)
+		return t, "", false
+	}
+	err = db.QueryRow(ctx, `
+		select bet_id::text, user_id::text, content, deleted_at is not null
+		from comments where id = $1::uuid
+	`, commentID).Scan(&t.BetID, &t.AuthorID, &t.Content, &t.Deleted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.NotFound(w, r)
+		return t, "", false
+	}
+	if err != nil {
+		slog.Error("comment.load", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return t, "", false
+	}
+	isMod := role == middleware.RoleModerator || role == middleware.RoleAdmin
+	if ok, err := canViewBet(ctx, db, t.BetID, uid, isMod); err != nil {
+		slog.Error("comment.access", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return t, "", false
+	} else if !ok {
+		http.NotFound(w, r)
+		return t, "", false
+	}
+	return t, role, true
+}
+
+func (h *CommentEditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	if uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	t, _, ok := loadCommentTarget(w, r, h.DB, uid)
+	if !ok {
+		return
+	}
+	if t.AuthorID != uid {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	commentID := r.PathValue("id")
+	dest := "/bets/" + t.BetID + "#comment-" + commentID
+	if t.Deleted {
+		http.Redirect(w, r, "/bets/"+t.BetID+"?comment=deleted#comments", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	content := clipComment(strings.TrimSpace(r.Form.Get("content")))
+	if content == "" || content == t.Content {
+		http.Redirect(w, r, dest, http.StatusSeeOther)
+		return
+	}
+	if _, err := h.DB.Exec(ctx, `
+		update comments set content = $2, edited_at = now()
+		where id = $1::uuid and deleted_at is null
+	`, commentID, content); err != nil {
+		slog.Error("comment.edit", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, dest, http.StatusSeeOther)
+}
+
+func (h *CommentDeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	if uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	t, role, ok := loadCommentTarget(w, r, h.DB, uid)
+	if !ok {
+		return
+	}
+	isMod := role == middleware.RoleModerator || role == middleware.RoleAdmin
+	if t.AuthorID != uid && !isMod {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	commentID := r.PathValue("id")
+	dest := "/bets/" + t.BetID + "?comment=deleted#comments"
+	if t.Deleted {
+		http.Redirect(w, r, dest, http.StatusSeeOther)
+		return
+	}
+
+	tx, err := h.DB.Begin(ctx)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `
+		update comments set content = '', deleted_at = now()
+		where id = $1::uuid and deleted_at is null
+	`, commentID); err != nil {
+		slog.Error("comment.delete", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	// Moderation removes someone else's words: keep them in the audit log.
+	if t.AuthorID != uid {
+		note := fmt.Sprintf("comment %s on bet %s: %s", commentID, t.BetID, truncateRunes(t.Content, 500))
+		if _, err := tx.Exec(ctx, `
+			insert into admin_actions (admin_user_id, target_user_id, action, note)
+			values ($1::uuid, $2::uuid, 'comment_delete', $3)
+		`, uid, t.AuthorID, note); err != nil {
+			slog.Error("comment.delete.audit", "err", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, dest, http.StatusSeeOther)
+}
+
 func redirectTarget(r *http.Request, betID string) string {
 	if ref := strings.TrimSpace(r.Header.Get("Referer")); ref != "" {
 		return ref
@@ -238,13 +478,14 @@ func (h *CommentCreateHandler) notifyComment(ctx context.Context, betID, userID,
 	notifyCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	var displayName, betTitle string
+	var displayName, betTitle, visibility string
 	if err := h.DB.QueryRow(notifyCtx, `select display_name from users where id = $1::uuid`, userID).Scan(&displayName); err != nil {
 		return
 	}
-	if err := h.DB.QueryRow(notifyCtx, `select title from bets where id = $1::uuid`, betID).Scan(&betTitle); err != nil {
+	if err := h.DB.QueryRow(notifyCtx, `select title, visibility::text from bets where id = $1::uuid`, betID).Scan(&betTitle, &visibility); err != nil {
 		return
 	}
+	public := visibility == visibilityPublic
 
 	link := betLink(h.BaseURL, betID)
 	commentLink := link + "#comment-" + commentID
@@ -263,6 +504,9 @@ func (h *CommentCreateHandler) notifyComment(ctx context.Context, betID, userID,
 		html.EscapeString(truncated),
 		html.EscapeString(commentLink),
 	)
-	h.Notifier.NotifyGroup(notifyCtx, msg)
-	h.Notifier.NotifySubscribers(notifyCtx, msg)
+	if public {
+		h.Notifier.NotifyGroup(notifyCtx, msg)
+		h.Notifier.NotifySubscribers(notifyCtx, msg)
+	}
+	notifyBetSubscribers(notifyCtx, h.DB, h.Notifier, betID, msg, public, userID)
 }
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/dbtest"
+	"betsandpedestres/internal/jobs"
+	"github.com/jackc/pgx/v5"
+)
+
+// TestAuditEscrowAccounts settles bets through resolution and cancellation
+// and checks that the escrow audit reports a settled bet whose escrow was
+// left non-zero, archives the emptied ones and leaves open bets alone.
+func TestAuditEscrowAccounts(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	unit := coins.Unit()
+	creator, _ := dbtest.User(t, pool, "creator", "")
+	admin, _ := dbtest.User(t, pool, "admin", "admin")
+	alice, aliceWallet := dbtest.User(t, pool, "alice", "")
+	bob, bobWallet := dbtest.User(t, pool, "bob", "")
+	dbtest.Fund(t, pool, aliceWallet, 100*unit)
+	dbtest.Fund(t, pool, bobWallet, 100*unit)
+
+	stakedBet := func() (betID string, opts []string) {
+		betID, opts = newTestBet(t, pool, creator, "Yes", "No")
+		placeTestWager(t, pool, alice, betID, opts[0], "10")
+		placeTestWager(t, pool, bob, betID, opts[1], "5")
+		return betID, opts
+	}
+	escrowOf := func(betID string) string {
+		var id string
+		if err := pool.QueryRow(ctx, `select id::text from accounts where bet_id = $1::uuid`, betID).Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	openBet, _ := stakedBet()
+
+	resolved, opts := stakedBet()
+	resolveTestBet(t, pool, resolved, opts[0], payoutPolicy{})
+
+	// A resolved bet whose escrow received a line after the payout.
+	leftover, opts := stakedBet()
+	resolveTestBet(t, pool, leftover, opts[0], payoutPolicy{})
+	if err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		return accounts.GiftFromHouse(ctx, tx, escrowOf(leftover), 1, "stray line")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, _ := stakedBet()
+	r := asUser(postForm("/bets/"+cancelled+"/cancel", url.Values{}), admin)
+	r.SetPathValue("id", cancelled)
+	rec := httptest.NewRecorder()
+	(&BetCancelHandler{DB: pool}).ServeHTTP(rec, r)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("cancel: status %d, body %q", rec.Code, rec.Body.String())
+	}
+
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(old)
+	if err := jobs.AuditEscrowAccounts(pool, true).Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	logs := buf.String()
+
+	for _, c := range []struct {
+		name     string
+		betID    string
+		reported bool
+		archived bool
+	}{
+		{"open", openBet, false, false},
+		{"resolved", resolved, false, true},
+		{"resolved with a leftover", leftover, true, false},
+		{"cancelled", cancelled, false, true},
+	} {
+		if got := strings.Contains(logs, "bet_id="+c.betID); got != c.reported {
+			t.Errorf("%s bet reported = %v, want %v", c.name, got, c.reported)
+		}
+		var archived bool
+		if err := pool.QueryRow(ctx, `select archived_at is not null from accounts where id = $1::uuid`, escrowOf(c.betID)).Scan(&archived); err != nil {
+			t.Fatal(err)
+		}
+		if archived != c.archived {
+			t.Errorf("%s bet escrow archived = %v, want %v", c.name, archived, c.archived)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserFollowHandler follows or unfollows the user named in the path,
+// depending on the form's action.
+type UserFollowHandler struct {
+	DB *pgxpool.Pool
+}
+
+func (h *UserFollowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	if uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	role, err := middleware.GetUserRole(ctx, h.DB, uid)
+	if err != nil || role == middleware.RoleUnverified {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	username := r.PathValue("username")
+	back := "/profile/" + url.PathEscape(username)
+
+	var targetID string
+	err = h.DB.QueryRow(ctx, `select id::text from users where username = $1 and disabled_at is null`, username).Scan(&targetID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if targetID == uid {
+		http.Redirect(w, r, back+"?follow=self", http.StatusSeeOther)
+		return
+	}
+
+	status := "followed"
+	if r.Form.Get("action") == "unfollow" {
+		status = "unfollowed"
+		_, err = h.DB.Exec(ctx, `delete from user_follows where follower_id = $1 and followee_id = $2`, uid, targetID)
+	} else {
+		_, err = h.DB.Exec(ctx, `
+			insert into user_follows (follower_id, followee_id) values ($1, $2)
+			on conflict do nothing
+		`, uid, targetID)
+	}
+	if err != nil {
+		slog.Error("follows.update", "err", err)
+		status = "error"
+	}
+	http.Redirect(w, r, back+"?follow="+status, http.StatusSeeOther)
+}
+
+type followStats struct {
+	Followers int
+	Following int
+	ByViewer  bool // the viewer follows this user
+}
+
+func fetchFollowStats(ctx context.Context, db *pgxpool.Pool, userID, viewerID string) (followStats, error) {
+	var st followStats
+	err := db.QueryRow(ctx, `
+		select
+		  (select count(*) from user_follows f join users u on u.id = f.follower_id
+		    where f.followee_id = $1 and u.disabled_at is null),
+		  (select count(*) from user_follows f join users u on u.id = f.followee_id
+		    where f.follower_id = $1 and u.disabled_at is null),
+		  exists (select 1 from user_follows where follower_id = $2::uuid and followee_id = $1)
+	`, userID, viewerID).Scan(&st.Followers, &st.Following, &st.ByViewer)
+	return st, err
+}
+
+// notifyFollowers tells the creator's followers about a new public bet.
+// Followers subscribed to every new bet already got the broadcast and are
+// skipped.
+func notifyFollowers(ctx context.Context, db *pgxpool.Pool, n notify.Notifier, creatorID, creatorName, betTitle, link string) {
+	if n == nil {
+		return
+	}
+	rows, err := db.Query(ctx, `
+		select f.follower_id::text
+		from user_follows f
+		join users u on u.id = f.follower_id
+		where f.followee_id = $1
+		  and u.disabled_at is null
+		  and u.role <> 'unverified'
+		  and not (u.telegram_notify and u.telegram_chat_id is not null)
+	`, creatorID)
+	if err != nil {
+		slog.Warn("follows.notify_query", "err", err)
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			slog.Warn("follows.notify_scan", "err", err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	msg := fmt.Sprintf("%s, whom you follow, opened a new bet: \"%s\".\n%s", creatorName, betTitle, link)
+	for _, id := range ids {
+		n.NotifyUser(ctx, id, msg)
+	}
+}
@@ -2,9 +2,13 @@ package http
 
 import (
 	"context"
+	"html/template"
 	"strings"
 	"time"
 
+	"betsandpedestres/internal/announcements"
+	"betsandpedestres/internal/captcha"
+	"betsandpedestres/internal/http/middleware"
 	"betsandpedestres/internal/web"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -21,8 +25,44 @@ func SetVersion(v string) {
 	appVersion = v
 }
 
+var appBranding = web.Branding{Name: "Bets & Pedestres"}
+
+// appCaptcha gates registration and recovery requests; nil disables it.
+var appCaptcha captcha.Provider
+
+// SetCaptcha configures the CAPTCHA provider checked on registration and
+// recovery requests. Call before NewMux; nil turns verification off.
+func SetCaptcha(p captcha.Provider) {
+	appCaptcha = p
+}
+
+func captchaWidget() web.Captcha {
+	if appCaptcha == nil {
+		return web.Captcha{}
+	}
+	return web.Captcha{ScriptURL: appCaptcha.ScriptURL(), Class: appCaptcha.WidgetClass(), SiteKey: appCaptcha.SiteKey()}
+}
+
+// SetBranding configures the instance name, tagline and footer shown in the UI.
+// footerHTML is operator config and is rendered unescaped.
+func SetBranding(name, tagline, footerHTML string) {
+	if name = strings.TrimSpace(name); name != "" {
+		appBranding.Name = name
+	}
+	appBranding.Tagline = strings.TrimSpace(tagline)
+	appBranding.FooterHTML = template.HTML(footerHTML)
+}
+
+func activeBanners(ctx context.Context) []web.Banner {
+	var out []web.Banner
+	for _, a := range announcements.Active(ctx) {
+		out = append(out, web.Banner{ID: a.ID, Message: a.Message, Severity: a.Severity})
+	}
+	return out
+}
+
 func loadHeader(ctx context.Context, db *pgxpool.Pool, uid string) (web.HeaderData, string) {
-	header := web.HeaderData{}
+	header := web.HeaderData{Brand: appBranding, Captcha: captchaWidget(), Banners: activeBanners(ctx)}
 	if uid == "" {
 		header.Version = appVersion
 		return header, ""
@@ -34,11 +74,12 @@ func loadHeader(ctx context.Context, db *pgxpool.Pool, uid string) (web.HeaderDa
 	err := db.QueryRow(ctxHead, `
 			select u.username, u.display_name, coalesce(b.balance,0), u.role
 			from users u
-			left join user_balances b on b.user_id = u.id
-			where u.id = $1
+			left join user_balances_mv b on b.user_id = u.id
+			where u.id = $1 and u.disabled_at is null
 		`, uid).Scan(&header.Username, &header.DisplayName, &header.Balance, &role)
 	if err == nil && header.Username != "" {
 		header.LoggedIn = true
+		middleware.StoreRole(ctx, uid, role)
 	}
 	header.Version = appVersion
 	return header, role
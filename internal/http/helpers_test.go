@@ -0,0 +1,89 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// asUser returns r as if sent by the logged-in user uid.
+func asUser(r *http.Request, uid string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), middleware.CtxUserID, uid))
+}
+
+// postForm builds a form POST to target.
+func postForm(target string, form url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+// newTestBet creates a public bet by creatorID with the given outcomes and
+// returns its id and option ids in the same order.
+func newTestBet(t *testing.T, pool *pgxpool.Pool, creatorID string, options ...string) (betID string, optionIDs []string) {
+	t.Helper()
+	return newTestBetForm(t, pool, creatorID, betForm{Title: "Test bet", Options: options, Visibility: visibilityPublic})
+}
+
+// newTestBetForm is newTestBet for a bet created from form.
+func newTestBetForm(t *testing.T, pool *pgxpool.Pool, creatorID string, form betForm) (betID string, optionIDs []string) {
+	t.Helper()
+	ctx := context.Background()
+	h := &BetCreateHandler{DB: pool}
+	betID, _, err := h.createBet(ctx, creatorID, form)
+	if err != nil {
+		t.Fatalf("create bet: %v", err)
+	}
+	for _, label := range form.Options {
+		var id string
+		if err := pool.QueryRow(ctx, `select id::text from bet_options where bet_id = $1::uuid and label = $2`, betID, label).Scan(&id); err != nil {
+			t.Fatalf("option %q: %v", label, err)
+		}
+		optionIDs = append(optionIDs, id)
+	}
+	return betID, optionIDs
+}
+
+// placeTestWager places a wager through BetWagerCreateHandler, as the form
+// on the bet page would, and fails the test unless it was accepted.
+func placeTestWager(t *testing.T, pool *pgxpool.Pool, uid, betID, optionID, amount string) {
+	t.Helper()
+	rec := postTestWager(t, pool, uid, betID, optionID, amount, randomHex(8))
+	if loc := rec.Header().Get("Location"); rec.Code != http.StatusSeeOther || !strings.HasSuffix(loc, "note=placed") {
+		t.Fatalf("wager of %s by %s: status %d, location %q, body %q", amount, uid, rec.Code, loc, rec.Body.String())
+	}
+}
+
+// postTestWager submits the wager form with a freshly issued nonce.
+func postTestWager(t *testing.T, pool *pgxpool.Pool, uid, betID, optionID, amount, idempotencyKey string) *httptest.ResponseRecorder {
+	t.Helper()
+	nonce, err := issueWagerNonce(context.Background(), pool, uid, betID)
+	if err != nil {
+		t.Fatalf("issue wager nonce: %v", err)
+	}
+	return postTestWagerNonce(t, pool, uid, betID, optionID, amount, idempotencyKey, nonce)
+}
+
+// postTestWagerNonce submits the wager form with the given nonce.
+func postTestWagerNonce(t *testing.T, pool *pgxpool.Pool, uid, betID, optionID, amount, idempotencyKey, nonce string) *httptest.ResponseRecorder {
+	t.Helper()
+	h := &BetWagerCreateHandler{DB: pool, Notifier: notify.Noop{}}
+	form := url.Values{
+		"option_id":       {optionID},
+		"amount":          {amount},
+		"idempotency_key": {idempotencyKey},
+		"nonce":           {nonce},
+	}
+	r := asUser(postForm("/bets/"+betID+"/wagers", form), uid)
+	r.SetPathValue("id", betID)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
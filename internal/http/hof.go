@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"betsandpedestres/internal/accounts"
 	"betsandpedestres/internal/http/middleware"
 	"betsandpedestres/internal/web"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -26,13 +27,14 @@ type hallOfFameRow struct {
 }
 
 type hallOfFameContent struct {
-	Title string
-	Rows  []hallOfFameRow
+	Title   string
+	Rows    []hallOfFameRow
+	Economy *accounts.Economy // admins only
 }
 
 func (h *HallOfFameHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	uid := middleware.UserID(r)
-	header, _ := loadHeader(r.Context(), h.DB, uid)
+	header, role := loadHeader(r.Context(), h.DB, uid)
 	if !header.LoggedIn {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
@@ -55,7 +57,7 @@ func (h *HallOfFameHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		       coalesce(e.escrow_total,0)::bigint as escrow,
 		       coalesce(ub.balance,0)::bigint + coalesce(e.escrow_total,0)::bigint as total
 		from users u
-		left join user_balances ub on ub.user_id = u.id
+		left join user_balances_mv ub on ub.user_id = u.id
 		left join escrow e on e.user_id = u.id
 		order by total desc, u.display_name asc
 		limit 50
@@ -81,13 +83,17 @@ func (h *HallOfFameHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	page := web.Page[hallOfFameContent]{
-		Header: header,
-		Content: hallOfFameContent{
-			Title: "PiedPièces Hall of Fame",
-			Rows:  list,
-		},
+	content := hallOfFameContent{
+		Title: "PiedPièces Hall of Fame",
+		Rows:  list,
 	}
+	if role == middleware.RoleAdmin {
+		if eco, err := accounts.LoadEconomy(ctx, h.DB); err == nil {
+			content.Economy = &eco
+		}
+	}
+
+	page := web.Page[hallOfFameContent]{Header: header, Content: content}
 
 	var buf bytes.Buffer
 	if err := h.TPL.Render(&buf, "hof", page); err != nil {
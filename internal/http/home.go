@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -17,8 +18,17 @@ import (
 )
 
 type HomeHandler struct {
-	DB  *pgxpool.Pool
+	DB  *pgxpool.Pool // may be a read replica
 	TPL *web.Renderer
+
+	// PublicBrowsing shows logged-out visitors the feed instead of only the
+	// signup page.
+	PublicBrowsing bool
+
+	// WriteDB (the primary) pays the optional DailyStipend (minor units),
+	// claimed on the first home page view of each UTC day.
+	WriteDB      *pgxpool.Pool
+	DailyStipend int64
 }
 
 type betOptionSummary struct {
@@ -35,6 +45,7 @@ type betCard struct {
 	Deadline      *time.Time
 	Stakes        int64
 	Participants  int64
+	Comments      int64
 	Options       []betOptionSummary
 	Status        string
 	StatusLabel   string
@@ -43,6 +54,9 @@ type betCard struct {
 	WinningOption *string
 	VoteCount     int
 	VotesAgree    bool
+	StakesHidden  bool // open blind bet seen by a non-moderator
+	ClosingSoon   bool // open, deadline within closingSoonWindow
+	Archived      bool
 }
 
 type creatorOpt struct {
@@ -63,6 +77,7 @@ type homeContent struct {
 	UserFilter   string // creator username ("" = all)
 	PartFilter   string // "all"|"me"|"notme"
 	ExpiryFilter string
+	Archived     bool // ?archived=1: archived bets instead of live ones
 	SortChoices  []struct{ Key, Label string }
 	Creators     []creatorOpt
 
@@ -71,49 +86,25 @@ type homeContent struct {
 	SignupStatus string
 	Role         string
 	Description  string
+	Stipend      int64 // daily stipend paid on this view, if any
+	Anonymous    bool  // logged-out visitor browsing the public feed
 }
 
 func (h *HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	uid := middleware.UserID(r)
 	header, role := loadHeader(r.Context(), h.DB, uid)
 
-	// Controls
 	q := r.URL.Query()
-	page := atoiDefault(q.Get("page"), 1)
-	if page < 1 {
-		page = 1
-	}
-	size := atoiDefault(q.Get("size"), 20)
-	if size < 1 {
-		size = 20
-	}
-	if size > 100 {
-		size = 100
-	}
-	sort := q.Get("sort")
-	if sort == "" {
-		sort = "created_desc"
-	}
-	userFilter := strings.TrimSpace(q.Get("user")) // creator username
-	partFilter := strings.TrimSpace(q.Get("p"))    // "all","me","notme"
-	if partFilter == "" {
-		partFilter = "all"
-	}
-	expiryFilter := strings.TrimSpace(q.Get("exp"))
-	switch expiryFilter {
-	case "", "unresolved":
-		expiryFilter = "unresolved"
-	case "all", "expired", "open", "waiting", "closed":
-	default:
-		expiryFilter = "unresolved"
-	}
+	lq := parseBetListQuery(q)
 
-	if !header.LoggedIn {
+	// Spectators reach the signup form through ?join=1; signup results
+	// (?signup=...) are always shown there.
+	if !header.LoggedIn && (!h.PublicBrowsing || q.Get("join") != "" || q.Get("signup") != "") {
 		content := homeContent{
-			Title:        "Welcome to Bets & Pedestres",
+			Title:        "Welcome to " + header.Brand.Name,
 			ShowSignup:   true,
 			SignupStatus: q.Get("signup"),
-			Description:  "Bets & Pedestres lets you create friendly prediction markets with transparent escrows and community-driven resolutions.",
+			Description:  header.Brand.Tagline,
 		}
 		page := web.Page[homeContent]{Header: header, Content: content}
 		var buf bytes.Buffer
@@ -145,20 +136,14 @@ func (h *HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	orderBy := `order by b.created_at desc, b.id desc`
-	switch sort {
-	case "created_asc":
-		orderBy = `order by b.created_at asc, b.id asc`
-	case "deadline_asc":
-		orderBy = `order by b.deadline asc nulls last, b.id asc`
-	case "deadline_desc":
-		orderBy = `order by b.deadline desc nulls last, b.id desc`
-	case "most_stakes":
-		orderBy = `order by coalesce(sum_w,0) desc, b.created_at desc, b.id desc`
-	case "least_stakes":
-		orderBy = `order by coalesce(sum_w,0) asc, b.created_at desc, b.id desc`
-	case "participants_desc":
-		orderBy = `order by coalesce(participants,0) desc, b.created_at desc, b.id desc`
+	var stipend int64
+	if uid != "" && h.DailyStipend > 0 && h.WriteDB != nil {
+		granted, err := claimDailyStipend(r.Context(), h.WriteDB, uid, h.DailyStipend)
+		if err != nil {
+			slog.Error("stipend.claim", "user", uid, "err", err)
+		} else if granted {
+			stipend = h.DailyStipend
+		}
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
@@ -171,7 +156,7 @@ func (h *HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			select distinct u.username, u.display_name
 			from bets b
 			join users u on u.id = b.creator_user_id
-			where b.status = 'open'
+			where b.status = 'open' and b.visibility = 'public'
 			order by u.display_name asc
 		`)
 		if err == nil {
@@ -186,6 +171,133 @@ func (h *HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	isMod := role == middleware.RoleModerator || role == middleware.RoleAdmin
+	list, hasNext, err := listBets(ctx, h.DB, uid, isMod, lq)
+	if err != nil {
+		slog.Error("db error", "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	choices := []struct{ Key, Label string }{
+		{"created_desc", "Latest created"},
+		{"created_asc", "Earliest created"},
+		{"deadline_asc", "Earliest deadline"},
+		{"deadline_desc", "Latest deadline"},
+		{"most_stakes", "Most stakes"},
+		{"least_stakes", "Least stakes"},
+		{"participants_desc", "Most participants"},
+	}
+
+	title, archivedParam := "Active bets", ""
+	if lq.Archived {
+		title, archivedParam = "Archived bets", "&archived=1"
+	}
+	content := homeContent{
+		Title:        title,
+		Rows:         list,
+		Page:         lq.Page,
+		Size:         lq.Size,
+		HasPrev:      lq.Page > 1,
+		HasNext:      hasNext,
+		PrevURL:      buildURL("/?page="+itoa(lq.Page-1)+"&size="+itoa(lq.Size)+"&sort="+lq.Sort+archivedParam, lq.UserFilter, lq.PartFilter, lq.ExpiryFilter),
+		NextURL:      buildURL("/?page="+itoa(lq.Page+1)+"&size="+itoa(lq.Size)+"&sort="+lq.Sort+archivedParam, lq.UserFilter, lq.PartFilter, lq.ExpiryFilter),
+		Sort:         lq.Sort,
+		UserFilter:   lq.UserFilter,
+		PartFilter:   lq.PartFilter,
+		ExpiryFilter: lq.ExpiryFilter,
+		Archived:     lq.Archived,
+		SortChoices:  choices,
+		Creators:     creators,
+		Role:         role,
+		Stipend:      stipend,
+		Anonymous:    !header.LoggedIn,
+	}
+
+	pageVM := web.Page[homeContent]{Header: header, Content: content}
+
+	var buf bytes.Buffer
+	if err := h.TPL.Render(&buf, "home", pageVM); err != nil {
+		slog.Error("could not render", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// betListQuery is the feed's page, sort and filters, shared by the home
+// page and GET /api/v1/bets.
+type betListQuery struct {
+	Page         int
+	Size         int
+	Sort         string
+	UserFilter   string // creator username ("" = all)
+	PartFilter   string // "all"|"me"|"notme"
+	ExpiryFilter string
+	Archived     bool
+}
+
+// parseBetListQuery reads a betListQuery from the query string, falling
+// back to defaults for missing or unknown values.
+func parseBetListQuery(q url.Values) betListQuery {
+	lq := betListQuery{
+		Page:         atoiDefault(q.Get("page"), 1),
+		Size:         atoiDefault(q.Get("size"), 20),
+		UserFilter:   strings.TrimSpace(q.Get("user")),
+		PartFilter:   strings.TrimSpace(q.Get("p")),
+		Sort:         q.Get("sort"),
+		Archived:     q.Get("archived") == "1",
+		ExpiryFilter: strings.TrimSpace(q.Get("exp")),
+	}
+	if lq.Page < 1 {
+		lq.Page = 1
+	}
+	if lq.Size < 1 {
+		lq.Size = 20
+	}
+	if lq.Size > 100 {
+		lq.Size = 100
+	}
+	if lq.PartFilter == "" {
+		lq.PartFilter = "all"
+	}
+	// Bets you are in are most useful soonest-deadline first.
+	if lq.Sort == "" {
+		lq.Sort = "created_desc"
+		if lq.PartFilter == "me" {
+			lq.Sort = "deadline_asc"
+		}
+	}
+	switch lq.ExpiryFilter {
+	case "", "unresolved":
+		lq.ExpiryFilter = "unresolved"
+	case "all", "expired", "open", "waiting", "closed":
+	default:
+		lq.ExpiryFilter = "unresolved"
+	}
+	return lq
+}
+
+// listBets returns one page of the bet feed visible to uid, and whether a
+// next page exists. Blind bets' stakes stay hidden unless isMod.
+func listBets(ctx context.Context, db *pgxpool.Pool, uid string, isMod bool, lq betListQuery) ([]betCard, bool, error) {
+	orderBy := `order by b.created_at desc, b.id desc`
+	switch lq.Sort {
+	case "created_asc":
+		orderBy = `order by b.created_at asc, b.id asc`
+	case "deadline_asc":
+		orderBy = `order by b.deadline asc nulls last, b.id asc`
+	case "deadline_desc":
+		orderBy = `order by b.deadline desc nulls last, b.id desc`
+	case "most_stakes":
+		orderBy = `order by coalesce(sum_w,0) desc, b.created_at desc, b.id desc`
+	case "least_stakes":
+		orderBy = `order by coalesce(sum_w,0) asc, b.created_at desc, b.id desc`
+	case "participants_desc":
+		orderBy = `order by coalesce(participants,0) desc, b.created_at desc, b.id desc`
+	}
+
 	args := []any{}
 	arg := func(v any) string {
 		args = append(args, v)
@@ -194,34 +306,44 @@ func (h *HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	baseFilters := []string{}
 	nowExpr := "now() at time zone 'utc'"
-	switch expiryFilter {
-	case "unresolved":
-		baseFilters = append(baseFilters, `(b.status = 'open')`)
-	case "open":
-		baseFilters = append(baseFilters, `(b.status = 'open' and (b.deadline is null or b.deadline > `+nowExpr+`))`)
-	case "expired":
-		baseFilters = append(baseFilters, `(b.status = 'open' and b.deadline is not null and b.deadline <= `+nowExpr+`)`)
-	case "waiting":
-		baseFilters = append(baseFilters, `(b.status = 'open' and exists (select 1 from bet_resolution_votes v where v.bet_id = b.id))`)
-	case "closed":
-		baseFilters = append(baseFilters, `(b.status <> 'open')`)
-	case "all":
-		// no filter
-	default:
-		baseFilters = append(baseFilters, `(b.status = 'open')`)
+	// Archived bets are all closed, so the status filter does not apply.
+	if lq.Archived {
+		baseFilters = append(baseFilters, `(b.archived_at is not null)`)
+	} else {
+		baseFilters = append(baseFilters, `(b.archived_at is null)`)
+		switch lq.ExpiryFilter {
+		case "unresolved":
+			baseFilters = append(baseFilters, `(b.status = 'open')`)
+		case "open":
+			baseFilters = append(baseFilters, `(b.status = 'open' and (b.deadline is null or b.deadline > `+nowExpr+`))`)
+		case "expired":
+			baseFilters = append(baseFilters, `(b.status = 'open' and b.deadline is not null and b.deadline <= `+nowExpr+`)`)
+		case "waiting":
+			baseFilters = append(baseFilters, `(b.status = 'open' and exists (select 1 from bet_resolution_votes v where v.bet_id = b.id))`)
+		case "closed":
+			baseFilters = append(baseFilters, `(b.status <> 'open')`)
+		case "all":
+			// no filter
+		default:
+			baseFilters = append(baseFilters, `(b.status = 'open')`)
+		}
 	}
 
+	// Unlisted bets never show up in the feed; private ones only for the
+	// people they are shared with.
+	baseFilters = append(baseFilters, `(b.visibility = 'public' or (b.visibility = 'private' and `+betParticipantSQL(arg(uid))+`))`)
+
 	whereAgg := "where true"
 	if len(baseFilters) > 0 {
 		whereAgg = `where ` + strings.Join(baseFilters, " and ")
 	}
 
 	whereOuterParts := append([]string{}, baseFilters...)
-	if userFilter != "" {
-		whereOuterParts = append(whereOuterParts, `u.username = `+arg(userFilter))
+	if lq.UserFilter != "" {
+		whereOuterParts = append(whereOuterParts, `u.username = `+arg(lq.UserFilter))
 	}
-	if uid != "" && partFilter != "all" {
-		switch partFilter {
+	if uid != "" && lq.PartFilter != "all" {
+		switch lq.PartFilter {
 		case "me":
 			whereOuterParts = append(whereOuterParts, `exists (
 			select 1 from wagers w where w.bet_id = b.id and w.user_id = `+arg(uid)+`
@@ -237,8 +359,8 @@ func (h *HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		whereOuter = `where ` + strings.Join(whereOuterParts, " and ")
 	}
 
-	limit := size + 1
-	offset := (page - 1) * size
+	limit := lq.Size + 1
+	offset := (lq.Page - 1) * lq.Size
 	limitPH := arg(limit)
 	offsetPH := arg(offset)
 
@@ -263,6 +385,9 @@ select
   b.deadline,
   coalesce(a.sum_w, 0)        as stakes,
   coalesce(a.participants, 0) as participants,
+  -- per page row rather than in agg: agg spans every matching bet before
+  -- the limit, while this runs only for the rows returned (idx_comments_bet)
+  (select count(*) from comments c where c.bet_id = b.id) as comment_count,
   (select array_agg(bo.label order by bo.position asc) from bet_options bo where bo.bet_id = b.id) as opt_labels,
   (select array_agg(coalesce(ws.sum_amount,0)::bigint order by bo.position asc)
      from bet_options bo
@@ -277,7 +402,9 @@ select
   (select count(*)::int from bet_resolution_votes v where v.bet_id = b.id) as vote_count,
   (select case when count(distinct option_id) <= 1 then true else false end
      from bet_resolution_votes v where v.bet_id = b.id) as votes_agree,
-  b.resolution_option_id::text as winning_option
+  b.resolution_option_id::text as winning_option,
+  b.blind,
+  b.archived_at is not null as archived
 from bets b
 join users u on u.id = b.creator_user_id
 left join agg a on a.id = b.id
@@ -285,12 +412,9 @@ left join agg a on a.id = b.id
 ` + orderBy + `
 limit ` + limitPH + `::int offset ` + offsetPH + `::int
 `
-	rows, err := h.DB.Query(ctx, sql, args...)
-
+	rows, err := db.Query(ctx, sql, args...)
 	if err != nil {
-		slog.Error("db error", "error", err)
-		http.Error(w, "db error", http.StatusInternalServerError)
-		return
+		return nil, false, err
 	}
 	defer rows.Close()
 
@@ -299,63 +423,29 @@ limit ` + limitPH + `::int offset ` + offsetPH + `::int
 		var bc betCard
 		var optLabels []string
 		var optStakes []int64
-		if err := rows.Scan(&bc.ID, &bc.Title, &bc.CreatorName, &bc.CreatorUser, &bc.CreatedAt, &bc.Deadline, &bc.Stakes, &bc.Participants, &optLabels, &optStakes, &bc.Status, &bc.VoteCount, &bc.VotesAgree, &bc.WinningOption); err != nil {
-			http.Error(w, "scan error", http.StatusInternalServerError)
-			return
+		var blind bool
+		if err := rows.Scan(&bc.ID, &bc.Title, &bc.CreatorName, &bc.CreatorUser, &bc.CreatedAt, &bc.Deadline, &bc.Stakes, &bc.Participants, &bc.Comments, &optLabels, &optStakes, &bc.Status, &bc.VoteCount, &bc.VotesAgree, &bc.WinningOption, &blind, &bc.Archived); err != nil {
+			return nil, false, err
+		}
+		bc.StakesHidden = blind && bc.Status == "open" && bc.WinningOption == nil && !isMod
+		if bc.StakesHidden {
+			bc.Stakes = 0
+			optStakes = make([]int64, len(optLabels))
 		}
 		bc.Options = buildOptionSummaries(optLabels, optStakes, bc.Stakes)
 		decorateBetCard(&bc)
 		list = append(list, bc)
 	}
 	if err := rows.Err(); err != nil {
-		http.Error(w, "db rows error", http.StatusInternalServerError)
-		return
+		return nil, false, err
 	}
 
 	hasNext := false
-	if len(list) > size {
+	if len(list) > lq.Size {
 		hasNext = true
-		list = list[:size]
-	}
-
-	choices := []struct{ Key, Label string }{
-		{"created_desc", "Latest created"},
-		{"created_asc", "Earliest created"},
-		{"deadline_asc", "Earliest deadline"},
-		{"deadline_desc", "Latest deadline"},
-		{"most_stakes", "Most stakes"},
-		{"least_stakes", "Least stakes"},
-		{"participants_desc", "Most participants"},
-	}
-
-	content := homeContent{
-		Title:        "Active bets",
-		Rows:         list,
-		Page:         page,
-		Size:         size,
-		HasPrev:      page > 1,
-		HasNext:      hasNext,
-		PrevURL:      buildURL("/?page="+itoa(page-1)+"&size="+itoa(size)+"&sort="+sort, userFilter, partFilter, expiryFilter),
-		NextURL:      buildURL("/?page="+itoa(page+1)+"&size="+itoa(size)+"&sort="+sort, userFilter, partFilter, expiryFilter),
-		Sort:         sort,
-		UserFilter:   userFilter,
-		PartFilter:   partFilter,
-		ExpiryFilter: expiryFilter,
-		SortChoices:  choices,
-		Creators:     creators,
-		Role:         role,
+		list = list[:lq.Size]
 	}
-
-	pageVM := web.Page[homeContent]{Header: header, Content: content}
-
-	var buf bytes.Buffer
-	if err := h.TPL.Render(&buf, "home", pageVM); err != nil {
-		slog.Error("could not render", "error", err)
-		http.Error(w, "template error", http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_, _ = w.Write(buf.Bytes())
+	return list, hasNext, nil
 }
 
 func buildURL(base, user, p, exp string) string {
@@ -431,9 +521,19 @@ func buildOptionSummaries(labels []string, stakes []int64, total int64) []betOpt
 	return opts
 }
 
+// closingSoonWindow is how close to its deadline an open bet gets flagged.
+const closingSoonWindow = 24 * time.Hour
+
 func decorateBetCard(bc *betCard) {
 	bc.StatusLabel, bc.StatusColor = statusBadge(bc.Deadline, bc.WinningOption, bc.Status, bc.VoteCount, bc.VotesAgree)
+	if bc.Archived {
+		bc.StatusLabel, bc.StatusColor = "Archived", "#374151"
+	}
 	bc.ExpiresIn = formatExpiresIn(bc.Deadline)
+	if bc.Deadline != nil && bc.Status == "open" && bc.WinningOption == nil {
+		left := bc.Deadline.Sub(time.Now().UTC())
+		bc.ClosingSoon = left > 0 && left <= closingSoonWindow
+	}
 }
 
 func statusBadge(deadline *time.Time, winning *string, status string, votes int, votesAgree bool) (string, string) {
@@ -444,6 +544,8 @@ func statusBadge(deadline *time.Time, winning *string, status string, votes int,
 	alreadyClosed := (status != "open") || (winning != nil)
 
 	switch {
+	case status == "cancelled":
+		return "Cancelled", "#4b5563"
 	case alreadyClosed:
 		return "Closed", "#5c1c1c"
 	case waitingAdmin:
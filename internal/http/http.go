@@ -1,53 +1,110 @@
 package http
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"betsandpedestres/internal/coins"
 	"betsandpedestres/internal/config"
 	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/metrics"
 	"betsandpedestres/internal/notify"
+	"betsandpedestres/internal/notify/email"
 	"betsandpedestres/internal/telegram"
 	"betsandpedestres/internal/web"
 	"betsandpedestres/resources"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func NewMux(db *pgxpool.Pool, cfg *config.Config) (*http.ServeMux, error) {
+// NewMux wires the routes behind the maintenance-mode gate. readDB serves
+// read-only pages and may be a replica; pass nil (or db) to read from the primary.
+func NewMux(db, readDB *pgxpool.Pool, cfg *config.Config) (http.Handler, error) {
 	mux := http.NewServeMux()
+	if readDB == nil {
+		readDB = db
+	}
 
 	rend, err := web.NewRenderer()
 	if err != nil {
 		return nil, err
 	}
 
-	var notifier notify.Notifier = notify.Noop{}
-	if cfg.Telegram.BotToken != "" {
-		notifier = telegram.New(db, cfg.Telegram.BotToken, cfg.Telegram.GroupChatID)
+	notifier := NewNotifier(db, cfg)
+
+	var creationFee, creatorReward int64
+	switch cfg.Bets.CreatorIncentive {
+	case config.CreatorIncentiveFee:
+		creationFee = cfg.Bets.CreatorIncentiveAmount * coins.Unit()
+	case config.CreatorIncentiveReward:
+		creatorReward = cfg.Bets.CreatorIncentiveAmount * coins.Unit()
 	}
 
-	mux.Handle("GET /", &HomeHandler{DB: db, TPL: rend})
-	mux.Handle("GET /transactions", &TransactionsHandler{DB: db, TPL: rend})
-	mux.Handle("GET /bets/new", &BetNewHandler{DB: db, TPL: rend})
-	mux.Handle("POST /bets", &BetCreateHandler{DB: db, Notifier: notifier, BaseURL: cfg.BaseURL})
-	mux.Handle("GET /bets/{id}", &BetShowHandler{DB: db, TPL: rend, Quorum: cfg.Moderation.Quorum})
-	mux.Handle("POST /bets/{id}/wagers", &BetWagerCreateHandler{DB: db, Notifier: notifier, BaseURL: cfg.BaseURL})
-	mux.Handle("POST /bets/{id}/comments", &CommentCreateHandler{DB: db, Notifier: notifier, BaseURL: cfg.BaseURL})
+	mux.Handle("GET /", &HomeHandler{DB: readDB, TPL: rend, PublicBrowsing: cfg.Site.PublicBrowsing, WriteDB: db, DailyStipend: cfg.Accounts.DailyStipend * coins.Unit()})
+	mux.Handle("GET /transactions", &TransactionsHandler{DB: readDB, TPL: rend})
+	mux.Handle("GET /api/v1/transactions", &TransactionsAPIHandler{DB: readDB})
+	mux.Handle("GET /bets/new", &BetNewHandler{DB: db, TPL: rend, CreationFee: creationFee})
+	mux.Handle("POST /bets", &BetCreateHandler{DB: db, Notifier: notifier, BaseURL: cfg.BaseURL, DeadlineHorizon: cfg.Bets.MaxDeadlineHorizon, MinAccountAge: cfg.Accounts.MinAccountAge, TitleCaseOptions: cfg.Bets.TitleCaseOptions, CreationFee: creationFee})
+	showHandler := &BetShowHandler{DB: db, TPL: rend, PublicBrowsing: cfg.Site.PublicBrowsing, BaseURL: cfg.BaseURL, Quorum: cfg.Moderation.Quorum, QuorumFraction: cfg.Moderation.QuorumFraction, PublicVotes: cfg.Moderation.PublicVotes, WagerCooldown: cfg.Bets.WagerCooldown, MinWager: cfg.Moderation.MinWager * coins.Unit(), MaxWager: cfg.Moderation.MaxWager * coins.Unit()}
+	mux.Handle("GET /bets/{id}", showHandler)
+	editHandler := &BetEditHandler{DB: db, TPL: rend, DeadlineHorizon: cfg.Bets.MaxDeadlineHorizon, TitleCaseOptions: cfg.Bets.TitleCaseOptions}
+	mux.Handle("GET /bets/{id}/edit", editHandler)
+	mux.Handle("POST /bets/{id}/edit", editHandler)
+	mux.Handle("GET /bets/{id}/results.json", &BetResultsHandler{Show: showHandler, Format: "json"})
+	mux.Handle("GET /bets/{id}/results.csv", &BetResultsHandler{Show: showHandler, Format: "csv"})
+	mux.Handle("GET /api/v1/bets", middleware.RequireAuth(&BetsAPIHandler{DB: readDB}))
+	mux.Handle("GET /api/v1/bets/{id}", middleware.RequireAuth(&BetAPIHandler{Show: showHandler}))
+	wagerLimiter := middleware.NewRateLimiter(cfg.RateLimits.Wager.Limit, cfg.RateLimits.Wager.Window)
+	wagerHandler := &BetWagerCreateHandler{DB: db, Notifier: notifier, BaseURL: cfg.BaseURL, Limiter: wagerLimiter, MinAccountAge: cfg.Accounts.MinAccountAge, Cooldown: cfg.Bets.WagerCooldown, MinWager: cfg.Moderation.MinWager * coins.Unit(), MaxWager: cfg.Moderation.MaxWager * coins.Unit()}
+	if cfg.Telegram.WagerBatchWindow > 0 {
+		wagerHandler.Batcher = newWagerBatcher(db, notifier, cfg.Telegram.WagerBatchWindow)
+		wagerBatcher = wagerHandler.Batcher
+	}
+	mux.Handle("POST /bets/{id}/wagers", wagerHandler)
+	mux.Handle("POST /bets/{id}/wagers/{wagerID}/cancel", &BetWagerCancelHandler{DB: db, Notifier: notifier, BaseURL: cfg.BaseURL})
+	mux.Handle("POST /bets/{id}/invite", &BetInviteHandler{DB: db, Notifier: notifier, BaseURL: cfg.BaseURL})
+	mux.Handle("POST /bets/{id}/comments", &CommentCreateHandler{DB: db, Notifier: notifier, BaseURL: cfg.BaseURL, MaxComments: cfg.Bets.MaxComments, MaxReplies: cfg.Bets.MaxRepliesPerComment})
 	mux.Handle("POST /comments/{id}/react", &CommentReactHandler{DB: db})
-	mux.Handle("POST /bets/{id}/resolve", &BetResolveHandler{DB: db, Quorum: cfg.Moderation.Quorum, Notifier: notifier, BaseURL: cfg.BaseURL})
+	mux.Handle("POST /comments/{id}/edit", &CommentEditHandler{DB: db})
+	mux.Handle("POST /comments/{id}/delete", &CommentDeleteHandler{DB: db})
+	resolveHandler := &BetResolveHandler{DB: db, Quorum: cfg.Moderation.Quorum, QuorumFraction: cfg.Moderation.QuorumFraction, RakeBps: cfg.Moderation.RakeBps, CreatorReward: creatorReward, CreatorRewardMinBettors: cfg.Bets.CreatorRewardMinBettors, Notifier: notifier, BaseURL: cfg.BaseURL}
+	mux.Handle("POST /bets/{id}/resolve", resolveHandler)
+	mux.Handle("POST /bets/{id}/subscribe", &BetSubscribeHandler{DB: db, Subscribe: true})
+	mux.Handle("POST /bets/{id}/unsubscribe", &BetSubscribeHandler{DB: db})
+	mux.Handle("POST /bets/{id}/suggest-resolution", &BetSuggestResolutionHandler{DB: db, Notifier: notifier, BaseURL: cfg.BaseURL, Threshold: cfg.Moderation.SuggestionThreshold})
+	mux.Handle("POST /bets/{id}/cancel", &BetCancelHandler{DB: db, Notifier: notifier, BaseURL: cfg.BaseURL})
+	mux.Handle("POST /admin/resolve-batch", &BetResolveBatchHandler{Resolve: resolveHandler})
 	registerLimiter := middleware.NewRateLimiter(3, time.Minute)
 	loginLimiter := middleware.NewRateLimiter(10, time.Minute)
+	testNotifyLimiter := middleware.NewRateLimiter(3, 10*time.Minute)
 
-	mux.Handle("POST /register", &AccountRegisterHandler{DB: db, Notifier: notifier, Limiter: registerLimiter})
-	profileHandler := &UserProfileHandler{DB: db, TPL: rend, Notifier: notifier}
+	mux.Handle("POST /register", &AccountRegisterHandler{DB: db, Notifier: notifier, Limiter: registerLimiter, Captcha: appCaptcha, ReservedUsernames: cfg.Accounts.ReservedUsernames})
+	profileHandler := &UserProfileHandler{DB: db, TPL: rend, Notifier: notifier, WelcomeBonus: cfg.Accounts.WelcomeBonus * coins.Unit(), PasswordHistory: cfg.Accounts.PasswordHistory, UniqueDisplayNames: cfg.Accounts.UniqueDisplayNames, EmailEnabled: cfg.Email.SMTPHost != "", TelegramBot: cfg.Telegram.BotUsername, TestLimiter: testNotifyLimiter, TwoFactorLimiter: loginLimiter}
 	mux.Handle("GET /profile", profileHandler)
 	mux.Handle("POST /profile", profileHandler)
 	mux.Handle("GET /profile/{username}", profileHandler)
 	mux.Handle("POST /profile/{username}", profileHandler)
-	mux.Handle("GET /hof", &HallOfFameHandler{DB: db, TPL: rend})
-	recoverHandler := &PasswordRecoveryHandler{DB: db, TPL: rend, Notifier: notifier}
+	mux.Handle("POST /profile/{username}/follow", &UserFollowHandler{DB: db})
+	mux.Handle("GET /avatars/{file}", &AvatarHandler{Palette: cfg.Site.AvatarPalette})
+	mux.Handle("GET /hof", &HallOfFameHandler{DB: readDB, TPL: rend})
+	mux.Handle("GET /leaderboard/bets", &LeaderboardBetsHandler{DB: readDB, TPL: rend})
+	mux.Handle("GET /api/v1/admin/house", &AdminHouseHandler{DB: db})
+	mux.Handle("POST /admin/users/{username}/adjust", &AdminAdjustHandler{DB: db, Notifier: notifier})
+	mux.Handle("GET /admin/users/{username}/transactions", &TransactionsHandler{DB: readDB, TPL: rend, PerUser: true})
+	maintenance := &middleware.Maintenance{DB: db, Forced: cfg.Maintenance.Mode}
+	mux.Handle("GET /api/v1/admin/maintenance", &AdminMaintenanceHandler{DB: db, Mode: maintenance})
+	mux.Handle("POST /api/v1/admin/maintenance", &AdminMaintenanceHandler{DB: db, Mode: maintenance})
+	settingsHandler := &AdminSettingsHandler{DB: db, TPL: rend, StuckAfter: cfg.Maintenance.StuckBetAlertAfter}
+	mux.Handle("GET /admin/settings", settingsHandler)
+	mux.Handle("POST /admin/settings", settingsHandler)
+	announcementsHandler := &AdminAnnouncementsHandler{DB: db, TPL: rend}
+	mux.Handle("GET /admin/announcements", announcementsHandler)
+	mux.Handle("POST /admin/announcements", announcementsHandler)
+	recoverHandler := &PasswordRecoveryHandler{DB: db, TPL: rend, Notifier: notifier, Captcha: appCaptcha, PasswordHistory: cfg.Accounts.PasswordHistory}
 	mux.Handle("GET /recover", recoverHandler)
 	mux.Handle("POST /recover", recoverHandler)
 	assetFS := http.StripPrefix("/assets/", http.FileServer(http.FS(resources.FS)))
@@ -59,31 +116,92 @@ func NewMux(db *pgxpool.Pool, cfg *config.Config) (*http.ServeMux, error) {
 		assetFS.ServeHTTP(w, r)
 	}))
 
+	if cfg.Telegram.Mode == "webhook" {
+		if wh := telegram.NewWebhook(db, cfg.Telegram.BotToken, cfg.Telegram.WebhookSecret); wh != nil {
+			mux.Handle("POST /telegram/webhook/{secret}", wh)
+		}
+	}
+
 	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
 
+	mux.Handle("GET /robots.txt", &RobotsHandler{Indexable: cfg.Site.Indexable, BaseURL: cfg.BaseURL})
+	if cfg.Site.Indexable {
+		mux.Handle("GET /sitemap.xml", &SitemapHandler{DB: readDB, BaseURL: cfg.BaseURL})
+	}
+
+	if cfg.HTTP.MetricsEnabled {
+		if err := metrics.RegisterPool("primary", db); err != nil {
+			return nil, err
+		}
+		if readDB != db {
+			if err := metrics.RegisterPool("replica", readDB); err != nil {
+				return nil, err
+			}
+		}
+		mux.Handle("GET /metrics", promhttp.Handler())
+	}
+
 	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ready"))
 	})
 
-	ah := &AuthHandler{DB: db, LoginLimiter: loginLimiter}
+	ah := &AuthHandler{DB: db, LoginLimiter: loginLimiter, CSRFCookie: cfg.Session.APICSRF}
 	ah.Routes(mux)
 
-	return mux, nil
+	return maintenance.Wrap(recordRoute(mux)), nil
 }
 
-func WithStandardMiddleware(next http.Handler) http.Handler {
-	return requestLogger(securityHeaders(middleware.WithAuth(next)))
+// NewNotifier sends through Telegram and email, whichever are configured.
+func NewNotifier(db *pgxpool.Pool, cfg *config.Config) notify.Notifier {
+	return notify.Fanout(
+		telegram.New(db, cfg.Telegram.BotToken, cfg.Telegram.GroupChatID),
+		email.New(db, email.Config{
+			Host:         cfg.Email.SMTPHost,
+			Port:         cfg.Email.SMTPPort,
+			Username:     cfg.Email.SMTPUsername,
+			Password:     cfg.Email.SMTPPassword,
+			From:         cfg.Email.From,
+			GroupAddress: cfg.Email.GroupAddress,
+			SiteName:     cfg.Site.Name,
+		}),
+	)
 }
 
-func securityHeaders(next http.Handler) http.Handler {
+type routeKey struct{}
+
+// recordRoute reports the pattern mux matched to requestLogger, which sits
+// outside middleware that copies the request and so never sees r.Pattern.
+func recordRoute(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			*route = r.Pattern
+		}
+	})
+}
+
+func WithStandardMiddleware(next http.Handler, cfg *config.Config) http.Handler {
+	if cfg.Session.APICSRF {
+		next = middleware.APICSRF(next)
+	}
+	return requestLogger(middleware.LimitInFlight(cfg.HTTP.MaxInFlight, securityHeaders(cfg.Site.Indexable, middleware.WithAuth(middleware.WithUserCache(next)))))
+}
+
+// securityHeaders sets response hardening headers. Connection-level hardening
+// (ReadHeaderTimeout against Slowloris) lives on the http.Server, see config.HTTPConfig.
+// Unless the instance is indexable, every response also asks crawlers not to index it.
+func securityHeaders(indexable bool, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "DENY")
 		w.Header().Set("Referrer-Policy", "no-referrer")
+		if !indexable {
+			w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -92,10 +210,22 @@ func requestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		ww := &wrapWriter{ResponseWriter: w, status: 200}
-		next.ServeHTTP(ww, r)
+		var route string
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), routeKey{}, &route)))
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(ww.status/100) + "xx"
+		metrics.RequestsTotal.WithLabelValues(route, status).Inc()
+		metrics.RequestDuration.WithLabelValues(route, status).Observe(time.Since(start).Seconds())
+		path := r.URL.Path
+		if strings.HasPrefix(path, "/telegram/webhook/") {
+			path = "/telegram/webhook/…" // the secret is the credential
+		}
 		slog.Info("http.request",
 			"method", r.Method,
-			"path", r.URL.Path,
+			"path", path,
+			"route", route,
 			"status", ww.status,
 			"duration_ms", time.Since(start).Milliseconds(),
 		)
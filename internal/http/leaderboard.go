@@ -0,0 +1,253 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/web"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LeaderboardBetsHandler ranks public bets by stakes or participants and
+// creators by the volume wagered on their bets, over a period.
+type LeaderboardBetsHandler struct {
+	DB  *pgxpool.Pool
+	TPL *web.Renderer
+}
+
+type leaderboardBetRow struct {
+	Rank         int
+	ID           string
+	Title        string
+	CreatorName  string
+	CreatorUser  string
+	Stakes       int64
+	Participants int
+	Status       string
+}
+
+type leaderboardCreatorRow struct {
+	Rank        int
+	DisplayName string
+	Username    string
+	Bets        int
+	Volume      int64
+	Bettors     int
+}
+
+type leaderboardContent struct {
+	Title    string
+	Board    string // "bets" | "creators"
+	Sort     string // "stakes" | "participants" (bets board only)
+	Period   string // key of leaderboardPeriods
+	Periods  []struct{ Key, Label string }
+	Bets     []leaderboardBetRow
+	Creators []leaderboardCreatorRow
+	Page     int
+	Size     int
+	HasPrev  bool
+	HasNext  bool
+	PrevURL  string
+	NextURL  string
+}
+
+// leaderboardPeriods maps the ?period= values to how far back bets are
+// counted, by creation date; 0 means all time.
+var leaderboardPeriods = []struct {
+	Key   string
+	Label string
+	Span  time.Duration
+}{
+	{"7d", "Last 7 days", 7 * 24 * time.Hour},
+	{"30d", "Last 30 days", 30 * 24 * time.Hour},
+	{"365d", "Last year", 365 * 24 * time.Hour},
+	{"all", "All time", 0},
+}
+
+// Stakes of blind bets stay secret until resolution, so those bets only
+// count once closed. Archived bets are left out.
+const leaderboardBetScope = `b.visibility = 'public'
+	  and b.archived_at is null
+	  and not (b.blind and b.status = 'open')
+	  and ($1::timestamptz is null or b.created_at >= $1)`
+
+func (h *LeaderboardBetsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	header, _ := loadHeader(r.Context(), h.DB, uid)
+	if !header.LoggedIn {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	board := q.Get("board")
+	if board != "creators" {
+		board = "bets"
+	}
+	sortKey := q.Get("sort")
+	if sortKey != "participants" {
+		sortKey = "stakes"
+	}
+	period := "30d"
+	var since *time.Time
+	var periods []struct{ Key, Label string }
+	for _, p := range leaderboardPeriods {
+		periods = append(periods, struct{ Key, Label string }{p.Key, p.Label})
+		if p.Key == q.Get("period") {
+			period = p.Key
+		}
+	}
+	for _, p := range leaderboardPeriods {
+		if p.Key == period && p.Span > 0 {
+			t := time.Now().UTC().Add(-p.Span)
+			since = &t
+		}
+	}
+
+	page := parseIntDefault(q.Get("page"), 1)
+	if page < 1 {
+		page = 1
+	}
+	size := parseIntDefault(q.Get("size"), 25)
+	if size < 1 || size > 100 {
+		size = 25
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	content := leaderboardContent{
+		Title:   "Leaderboard",
+		Board:   board,
+		Sort:    sortKey,
+		Period:  period,
+		Periods: periods,
+		Page:    page,
+		Size:    size,
+		HasPrev: page > 1,
+	}
+
+	var err error
+	var n int
+	if board == "creators" {
+		content.Creators, err = h.fetchCreators(ctx, since, size+1, (page-1)*size)
+		n = len(content.Creators)
+		if n > size {
+			content.Creators = content.Creators[:size]
+		}
+	} else {
+		content.Bets, err = h.fetchBets(ctx, since, sortKey, size+1, (page-1)*size)
+		n = len(content.Bets)
+		if n > size {
+			content.Bets = content.Bets[:size]
+		}
+	}
+	if err != nil {
+		slog.Error("leaderboard.query", "board", board, "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	content.HasNext = n > size
+	content.PrevURL = leaderboardURL(board, sortKey, period, page-1, size)
+	content.NextURL = leaderboardURL(board, sortKey, period, page+1, size)
+
+	pg := web.Page[leaderboardContent]{Header: header, Content: content}
+
+	var buf bytes.Buffer
+	if err := h.TPL.Render(&buf, "leaderboard", pg); err != nil {
+		slog.Error("template error", "error", err)
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+func leaderboardURL(board, sortKey, period string, page, size int) string {
+	v := url.Values{}
+	v.Set("board", board)
+	if board == "bets" {
+		v.Set("sort", sortKey)
+	}
+	v.Set("period", period)
+	v.Set("page", itoa(page))
+	v.Set("size", itoa(size))
+	return "/leaderboard/bets?" + v.Encode()
+}
+
+func (h *LeaderboardBetsHandler) fetchBets(ctx context.Context, since *time.Time, sortKey string, limit, offset int) ([]leaderboardBetRow, error) {
+	orderBy := `order by stakes desc, participants desc, b.created_at desc, b.id`
+	if sortKey == "participants" {
+		orderBy = `order by participants desc, stakes desc, b.created_at desc, b.id`
+	}
+	rows, err := h.DB.Query(ctx, `
+		with agg as (
+		  select b.id,
+		         coalesce(sum(w.amount),0)::bigint as stakes,
+		         count(distinct w.user_id)::int as participants
+		  from bets b
+		  left join wagers w on w.bet_id = b.id
+		  where `+leaderboardBetScope+`
+		  group by b.id
+		)
+		select b.id::text, b.title, u.display_name, u.username, agg.stakes, agg.participants, b.status
+		from agg
+		join bets b on b.id = agg.id
+		join users u on u.id = b.creator_user_id
+		where agg.participants > 0
+		`+orderBy+`
+		limit $2 offset $3
+	`, since, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []leaderboardBetRow
+	for rows.Next() {
+		var row leaderboardBetRow
+		if err := rows.Scan(&row.ID, &row.Title, &row.CreatorName, &row.CreatorUser, &row.Stakes, &row.Participants, &row.Status); err != nil {
+			return nil, err
+		}
+		row.Rank = offset + len(list) + 1
+		list = append(list, row)
+	}
+	return list, rows.Err()
+}
+
+func (h *LeaderboardBetsHandler) fetchCreators(ctx context.Context, since *time.Time, limit, offset int) ([]leaderboardCreatorRow, error) {
+	rows, err := h.DB.Query(ctx, `
+		select u.display_name, u.username,
+		       count(distinct b.id)::int as bets,
+		       coalesce(sum(w.amount),0)::bigint as volume,
+		       count(distinct w.user_id)::int as bettors
+		from bets b
+		join users u on u.id = b.creator_user_id
+		left join wagers w on w.bet_id = b.id
+		where `+leaderboardBetScope+`
+		group by u.id
+		having coalesce(sum(w.amount),0) > 0
+		order by volume desc, bettors desc, u.display_name
+		limit $2 offset $3
+	`, since, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []leaderboardCreatorRow
+	for rows.Next() {
+		var row leaderboardCreatorRow
+		if err := rows.Scan(&row.DisplayName, &row.Username, &row.Bets, &row.Volume, &row.Bettors); err != nil {
+			return nil, err
+		}
+		row.Rank = offset + len(list) + 1
+		list = append(list, row)
+	}
+	return list, rows.Err()
+}
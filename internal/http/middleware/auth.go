@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"betsandpedestres/internal/apperr"
 	"betsandpedestres/internal/auth"
 )
 
@@ -18,7 +19,7 @@ func WithAuth(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
-		if uid, err := auth.ParseToken(c.Value); err == nil && uid != "" {
+		if uid, err := auth.SessionUser(r.Context(), c.Value); err == nil && uid != "" {
 			ctx := context.WithValue(r.Context(), CtxUserID, uid)
 			next.ServeHTTP(w, r.WithContext(ctx))
 			return
@@ -27,13 +28,16 @@ func WithAuth(next http.Handler) http.Handler {
 	})
 }
 
+var errUnauthenticated = apperr.Coded(http.StatusUnauthorized, "unauthenticated", "unauthorized")
+
+// RequireAuth guards JSON endpoints: anonymous requests get a JSON 401.
 func RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if uid := UserID(r); uid != "" {
 			next.ServeHTTP(w, r)
 			return
 		}
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		apperr.WriteJSON(w, errUnauthenticated)
 	})
 }
 
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"betsandpedestres/internal/metrics"
+)
+
+// LimitInFlight sheds load with 503 once max requests are already being served,
+// instead of letting them queue on DB connections. max <= 0 disables the cap.
+// Health, readiness and metrics endpoints are never shed.
+func LimitInFlight(max int, next http.Handler) http.Handler {
+	var sem chan struct{}
+	if max > 0 {
+		sem = make(chan struct{}, max)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz", "/readyz", "/metrics":
+			next.ServeHTTP(w, r)
+			return
+		}
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			default:
+				metrics.ShedRequests.Inc()
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "server busy, try again", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		metrics.InFlightRequests.Inc()
+		defer metrics.InFlightRequests.Dec()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/apperr"
+	"betsandpedestres/internal/auth"
+)
+
+// The SPA reads CSRFCookie (it is not HttpOnly) and echoes it in
+// CSRFHeader on every /api/v1 write: a cross-site page can make the
+// browser send the session cookie but cannot read this one.
+const (
+	CSRFCookie = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+var errCSRF = apperr.Coded(http.StatusForbidden, "csrf_failed", "missing or invalid CSRF token")
+
+// IssueCSRFCookie sets a fresh double-submit token living as long as a
+// session.
+func IssueCSRFCookie(w http.ResponseWriter) {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    hex.EncodeToString(b),
+		Path:     "/",
+		HttpOnly: false,
+		Secure:   false,
+		SameSite: http.SameSiteStrictMode,
+		Expires:  time.Now().Add(auth.SessionTTL),
+	})
+}
+
+func ClearCSRFCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    "",
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+	})
+}
+
+// APICSRF rejects /api/v1 writes carrying a session cookie unless the
+// CSRFHeader matches the CSRFCookie. Anonymous requests (login) pass, and
+// sessions opened before the token existed get one on their next read.
+func APICSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s, err := r.Cookie("session"); err != nil || s.Value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := ""
+		if c, err := r.Cookie(CSRFCookie); err == nil {
+			token = c.Value
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if token == "" {
+				IssueCSRFCookie(w)
+			}
+		default:
+			if strings.HasPrefix(r.URL.Path, "/api/v1/") &&
+				(token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(r.Header.Get(CSRFHeader))) != 1) {
+				apperr.WriteJSON(w, errCSRF)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"betsandpedestres/internal/settings"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaintenanceSettingKey is the runtime setting holding the mode ("on" / "off").
+const MaintenanceSettingKey = "maintenance"
+
+func init() {
+	settings.Define(MaintenanceSettingKey, "Serve a 503 maintenance page to everyone but admins (on/off).", "off")
+}
+
+// Maintenance answers 503 to everyone but admins while maintenance mode is
+// on. The flag is a runtime setting so it can be flipped without a restart;
+// Forced (from config) keeps it on regardless.
+type Maintenance struct {
+	DB     *pgxpool.Pool
+	Forced bool
+}
+
+// Enabled reports the current mode.
+func (m *Maintenance) Enabled(ctx context.Context) bool {
+	return m.Forced || settings.Get(ctx, MaintenanceSettingKey, false)
+}
+
+// Set stores the runtime flag.
+func (m *Maintenance) Set(ctx context.Context, on bool, adminID string) error {
+	value := "off"
+	if on {
+		value = "on"
+	}
+	return settings.Set(ctx, MaintenanceSettingKey, value, adminID)
+}
+
+// Wrap must run inside WithAuth so admins can be recognized. Health checks,
+// metrics, assets and login stay reachable so admins can get in.
+func (m *Maintenance) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maintenanceExempt(r) || !m.Enabled(r.Context()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if uid := UserID(r); uid != "" {
+			if role, err := GetUserRole(r.Context(), m.DB, uid); err == nil && role == RoleAdmin {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		w.Header().Set("Retry-After", "300")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(maintenancePage))
+	})
+}
+
+func maintenanceExempt(r *http.Request) bool {
+	p := r.URL.Path
+	switch p {
+	case "/healthz", "/readyz", "/metrics", "/robots.txt", "/api/v1/auth/login", "/api/v1/auth/logout":
+		return true
+	}
+	return strings.HasPrefix(p, "/assets/")
+}
+
+const maintenancePage = `<!doctype html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Under maintenance</title>
+  <style>
+    body{margin:0;min-height:100vh;display:flex;align-items:center;justify-content:center;font-family:system-ui,-apple-system,Segoe UI,Roboto,sans-serif;background:radial-gradient(circle at top,#111526 0%,#06070b 45%) fixed;color:#f2f5ff}
+    .card{max-width:480px;padding:32px;border:1px solid #1f2431;border-radius:14px;background:#11131b;text-align:center}
+    h1{margin-top:0;color:#c084fc}
+    p{color:#9ca4bf}
+  </style>
+</head>
+<body>
+  <div class="card">
+    <h1>🛠️ Under maintenance</h1>
+    <p>We are doing some work on the site. Your PiedPièces are safe. Please come back in a few minutes.</p>
+    <details style="margin-top:24px;color:#9ca4bf">
+      <summary>Admin login</summary>
+      <form id="loginForm" onsubmit="doLogin(); return false" style="display:grid;gap:8px;margin-top:12px">
+        <input name="username" placeholder="username" autocomplete="username" required>
+        <input name="password" type="password" placeholder="password" autocomplete="current-password" required>
+        <button>Login</button>
+      </form>
+    </details>
+  </div>
+  <script>
+  async function doLogin(){
+    const f = document.getElementById('loginForm');
+    const body = JSON.stringify({username: f.username.value, password: f.password.value});
+    const res = await fetch('/api/v1/auth/login', {method:'POST', headers:{'Content-Type':'application/json'}, body});
+    if(res.ok){ window.location.reload(); } else { alert('Login failed'); }
+  }
+  </script>
+</body>
+</html>
+`
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"fmt"
 	"net"
 	"net/http"
 	"strings"
@@ -65,23 +66,80 @@ func (rl *RateLimiter) Allow(key string) bool {
 	return true
 }
 
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies configures the CIDRs whose forwarded headers ClientIP honors.
+// Call this once at startup; with no trusted proxies only RemoteAddr is used.
+func SetTrustedProxies(cidrs []string) error {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil && ip.To4() != nil {
+				c += "/32"
+			} else {
+				c += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return fmt.Errorf("trusted proxy %q: %w", c, err)
+		}
+		nets = append(nets, n)
+	}
+	trustedProxies = nets
+	return nil
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the client address for rate limiting. Forwarded headers are
+// only honored when the direct peer is a trusted proxy; X-Forwarded-For is then
+// walked right to left and the first untrusted hop is the client.
 func ClientIP(r *http.Request) string {
 	if r == nil {
 		return ""
 	}
+	remote := strings.TrimSpace(r.RemoteAddr)
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !isTrustedProxy(net.ParseIP(remote)) {
+		return remote
+	}
 	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
 		parts := strings.Split(xff, ",")
-		ip := strings.TrimSpace(parts[0])
-		if ip != "" {
-			return ip
+		leftmost := ""
+		for i := len(parts) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(parts[i])
+			ip := net.ParseIP(hop)
+			if ip == nil {
+				continue
+			}
+			leftmost = hop
+			if !isTrustedProxy(ip) {
+				return hop
+			}
+		}
+		if leftmost != "" {
+			return leftmost
 		}
 	}
-	if xrip := strings.TrimSpace(r.Header.Get("X-Real-IP")); xrip != "" {
+	if xrip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xrip) != nil {
 		return xrip
 	}
-	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
-	if err == nil {
-		return host
-	}
-	return r.RemoteAddr
+	return remote
 }
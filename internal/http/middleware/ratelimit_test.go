@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted []string
+		remote  string
+		xff     string
+		xRealIP string
+		want    string
+	}{
+		{"no proxy", nil, "203.0.113.7:5555", "", "", "203.0.113.7"},
+		{"untrusted peer's headers are ignored", nil, "203.0.113.7:5555", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"remote without port", nil, "203.0.113.7", "", "", "203.0.113.7"},
+		{"ipv6 remote", nil, "[2001:db8::1]:443", "", "", "2001:db8::1"},
+		{"trusted proxy, single hop", []string{"10.0.0.0/8"}, "10.0.0.2:80", "198.51.100.1", "", "198.51.100.1"},
+		{"rightmost untrusted hop wins", []string{"10.0.0.0/8"}, "10.0.0.2:80", "1.1.1.1, 198.51.100.1, 10.0.0.3", "", "198.51.100.1"},
+		{"spoofed leftmost entry is skipped", []string{"10.0.0.0/8"}, "10.0.0.2:80", "6.6.6.6, 198.51.100.1", "", "198.51.100.1"},
+		{"garbage hops are skipped", []string{"10.0.0.0/8"}, "10.0.0.2:80", "198.51.100.1, unknown", "", "198.51.100.1"},
+		{"all hops trusted", []string{"10.0.0.0/8"}, "10.0.0.2:80", "10.0.0.9, 10.0.0.3", "", "10.0.0.9"},
+		{"x-real-ip from a trusted proxy", []string{"10.0.0.2"}, "10.0.0.2:80", "", "198.51.100.1", "198.51.100.1"},
+		{"invalid x-real-ip", []string{"10.0.0.2"}, "10.0.0.2:80", "", "nope", "10.0.0.2"},
+		{"ipv6 trusted proxy", []string{"2001:db8::/32"}, "[2001:db8::5]:80", "198.51.100.1", "", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetTrustedProxies(tt.trusted); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = SetTrustedProxies(nil) })
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xRealIP != "" {
+				r.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if got := ClientIP(nil); got != "" {
+		t.Errorf("ClientIP(nil) = %q", got)
+	}
+}
+
+func TestSetTrustedProxiesRejectsGarbage(t *testing.T) {
+	t.Cleanup(func() { _ = SetTrustedProxies(nil) })
+	if err := SetTrustedProxies([]string{"10.0.0.0/8", "not-an-ip"}); err == nil {
+		t.Error("invalid proxy accepted")
+	}
+	if err := SetTrustedProxies([]string{" ", "192.0.2.1", "2001:db8::1"}); err != nil {
+		t.Errorf("bare addresses: %v", err)
+	}
+}
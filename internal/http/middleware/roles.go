@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"context"
+	"net/http"
+	"sync"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -13,9 +15,46 @@ const (
 	RoleAdmin      = "admin"
 )
 
+const ctxUserCache ctxKey = "user_cache"
+
+// userCache memoizes role lookups for the lifetime of one request, so a
+// handler checking the header, then moderator rights, then admin rights
+// hits the users table once.
+type userCache struct {
+	mu    sync.Mutex
+	roles map[string]string
+}
+
+// WithUserCache attaches a request-scoped user cache to the context.
+func WithUserCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), ctxUserCache, &userCache{roles: map[string]string{}})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// StoreRole records a role already read by the caller (e.g. with the header).
+func StoreRole(ctx context.Context, userID, role string) {
+	if c, ok := ctx.Value(ctxUserCache).(*userCache); ok && userID != "" {
+		c.mu.Lock()
+		c.roles[userID] = role
+		c.mu.Unlock()
+	}
+}
+
+func cachedRole(ctx context.Context, userID string) (string, bool) {
+	c, ok := ctx.Value(ctxUserCache).(*userCache)
+	if !ok {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	role, ok := c.roles[userID]
+	return role, ok
+}
+
 func IsModerator(ctx context.Context, db *pgxpool.Pool, userID string) (bool, error) {
-	var roleID string
-	err := db.QueryRow(ctx, `select role from users where id = $1`, userID).Scan(&roleID)
+	roleID, err := GetUserRole(ctx, db, userID)
 	if err != nil {
 		return false, err
 	}
@@ -23,7 +62,13 @@ func IsModerator(ctx context.Context, db *pgxpool.Pool, userID string) (bool, er
 }
 
 func GetUserRole(ctx context.Context, db *pgxpool.Pool, userID string) (string, error) {
+	if role, ok := cachedRole(ctx, userID); ok {
+		return role, nil
+	}
 	var roleID string
 	err := db.QueryRow(ctx, `select role from users where id = $1`, userID).Scan(&roleID)
+	if err == nil {
+		StoreRole(ctx, userID, roleID)
+	}
 	return roleID, err
 }
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"betsandpedestres/internal/db"
+	"betsandpedestres/internal/dbtest"
+)
+
+// countRoleQueries runs fn and counts the role lookups it sent to the
+// database, using the slow-query log with a threshold every query exceeds.
+func countRoleQueries(t *testing.T, fn func()) int {
+	t.Helper()
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(old)
+	fn()
+	return strings.Count(buf.String(), "select role from users")
+}
+
+func TestUserCache(t *testing.T) {
+	pool := dbtest.NewWithOptions(t, db.Options{SlowQueryThreshold: time.Nanosecond})
+	mod, _ := dbtest.User(t, pool, "mod", RoleModerator)
+
+	// checkRoles does what a handler typically does: moderator rights, then
+	// the exact role, then moderator rights again.
+	checkRoles := func(ctx context.Context) {
+		for range 2 {
+			if ok, err := IsModerator(ctx, pool, mod); err != nil || !ok {
+				t.Fatalf("IsModerator = %v, %v", ok, err)
+			}
+			if role, err := GetUserRole(ctx, pool, mod); err != nil || role != RoleModerator {
+				t.Fatalf("GetUserRole = %q, %v", role, err)
+			}
+		}
+	}
+
+	if n := countRoleQueries(t, func() { checkRoles(context.Background()) }); n != 4 {
+		t.Errorf("without a cache: %d role queries, want 4", n)
+	}
+
+	serve := func(h http.HandlerFunc) {
+		WithUserCache(h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if n := countRoleQueries(t, func() {
+		serve(func(_ http.ResponseWriter, r *http.Request) { checkRoles(r.Context()) })
+	}); n != 1 {
+		t.Errorf("with a cache: %d role queries, want 1", n)
+	}
+	if n := countRoleQueries(t, func() {
+		serve(func(_ http.ResponseWriter, r *http.Request) {
+			StoreRole(r.Context(), mod, RoleModerator)
+			checkRoles(r.Context())
+		})
+	}); n != 0 {
+		t.Errorf("with a stored role: %d role queries, want 0", n)
+	}
+
+	// The cache lives for one request only.
+	if n := countRoleQueries(t, func() {
+		for range 2 {
+			serve(func(_ http.ResponseWriter, r *http.Request) { checkRoles(r.Context()) })
+		}
+	}); n != 2 {
+		t.Errorf("two requests: %d role queries, want 2", n)
+	}
+}
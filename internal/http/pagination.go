@@ -0,0 +1,56 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// listPage describes where a page of a JSON list sits, for
+// setPaginationHeaders. Next and Prev hold the query of the neighbouring
+// pages; nil means there is no such page.
+type listPage struct {
+	Total int64 // matching rows across all pages; negative when unknown
+	Next  url.Values
+	Prev  url.Values
+}
+
+// setPaginationHeaders sets X-Total-Count and a Link header (RFC 8288)
+// with rel="next"/"prev" relative to the request path, so every JSON list
+// endpoint paginates the same way.
+func setPaginationHeaders(w http.ResponseWriter, r *http.Request, p listPage) {
+	if p.Total >= 0 {
+		w.Header().Set("X-Total-Count", strconv.FormatInt(p.Total, 10))
+	}
+	var links []string
+	if p.Next != nil {
+		links = append(links, pageLink(r, p.Next, "next"))
+	}
+	if p.Prev != nil {
+		links = append(links, pageLink(r, p.Prev, "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+func pageLink(r *http.Request, q url.Values, rel string) string {
+	u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	return "<" + u.String() + `>; rel="` + rel + `"`
+}
+
+// withQuery returns a copy of q with key set to value, or removed when
+// value is empty.
+func withQuery(q url.Values, key, value string) url.Values {
+	out := make(url.Values, len(q))
+	for k, v := range q {
+		out[k] = append([]string(nil), v...)
+	}
+	if value == "" {
+		out.Del(key)
+	} else {
+		out.Set(key, value)
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
+	"testing"
+
+	"betsandpedestres/internal/dbtest"
+)
+
+func TestSetPaginationHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		page      listPage
+		wantTotal string
+		wantLink  string
+	}{
+		{"nothing known", listPage{Total: -1}, "", ""},
+		{"empty list", listPage{Total: 0}, "0", ""},
+		{
+			"first page",
+			listPage{Total: 42, Next: url.Values{"page": {"2"}, "status": {"open"}}},
+			"42", `</api/v1/bets?page=2&status=open>; rel="next"`,
+		},
+		{
+			"middle page",
+			listPage{Total: 42, Next: url.Values{"page": {"3"}}, Prev: url.Values{"page": {"1"}}},
+			"42", `</api/v1/bets?page=3>; rel="next", </api/v1/bets?page=1>; rel="prev"`,
+		},
+		{
+			"last page, unknown total",
+			listPage{Total: -1, Prev: url.Values{"cursor": {"a b&c"}}},
+			"", `</api/v1/bets?cursor=a+b%26c>; rel="prev"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			setPaginationHeaders(rec, httptest.NewRequest(http.MethodGet, "/api/v1/bets?page=2", nil), tt.page)
+			if got := rec.Header().Get("X-Total-Count"); got != tt.wantTotal {
+				t.Errorf("X-Total-Count = %q, want %q", got, tt.wantTotal)
+			}
+			if got := rec.Header().Get("Link"); got != tt.wantLink {
+				t.Errorf("Link = %q, want %q", got, tt.wantLink)
+			}
+		})
+	}
+}
+
+func TestWithQuery(t *testing.T) {
+	q := url.Values{"page": {"1"}, "tag": {"a", "b"}}
+	if got := withQuery(q, "page", "2").Encode(); got != "page=2&tag=a&tag=b" {
+		t.Errorf("set: %s", got)
+	}
+	if got := withQuery(q, "page", "").Encode(); got != "tag=a&tag=b" {
+		t.Errorf("delete: %s", got)
+	}
+	if got := q.Encode(); got != "page=1&tag=a&tag=b" {
+		t.Errorf("input modified: %s", got)
+	}
+}
+
+var linkRE = regexp.MustCompile(`<([^>]+)>; rel="(\w+)"`)
+
+// TestTransactionsAPIPagination follows the Link headers of the ledger API
+// forwards to the last page and back again.
+func TestTransactionsAPIPagination(t *testing.T) {
+	pool := dbtest.New(t)
+	uid, wallet := dbtest.User(t, pool, "alice", "")
+	for range 5 {
+		dbtest.Fund(t, pool, wallet, 1)
+	}
+
+	type page struct {
+		ids   []string
+		total string
+		links map[string]string
+	}
+	get := func(target string) page {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		(&TransactionsAPIHandler{DB: pool}).ServeHTTP(rec, asUser(httptest.NewRequest(http.MethodGet, target, nil), uid))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d", target, rec.Code)
+		}
+		var body apiTxPage
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		p := page{total: rec.Header().Get("X-Total-Count"), links: map[string]string{}}
+		for _, tx := range body.Transactions {
+			p.ids = append(p.ids, tx.ID)
+		}
+		for _, m := range linkRE.FindAllStringSubmatch(rec.Header().Get("Link"), -1) {
+			u, err := url.Parse(m[1])
+			if err != nil || u.Path != "/api/v1/transactions" || u.Query().Get("reason") != "GIFT" || u.Query().Get("limit") != "2" {
+				t.Errorf("GET %s: %s link %q lost the path or the filters", target, m[2], m[1])
+			}
+			p.links[m[2]] = m[1]
+		}
+		return p
+	}
+
+	var pages []page
+	target := "/api/v1/transactions?reason=GIFT&limit=2"
+	for target != "" {
+		p := get(target)
+		if p.total != "5" {
+			t.Errorf("GET %s: X-Total-Count = %q, want 5", target, p.total)
+		}
+		pages = append(pages, p)
+		if len(pages) > 5 {
+			t.Fatal("the next links do not end")
+		}
+		target = p.links["next"]
+	}
+	if len(pages) != 3 {
+		t.Fatalf("%d pages, want 3", len(pages))
+	}
+	var seen []string
+	for i, p := range pages {
+		if want := min(2, 5-2*i); len(p.ids) != want {
+			t.Errorf("page %d has %d rows, want %d", i+1, len(p.ids), want)
+		}
+		seen = append(seen, p.ids...)
+	}
+	slices.Sort(seen)
+	if distinct := len(slices.Compact(seen)); distinct != 5 {
+		t.Errorf("pages hold %d distinct rows, want 5", distinct)
+	}
+	if _, ok := pages[0].links["prev"]; ok {
+		t.Error("the first page links to a previous one")
+	}
+
+	// Walking back lands on the same pages.
+	for i := len(pages) - 1; i > 0; i-- {
+		prev := get(pages[i].links["prev"])
+		if !slices.Equal(prev.ids, pages[i-1].ids) {
+			t.Errorf("prev of page %d = %v, want %v", i+1, prev.ids, pages[i-1].ids)
+		}
+	}
+	if got := pages[1].links["prev"]; got != "/api/v1/transactions?limit=2&reason=GIFT" {
+		t.Errorf("prev of page 2 = %q, want the first page", got)
+	}
+}
@@ -0,0 +1,209 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/auth"
+	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/web"
+	"github.com/jackc/pgx/v5"
+)
+
+// twoFactorPanel is the two-factor section of one's own profile.
+type twoFactorPanel struct {
+	Enabled   bool
+	CodesLeft int    // unused recovery codes
+	Status    string // ?2fa= result of the last action
+	// SetupSecret is a fresh secret offered for enrollment while 2FA is off;
+	// the enable form posts it back along with a first code.
+	SetupSecret string
+	SetupURI    template.URL // otpauth://, which html/template would otherwise reject
+}
+
+type twoFactorCodesContent struct {
+	Title string
+	Codes []string
+}
+
+// loadTwoFactorPanel builds the profile's two-factor section for uid.
+func (h *UserProfileHandler) loadTwoFactorPanel(ctx context.Context, uid, username, issuer, status string) (*twoFactorPanel, error) {
+	p := &twoFactorPanel{Status: status}
+	err := h.DB.QueryRow(ctx, `
+		select u.totp_secret is not null,
+		       (select count(*)::int from totp_recovery_codes where user_id = u.id and used_at is null)
+		from users u where u.id = $1::uuid
+	`, uid).Scan(&p.Enabled, &p.CodesLeft)
+	if err != nil || p.Enabled {
+		return p, err
+	}
+	if p.SetupSecret, err = auth.NewTOTPSecret(); err != nil {
+		return p, err
+	}
+	if issuer == "" {
+		issuer = "Bets & Pedestres"
+	}
+	p.SetupURI = template.URL(auth.TOTPURI(issuer, username, p.SetupSecret))
+	return p, nil
+}
+
+// handleTwoFactorEnable turns on 2FA with the secret the profile offered,
+// once the user proves their app has it by entering a current code, and
+// shows the recovery codes.
+func (h *UserProfileHandler) handleTwoFactorEnable(w http.ResponseWriter, r *http.Request, uid string) {
+	secret := strings.TrimSpace(r.Form.Get("secret"))
+	if !auth.ValidTOTPSecret(secret) {
+		http.Redirect(w, r, "/profile?2fa=error#two-factor", http.StatusSeeOther)
+		return
+	}
+	step, ok := auth.CheckTOTP(secret, r.Form.Get("code"), time.Now())
+	if !ok {
+		http.Redirect(w, r, "/profile?2fa=invalid#two-factor", http.StatusSeeOther)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		http.Redirect(w, r, "/profile?2fa=error#two-factor", http.StatusSeeOther)
+		return
+	}
+	err = pgx.BeginFunc(ctx, h.DB, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			update users set totp_secret = $2, totp_last_step = $3
+			where id = $1::uuid and totp_secret is null
+		`, uid, secret, step)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return errTwoFactorAlreadyOn
+		}
+		return replaceRecoveryCodes(ctx, tx, uid, codes)
+	})
+	if errors.Is(err, errTwoFactorAlreadyOn) {
+		http.Redirect(w, r, "/profile?2fa=already#two-factor", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		slog.Error("profile.2fa.enable", "err", err)
+		http.Redirect(w, r, "/profile?2fa=error#two-factor", http.StatusSeeOther)
+		return
+	}
+	h.renderRecoveryCodes(w, r, uid, codes)
+}
+
+var errTwoFactorAlreadyOn = errors.New("two-factor already enabled")
+
+// handleTwoFactorDisable turns 2FA off; it takes a current code (or a
+// recovery code) so a hijacked session alone cannot do it.
+func (h *UserProfileHandler) handleTwoFactorDisable(w http.ResponseWriter, r *http.Request, uid string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if !h.verifyTwoFactor(ctx, w, r, uid) {
+		return
+	}
+	err := pgx.BeginFunc(ctx, h.DB, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `update users set totp_secret = null, totp_last_step = null where id = $1::uuid`, uid); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `delete from totp_recovery_codes where user_id = $1::uuid`, uid)
+		return err
+	})
+	if err != nil {
+		slog.Error("profile.2fa.disable", "err", err)
+		http.Redirect(w, r, "/profile?2fa=error#two-factor", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/profile?2fa=disabled#two-factor", http.StatusSeeOther)
+}
+
+// handleTwoFactorCodes replaces the recovery codes with a fresh set.
+func (h *UserProfileHandler) handleTwoFactorCodes(w http.ResponseWriter, r *http.Request, uid string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if !h.verifyTwoFactor(ctx, w, r, uid) {
+		return
+	}
+	codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err == nil {
+		err = pgx.BeginFunc(ctx, h.DB, func(tx pgx.Tx) error {
+			return replaceRecoveryCodes(ctx, tx, uid, codes)
+		})
+	}
+	if err != nil {
+		slog.Error("profile.2fa.codes", "err", err)
+		http.Redirect(w, r, "/profile?2fa=error#two-factor", http.StatusSeeOther)
+		return
+	}
+	h.renderRecoveryCodes(w, r, uid, codes)
+}
+
+// verifyTwoFactor checks the posted code against uid's second factor and
+// redirects with the outcome when it does not pass.
+func (h *UserProfileHandler) verifyTwoFactor(ctx context.Context, w http.ResponseWriter, r *http.Request, uid string) bool {
+	if h.TwoFactorLimiter != nil && !h.TwoFactorLimiter.Allow(middleware.ClientIP(r)) {
+		http.Redirect(w, r, "/profile?2fa=limited#two-factor", http.StatusSeeOther)
+		return false
+	}
+	var secret *string
+	if err := h.DB.QueryRow(ctx, `select totp_secret from users where id = $1::uuid`, uid).Scan(&secret); err != nil {
+		http.Redirect(w, r, "/profile?2fa=error#two-factor", http.StatusSeeOther)
+		return false
+	}
+	if secret == nil {
+		http.Redirect(w, r, "/profile?2fa=off#two-factor", http.StatusSeeOther)
+		return false
+	}
+	ok, err := checkSecondFactor(ctx, h.DB, uid, *secret, r.Form.Get("code"))
+	if err != nil {
+		slog.Error("profile.2fa.verify", "err", err)
+		http.Redirect(w, r, "/profile?2fa=error#two-factor", http.StatusSeeOther)
+		return false
+	}
+	if !ok {
+		http.Redirect(w, r, "/profile?2fa=invalid#two-factor", http.StatusSeeOther)
+		return false
+	}
+	return true
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, uid string, codes []string) error {
+	if _, err := tx.Exec(ctx, `delete from totp_recovery_codes where user_id = $1::uuid`, uid); err != nil {
+		return err
+	}
+	for _, c := range codes {
+		if _, err := tx.Exec(ctx, `
+			insert into totp_recovery_codes (user_id, code_hash) values ($1::uuid, $2)
+			on conflict do nothing
+		`, uid, auth.HashRecoveryCode(c)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderRecoveryCodes shows freshly issued recovery codes. They are only
+// stored hashed, so this response is the one chance to copy them.
+func (h *UserProfileHandler) renderRecoveryCodes(w http.ResponseWriter, r *http.Request, uid string, codes []string) {
+	header, _ := loadHeader(r.Context(), h.DB, uid)
+	page := web.Page[twoFactorCodesContent]{
+		Header:  header,
+		Content: twoFactorCodesContent{Title: "Recovery codes", Codes: codes},
+	}
+	var buf bytes.Buffer
+	if err := h.TPL.Render(&buf, "two_factor_codes", page); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(buf.Bytes())
+}
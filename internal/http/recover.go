@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"betsandpedestres/internal/auth"
+	"betsandpedestres/internal/captcha"
 	"betsandpedestres/internal/http/middleware"
 	"betsandpedestres/internal/notify"
 	"betsandpedestres/internal/web"
@@ -24,6 +25,10 @@ type PasswordRecoveryHandler struct {
 	DB       *pgxpool.Pool
 	TPL      *web.Renderer
 	Notifier notify.Notifier
+	Captcha  captcha.Provider // checked on token requests; nil skips it
+
+	// PasswordHistory is how many recent passwords cannot be reused; 0 off.
+	PasswordHistory int
 }
 
 type recoveryContent struct {
@@ -62,6 +67,13 @@ func (h *PasswordRecoveryHandler) handleRequest(w http.ResponseWriter, r *http.R
 	}
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
+	if err := captcha.Check(ctx, h.Captcha, r, middleware.ClientIP(r)); err != nil {
+		if !errors.Is(err, captcha.ErrFailed) {
+			slog.Error("recover.captcha", "err", err)
+		}
+		h.render(w, r, "captcha")
+		return
+	}
 
 	var (
 		userID      string
@@ -82,6 +94,9 @@ func (h *PasswordRecoveryHandler) handleRequest(w http.ResponseWriter, r *http.R
 		h.render(w, r, "notlinked")
 		return
 	}
+	if _, err := h.DB.Exec(ctx, `delete from password_recoveries where expires_at < now()`); err != nil {
+		slog.Warn("recover.prune", "err", err)
+	}
 	token := generateRecoveryToken()
 	expires := time.Now().UTC().Add(10 * time.Minute)
 	if _, err := h.DB.Exec(ctx, `
@@ -153,19 +168,44 @@ func (h *PasswordRecoveryHandler) handleReset(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	reused, err := auth.RecentlyUsed(ctx, h.DB, userID, newPass, h.PasswordHistory)
+	if err != nil {
+		slog.Error("recover.history", "err", err)
+		h.render(w, r, "error")
+		return
+	}
+	if reused {
+		h.render(w, r, "reused")
+		return
+	}
+
 	hash, err := auth.HashPassword(newPass)
 	if err != nil {
 		h.render(w, r, "error")
 		return
 	}
-	if _, err := h.DB.Exec(ctx, `update users set password_hash = $2 where id = $1::uuid`, userID, hash); err != nil {
+	if err := pgx.BeginFunc(ctx, h.DB, func(tx pgx.Tx) error {
+		return auth.SetPassword(ctx, tx, userID, hash, h.PasswordHistory)
+	}); err != nil {
 		slog.Error("recover.update", "err", err)
 		h.render(w, r, "error")
 		return
 	}
 	_, _ = h.DB.Exec(ctx, `delete from password_recoveries where user_id = $1::uuid`, userID)
+	// Whoever held the old password is logged out everywhere.
+	if err := auth.EndUserSessions(ctx, userID, ""); err != nil {
+		slog.Error("recover.end_sessions", "err", err)
+	}
+
+	// The Telegram token replaces the password, not the second factor:
+	// two-factor accounts log in again with their code.
+	var twoFactor bool
+	if err := h.DB.QueryRow(ctx, `select totp_secret is not null from users where id = $1::uuid`, userID).Scan(&twoFactor); err != nil || twoFactor {
+		h.render(w, r, "reset")
+		return
+	}
 
-	token, err := auth.IssueToken(userID)
+	token, err := auth.NewSession(ctx, userID)
 	if err != nil {
 		h.render(w, r, "error")
 		return
@@ -177,7 +217,7 @@ func (h *PasswordRecoveryHandler) handleReset(w http.ResponseWriter, r *http.Req
 		HttpOnly: true,
 		Secure:   false,
 		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(72 * time.Hour),
+		Expires:  time.Now().Add(auth.SessionTTL),
 	})
 	http.Redirect(w, r, "/profile?pwd=recovered", http.StatusSeeOther)
 }
@@ -2,12 +2,16 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
 	"betsandpedestres/internal/auth"
+	"betsandpedestres/internal/captcha"
+	"betsandpedestres/internal/config"
 	"betsandpedestres/internal/http/middleware"
 	"betsandpedestres/internal/notify"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -18,6 +22,9 @@ type AccountRegisterHandler struct {
 	DB       *pgxpool.Pool
 	Notifier notify.Notifier
 	Limiter  *middleware.RateLimiter
+	Captcha  captcha.Provider // nil skips verification
+
+	ReservedUsernames []string
 }
 
 func (h *AccountRegisterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -38,6 +45,17 @@ func (h *AccountRegisterHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 		http.Redirect(w, r, "/?signup=missing", http.StatusSeeOther)
 		return
 	}
+	if config.IsReservedUsername(h.ReservedUsernames, username) {
+		http.Redirect(w, r, "/?signup=reserved", http.StatusSeeOther)
+		return
+	}
+	if err := captcha.Check(r.Context(), h.Captcha, r, middleware.ClientIP(r)); err != nil {
+		if !errors.Is(err, captcha.ErrFailed) {
+			slog.Error("register.captcha", "err", err)
+		}
+		http.Redirect(w, r, "/?signup=captcha", http.StatusSeeOther)
+		return
+	}
 
 	hash, err := auth.HashPassword(password)
 	if err != nil {
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"context"
+	"encoding/xml"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RobotsHandler serves robots.txt: everything disallowed unless the instance
+// is indexable, in which case the feed and bet pages are open to crawlers.
+type RobotsHandler struct {
+	Indexable bool
+	BaseURL   string
+}
+
+func (h *RobotsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if !h.Indexable {
+		b.WriteString("Disallow: /\n")
+		_, _ = w.Write([]byte(b.String()))
+		return
+	}
+	b.WriteString("Allow: /$\nAllow: /bets/\nDisallow: /bets/new\nDisallow: /\n")
+	b.WriteString("\nSitemap: " + strings.TrimRight(h.BaseURL, "/") + "/sitemap.xml\n")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// SitemapHandler lists the open bets of an indexable instance.
+type SitemapHandler struct {
+	DB      *pgxpool.Pool
+	BaseURL string
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// sitemapMaxURLs is the per-file limit of the sitemap protocol.
+const sitemapMaxURLs = 50000
+
+func (h *SitemapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := h.DB.Query(ctx, `
+		select id::text, created_at
+		from bets
+		where status = 'open' and visibility = 'public'
+		order by created_at desc
+		limit $1
+	`, sitemapMaxURLs-1)
+	if err != nil {
+		slog.Error("sitemap.query", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	set.URLs = append(set.URLs, sitemapURL{Loc: strings.TrimRight(h.BaseURL, "/") + "/"})
+	for rows.Next() {
+		var id string
+		var created time.Time
+		if err := rows.Scan(&id, &created); err != nil {
+			slog.Error("sitemap.scan", "err", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		set.URLs = append(set.URLs, sitemapURL{Loc: betLink(h.BaseURL, id), LastMod: created.UTC().Format("2006-01-02")})
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(set); err != nil {
+		slog.Warn("sitemap.write", "err", err)
+	}
+}
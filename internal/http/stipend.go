@@ -0,0 +1,40 @@
+package http
+
+import (
+	"context"
+
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// claimDailyStipend pays amount to uid once per UTC day and reports whether
+// this call was the one that paid it.
+func claimDailyStipend(ctx context.Context, pool *pgxpool.Pool, uid string, amount int64) (bool, error) {
+	var granted bool
+	err := db.WithRetryTx(ctx, pool, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		granted = false
+		tag, err := tx.Exec(ctx, `
+			insert into daily_stipends (user_id, day)
+			values ($1::uuid, (now() at time zone 'utc')::date)
+			on conflict do nothing
+		`, uid)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+		accountID, err := ensureDefaultAccountTx(ctx, tx, uid, false)
+		if err != nil {
+			return err
+		}
+		if err := accounts.GiftFromHouse(ctx, tx, accountID, amount, "daily stipend"); err != nil {
+			return err
+		}
+		granted = true
+		return nil
+	})
+	return granted, err
+}
@@ -4,20 +4,28 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
+	"betsandpedestres/internal/accounts"
 	"betsandpedestres/internal/http/middleware"
 	"betsandpedestres/internal/web"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type TransactionsHandler struct {
 	DB  *pgxpool.Pool
 	TPL *web.Renderer
+
+	// PerUser scopes the ledger to the wallet of the {username} path user
+	// and restricts the page to admins.
+	PerUser bool
 }
 
 type TxEntry struct {
@@ -25,7 +33,9 @@ type TxEntry struct {
 	UserID      *string
 	Delta       int64
 	DisplayName *string
-	AccountKind string
+	AccountKind string  // accounts.KindWallet or accounts.KindEscrow
+	BetID       *string // escrow entries only
+	BetTitle    *string // escrow entries only
 }
 
 type TxRow struct {
@@ -39,6 +49,10 @@ type TxRow struct {
 	Hash      string
 	Entries   []TxEntry
 
+	// StakesHidden is set, and Entries left empty, for the transactions of
+	// a blind bet that is still open: they would show who staked how much.
+	StakesHidden bool
+
 	// Derived for UI within this page:
 	ChainOK bool // does this row's prev_hash == previous row's hash
 }
@@ -53,6 +67,19 @@ type TxContent struct {
 	NextURL   string
 	OverallOK bool
 	Title     string
+
+	// Filters; the chain check is skipped when any is set since
+	// neighbouring rows are then not neighbours in the chain.
+	Filter   txFilter
+	Reasons  []string
+	Filtered bool
+	BasePath string // "/transactions" or the admin per-user path
+}
+
+// blindStakesSQL is a predicate that holds when betIDArg is a blind bet
+// still open, whose ledger entries are hidden from non-moderators.
+func blindStakesSQL(betIDArg string) string {
+	return `exists (select 1 from bets hb where hb.id = ` + betIDArg + ` and hb.blind and hb.status = 'open')`
 }
 
 type userLite struct {
@@ -75,6 +102,33 @@ func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	basePath := "/transactions"
+	title := "All transactions"
+	var scopeUserID string
+	if h.PerUser {
+		if role != middleware.RoleAdmin {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		username := r.PathValue("username")
+		var displayName string
+		if err := h.DB.QueryRow(ctx, `
+			select id::text, display_name from users where username = $1
+		`, username).Scan(&scopeUserID, &displayName); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.NotFound(w, r)
+			} else {
+				http.Error(w, "db error", http.StatusInternalServerError)
+			}
+			return
+		}
+		basePath = "/admin/users/" + url.PathEscape(username) + "/transactions"
+		title = "Transactions of " + displayName
+	}
+
 	// pagination (defaults)
 	pagenb := parseIntDefault(r.URL.Query().Get("page"), 1)
 	if pagenb < 1 {
@@ -88,18 +142,29 @@ func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		size = 200
 	}
 
+	filter, err := parseTxFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, "bad date range (use YYYY-MM-DD, from <= to)", http.StatusBadRequest)
+		return
+	}
+	fromTS, toTS := filter.nullableBounds()
+
 	limit := size + 1 // fetch one extra to detect "has next"
 	offset := (pagenb - 1) * size
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
+	isMod := role == middleware.RoleModerator || role == middleware.RoleAdmin
 	rows, err := h.DB.Query(ctx, `
-		select id, reason, bet_id::text, note, created_at, prev_hash_hex, hash_hex, entries
+		select id, reason, bet_id::text, note, created_at, prev_hash_hex, hash_hex, entries,
+		       not $8 and `+blindStakesSQL("bet_id")+`
 		from public_transactions
+		where ($3 = '' or reason::text = $3)
+		  and ($4 = '' or bet_id::text = $4)
+		  and ($5::timestamptz is null or created_at >= $5)
+		  and ($6::timestamptz is null or created_at < $6)
+		  and ($7 = '' or entries @> jsonb_build_array(jsonb_build_object('user_id', $7::text)))
 		order by created_at desc, id desc
 		limit $1 offset $2
-	`, limit, offset)
+	`, limit, offset, filter.Reason, filter.BetID, fromTS, toTS, scopeUserID, isMod)
 	if err != nil {
 		slog.Error("transactions.query", "err", err)
 		http.Error(w, "db error", http.StatusInternalServerError)
@@ -113,7 +178,7 @@ func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		var betID *string
 		var note *string
 		var entriesJSON []byte
-		if err := rows.Scan(&t.ID, &t.Reason, &betID, &note, &t.CreatedAt, &t.PrevHash, &t.Hash, &entriesJSON); err != nil {
+		if err := rows.Scan(&t.ID, &t.Reason, &betID, &note, &t.CreatedAt, &t.PrevHash, &t.Hash, &entriesJSON, &t.StakesHidden); err != nil {
 			slog.Error("transactions.scan", "err", err)
 			http.Error(w, "scan error", http.StatusInternalServerError)
 			return
@@ -127,7 +192,9 @@ func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 			http.Error(w, "decode error", http.StatusInternalServerError)
 			return
 		}
-		t.Entries = ents
+		if !t.StakesHidden {
+			t.Entries = ents
+		}
 
 		list = append(list, t)
 
@@ -208,7 +275,7 @@ func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 					http.Error(w, "db error", http.StatusInternalServerError)
 					return
 				}
-				if u.Username == "house" {
+				if u.Username == accounts.HouseUsername {
 					houseUserID = &u.ID
 				}
 				userMap[u.ID] = u
@@ -235,10 +302,11 @@ func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 					}
 					name := u.DisplayName
 					e.DisplayName = &name
-					e.AccountKind = "wallet"
+					e.AccountKind = accounts.KindWallet
 				} else {
 					// escrow account (bet)
-					e.AccountKind = "escrow"
+					e.AccountKind = accounts.KindEscrow
+					e.BetID = acc.BetID
 				}
 				enriched = append(enriched, e)
 			}
@@ -256,6 +324,12 @@ func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		if list[i].BetID != nil {
 			betIDs[*list[i].BetID] = struct{}{}
 		}
+		// Escrow lines name their bet even when the tx itself has no bet_id.
+		for _, e := range list[i].Entries {
+			if e.BetID != nil {
+				betIDs[*e.BetID] = struct{}{}
+			}
+		}
 	}
 	if len(betIDs) > 0 {
 		idSlice := make([]string, 0, len(betIDs))
@@ -267,7 +341,7 @@ func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		rowsB, err := h.DB.Query(ctx, `
 		select id::text, title
 		from bets
-		where id = any($1::uuid[])
+		where id = any($1::uuid[]) and visibility <> 'private'
 	`, idSlice)
 		if err != nil {
 			slog.Error("transactions.bets.query", "err", err)
@@ -294,6 +368,14 @@ func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 					list[i].BetTitle = &t
 				}
 			}
+			for j := range list[i].Entries {
+				e := &list[i].Entries[j]
+				if e.BetID != nil {
+					if t, ok := bt[*e.BetID]; ok {
+						e.BetTitle = &t
+					}
+				}
+			}
 		}
 	}
 
@@ -303,9 +385,10 @@ func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		list = list[:size]
 	}
 
+	filtered := filter.Active() || h.PerUser
 	overallOK := true
 	for i := range list {
-		if i+1 >= len(list) {
+		if filtered || i+1 >= len(list) {
 			list[i].ChainOK = true
 			continue
 		}
@@ -322,10 +405,14 @@ func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		Size:      size,
 		HasPrev:   pagenb > 1,
 		HasNext:   hasNext,
-		PrevURL:   "/transactions?page=" + itoa(pagenb-1) + "&size=" + itoa(size),
-		NextURL:   "/transactions?page=" + itoa(pagenb+1) + "&size=" + itoa(size),
+		PrevURL:   filter.pageURL(basePath, pagenb-1, size),
+		NextURL:   filter.pageURL(basePath, pagenb+1, size),
 		OverallOK: overallOK,
-		Title:     "All transactions",
+		Title:     title,
+		Filter:    filter,
+		Reasons:   txReasons,
+		Filtered:  filtered,
+		BasePath:  basePath,
 	}
 
 	page := web.Page[TxContent]{Header: header, Content: content}
@@ -0,0 +1,241 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/http/middleware"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TransactionsAPIHandler serves the public ledger as JSON with keyset
+// pagination on (created_at, id), newest first. It accepts the same filters
+// as the HTML page.
+type TransactionsAPIHandler struct {
+	DB *pgxpool.Pool
+}
+
+type apiTxEntry struct {
+	AccountID string  `json:"account_id"`
+	UserID    *string `json:"user_id"`
+	Delta     int64   `json:"delta"`
+}
+
+type apiTx struct {
+	ID           string       `json:"id"`
+	Reason       string       `json:"reason"`
+	BetID        *string      `json:"bet_id"`
+	Note         *string      `json:"note"`
+	CreatedAt    time.Time    `json:"created_at"`
+	PrevHash     *string      `json:"prev_hash"`
+	Hash         string       `json:"hash"`
+	Entries      []apiTxEntry `json:"entries"`
+	StakesHidden bool         `json:"stakes_hidden,omitempty"` // entries withheld until the blind bet is resolved
+}
+
+type apiTxPage struct {
+	Transactions []apiTx `json:"transactions"`
+	NextCursor   string  `json:"next_cursor,omitempty"`
+}
+
+var errBadCursor = errors.New("invalid cursor")
+
+// txCursor points just past the last row of a page.
+type txCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func (c txCursor) encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeTxCursor(s string) (txCursor, error) {
+	var c txCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errBadCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || !looksLikeUUID(id) {
+		return c, errBadCursor
+	}
+	if c.CreatedAt, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+		return c, errBadCursor
+	}
+	c.ID = id
+	return c, nil
+}
+
+func looksLikeUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F'):
+			return false
+		}
+	}
+	return true
+}
+
+func (h *TransactionsAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	if uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	role, err := middleware.GetUserRole(ctx, h.DB, uid)
+	if err != nil || role == middleware.RoleUnverified {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	limit := parseIntDefault(q.Get("limit"), 50)
+	if limit < 1 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	var after *txCursor
+	if s := q.Get("cursor"); s != "" {
+		c, err := decodeTxCursor(s)
+		if err != nil {
+			http.Error(w, "bad cursor", http.StatusBadRequest)
+			return
+		}
+		after = &c
+	}
+	filter, err := parseTxFilter(q)
+	if err != nil {
+		http.Error(w, "bad date range (use YYYY-MM-DD, from <= to)", http.StatusBadRequest)
+		return
+	}
+	fromTS, toTS := filter.nullableBounds()
+
+	var afterTS *time.Time
+	var afterID *string
+	if after != nil {
+		afterTS, afterID = &after.CreatedAt, &after.ID
+	}
+
+	// Same shape as public_transactions, but selecting from transactions
+	// directly so the keyset predicate can use idx_tx_created_id.
+	isMod := role == middleware.RoleModerator || role == middleware.RoleAdmin
+	rows, err := h.DB.Query(ctx, `
+		select t.id::text, t.reason::text, t.bet_id::text, t.note, t.created_at,
+		       encode(t.prev_hash, 'hex'), encode(t.hash, 'hex'),
+		       (select jsonb_agg(jsonb_build_object(
+		                 'account_id', e.account_id,
+		                 'user_id', a.user_id,
+		                 'delta', e.delta
+		               ) order by e.account_id)
+		          from ledger_entries e
+		          join accounts a on a.id = e.account_id
+		         where e.tx_id = t.id) as entries,
+		       not $8 and `+blindStakesSQL("t.bet_id")+`
+		from transactions t
+		where ($2::timestamptz is null or (t.created_at, t.id) < ($2, $3::uuid))
+		  and ($4 = '' or t.reason::text = $4)
+		  and ($5 = '' or t.bet_id::text = $5)
+		  and ($6::timestamptz is null or t.created_at >= $6)
+		  and ($7::timestamptz is null or t.created_at < $7)
+		order by t.created_at desc, t.id desc
+		limit $1
+	`, limit+1, afterTS, afterID, filter.Reason, filter.BetID, fromTS, toTS, isMod)
+	if err != nil {
+		slog.Error("api.transactions.query", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	out := apiTxPage{Transactions: []apiTx{}}
+	for rows.Next() {
+		var t apiTx
+		var entriesJSON []byte
+		if err := rows.Scan(&t.ID, &t.Reason, &t.BetID, &t.Note, &t.CreatedAt, &t.PrevHash, &t.Hash, &entriesJSON, &t.StakesHidden); err != nil {
+			slog.Error("api.transactions.scan", "err", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if entriesJSON == nil || t.StakesHidden {
+			entriesJSON = []byte("[]")
+		}
+		if err := json.Unmarshal(entriesJSON, &t.Entries); err != nil {
+			slog.Error("api.transactions.decode_entries", "err", err)
+			http.Error(w, "decode error", http.StatusInternalServerError)
+			return
+		}
+		out.Transactions = append(out.Transactions, t)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("api.transactions.rows_err", "err", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	page := listPage{Total: -1}
+	if len(out.Transactions) > limit {
+		out.Transactions = out.Transactions[:limit]
+		last := out.Transactions[limit-1]
+		out.NextCursor = txCursor{CreatedAt: last.CreatedAt, ID: last.ID}.encode()
+		page.Next = withQuery(q, "cursor", out.NextCursor)
+	}
+
+	// The total ignores the cursor; prev is the limit rows just newer than
+	// it, so it needs the cursor of the row before those, if any.
+	if err := h.DB.QueryRow(ctx, `
+		select count(*) from transactions t
+		where ($1 = '' or t.reason::text = $1)
+		  and ($2 = '' or t.bet_id::text = $2)
+		  and ($3::timestamptz is null or t.created_at >= $3)
+		  and ($4::timestamptz is null or t.created_at < $4)
+	`, filter.Reason, filter.BetID, fromTS, toTS).Scan(&page.Total); err != nil {
+		slog.Warn("api.transactions.count", "err", err)
+		page.Total = -1
+	}
+	if after != nil {
+		var c txCursor
+		err := h.DB.QueryRow(ctx, `
+			select t.created_at, t.id::text from transactions t
+			where (t.created_at, t.id) > ($1, $2::uuid)
+			  and ($3 = '' or t.reason::text = $3)
+			  and ($4 = '' or t.bet_id::text = $4)
+			  and ($5::timestamptz is null or t.created_at >= $5)
+			  and ($6::timestamptz is null or t.created_at < $6)
+			order by t.created_at asc, t.id asc
+			offset $7 limit 1
+		`, after.CreatedAt, after.ID, filter.Reason, filter.BetID, fromTS, toTS, limit-1).Scan(&c.CreatedAt, &c.ID)
+		switch {
+		case err == nil:
+			page.Prev = withQuery(q, "cursor", c.encode())
+		case errors.Is(err, pgx.ErrNoRows):
+			page.Prev = withQuery(q, "cursor", "") // the first page
+		default:
+			slog.Warn("api.transactions.prev", "err", err)
+		}
+	}
+	setPaginationHeaders(w, r, page)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
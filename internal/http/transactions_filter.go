@@ -0,0 +1,111 @@
+package http
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// txReasons are the values accepted by the ?reason= filter.
+var txReasons = []string{"BET", "TRANSFER", "GIFT", "AIRDROP", "ADJUST"}
+
+func isTxReason(s string) bool {
+	for _, r := range txReasons {
+		if r == s {
+			return true
+		}
+	}
+	return false
+}
+
+const txDateLayout = "2006-01-02"
+
+var errBadDateRange = errors.New("invalid date range")
+
+// txFilter narrows the ledger listing. From and To are UTC calendar days and
+// both ends are inclusive.
+type txFilter struct {
+	Reason string
+	BetID  string
+	From   string // YYYY-MM-DD, "" for unbounded
+	To     string // YYYY-MM-DD, "" for unbounded
+}
+
+func parseTxFilter(q url.Values) (txFilter, error) {
+	f := txFilter{
+		Reason: strings.ToUpper(strings.TrimSpace(q.Get("reason"))),
+		BetID:  strings.TrimSpace(q.Get("bet")),
+		From:   strings.TrimSpace(q.Get("from")),
+		To:     strings.TrimSpace(q.Get("to")),
+	}
+	if !isTxReason(f.Reason) {
+		f.Reason = ""
+	}
+	from, to, err := f.bounds()
+	if err != nil {
+		return f, err
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return f, errBadDateRange
+	}
+	return f, nil
+}
+
+// bounds returns the half-open [from, to) interval covered by the filter;
+// zero times mean unbounded.
+func (f txFilter) bounds() (from, to time.Time, err error) {
+	if f.From != "" {
+		if from, err = time.Parse(txDateLayout, f.From); err != nil {
+			return from, to, errBadDateRange
+		}
+	}
+	if f.To != "" {
+		if to, err = time.Parse(txDateLayout, f.To); err != nil {
+			return from, to, errBadDateRange
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
+
+// nullableBounds converts bounds to query arguments, nil meaning unbounded.
+func (f txFilter) nullableBounds() (from, to *time.Time) {
+	lo, hi, _ := f.bounds()
+	if !lo.IsZero() {
+		from = &lo
+	}
+	if !hi.IsZero() {
+		to = &hi
+	}
+	return from, to
+}
+
+func (f txFilter) Active() bool {
+	return f.Reason != "" || f.BetID != "" || f.From != "" || f.To != ""
+}
+
+// values encodes the filter for links, omitting unset fields.
+func (f txFilter) values() url.Values {
+	q := url.Values{}
+	if f.Reason != "" {
+		q.Set("reason", f.Reason)
+	}
+	if f.BetID != "" {
+		q.Set("bet", f.BetID)
+	}
+	if f.From != "" {
+		q.Set("from", f.From)
+	}
+	if f.To != "" {
+		q.Set("to", f.To)
+	}
+	return q
+}
+
+func (f txFilter) pageURL(base string, page, size int) string {
+	q := f.values()
+	q.Set("page", itoa(page))
+	q.Set("size", itoa(size))
+	return base + "?" + q.Encode()
+}
@@ -0,0 +1,264 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/dbtest"
+	"betsandpedestres/internal/web"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestTransactionsHideBlindStakes checks that the public ledger does not
+// reveal who staked what on a blind bet until it is resolved.
+func TestTransactionsHideBlindStakes(t *testing.T) {
+	pool := dbtest.New(t)
+	rend, err := web.NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	creator, _ := dbtest.User(t, pool, "creator", "")
+	viewer, _ := dbtest.User(t, pool, "viewer", "")
+	mod, _ := dbtest.User(t, pool, "mod", "moderator")
+	blindBettor, blindWallet := dbtest.User(t, pool, "blindbettor", "")
+	openBettor, openWallet := dbtest.User(t, pool, "openbettor", "")
+	dbtest.Fund(t, pool, blindWallet, 100*coins.Unit())
+	dbtest.Fund(t, pool, openWallet, 100*coins.Unit())
+
+	blindBet, blindOpts := newTestBetForm(t, pool, creator, betForm{Title: "Blind", Options: []string{"Yes", "No"}, Visibility: visibilityPublic, Blind: true})
+	publicBet, publicOpts := newTestBet(t, pool, creator, "Yes", "No")
+	placeTestWager(t, pool, blindBettor, blindBet, blindOpts[0], "17")
+	placeTestWager(t, pool, openBettor, publicBet, publicOpts[0], "5")
+
+	ledger := func(uid, query string) string {
+		t.Helper()
+		r := asUser(httptest.NewRequest(http.MethodGet, "/transactions"+query, nil), uid)
+		rec := httptest.NewRecorder()
+		(&TransactionsHandler{DB: pool, TPL: rend}).ServeHTTP(rec, r)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /transactions%s: status %d", query, rec.Code)
+		}
+		return rec.Body.String()
+	}
+	api := func(uid, query string) apiTxPage {
+		t.Helper()
+		r := asUser(httptest.NewRequest(http.MethodGet, "/api/v1/transactions"+query, nil), uid)
+		rec := httptest.NewRecorder()
+		(&TransactionsAPIHandler{DB: pool}).ServeHTTP(rec, r)
+		var page apiTxPage
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatalf("GET /api/v1/transactions%s: status %d: %v", query, rec.Code, err)
+		}
+		return page
+	}
+	// blindEntries counts the API entries of the blind bet's transactions.
+	blindEntries := func(page apiTxPage) (hidden, entries int) {
+		for _, tx := range page.Transactions {
+			if tx.BetID == nil || *tx.BetID != blindBet {
+				continue
+			}
+			if tx.StakesHidden {
+				hidden++
+			}
+			entries += len(tx.Entries)
+		}
+		return hidden, entries
+	}
+
+	for _, query := range []string{"", "?bet=" + blindBet, "?reason=BET"} {
+		body := ledger(viewer, query)
+		if strings.Contains(body, "blindbettor") {
+			t.Errorf("/transactions%s names the blind bettor", query)
+		}
+		if !strings.Contains(body, "Stakes hidden until resolution") {
+			t.Errorf("/transactions%s does not mark the hidden stakes", query)
+		}
+		if hidden, entries := blindEntries(api(viewer, query)); hidden == 0 || entries != 0 {
+			t.Errorf("/api/v1/transactions%s: %d hidden transactions, %d entries of the blind bet", query, hidden, entries)
+		}
+	}
+	if body := ledger(viewer, ""); !strings.Contains(body, "openbettor") {
+		t.Error("/transactions hides the stakes of a public bet")
+	}
+	if body := ledger(mod, "?bet="+blindBet); !strings.Contains(body, "blindbettor") {
+		t.Error("moderators do not see the blind bettor")
+	}
+
+	resolveTestBet(t, pool, blindBet, blindOpts[0], payoutPolicy{})
+
+	if body := ledger(viewer, "?bet="+blindBet); !strings.Contains(body, "blindbettor") || strings.Contains(body, "Stakes hidden") {
+		t.Error("the blind bet's stakes stay hidden after resolution")
+	}
+	if hidden, entries := blindEntries(api(viewer, "?bet="+blindBet)); hidden != 0 || entries == 0 {
+		t.Errorf("API after resolution: %d hidden transactions, %d entries", hidden, entries)
+	}
+}
+
+// TestTransactionsAccountLabels checks how ledger lines are labelled:
+// wallets by their owner's display name, escrows by their bet's title,
+// except for private bets, which only show their id.
+func TestTransactionsAccountLabels(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	rend, err := web.NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	creator, _ := dbtest.User(t, pool, "creator", "")
+	alice, wallet := dbtest.User(t, pool, "alice", "")
+	if _, err := pool.Exec(ctx, `update users set display_name = 'Alice Liddell' where id = $1::uuid`, alice); err != nil {
+		t.Fatal(err)
+	}
+	dbtest.Fund(t, pool, wallet, 100*coins.Unit())
+	publicBet, publicOpts := newTestBetForm(t, pool, creator, betForm{Title: "Rain tomorrow", Options: []string{"Yes", "No"}, Visibility: visibilityPublic})
+	privateBet, privateOpts := newTestBetForm(t, pool, creator, betForm{Title: "Secret party", Options: []string{"Yes", "No"}, Visibility: visibilityPrivate, Invitees: []string{"alice"}})
+	placeTestWager(t, pool, alice, publicBet, publicOpts[0], "3")
+	placeTestWager(t, pool, alice, privateBet, privateOpts[0], "4")
+
+	var escrowName string
+	if err := pool.QueryRow(ctx, `select name from accounts where bet_id = $1::uuid`, publicBet).Scan(&escrowName); err != nil {
+		t.Fatal(err)
+	}
+	if want := accounts.EscrowName(publicBet); escrowName != want {
+		t.Errorf("escrow account named %q, want %q", escrowName, want)
+	}
+
+	r := asUser(httptest.NewRequest(http.MethodGet, "/transactions", nil), creator)
+	rec := httptest.NewRecorder()
+	(&TransactionsHandler{DB: pool, TPL: rend}).ServeHTTP(rec, r)
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<b>user</b>: Alice Liddell",
+		`<b>escrow</b>: <a href="/bets/` + publicBet + `">Rain tomorrow</a>`,
+		"<b>escrow</b>: " + privateBet,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("ledger lacks %q", want)
+		}
+	}
+	if strings.Contains(body, "Secret party") {
+		t.Error("ledger shows the title of a private bet")
+	}
+}
+
+func TestTxFilterBounds(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse(txDateLayout, s)
+		return d
+	}
+	tests := []struct {
+		from, to       string
+		wantLo, wantHi time.Time
+		wantErr        bool
+	}{
+		{"2026-03-01", "2026-03-31", day("2026-03-01"), day("2026-04-01"), false},
+		{"2026-03-01", "2026-03-01", day("2026-03-01"), day("2026-03-02"), false},
+		{"2026-03-01", "", day("2026-03-01"), time.Time{}, false},
+		{"", "2026-02-28", time.Time{}, day("2026-03-01"), false},
+		{"", "", time.Time{}, time.Time{}, false},
+		{"2026-03-02", "2026-03-01", time.Time{}, time.Time{}, true},
+		{"2026-3-1", "", time.Time{}, time.Time{}, true},
+		{"", "2026-02-30", time.Time{}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		q := url.Values{"from": {tt.from}, "to": {tt.to}}
+		f, err := parseTxFilter(q)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("from %q to %q: no error", tt.from, tt.to)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("from %q to %q: %v", tt.from, tt.to, err)
+			continue
+		}
+		lo, hi, _ := f.bounds()
+		if !lo.Equal(tt.wantLo) || !hi.Equal(tt.wantHi) {
+			t.Errorf("from %q to %q: bounds [%v, %v), want [%v, %v)", tt.from, tt.to, lo, hi, tt.wantLo, tt.wantHi)
+		}
+	}
+}
+
+// giftAt records a gift from the house to walletID dated at.
+func giftAt(t *testing.T, pool *pgxpool.Pool, walletID string, at time.Time, note string) {
+	t.Helper()
+	ctx := context.Background()
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		house, err := accounts.EnsureHouseAccount(ctx, tx)
+		if err != nil {
+			return err
+		}
+		var txID string
+		if err := tx.QueryRow(ctx, `insert into transactions (reason, note, created_at) values ('GIFT', $1, $2) returning id`, note, at).Scan(&txID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `insert into ledger_entries (tx_id, account_id, delta) values ($1, $2, -1), ($1, $3, 1)`, txID, house, walletID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("gift at %v: %v", at, err)
+	}
+}
+
+// TestTransactionsDateWindow checks both ends of the ?from=&to= window: the
+// first instant of the from day and the last instant of the to day are in,
+// the instants just outside are not.
+func TestTransactionsDateWindow(t *testing.T) {
+	pool := dbtest.New(t)
+	rend, err := web.NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, wallet := dbtest.User(t, pool, "alice", "")
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	giftAt(t, pool, wallet, start.Add(-time.Microsecond), "before-start")
+	giftAt(t, pool, wallet, start, "at-start")
+	giftAt(t, pool, wallet, end.Add(-time.Microsecond), "before-end")
+	giftAt(t, pool, wallet, end, "at-end")
+	// 00:30 in CET is still February in UTC, which the window is in.
+	giftAt(t, pool, wallet, time.Date(2026, 3, 1, 0, 30, 0, 0, time.FixedZone("CET", 3600)), "cet-before-start")
+
+	const query = "?from=2026-03-01&to=2026-03-31"
+	want := map[string]bool{"before-start": false, "at-start": true, "before-end": true, "at-end": false, "cet-before-start": false}
+
+	r := asUser(httptest.NewRequest(http.MethodGet, "/api/v1/transactions"+query, nil), alice)
+	rec := httptest.NewRecorder()
+	(&TransactionsAPIHandler{DB: pool}).ServeHTTP(rec, r)
+	var page apiTxPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("api: status %d: %v", rec.Code, err)
+	}
+	got := map[string]bool{}
+	for _, tx := range page.Transactions {
+		if tx.Note != nil {
+			got[*tx.Note] = true
+		}
+	}
+
+	r = asUser(httptest.NewRequest(http.MethodGet, "/transactions"+query, nil), alice)
+	rec = httptest.NewRecorder()
+	(&TransactionsHandler{DB: pool, TPL: rend}).ServeHTTP(rec, r)
+	body := rec.Body.String()
+
+	for note, in := range want {
+		if got[note] != in {
+			t.Errorf("api: %s listed = %v, want %v", note, got[note], in)
+		}
+		// "before-start" is a substring of "cet-before-start": match the
+		// note with its surrounding markup.
+		if listed := strings.Contains(body, ">"+note+"<"); listed != in {
+			t.Errorf("page: %s listed = %v, want %v", note, listed, in)
+		}
+	}
+}
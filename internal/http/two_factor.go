@@ -0,0 +1,36 @@
+package http
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/auth"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// recoveryCodeCount is how many recovery codes enrolling hands out.
+const recoveryCodeCount = 10
+
+// checkSecondFactor accepts either a current TOTP code for secret or one of
+// uid's unused recovery codes, consuming whichever matched: the TOTP time
+// step is recorded so the same code cannot be replayed, a recovery code is
+// marked used.
+func checkSecondFactor(ctx context.Context, db *pgxpool.Pool, uid, secret, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return false, nil
+	}
+	if step, ok := auth.CheckTOTP(secret, code, time.Now()); ok {
+		tag, err := db.Exec(ctx, `
+			update users set totp_last_step = $2
+			where id = $1::uuid and (totp_last_step is null or totp_last_step < $2)
+		`, uid, step)
+		return err == nil && tag.RowsAffected() == 1, err
+	}
+	tag, err := db.Exec(ctx, `
+		update totp_recovery_codes set used_at = now()
+		where user_id = $1::uuid and code_hash = $2 and used_at is null
+	`, uid, auth.HashRecoveryCode(code))
+	return err == nil && tag.RowsAffected() == 1, err
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"betsandpedestres/internal/auth"
+	"betsandpedestres/internal/dbtest"
+	"betsandpedestres/internal/http/middleware"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pquerna/otp/totp"
+)
+
+// enableTestTwoFactor turns on 2FA for uid and returns its secret and
+// recovery codes.
+func enableTestTwoFactor(t *testing.T, pool *pgxpool.Pool, uid string) (secret string, codes []string) {
+	t.Helper()
+	ctx := context.Background()
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codes, err = auth.NewRecoveryCodes(3); err != nil {
+		t.Fatal(err)
+	}
+	err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `update users set totp_secret = $2 where id = $1::uuid`, uid, secret); err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(ctx, tx, uid, codes)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret, codes
+}
+
+func testTOTPCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := totp.GenerateCode(secret, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestCheckSecondFactor(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	uid, _ := dbtest.User(t, pool, "alice", "")
+	secret, codes := enableTestTwoFactor(t, pool, uid)
+	now := time.Now()
+
+	steps := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"empty", "", false},
+		{"wrong code", "not-a-code", false},
+		{"current code", testTOTPCode(t, secret, now), true},
+		{"replayed code", testTOTPCode(t, secret, now), false},
+		{"older code after a newer one", testTOTPCode(t, secret, now.Add(-30*time.Second)), false},
+		{"next window", testTOTPCode(t, secret, now.Add(30*time.Second)), true},
+		{"two windows ahead", testTOTPCode(t, secret, now.Add(90*time.Second)), false},
+		{"recovery code", codes[0], true},
+		{"recovery code reused", strings.ToUpper(codes[0]), false},
+		{"another recovery code", " " + codes[1] + " ", true},
+	}
+	for _, s := range steps {
+		ok, err := checkSecondFactor(ctx, pool, uid, secret, s.code)
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if ok != s.want {
+			t.Errorf("%s: ok = %v, want %v", s.name, ok, s.want)
+		}
+	}
+}
+
+func TestVerifyTwoFactorRateLimited(t *testing.T) {
+	pool := dbtest.New(t)
+	uid, _ := dbtest.User(t, pool, "alice", "")
+	secret, _ := enableTestTwoFactor(t, pool, uid)
+	h := &UserProfileHandler{DB: pool, TwoFactorLimiter: middleware.NewRateLimiter(2, time.Minute)}
+
+	disable := func(code string) string {
+		t.Helper()
+		form := url.Values{"action": {"2fa_disable"}, "code": {code}}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, asUser(postForm("/profile", form), uid))
+		if rec.Code != http.StatusSeeOther {
+			t.Fatalf("status %d, want 303", rec.Code)
+		}
+		return rec.Header().Get("Location")
+	}
+	for i := range 2 {
+		if loc := disable("000000"); !strings.Contains(loc, "2fa=invalid") {
+			t.Fatalf("attempt %d: redirected to %q, want 2fa=invalid", i+1, loc)
+		}
+	}
+	if loc := disable(testTOTPCode(t, secret, time.Now())); !strings.Contains(loc, "2fa=limited") {
+		t.Fatalf("third attempt: redirected to %q, want 2fa=limited", loc)
+	}
+	var enabled bool
+	if err := pool.QueryRow(context.Background(), `select totp_secret is not null from users where id = $1::uuid`, uid).Scan(&enabled); err != nil {
+		t.Fatal(err)
+	}
+	if !enabled {
+		t.Error("2FA was turned off past the limit")
+	}
+}
@@ -2,6 +2,11 @@ package http
 
 import "strings"
 
+// assetLink is the URL of an embedded asset, absolute when baseURL is set.
+func assetLink(baseURL, name string) string {
+	return strings.TrimRight(baseURL, "/") + "/assets/" + name
+}
+
 func betLink(baseURL, betID string) string {
 	base := strings.TrimRight(baseURL, "/")
 	if base == "" {
@@ -3,14 +3,21 @@ package http
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/mail"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/achievements"
 	"betsandpedestres/internal/auth"
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/db"
 	"betsandpedestres/internal/http/middleware"
 	"betsandpedestres/internal/notify"
 	"betsandpedestres/internal/web"
@@ -22,6 +29,23 @@ type UserProfileHandler struct {
 	DB       *pgxpool.Pool
 	TPL      *web.Renderer
 	Notifier notify.Notifier
+
+	// WelcomeBonus (minor units) is gifted on a user's first approval.
+	WelcomeBonus int64
+	// PasswordHistory is how many recent passwords cannot be reused; 0 off.
+	PasswordHistory int
+	// UniqueDisplayNames refuses display names already used by someone else.
+	UniqueDisplayNames bool
+	// EmailEnabled offers the email notification address setting.
+	EmailEnabled bool
+	// TelegramBot is the bot username linked from the Telegram section.
+	TelegramBot string
+	// TestLimiter throttles "send test notification", per user.
+	TestLimiter *middleware.RateLimiter
+	// TwoFactorLimiter throttles the code checks guarding "turn off" and
+	// "new recovery codes", per client IP. It is the login limiter, so the
+	// profile is no way around it.
+	TwoFactorLimiter *middleware.RateLimiter
 }
 
 type profileUserInfo struct {
@@ -32,6 +56,7 @@ type profileUserInfo struct {
 	JoinedAt       time.Time
 	TelegramChatID *int64
 	TelegramNotify bool
+	Email          *string
 }
 
 type profileWallet struct {
@@ -63,6 +88,65 @@ type profileTransaction struct {
 	Delta     int64
 }
 
+// profileInvite is an invitation to an open bet the invitee has not
+// wagered on yet; Name/Username is the other party.
+type profileInvite struct {
+	BetID     string
+	BetTitle  string
+	Name      string
+	Username  string
+	CreatedAt time.Time
+}
+
+// profilePageSize is how many rows each paginated profile list shows.
+const profilePageSize = 20
+
+var profilePageParams = []string{"bets_page", "wagers_page", "tx_page"}
+
+// profilePager links to the neighbouring pages of one profile list. Each
+// list pages on its own query parameter so the others keep their place.
+type profilePager struct {
+	HasPrev bool
+	HasNext bool
+	PrevURL string
+	NextURL string
+}
+
+// profileListPage reads the 1-based page number of the list paginated by
+// param.
+func profileListPage(r *http.Request, param string) int {
+	page := atoiDefault(r.URL.Query().Get(param), 1)
+	if page < 1 {
+		page = 1
+	}
+	return page
+}
+
+// newProfilePager builds the links for a list that fetched n rows (up to
+// profilePageSize+1) on page. anchor is the id of the list's section.
+func newProfilePager(r *http.Request, param, anchor string, page, n int) profilePager {
+	// Keep the other lists' pages but drop one-shot status flags.
+	q := url.Values{}
+	for _, k := range profilePageParams {
+		if v := r.URL.Query().Get(k); v != "" {
+			q.Set(k, v)
+		}
+	}
+	link := func(p int) string {
+		v := ""
+		if p > 1 {
+			v = strconv.Itoa(p)
+		}
+		return r.URL.Path + "?" + withQuery(q, param, v).Encode() + "#" + anchor
+	}
+	return profilePager{
+		HasPrev: page > 1,
+		HasNext: n > profilePageSize,
+		PrevURL: link(page - 1),
+		NextURL: link(page + 1),
+	}
+}
+
 type profileUserOption struct {
 	Username    string
 	DisplayName string
@@ -75,16 +159,30 @@ type profileContent struct {
 	ActiveBets           []profileBet
 	ActiveWagers         []profileWager
 	Transactions         []profileTransaction
+	BetsPager            profilePager
+	WagersPager          profilePager
+	TxPager              profilePager
 	ViewingOther         bool
 	ShowUserPicker       bool
 	UserOptions          []profileUserOption
 	CanEditRoles         bool
 	RoleUpdateStatus     string
 	ShowTelegram         bool
+	TelegramBot          string
 	PasswordUpdateStatus string
 	DisplayUpdateStatus  string
 	NotifyUpdateStatus   string
+	TestNotifyStatus     string
+	ShowEmail            bool
+	EmailUpdateStatus    string
+	TwoFactor            *twoFactorPanel // own profile only
 	TransferStatus       string
+	AdjustStatus         string
+	InvitesReceived      []profileInvite // own profile only
+	InvitesSent          []profileInvite // own profile only
+	Achievements         []achievements.Unlocked
+	Follows              followStats
+	FollowStatus         string
 }
 
 func (h *UserProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -123,6 +221,16 @@ func (h *UserProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				h.handleDisplayChange(w, r, uid)
 			case "notify":
 				h.handleNotifyToggle(w, r, uid)
+			case "test_notify":
+				h.handleTestNotification(w, r, uid)
+			case "2fa_enable":
+				h.handleTwoFactorEnable(w, r, uid)
+			case "2fa_disable":
+				h.handleTwoFactorDisable(w, r, uid)
+			case "2fa_codes":
+				h.handleTwoFactorCodes(w, r, uid)
+			case "email":
+				h.handleEmailChange(w, r, uid)
 			case "transfer":
 				h.handleTransfer(w, r, uid)
 			default:
@@ -150,15 +258,18 @@ func (h *UserProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
-		targetDisplay, err := h.updateUserRole(ctx, uid, target, newRole)
+		res, err := h.updateUserRole(ctx, uid, target, newRole)
 		if err != nil {
 			http.Error(w, "db error", http.StatusInternalServerError)
 			return
 		}
-		if targetDisplay != "" {
-			msg := fmt.Sprintf("Admin %s set role for %s to %s", header.DisplayName, targetDisplay, newRole)
+		if res.DisplayName != "" {
+			msg := fmt.Sprintf("Admin %s set role for %s to %s", header.DisplayName, res.DisplayName, newRole)
 			h.Notifier.NotifyAdmins(ctx, msg)
 		}
+		if res.Bonus > 0 {
+			h.Notifier.NotifyUser(ctx, res.UserID, fmt.Sprintf("Welcome aboard! Your account was approved and you received 🦶 %s PiedPièces to get started.", coins.Format(res.Bonus)))
+		}
 		http.Redirect(w, r, "/profile/"+target+"?role=updated", http.StatusSeeOther)
 		return
 	}
@@ -188,22 +299,64 @@ func (h *UserProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	wallet := h.fetchWallet(ctx, targetUser.ID)
-	activeBets, err := h.fetchActiveBets(ctx, targetUser.ID)
+	showAll := targetUser.ID == uid || role == middleware.RoleModerator || role == middleware.RoleAdmin
+	betsPage := profileListPage(r, "bets_page")
+	activeBets, err := h.fetchActiveBets(ctx, targetUser.ID, uid, showAll, profilePageSize+1, (betsPage-1)*profilePageSize)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	betsPager := newProfilePager(r, "bets_page", "bets", betsPage, len(activeBets))
+	if len(activeBets) > profilePageSize {
+		activeBets = activeBets[:profilePageSize]
+	}
+	wagersPage := profileListPage(r, "wagers_page")
+	activeWagers, err := h.fetchActiveWagers(ctx, targetUser.ID, uid, showAll, profilePageSize+1, (wagersPage-1)*profilePageSize)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	wagersPager := newProfilePager(r, "wagers_page", "wagers", wagersPage, len(activeWagers))
+	if len(activeWagers) > profilePageSize {
+		activeWagers = activeWagers[:profilePageSize]
+	}
+	txPage := profileListPage(r, "tx_page")
+	transactions, err := h.fetchTransactions(ctx, targetUser.ID, showAll, profilePageSize+1, (txPage-1)*profilePageSize)
 	if err != nil {
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
-	activeWagers, err := h.fetchActiveWagers(ctx, targetUser.ID)
+	txPager := newProfilePager(r, "tx_page", "transactions", txPage, len(transactions))
+	if len(transactions) > profilePageSize {
+		transactions = transactions[:profilePageSize]
+	}
+
+	unlocked, err := achievements.ForUser(ctx, h.DB, targetUser.ID)
 	if err != nil {
+		slog.Error("profile.achievements", "err", err)
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
-	transactions, err := h.fetchTransactions(ctx, targetUser.ID)
+
+	follows, err := fetchFollowStats(ctx, h.DB, targetUser.ID, uid)
 	if err != nil {
+		slog.Error("profile.follows", "err", err)
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
 
+	var invitesReceived, invitesSent []profileInvite
+	if targetUser.ID == uid {
+		if invitesReceived, err = h.fetchPendingInvites(ctx, uid, true); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if invitesSent, err = h.fetchPendingInvites(ctx, uid, false); err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	var userOptions []profileUserOption
 	showPicker := role != middleware.RoleUnverified
 	if showPicker {
@@ -217,16 +370,37 @@ func (h *UserProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ActiveBets:           activeBets,
 		ActiveWagers:         activeWagers,
 		Transactions:         transactions,
+		BetsPager:            betsPager,
+		WagersPager:          wagersPager,
+		TxPager:              txPager,
 		ViewingOther:         targetUsername != header.Username,
 		ShowUserPicker:       showPicker,
 		UserOptions:          userOptions,
 		RoleUpdateStatus:     r.URL.Query().Get("role"),
 		CanEditRoles:         role == middleware.RoleAdmin,
 		ShowTelegram:         targetUsername == header.Username,
+		TelegramBot:          h.TelegramBot,
 		PasswordUpdateStatus: r.URL.Query().Get("pwd"),
 		DisplayUpdateStatus:  r.URL.Query().Get("display"),
 		NotifyUpdateStatus:   r.URL.Query().Get("notify"),
+		TestNotifyStatus:     r.URL.Query().Get("test"),
+		ShowEmail:            h.EmailEnabled && targetUsername == header.Username,
+		EmailUpdateStatus:    r.URL.Query().Get("email"),
 		TransferStatus:       r.URL.Query().Get("transfer"),
+		AdjustStatus:         r.URL.Query().Get("adjust"),
+		InvitesReceived:      invitesReceived,
+		InvitesSent:          invitesSent,
+		Achievements:         unlocked,
+		Follows:              follows,
+		FollowStatus:         r.URL.Query().Get("follow"),
+	}
+
+	if !content.ViewingOther {
+		content.TwoFactor, err = h.loadTwoFactorPanel(ctx, targetUser.ID, targetUser.Username, header.Brand.Name, r.URL.Query().Get("2fa"))
+		if err != nil {
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	page := web.Page[profileContent]{Header: header, Content: content}
@@ -243,10 +417,10 @@ func (h *UserProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (h *UserProfileHandler) fetchUserInfo(ctx context.Context, username string) (profileUserInfo, error) {
 	var info profileUserInfo
 	err := h.DB.QueryRow(ctx, `
-		select id::text, username, display_name, role, created_at, telegram_chat_id, telegram_notify
+		select id::text, username, display_name, role, created_at, telegram_chat_id, telegram_notify, email
 		from users
 		where username = $1
-	`, username).Scan(&info.ID, &info.Username, &info.DisplayName, &info.Role, &info.JoinedAt, &info.TelegramChatID, &info.TelegramNotify)
+	`, username).Scan(&info.ID, &info.Username, &info.DisplayName, &info.Role, &info.JoinedAt, &info.TelegramChatID, &info.TelegramNotify, &info.Email)
 	return info, err
 }
 
@@ -268,7 +442,13 @@ func (h *UserProfileHandler) fetchWallet(ctx context.Context, userID string) pro
 	return wallet
 }
 
-func (h *UserProfileHandler) fetchActiveBets(ctx context.Context, userID string) ([]profileBet, error) {
+// profileBetScopeSQL hides unlisted and private bets from other people's
+// profiles unless showAll ($3) or the viewer ($2) is a participant.
+func profileBetScopeSQL() string {
+	return `($3 or b.visibility = 'public' or (b.visibility = 'private' and ` + betParticipantSQL("$2") + `))`
+}
+
+func (h *UserProfileHandler) fetchActiveBets(ctx context.Context, userID, viewerID string, showAll bool, limit, offset int) ([]profileBet, error) {
 	rows, err := h.DB.Query(ctx, `
 		select
 			b.id::text,
@@ -279,10 +459,11 @@ func (h *UserProfileHandler) fetchActiveBets(ctx context.Context, userID string)
 		from bets b
 		left join wagers w on w.bet_id = b.id
 		where b.creator_user_id = $1::uuid and b.status = 'open'
+		  and `+profileBetScopeSQL()+`
 		group by b.id
-		order by b.created_at desc
-		limit 20
-	`, userID)
+		order by b.created_at desc, b.id
+		limit $4 offset $5
+	`, userID, viewerID, showAll, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -302,7 +483,7 @@ func (h *UserProfileHandler) fetchActiveBets(ctx context.Context, userID string)
 	return list, nil
 }
 
-func (h *UserProfileHandler) fetchActiveWagers(ctx context.Context, userID string) ([]profileWager, error) {
+func (h *UserProfileHandler) fetchActiveWagers(ctx context.Context, userID, viewerID string, showAll bool, limit, offset int) ([]profileWager, error) {
 	rows, err := h.DB.Query(ctx, `
 		select
 			b.id::text,
@@ -312,10 +493,12 @@ func (h *UserProfileHandler) fetchActiveWagers(ctx context.Context, userID strin
 		from wagers w
 		join bets b on b.id = w.bet_id
 		where w.user_id = $1::uuid and b.status = 'open'
+		  and `+profileBetScopeSQL()+`
+		  and ($3 or not b.blind)
 		group by b.id
-		order by b.deadline asc nulls last, b.title asc
-		limit 20
-	`, userID)
+		order by b.deadline asc nulls last, b.title asc, b.id
+		limit $4 offset $5
+	`, userID, viewerID, showAll, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -335,7 +518,38 @@ func (h *UserProfileHandler) fetchActiveWagers(ctx context.Context, userID strin
 	return list, nil
 }
 
-func (h *UserProfileHandler) fetchTransactions(ctx context.Context, userID string) ([]profileTransaction, error) {
+// fetchPendingInvites lists invitations userID received (or sent) to open
+// bets the invitee has not wagered on.
+func (h *UserProfileHandler) fetchPendingInvites(ctx context.Context, userID string, received bool) ([]profileInvite, error) {
+	rows, err := h.DB.Query(ctx, `
+		select b.id::text, b.title, u.display_name, u.username, bi.created_at
+		from bet_invitations bi
+		join bets b on b.id = bi.bet_id
+		join users u on u.id = case when $2 then bi.invited_by else bi.user_id end
+		where (case when $2 then bi.user_id else bi.invited_by end) = $1::uuid
+		  and b.status = 'open'
+		  and (b.deadline is null or b.deadline > now())
+		  and not exists (select 1 from wagers w where w.bet_id = b.id and w.user_id = bi.user_id)
+		order by bi.created_at desc
+		limit 20
+	`, userID, received)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []profileInvite
+	for rows.Next() {
+		var inv profileInvite
+		if err := rows.Scan(&inv.BetID, &inv.BetTitle, &inv.Name, &inv.Username, &inv.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, inv)
+	}
+	return list, rows.Err()
+}
+
+func (h *UserProfileHandler) fetchTransactions(ctx context.Context, userID string, showAll bool, limit, offset int) ([]profileTransaction, error) {
 	rows, err := h.DB.Query(ctx, `
 		select
 			t.id::text,
@@ -349,9 +563,10 @@ func (h *UserProfileHandler) fetchTransactions(ctx context.Context, userID strin
 		join transactions t on t.id = le.tx_id
 		left join bets b on b.id = t.bet_id
 		where a.user_id = $1::uuid
+		  and ($4 or not `+blindStakesSQL("t.bet_id")+`)
 		order by t.created_at desc, t.id desc
-		limit 20
-	`, userID)
+		limit $2 offset $3
+	`, userID, limit, offset, showAll)
 	if err != nil {
 		return nil, err
 	}
@@ -402,12 +617,26 @@ func (h *UserProfileHandler) handlePasswordChange(w http.ResponseWriter, r *http
 		return
 	}
 
+	reused, err := auth.RecentlyUsed(ctx, h.DB, uid, newPass, h.PasswordHistory)
+	if err != nil {
+		slog.Error("profile.password.history", "err", err)
+		http.Redirect(w, r, "/profile?pwd=error", http.StatusSeeOther)
+		return
+	}
+	if reused {
+		http.Redirect(w, r, "/profile?pwd=reused", http.StatusSeeOther)
+		return
+	}
+
 	newHash, err := auth.HashPassword(newPass)
 	if err != nil {
 		http.Redirect(w, r, "/profile?pwd=error", http.StatusSeeOther)
 		return
 	}
-	if _, err := h.DB.Exec(ctx, `update users set password_hash = $2 where id = $1::uuid`, uid, newHash); err != nil {
+	if err := pgx.BeginFunc(ctx, h.DB, func(tx pgx.Tx) error {
+		return auth.SetPassword(ctx, tx, uid, newHash, h.PasswordHistory)
+	}); err != nil {
+		slog.Error("profile.password.update", "err", err)
 		http.Redirect(w, r, "/profile?pwd=error", http.StatusSeeOther)
 		return
 	}
@@ -416,19 +645,36 @@ func (h *UserProfileHandler) handlePasswordChange(w http.ResponseWriter, r *http
 }
 
 func (h *UserProfileHandler) handleDisplayChange(w http.ResponseWriter, r *http.Request, uid string) {
-	newName := strings.TrimSpace(r.Form.Get("display_name"))
-	if newName == "" {
+	newName, err := accounts.CleanDisplayName(r.Form.Get("display_name"))
+	switch {
+	case newName == "":
 		http.Redirect(w, r, "/profile?display=missing", http.StatusSeeOther)
 		return
-	}
-	if len([]rune(newName)) > 64 {
-		runes := []rune(newName)
-		newName = string(runes[:64])
+	case errors.Is(err, accounts.ErrDisplayNameShort):
+		http.Redirect(w, r, "/profile?display=short", http.StatusSeeOther)
+		return
+	case errors.Is(err, accounts.ErrDisplayNameLong):
+		http.Redirect(w, r, "/profile?display=long", http.StatusSeeOther)
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
+	if h.UniqueDisplayNames {
+		var taken bool
+		if err := h.DB.QueryRow(ctx, `
+			select exists (select 1 from users where lower(display_name) = lower($2) and id <> $1::uuid)
+		`, uid, newName).Scan(&taken); err != nil {
+			http.Redirect(w, r, "/profile?display=error", http.StatusSeeOther)
+			return
+		}
+		if taken {
+			http.Redirect(w, r, "/profile?display=taken", http.StatusSeeOther)
+			return
+		}
+	}
+
 	if _, err := h.DB.Exec(ctx, `update users set display_name = $2 where id = $1::uuid`, uid, newName); err != nil {
 		http.Redirect(w, r, "/profile?display=error", http.StatusSeeOther)
 		return
@@ -457,6 +703,74 @@ func (h *UserProfileHandler) handleNotifyToggle(w http.ResponseWriter, r *http.R
 	http.Redirect(w, r, "/profile?notify=updated", http.StatusSeeOther)
 }
 
+// handleTestNotification DMs the user a sample message through every
+// channel they set up, so they can check it arrives. Delivery is
+// asynchronous: "sent" means handed to the notifiers.
+func (h *UserProfileHandler) handleTestNotification(w http.ResponseWriter, r *http.Request, uid string) {
+	if h.TestLimiter != nil && !h.TestLimiter.Allow(uid) {
+		http.Redirect(w, r, "/profile?test=limited", http.StatusSeeOther)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var linked, hasEmail bool
+	if err := h.DB.QueryRow(ctx, `
+		select telegram_chat_id is not null, email is not null from users where id = $1::uuid
+	`, uid).Scan(&linked, &hasEmail); err != nil {
+		http.Redirect(w, r, "/profile?test=error", http.StatusSeeOther)
+		return
+	}
+	if h.Notifier == nil || !linked && !(hasEmail && h.EmailEnabled) {
+		http.Redirect(w, r, "/profile?test=none", http.StatusSeeOther)
+		return
+	}
+	h.Notifier.NotifyUser(ctx, uid, "🔔 Test notification from your profile: notifications reach you here.")
+	http.Redirect(w, r, "/profile?test=sent", http.StatusSeeOther)
+}
+
+// handleEmailChange saves (or, when blank, clears) the address email
+// notifications go to.
+func (h *UserProfileHandler) handleEmailChange(w http.ResponseWriter, r *http.Request, uid string) {
+	if !h.EmailEnabled {
+		http.Redirect(w, r, "/profile?email=error", http.StatusSeeOther)
+		return
+	}
+	var email *string
+	if raw := strings.TrimSpace(r.Form.Get("email")); raw != "" {
+		addr, err := mail.ParseAddress(raw)
+		if err != nil || addr.Name != "" || len(addr.Address) > 254 {
+			http.Redirect(w, r, "/profile?email=invalid", http.StatusSeeOther)
+			return
+		}
+		email = &addr.Address
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if _, err := h.DB.Exec(ctx, `update users set email = $2 where id = $1::uuid`, uid, email); err != nil {
+		http.Redirect(w, r, "/profile?email=error", http.StatusSeeOther)
+		return
+	}
+	if email == nil {
+		http.Redirect(w, r, "/profile?email=cleared", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/profile?email=updated", http.StatusSeeOther)
+}
+
+// transferError carries the status code shown to the user and the failed
+// step out of the transfer transaction.
+type transferError struct {
+	code, step string
+	err        error
+}
+
+func (e *transferError) Error() string { return e.step + ": " + e.code }
+
+// Unwrap exposes the database error so serialization failures are retried.
+func (e *transferError) Unwrap() error { return e.err }
+
 func (h *UserProfileHandler) handleTransfer(w http.ResponseWriter, r *http.Request, uid string) {
 	redirect := func(code, step string, err error) {
 		if err != nil {
@@ -470,7 +784,7 @@ func (h *UserProfileHandler) handleTransfer(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	amountStr := strings.TrimSpace(r.Form.Get("amount"))
-	amount, err := strconv.ParseInt(amountStr, 10, 64)
+	amount, err := coins.Parse(amountStr)
 	if err != nil || amount <= 0 {
 		redirect("invalid", "amount", err)
 		return
@@ -507,61 +821,52 @@ func (h *UserProfileHandler) handleTransfer(w http.ResponseWriter, r *http.Reque
 		redirect("self", "recipient_self", nil)
 		return
 	}
-	tx, err := h.DB.Begin(ctx)
-	if err != nil {
-		redirect("error", "tx_begin", err)
-		return
-	}
-	defer func() {
-		if tx != nil {
-			_ = tx.Rollback(ctx)
+	err = db.RunSerializable(ctx, h.DB, func(tx pgx.Tx) error {
+		var err error
+		if senderAcct, err = ensureDefaultAccountTx(ctx, tx, uid, true); err != nil {
+			return &transferError{"error", "sender_wallet", err}
+		}
+		if recipientAcct, err = ensureDefaultAccountTx(ctx, tx, recipientID, false); err != nil {
+			return &transferError{"error", "recipient_wallet", err}
 		}
-	}()
-
-	if senderAcct, err = ensureDefaultAccountTx(ctx, tx, uid, true); err != nil {
-		redirect("error", "sender_wallet", err)
-		return
-	}
-	if recipientAcct, err = ensureDefaultAccountTx(ctx, tx, recipientID, false); err != nil {
-		redirect("error", "recipient_wallet", err)
-		return
-	}
 
-	err = tx.QueryRow(ctx, `select coalesce(balance,0)::bigint from user_balances where user_id = $1::uuid`, uid).Scan(&currentBalance)
-	if err == pgx.ErrNoRows {
-		currentBalance = 0
-	} else if err != nil {
-		redirect("error", "balance_lookup", err)
-		return
-	}
-	if amount > currentBalance {
-		redirect("notenough", "balance_check", nil)
-		return
-	}
+		err = tx.QueryRow(ctx, `select coalesce(balance,0)::bigint from user_balances where user_id = $1::uuid`, uid).Scan(&currentBalance)
+		if err == pgx.ErrNoRows {
+			currentBalance = 0
+		} else if err != nil {
+			return &transferError{"error", "balance_lookup", err}
+		}
+		if amount > currentBalance {
+			return &transferError{"notenough", "balance_check", nil}
+		}
 
-	var txID string
-	if err := tx.QueryRow(ctx, `
-			insert into transactions (reason, note)
-			values ('TRANSFER', nullif($1,''))
-			returning id::text
-		`, note).Scan(&txID); err != nil {
-		redirect("error", "tx_insert", err)
-		return
-	}
-	if _, err := tx.Exec(ctx, `
-			insert into ledger_entries (tx_id, account_id, delta) values
-			($1,$2,$4), ($1,$3,$5)
-		`, txID, senderAcct, recipientAcct, -amount, amount); err != nil {
-		redirect("error", "ledger_insert", err)
-		return
-	}
-	if err := tx.Commit(ctx); err != nil {
-		redirect("error", "tx_commit", err)
+		var txID string
+		if err := tx.QueryRow(ctx, `
+				insert into transactions (reason, note)
+				values ('TRANSFER', nullif($1,''))
+				returning id::text
+			`, note).Scan(&txID); err != nil {
+			return &transferError{"error", "tx_insert", err}
+		}
+		if _, err := tx.Exec(ctx, `
+				insert into ledger_entries (tx_id, account_id, delta) values
+				($1,$2,$4), ($1,$3,$5)
+			`, txID, senderAcct, recipientAcct, -amount, amount); err != nil {
+			return &transferError{"error", "ledger_insert", err}
+		}
+		return nil
+	})
+	if err != nil {
+		var te *transferError
+		if errors.As(err, &te) {
+			redirect(te.code, te.step, te.err)
+		} else {
+			redirect("error", "tx_commit", err)
+		}
 		return
 	}
-	tx = nil
 
-	summary := fmt.Sprintf("🦶 %d PiedPièces", amount)
+	summary := fmt.Sprintf("🦶 %s PiedPièces", coins.Format(amount))
 	if note != "" {
 		summary += "\nNote: " + note
 	}
@@ -596,42 +901,83 @@ func (h *UserProfileHandler) fetchUserOptions(ctx context.Context) ([]profileUse
 	return opts, nil
 }
 
-func (h *UserProfileHandler) updateUserRole(ctx context.Context, adminID, targetUsername, newRole string) (string, error) {
+type roleUpdate struct {
+	UserID      string
+	DisplayName string
+	Bonus       int64 // welcome bonus granted by this change, if any
+}
+
+func (h *UserProfileHandler) updateUserRole(ctx context.Context, adminID, targetUsername, newRole string) (roleUpdate, error) {
+	var res roleUpdate
 	tx, err := h.DB.Begin(ctx)
 	if err != nil {
-		return "", err
+		return res, err
 	}
 	defer tx.Rollback(ctx)
 
-	var targetID, oldRole, displayName string
+	var oldRole string
 	if err := tx.QueryRow(ctx, `
 		select id::text, role, display_name
 		from users
 		where username = $1
 		for update
-	`, targetUsername).Scan(&targetID, &oldRole, &displayName); err != nil {
-		return "", err
+	`, targetUsername).Scan(&res.UserID, &oldRole, &res.DisplayName); err != nil {
+		return res, err
 	}
 
 	if oldRole != newRole {
+		if oldRole == middleware.RoleUnverified && h.WelcomeBonus > 0 {
+			granted, err := h.grantWelcomeBonus(ctx, tx, res.UserID)
+			if err != nil {
+				return res, err
+			}
+			if granted {
+				res.Bonus = h.WelcomeBonus
+			}
+		}
 		if _, err := tx.Exec(ctx, `
 			update users
 			set role = $1
 			where id = $2::uuid
-		`, newRole, targetID); err != nil {
-			return "", err
+		`, newRole, res.UserID); err != nil {
+			return res, err
 		}
 		if _, err := tx.Exec(ctx, `
 			insert into admin_actions (admin_user_id, target_user_id, action, old_role, new_role)
 			values ($1::uuid, $2::uuid, $3, $4, $5)
-		`, adminID, targetID, "role_change", oldRole, newRole); err != nil {
-			return "", err
+		`, adminID, res.UserID, "role_change", oldRole, newRole); err != nil {
+			return res, err
 		}
 	}
 	if err := tx.Commit(ctx); err != nil {
-		return "", err
+		return res, err
+	}
+	return res, nil
+}
+
+// grantWelcomeBonus gifts the welcome bonus unless the user was approved
+// before (and later demoted back to unverified).
+func (h *UserProfileHandler) grantWelcomeBonus(ctx context.Context, tx pgx.Tx, userID string) (bool, error) {
+	var approvedBefore bool
+	if err := tx.QueryRow(ctx, `
+		select exists (
+			select 1 from admin_actions
+			where target_user_id = $1::uuid and action = 'role_change' and old_role = 'unverified'
+		)
+	`, userID).Scan(&approvedBefore); err != nil {
+		return false, err
+	}
+	if approvedBefore {
+		return false, nil
+	}
+	accountID, err := ensureDefaultAccountTx(ctx, tx, userID, false)
+	if err != nil {
+		return false, err
+	}
+	if err := accounts.GiftFromHouse(ctx, tx, accountID, h.WelcomeBonus, "welcome bonus"); err != nil {
+		return false, err
 	}
-	return displayName, nil
+	return true, nil
 }
 
 func isValidRole(role string) bool {
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/auth"
+	"betsandpedestres/internal/dbtest"
+)
+
+// TestPasswordChangeRejectsReuse walks a user through password changes with
+// a history of 3: the current password and the two before it are refused.
+func TestPasswordChangeRejectsReuse(t *testing.T) {
+	pool := dbtest.New(t)
+	uid, _ := dbtest.User(t, pool, "alice", "")
+	hash, err := auth.HashPassword("first-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec(context.Background(), `update users set password_hash = $2 where id = $1::uuid`, uid, hash); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &UserProfileHandler{DB: pool, PasswordHistory: 3}
+	current := "first-pass"
+	change := func(newPass, want string) {
+		t.Helper()
+		form := url.Values{"action": {"password"}, "current_password": {current}, "new_password": {newPass}, "confirm_password": {newPass}}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, asUser(postForm("/profile", form), uid))
+		if loc := rec.Header().Get("Location"); rec.Code != http.StatusSeeOther || loc != "/profile?pwd="+want {
+			t.Fatalf("change to %s: status %d, location %q, want pwd=%s", newPass, rec.Code, loc, want)
+		}
+		if want == "updated" {
+			current = newPass
+		}
+	}
+
+	change("first-pass", "reused")
+	change("second-pass", "updated")
+	change("third-pass", "updated")
+	change("first-pass", "reused")
+	change("second-pass", "reused")
+	change("third-pass", "reused")
+	change("fourth-pass", "updated")
+	// first-pass is now four passwords back and may be used again.
+	change("first-pass", "updated")
+
+	var kept int
+	if err := pool.QueryRow(context.Background(), `select count(*) from password_history where user_id = $1::uuid`, uid).Scan(&kept); err != nil {
+		t.Fatal(err)
+	}
+	if kept != 2 {
+		t.Errorf("%d hashes kept in the history, want 2", kept)
+	}
+
+	h.PasswordHistory = 0
+	change("first-pass", "updated")
+}
+
+// TestDisplayNameImpersonation checks that invisible characters or case do
+// not get a name past the uniqueness check, and that over-long names are
+// refused rather than stored truncated.
+func TestDisplayNameImpersonation(t *testing.T) {
+	pool := dbtest.New(t)
+	dbtest.User(t, pool, "Alice", "")
+	mallory, _ := dbtest.User(t, pool, "mallory", "")
+	h := &UserProfileHandler{DB: pool, UniqueDisplayNames: true}
+
+	for _, c := range []struct{ name, want string }{
+		{"Alice", "taken"},
+		{"  ALICE ", "taken"},
+		{"Ali\u200bce", "taken"},
+		{"\u202aAlice\u202c", "taken"},
+		{"\ufeffalice\u200d", "taken"},
+		{"\u200b", "missing"},
+		{"A\u200b", "short"},
+		{strings.Repeat("x", accounts.MaxDisplayNameLen+1), "long"},
+		{"Mallory\u200b", "updated"},
+	} {
+		form := url.Values{"action": {"display"}, "display_name": {c.name}}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, asUser(postForm("/profile", form), mallory))
+		if loc := rec.Header().Get("Location"); loc != "/profile?display="+c.want {
+			t.Errorf("display name %q: location %q, want display=%s", c.name, loc, c.want)
+		}
+	}
+
+	var stored string
+	if err := pool.QueryRow(context.Background(), `select display_name from users where id = $1::uuid`, mallory).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != "Mallory" {
+		t.Errorf("stored display name %q, want %q", stored, "Mallory")
+	}
+}
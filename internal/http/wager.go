@@ -6,16 +6,20 @@ import (
 	"errors"
 	"fmt"
 	"html"
-	"log/slog"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/apperr"
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/db"
 	"betsandpedestres/internal/http/middleware"
+	"betsandpedestres/internal/metrics"
 	"betsandpedestres/internal/notify"
-	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func (h *BetWagerCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -24,6 +28,12 @@ func (h *BetWagerCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if h.Limiter != nil {
+		if !h.Limiter.Allow("user:"+uid) || !h.Limiter.Allow("ip:"+middleware.ClientIP(r)) {
+			http.Error(w, "too many wagers, slow down", http.StatusTooManyRequests)
+			return
+		}
+	}
 	betID := r.PathValue("id")
 	if betID == "" {
 		http.NotFound(w, r)
@@ -36,9 +46,10 @@ func (h *BetWagerCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 
 	optionID := strings.TrimSpace(r.Form.Get("option_id"))
 	idempKey := strings.TrimSpace(r.Form.Get("idempotency_key"))
+	nonce := strings.TrimSpace(r.Form.Get("nonce"))
 	amtStr := strings.TrimSpace(r.Form.Get("amount"))
 
-	amount, err := strconv.ParseInt(amtStr, 10, 64)
+	amount, err := coins.Parse(amtStr)
 	if err != nil || amount <= 0 {
 		http.Error(w, "invalid amount", http.StatusBadRequest)
 		return
@@ -51,125 +62,299 @@ func (h *BetWagerCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
 	defer cancel()
 
-	tx, err := h.DB.Begin(ctx)
-	if err != nil {
-		http.Error(w, "db error", http.StatusInternalServerError)
-		return
+	if h.MinAccountAge > 0 {
+		role, err := middleware.GetUserRole(ctx, h.DB, uid)
+		if err == nil {
+			err = checkAccountAge(ctx, h.DB, uid, role, h.MinAccountAge, "place wagers")
+		}
+		if err != nil {
+			apperr.Write(w, err)
+			return
+		}
 	}
-	defer tx.Rollback(ctx)
 
-	// 1) Validate bet + option belong together and bet open & not past deadline & no votes yet
 	var (
-		ok          bool
 		creatorID   string
 		betTitle    string
 		optionLabel string
 		bettorName  string
+		blind       bool
+		visibility  string
+		createdAt   time.Time
+		minWager    int64
+		maxWager    int64
 	)
-	err = tx.QueryRow(ctx, `
-		select (b.status = 'open')
-		       and (b.deadline is null or b.deadline > now() at time zone 'utc')
-		       and not exists (select 1 from bet_resolution_votes v where v.bet_id = b.id) as can_wager,
-		       b.creator_user_id::text,
-		       b.title,
-		       o.label,
-		       u.display_name
-		from bet_options o
-		join bets b on b.id = o.bet_id
-		join users u on u.id = $3::uuid
-		where o.id = $1 and b.id = $2
-	`, optionID, betID, uid).Scan(&ok, &creatorID, &betTitle, &optionLabel, &bettorName)
-	if err != nil {
-		http.Error(w, "invalid bet or option", http.StatusBadRequest)
-		return
-	}
-	if !ok {
-		http.Error(w, "bet is closed, past deadline, or awaiting resolution", http.StatusConflict)
-		return
-	}
+	err = db.WithRetryTx(ctx, h.DB, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		// 0) The idempotency key alone can't tell a retry from a form left
+		// open while the odds or the balance moved on: both carry an unused
+		// key. Each rendered form carries a nonce, spent only if this commits.
+		fresh, err := consumeWagerNonce(ctx, tx, nonce, uid, betID)
+		if err != nil {
+			return apperr.Internal("db error", err)
+		}
+		if !fresh {
+			return errStaleWagerForm
+		}
 
-	// 2) Check available balance (nice UX + faster fail); constraint trigger will also protect
-	var avail int64
-	err = tx.QueryRow(ctx, `select coalesce(balance,0) from user_balances where user_id = $1`, uid).Scan(&avail)
-	if err != nil {
-		http.Error(w, "db error", http.StatusInternalServerError)
-		return
-	}
-	if amount > avail {
-		http.Error(w, "insufficient balance", http.StatusForbidden)
-		return
-	}
+		// 1) Validate bet + option belong together and bet open & not past deadline & no votes yet
+		var ok, participant bool
+		err = tx.QueryRow(ctx, `
+			select (b.status = 'open')
+			       and (b.deadline is null or b.deadline > now() at time zone 'utc')
+			       and not exists (select 1 from bet_resolution_votes v where v.bet_id = b.id) as can_wager,
+			       b.creator_user_id::text,
+			       b.title,
+			       o.label,
+			       u.display_name,
+			       b.blind,
+			       b.visibility::text,
+			       `+betParticipantSQL("$4")+` as participant,
+			       b.created_at,
+			       coalesce(b.min_wager, $5),
+			       coalesce(b.max_wager, $6)
+			from bet_options o
+			join bets b on b.id = o.bet_id
+			join users u on u.id = $3::uuid
+			where o.id = $1 and b.id = $2
+		`, optionID, betID, uid, uid, h.MinWager, h.MaxWager).Scan(&ok, &creatorID, &betTitle, &optionLabel, &bettorName, &blind, &visibility, &participant, &createdAt, &minWager, &maxWager)
+		if err != nil {
+			return apperr.Wrap(http.StatusBadRequest, "invalid bet or option", err)
+		}
+		if !participant {
+			return apperr.Forbidden("this bet is private")
+		}
+		if !ok {
+			return apperr.Conflict("bet is closed, past deadline, or awaiting resolution")
+		}
+		if opensAt := createdAt.Add(h.Cooldown); time.Now().Before(opensAt) {
+			return apperr.Conflict("betting on this bet opens in " + formatExpiresIn(&opensAt))
+		}
+		if minWager > 0 && amount < minWager {
+			return apperr.BadRequest("the minimum wager on this bet is " + coins.Format(minWager))
+		}
+		if maxWager > 0 && amount > maxWager {
+			return apperr.BadRequest("the maximum wager on this bet is " + coins.Format(maxWager))
+		}
 
-	// 3) Ensure bet escrow account exists
-	escrowAcctID, err := ensureBetEscrowAccount(ctx, tx, betID)
-	if err != nil {
-		slog.Error("escrow error", "error", err)
-		http.Error(w, "escrow error", http.StatusInternalServerError)
-		return
-	}
+		// 2) Check available balance (nice UX + faster fail); constraint trigger will also protect
+		var avail int64
+		if err := tx.QueryRow(ctx, `select coalesce(balance,0) from user_balances where user_id = $1`, uid).Scan(&avail); err != nil {
+			return apperr.Internal("db error", err)
+		}
+		if amount > avail {
+			return apperr.Forbidden("insufficient balance")
+		}
 
-	// 4) Get user's default wallet account id
-	var userAcctID string
-	if err := tx.QueryRow(ctx, `
-		select id::text from accounts where user_id = $1 and is_default
-	`, uid).Scan(&userAcctID); err != nil {
-		http.Error(w, "account error", http.StatusInternalServerError)
-		return
-	}
+		// 3) Ensure bet escrow account exists
+		escrowAcctID, err := ensureBetEscrowAccount(ctx, tx, betID)
+		if err != nil {
+			return apperr.Internal("escrow error", err)
+		}
 
-	// 5) Create transaction header
-	var txID string
-	if err := tx.QueryRow(ctx, `
-		insert into transactions (reason, bet_id, note) values ('BET', $1, null) returning id::text
-	`, betID).Scan(&txID); err != nil {
-		http.Error(w, "tx error", http.StatusInternalServerError)
-		return
-	}
+		// 4) Get user's default wallet account id
+		var userAcctID string
+		if err := tx.QueryRow(ctx, `
+			select id::text from accounts where user_id = $1 and is_default
+		`, uid).Scan(&userAcctID); err != nil {
+			return apperr.Internal("account error", err)
+		}
+
+		// 5) Create transaction header
+		var txID string
+		if err := tx.QueryRow(ctx, `
+			insert into transactions (reason, bet_id, note) values ('BET', $1, null) returning id::text
+		`, betID).Scan(&txID); err != nil {
+			return apperr.Internal("tx error", err)
+		}
+
+		// 6) Ledger entries: user -> escrow
+		if _, err := tx.Exec(ctx, `
+			insert into ledger_entries (tx_id, account_id, delta) values ($1,$2,$3), ($1,$4,$5)
+		`, txID, userAcctID, -amount, escrowAcctID, amount); err != nil {
+			return apperr.Internal("ledger error", err)
+		}
+
+		// 7) Insert the wager with idempotency: (user, bet, key) is unique, so a
+		// resubmitted form is a no-op while the same key on another bet still goes through.
+		if _, err := tx.Exec(ctx, `
+			insert into wagers (bet_id, user_id, option_id, amount, created_at, idempotency_key)
+			values ($1, $2, $3, $4, now() at time zone 'utc', $5)
+		`, betID, uid, optionID, amount, idempKey); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique violation (idempotency)
+				return errWagerAlreadySubmitted
+			}
+			return apperr.Internal("wager error", err)
+		}
 
-	// 6) Ledger entries: user -> escrow
-	if _, err := tx.Exec(ctx, `
-		insert into ledger_entries (tx_id, account_id, delta) values ($1,$2,$3), ($1,$4,$5)
-	`, txID, userAcctID, -amount, escrowAcctID, amount); err != nil {
-		http.Error(w, "ledger error", http.StatusInternalServerError)
+		// 8) Keep the bettor posted on the bet from now on
+		if err := subscribeToBet(ctx, tx, betID, uid); err != nil {
+			return apperr.Internal("subscription error", err)
+		}
+		return nil
+	})
+	if errors.Is(err, errStaleWagerForm) {
+		var recorded bool
+		if err := h.DB.QueryRow(ctx, `
+			select exists (select 1 from wagers where bet_id = $1 and user_id = $2 and idempotency_key = $3)
+		`, betID, uid, idempKey).Scan(&recorded); err != nil {
+			apperr.Write(w, apperr.Internal("db error", err))
+			return
+		}
+		if recorded {
+			http.Redirect(w, r, "/bets/"+betID+"?note=already_submitted", http.StatusSeeOther)
+		} else {
+			http.Redirect(w, r, "/bets/"+betID+"?note=stale_form", http.StatusSeeOther)
+		}
 		return
 	}
-
-	// 7) Insert the wager with idempotency
-	_, err = tx.Exec(ctx, `
-		insert into wagers (bet_id, user_id, option_id, amount, created_at, idempotency_key)
-		values ($1, $2, $3, $4, now() at time zone 'utc', $5)
-	`, betID, uid, optionID, amount, idempKey)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique violation (idempotency)
+		if errors.Is(err, errWagerAlreadySubmitted) {
 			// Treat as already successfully processed
 			http.Redirect(w, r, "/bets/"+betID+"?note=already_submitted", http.StatusSeeOther)
 			return
 		}
-		http.Error(w, "wager error", http.StatusInternalServerError)
-		return
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		http.Error(w, "commit error", http.StatusInternalServerError)
+		var ae *apperr.Error
+		if !errors.As(err, &ae) {
+			err = apperr.Internal("commit error", err)
+		}
+		apperr.Write(w, err)
 		return
 	}
+	metrics.WagersPlaced.Inc()
 
 	var totalStakes int64
 	if err := h.DB.QueryRow(ctx, `select coalesce(sum(amount),0)::bigint from wagers where bet_id = $1::uuid`, betID).Scan(&totalStakes); err != nil {
 		totalStakes = amount
 	}
 
-	if h.Notifier != nil {
-		link := betLink(h.BaseURL, betID)
-		groupMsg := formatWagerGroupMessage(bettorName, amount, betTitle, optionLabel, link, totalStakes)
-		h.Notifier.NotifyGroup(r.Context(), groupMsg)
-		h.Notifier.NotifySubscribers(r.Context(), groupMsg)
+	ev := wagerEvent{
+		BettorID:    uid,
+		Bettor:      bettorName,
+		Amount:      amount,
+		BetTitle:    betTitle,
+		OptionLabel: optionLabel,
+		Link:        betLink(h.BaseURL, betID),
+		Total:       totalStakes,
+		Blind:       blind,
+		Public:      visibility == visibilityPublic,
+	}
+	if h.Batcher != nil {
+		h.Batcher.Add(betID, ev)
+	} else {
+		announceWagers(r.Context(), h.DB, h.Notifier, betID, []wagerEvent{ev})
 	}
 
 	http.Redirect(w, r, "/bets/"+betID+"?note=placed", http.StatusSeeOther)
 }
 
+var errWagerAlreadySubmitted = errors.New("wager already submitted")
+
+// wagerNonceTTL is how long a rendered wager form can be submitted.
+const wagerNonceTTL = 30 * time.Minute
+
+// wagerNoncesPerBet caps the live nonces of one user on one bet: reloading
+// the bet page again and again only keeps the newest forms valid.
+const wagerNoncesPerBet = 8
+
+var errStaleWagerForm = errors.New("stale wager form")
+
+// issueWagerNonce stores and returns a nonce valid once for a wager by uid
+// on betID, dropping that user's oldest nonces for the bet beyond
+// wagerNoncesPerBet. Expired nonces are pruned by jobs.PruneWagerNonces.
+func issueWagerNonce(ctx context.Context, q execer, uid, betID string) (string, error) {
+	nonce := randomHex(16)
+	if _, err := q.Exec(ctx, `
+		insert into wager_nonces (nonce, user_id, bet_id, expires_at)
+		values ($1, $2::uuid, $3::uuid, now() + $4::interval)
+	`, nonce, uid, betID, wagerNonceTTL); err != nil {
+		return "", err
+	}
+	_, err := q.Exec(ctx, `
+		delete from wager_nonces
+		where user_id = $1::uuid and bet_id = $2::uuid
+		  and nonce not in (
+		    select nonce from wager_nonces
+		    where user_id = $1::uuid and bet_id = $2::uuid
+		    order by expires_at desc
+		    limit $3
+		  )
+	`, uid, betID, wagerNoncesPerBet)
+	return nonce, err
+}
+
+// consumeWagerNonce deletes nonce if it was issued to uid for betID and has
+// not expired, and reports whether it did. Called inside the wager's
+// transaction, so a wager that fails leaves the nonce usable.
+func consumeWagerNonce(ctx context.Context, q execer, nonce, uid, betID string) (bool, error) {
+	if nonce == "" {
+		return false, nil
+	}
+	tag, err := q.Exec(ctx, `
+		delete from wager_nonces
+		where nonce = $1 and user_id = $2::uuid and bet_id = $3::uuid and expires_at > now()
+	`, nonce, uid, betID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// wagerEvent is one placed wager waiting to be announced to the group and
+// the bet's subscribers.
+type wagerEvent struct {
+	BettorID    string
+	Bettor      string
+	Amount      int64
+	BetTitle    string
+	OptionLabel string
+	Link        string
+	Total       int64 // total stakes on the bet right after this wager
+	Blind       bool  // announce who wagered, but not how much or on what
+	Public      bool  // only public bets are announced to the group
+}
+
+// wagerBatcher is the batcher installed by NewMux, if any.
+var wagerBatcher *notify.Coalescer[wagerEvent]
+
+// FlushNotifications sends the wager announcements still waiting out their
+// batch window and waits for those being sent. Call it once the server has
+// stopped taking requests, before closing the pool.
+func FlushNotifications() {
+	if wagerBatcher != nil {
+		wagerBatcher.Close()
+	}
+}
+
+// newWagerBatcher announces wagers like announceWagers, merging those
+// placed on the same bet within window into a single message.
+func newWagerBatcher(db *pgxpool.Pool, n notify.Notifier, window time.Duration) *notify.Coalescer[wagerEvent] {
+	return notify.NewCoalescer(window, func(betID string, evs []wagerEvent) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		announceWagers(ctx, db, n, betID, evs)
+	})
+}
+
+// announceWagers tells the bet's subscribers about evs, except the bettors
+// themselves, and the group and global subscribers when the bet is public.
+func announceWagers(ctx context.Context, db *pgxpool.Pool, n notify.Notifier, betID string, evs []wagerEvent) {
+	if n == nil || len(evs) == 0 {
+		return
+	}
+	msg := formatWagerBatchMessage(evs)
+	public := evs[0].Public
+	if public {
+		n.NotifyGroup(ctx, msg)
+		n.NotifySubscribers(ctx, msg)
+	}
+	bettors := make([]string, 0, len(evs))
+	for _, ev := range evs {
+		bettors = append(bettors, ev.BettorID)
+	}
+	notifyBetSubscribers(ctx, db, n, betID, msg, public, bettors...)
+}
+
 func ensureBetEscrowAccount(ctx context.Context, tx pgx.Tx, betID string) (string, error) {
 	var acctID string
 	err := tx.QueryRow(ctx,
@@ -183,7 +368,7 @@ func ensureBetEscrowAccount(ctx context.Context, tx pgx.Tx, betID string) (strin
 		return "", err
 	}
 
-	name := "escrow:" + betID
+	name := accounts.EscrowName(betID)
 	err = tx.QueryRow(ctx, `
 		insert into accounts (user_id, bet_id, name, is_default)
 		values (null, $1::uuid, $2, true)
@@ -216,11 +401,56 @@ func formatWagerGroupMessage(bettor string, amount int64, betTitle, optionLabel,
 	if emojis != "" {
 		emojis = " " + emojis
 	}
-	msg := fmt.Sprintf("<strong>%s</strong> wagered 🦶 %d PiedPièces%s on <strong><a href=\"%s\">%s</a></strong> !\nOption: <em>%s</em>\nTotal wagers on this bet: 🦶 %d PiedPièces", safeBettor, amount, emojis, safeLink, safeTitle, safeOption, total)
+	msg := fmt.Sprintf("<strong>%s</strong> wagered 🦶 %s PiedPièces%s on <strong><a href=\"%s\">%s</a></strong> !\nOption: <em>%s</em>\nTotal wagers on this bet: 🦶 %s PiedPièces", safeBettor, coins.Format(amount), emojis, safeLink, safeTitle, safeOption, coins.Format(total))
+	return notify.HTMLPrefix + msg
+}
+
+func formatWagerBatchMessage(evs []wagerEvent) string {
+	if evs[0].Blind {
+		return formatBlindWagerMessage(evs)
+	}
+	if len(evs) == 1 {
+		ev := evs[0]
+		return formatWagerGroupMessage(ev.Bettor, ev.Amount, ev.BetTitle, ev.OptionLabel, ev.Link, ev.Total)
+	}
+	var added, total int64
+	var lines strings.Builder
+	for _, ev := range evs {
+		added += ev.Amount
+		total = max(total, ev.Total)
+		name := html.EscapeString(strings.TrimSpace(ev.Bettor))
+		if name == "" {
+			name = "Anonymous"
+		}
+		fmt.Fprintf(&lines, "\n- %s: 🦶 %s on <em>%s</em>", name, coins.Format(ev.Amount), html.EscapeString(ev.OptionLabel))
+	}
+	emojis := wagerEmojis(added)
+	if emojis != "" {
+		emojis = " " + emojis
+	}
+	last := evs[len(evs)-1]
+	msg := fmt.Sprintf("<strong>%d new wagers</strong> on <strong><a href=\"%s\">%s</a></strong> (+🦶 %s PiedPièces)%s%s\nTotal wagers on this bet: 🦶 %s PiedPièces",
+		len(evs), html.EscapeString(last.Link), html.EscapeString(last.BetTitle), coins.Format(added), emojis, lines.String(), coins.Format(total))
+	return notify.HTMLPrefix + msg
+}
+
+func formatBlindWagerMessage(evs []wagerEvent) string {
+	names := make([]string, 0, len(evs))
+	for _, ev := range evs {
+		name := html.EscapeString(strings.TrimSpace(ev.Bettor))
+		if name == "" {
+			name = "Anonymous"
+		}
+		names = append(names, name)
+	}
+	last := evs[len(evs)-1]
+	msg := fmt.Sprintf("<strong>%s</strong> placed a wager on <strong><a href=\"%s\">%s</a></strong> 🙈\nStakes stay hidden until the bet is resolved.",
+		strings.Join(names, ", "), html.EscapeString(last.Link), html.EscapeString(last.BetTitle))
 	return notify.HTMLPrefix + msg
 }
 
 func wagerEmojis(amount int64) string {
+	amount /= coins.Unit()
 	var b strings.Builder
 	if amount > 10 {
 		b.WriteString("🤑")
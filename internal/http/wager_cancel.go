@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"betsandpedestres/internal/apperr"
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/db"
+	"betsandpedestres/internal/http/middleware"
+	"github.com/jackc/pgx/v5"
+)
+
+func (h *BetWagerCancelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uid := middleware.UserID(r)
+	if uid == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	betID := r.PathValue("id")
+	wagerID := r.PathValue("wagerID")
+	if !looksLikeUUID(betID) || !looksLikeUUID(wagerID) {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	var (
+		creatorID   string
+		betTitle    string
+		optionLabel string
+		bettorName  string
+		amount      int64
+		blind       bool
+	)
+	err := db.WithRetryTx(ctx, h.DB, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		// Lock the bet first, like resolution does, so a cancel and a vote
+		// or payout on the same bet run one after the other.
+		var open bool
+		err := tx.QueryRow(ctx, `
+			select (b.status = 'open')
+			       and b.resolution_option_id is null
+			       and (b.deadline is null or b.deadline > now() at time zone 'utc'),
+			       b.creator_user_id::text, b.title, b.blind
+			from bets b
+			where b.id = $1
+			for update
+		`, betID).Scan(&open, &creatorID, &betTitle, &blind)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperr.New(http.StatusNotFound, "bet not found")
+		}
+		if err != nil {
+			return apperr.Internal("db error", err)
+		}
+		if !open {
+			return apperr.Conflict("bet is closed or past its deadline; wagers can no longer be withdrawn")
+		}
+		var voted bool
+		if err := tx.QueryRow(ctx, `select exists (select 1 from bet_resolution_votes where bet_id = $1)`, betID).Scan(&voted); err != nil {
+			return apperr.Internal("db error", err)
+		}
+		if voted {
+			return apperr.Conflict("resolution has started; wagers can no longer be withdrawn")
+		}
+
+		err = tx.QueryRow(ctx, `
+			select w.amount, o.label, u.display_name
+			from wagers w
+			join bet_options o on o.id = w.option_id
+			join users u on u.id = w.user_id
+			where w.id = $1 and w.bet_id = $2 and w.user_id = $3
+			for update of w
+		`, wagerID, betID, uid).Scan(&amount, &optionLabel, &bettorName)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperr.New(http.StatusNotFound, "wager not found")
+		}
+		if err != nil {
+			return apperr.Internal("db error", err)
+		}
+
+		escrowAcctID, err := ensureBetEscrowAccount(ctx, tx, betID)
+		if err != nil {
+			return apperr.Internal("escrow error", err)
+		}
+		var userAcctID string
+		if err := tx.QueryRow(ctx, `
+			select id::text from accounts where user_id = $1 and is_default
+		`, uid).Scan(&userAcctID); err != nil {
+			return apperr.Internal("account error", err)
+		}
+
+		var txID string
+		if err := tx.QueryRow(ctx, `
+			insert into transactions (reason, bet_id, note) values ('TRANSFER', $1, 'Wager withdrawn') returning id::text
+		`, betID).Scan(&txID); err != nil {
+			return apperr.Internal("tx error", err)
+		}
+		if _, err := tx.Exec(ctx, `
+			insert into ledger_entries (tx_id, account_id, delta) values ($1,$2,$3), ($1,$4,$5)
+		`, txID, escrowAcctID, -amount, userAcctID, amount); err != nil {
+			return apperr.Internal("ledger error", err)
+		}
+		if _, err := tx.Exec(ctx, `delete from wagers where id = $1`, wagerID); err != nil {
+			return apperr.Internal("wager error", err)
+		}
+		return nil
+	})
+	if err != nil {
+		var ae *apperr.Error
+		if !errors.As(err, &ae) {
+			err = apperr.Internal("commit error", err)
+		}
+		apperr.Write(w, err)
+		return
+	}
+
+	if h.Notifier != nil && creatorID != uid {
+		msg := fmt.Sprintf("%s withdrew a wager of 🦶 %s PiedPièces on \"%s\" (%s).\n%s",
+			bettorName, coins.Format(amount), betTitle, optionLabel, betLink(h.BaseURL, betID))
+		if blind {
+			msg = fmt.Sprintf("%s withdrew a wager on \"%s\".\n%s", bettorName, betTitle, betLink(h.BaseURL, betID))
+		}
+		h.Notifier.NotifyUser(r.Context(), creatorID, msg)
+	}
+	http.Redirect(w, r, "/bets/"+betID+"?note=withdrawn", http.StatusSeeOther)
+}
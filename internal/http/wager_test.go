@@ -0,0 +1,205 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/dbtest"
+	"betsandpedestres/internal/notify"
+)
+
+func TestWagerNonce(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	creator, _ := dbtest.User(t, pool, "creator", "")
+	alice, wallet := dbtest.User(t, pool, "alice", "")
+	dbtest.Fund(t, pool, wallet, 10*coins.Unit())
+	betID, opts := newTestBet(t, pool, creator, "Yes", "No")
+
+	nonce, err := issueWagerNonce(ctx, pool, alice, betID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNote := func(name, amount, key, want string) {
+		t.Helper()
+		rec := postTestWagerNonce(t, pool, alice, betID, opts[0], amount, key, nonce)
+		if loc := rec.Header().Get("Location"); rec.Code != http.StatusSeeOther || !strings.HasSuffix(loc, "note="+want) {
+			t.Fatalf("%s: status %d, location %q, want note=%s", name, rec.Code, loc, want)
+		}
+	}
+
+	// A wager that fails must not spend the form's nonce.
+	rec := postTestWagerNonce(t, pool, alice, betID, opts[0], "50", "k1", nonce)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("over balance: status %d, want 403", rec.Code)
+	}
+	expectNote("retry with a lower amount", "5", "k1", "placed")
+	expectNote("resubmitted form", "5", "k1", "already_submitted")
+	expectNote("stale form with a new key", "5", "k2", "stale_form")
+
+	if got := dbtest.Balance(t, pool, wallet); got != 5*coins.Unit() {
+		t.Errorf("balance = %d, want %d", got, 5*coins.Unit())
+	}
+}
+
+func TestWagerNonceScope(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	creator, _ := dbtest.User(t, pool, "creator", "")
+	alice, _ := dbtest.User(t, pool, "alice", "")
+	bob, _ := dbtest.User(t, pool, "bob", "")
+	betID, _ := newTestBet(t, pool, creator, "Yes", "No")
+	otherBet, _ := newTestBet(t, pool, creator, "Up", "Down")
+
+	nonce, err := issueWagerNonce(ctx, pool, alice, betID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ uid, betID string }{{bob, betID}, {alice, otherBet}} {
+		ok, err := consumeWagerNonce(ctx, pool, nonce, c.uid, c.betID)
+		if err != nil || ok {
+			t.Errorf("nonce of alice on %s consumed by %s on %s: %v, %v", betID, c.uid, c.betID, ok, err)
+		}
+	}
+	if ok, err := consumeWagerNonce(ctx, pool, "", alice, betID); err != nil || ok {
+		t.Errorf("empty nonce consumed: %v, %v", ok, err)
+	}
+
+	if _, err := pool.Exec(ctx, `update wager_nonces set expires_at = now() - interval '1 second' where nonce = $1`, nonce); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := consumeWagerNonce(ctx, pool, nonce, alice, betID); err != nil || ok {
+		t.Errorf("expired nonce consumed: %v, %v", ok, err)
+	}
+}
+
+func TestWagerNonceCap(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	creator, _ := dbtest.User(t, pool, "creator", "")
+	alice, _ := dbtest.User(t, pool, "alice", "")
+	betID, _ := newTestBet(t, pool, creator, "Yes", "No")
+
+	var nonces []string
+	for range wagerNoncesPerBet + 5 {
+		n, err := issueWagerNonce(ctx, pool, alice, betID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonces = append(nonces, n)
+	}
+	var live int
+	if err := pool.QueryRow(ctx, `select count(*) from wager_nonces where user_id = $1::uuid`, alice).Scan(&live); err != nil {
+		t.Fatal(err)
+	}
+	if live != wagerNoncesPerBet {
+		t.Errorf("%d live nonces, want %d", live, wagerNoncesPerBet)
+	}
+	if ok, err := consumeWagerNonce(ctx, pool, nonces[len(nonces)-1], alice, betID); err != nil || !ok {
+		t.Errorf("newest nonce: %v, %v", ok, err)
+	}
+}
+
+// TestWagerIdempotencyKeyScope checks that idempotency keys are scoped to
+// (user, bet): reusing a key on another bet, or by another user, is a new
+// wager, while resubmitting it on the same bet is not.
+func TestWagerIdempotencyKeyScope(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	unit := coins.Unit()
+	creator, _ := dbtest.User(t, pool, "creator", "")
+	alice, aliceWallet := dbtest.User(t, pool, "alice", "")
+	bob, bobWallet := dbtest.User(t, pool, "bob", "")
+	dbtest.Fund(t, pool, aliceWallet, 100*unit)
+	dbtest.Fund(t, pool, bobWallet, 100*unit)
+	bet1, opts1 := newTestBet(t, pool, creator, "Yes", "No")
+	bet2, opts2 := newTestBet(t, pool, creator, "Up", "Down")
+
+	for _, c := range []struct {
+		name          string
+		uid, bet, opt string
+		wantNote      string
+	}{
+		{"first bet", alice, bet1, opts1[0], "placed"},
+		{"same key, other bet", alice, bet2, opts2[0], "placed"},
+		{"same key, other user", bob, bet1, opts1[1], "placed"},
+		{"same key, same bet", alice, bet1, opts1[1], "already_submitted"},
+	} {
+		rec := postTestWager(t, pool, c.uid, c.bet, c.opt, "10", "same-key")
+		if loc := rec.Header().Get("Location"); rec.Code != http.StatusSeeOther || !strings.HasSuffix(loc, "note="+c.wantNote) {
+			t.Fatalf("%s: status %d, location %q, want note=%s", c.name, rec.Code, loc, c.wantNote)
+		}
+	}
+
+	var wagers int
+	if err := pool.QueryRow(ctx, `select count(*) from wagers where idempotency_key = 'same-key'`).Scan(&wagers); err != nil {
+		t.Fatal(err)
+	}
+	if wagers != 3 {
+		t.Errorf("%d wagers recorded, want 3", wagers)
+	}
+	if got := dbtest.Balance(t, pool, aliceWallet); got != 80*unit {
+		t.Errorf("alice's balance = %d, want %d", got, 80*unit)
+	}
+}
+
+func TestFormatWagerBatchMessage(t *testing.T) {
+	unit := coins.Unit()
+	ev := func(bettor string, amount int64, option string, total int64) wagerEvent {
+		return wagerEvent{Bettor: bettor, Amount: amount * unit, BetTitle: "Rain <tomorrow>?", OptionLabel: option, Link: "https://bap.example/bets/1?a=1&b=2", Total: total * unit}
+	}
+	tests := []struct {
+		name    string
+		evs     []wagerEvent
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "single wager",
+			evs:  []wagerEvent{ev("Alice", 5, "Yes", 5)},
+			want: []string{"<strong>Alice</strong> wagered 🦶 5 PiedPièces", "Option: <em>Yes</em>", "Total wagers on this bet: 🦶 5 PiedPièces"},
+		},
+		{
+			name: "batch sums the amounts and keeps the newest total",
+			evs:  []wagerEvent{ev("Alice", 5, "Yes", 5), ev("Bob", 20, "No", 25), ev("", 1, "Yes", 26)},
+			want: []string{
+				"<strong>3 new wagers</strong>", "(+🦶 26 PiedPièces) 🤑💵",
+				"\n- Alice: 🦶 5 on <em>Yes</em>", "\n- Bob: 🦶 20 on <em>No</em>", "\n- Anonymous: 🦶 1 on <em>Yes</em>",
+				"Total wagers on this bet: 🦶 26 PiedPièces",
+			},
+		},
+		{
+			name:    "escapes user input",
+			evs:     []wagerEvent{ev("<b>Eve</b>", 1, "<i>x</i>", 1), ev("Bob", 1, "No", 2)},
+			want:    []string{"&lt;b&gt;Eve&lt;/b&gt;", "&lt;i&gt;x&lt;/i&gt;", "Rain &lt;tomorrow&gt;?", `href="https://bap.example/bets/1?a=1&amp;b=2"`},
+			notWant: []string{"<b>Eve</b>", "<i>x</i>"},
+		},
+		{
+			name:    "blind bets name the bettors only",
+			evs:     []wagerEvent{{Bettor: "Alice", Amount: 50 * unit, BetTitle: "Secret", OptionLabel: "Yes", Total: 50 * unit, Blind: true}, {Bettor: "Bob", Amount: 7 * unit, BetTitle: "Secret", OptionLabel: "No", Total: 57 * unit, Blind: true}},
+			want:    []string{"<strong>Alice, Bob</strong> placed a wager", "Stakes stay hidden"},
+			notWant: []string{"50", "57", "Yes", "No"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := formatWagerBatchMessage(tt.evs)
+			if !strings.HasPrefix(msg, notify.HTMLPrefix) {
+				t.Errorf("message lacks the HTML prefix: %q", msg)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(msg, w) {
+					t.Errorf("message lacks %q:\n%s", w, msg)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(msg, w) {
+					t.Errorf("message contains %q:\n%s", w, msg)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"betsandpedestres/internal/achievements"
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AwardAchievements evaluates the registered achievement rules and tells
+// users about the ones they just unlocked.
+func AwardAchievements(db *pgxpool.Pool, notifier notify.Notifier) Job {
+	return Job{
+		Name:     "award_achievements",
+		Interval: 10 * time.Minute,
+		Run: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, time.Minute)
+			defer cancel()
+			n, err := achievements.Award(ctx, db, notifier)
+			if n > 0 {
+				slog.Info("jobs.achievements.awarded", "count", n)
+			}
+			return err
+		},
+	}
+}
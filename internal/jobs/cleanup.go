@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"betsandpedestres/internal/dbinit"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PruneExpiredRecoveries deletes password recovery tokens past their expiry.
+func PruneExpiredRecoveries(db *pgxpool.Pool) Job {
+	return Job{
+		Name:     "prune_password_recoveries",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			tag, err := db.Exec(ctx, `delete from password_recoveries where expires_at < now()`)
+			if err != nil {
+				return err
+			}
+			if n := tag.RowsAffected(); n > 0 {
+				slog.Info("jobs.prune_password_recoveries", "deleted", n)
+			}
+			return nil
+		},
+	}
+}
+
+// PruneExpiredSessions deletes database sessions past their expiry.
+func PruneExpiredSessions(db *pgxpool.Pool) Job {
+	return Job{
+		Name:     "prune_sessions",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			tag, err := db.Exec(ctx, `delete from sessions where expires_at < now()`)
+			if err != nil {
+				return err
+			}
+			if n := tag.RowsAffected(); n > 0 {
+				slog.Info("jobs.prune_sessions", "deleted", n)
+			}
+			return nil
+		},
+	}
+}
+
+// PruneWagerNonces deletes wager form nonces past their expiry.
+func PruneWagerNonces(db *pgxpool.Pool) Job {
+	return Job{
+		Name:     "prune_wager_nonces",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			tag, err := db.Exec(ctx, `delete from wager_nonces where expires_at < now()`)
+			if err != nil {
+				return err
+			}
+			if n := tag.RowsAffected(); n > 0 {
+				slog.Info("jobs.prune_wager_nonces", "deleted", n)
+			}
+			return nil
+		},
+	}
+}
+
+// ArchiveClosedBets flags bets that were closed (or cancelled) more than
+// after ago as archived.
+func ArchiveClosedBets(db *pgxpool.Pool, after time.Duration) Job {
+	return Job{
+		Name:     "archive_closed_bets",
+		Interval: 6 * time.Hour,
+		Run: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, time.Minute)
+			defer cancel()
+			tag, err := db.Exec(ctx, `
+				update bets set archived_at = now()
+				where archived_at is null
+				  and status <> 'open'
+				  and coalesce(resolved_at, deadline, created_at) < now() - $1::interval
+			`, after)
+			if err != nil {
+				return err
+			}
+			if n := tag.RowsAffected(); n > 0 {
+				slog.Info("jobs.archive_closed_bets", "archived", n)
+			}
+			return nil
+		},
+	}
+}
+
+// AuditEscrowAccounts checks that escrow accounts of settled bets net to zero.
+// A bet is settled once it is no longer open: resolution pays the escrow out
+// and marks the bet closed, cancellation refunds it. Non-zero escrows are only
+// reported, never touched. When archive is set, zero-balance escrows of
+// settled bets are flagged with archived_at.
+func AuditEscrowAccounts(db *pgxpool.Pool, archive bool) Job {
+	return Job{
+		Name:     "audit_escrow_accounts",
+		Interval: 6 * time.Hour,
+		Run: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, time.Minute)
+			defer cancel()
+			rows, err := db.Query(ctx, `
+				select a.id::text, b.id::text, b.status::text, coalesce(sum(le.delta),0)::bigint
+				from accounts a
+				join bets b on b.id = a.bet_id
+				left join ledger_entries le on le.account_id = a.id
+				where b.status <> 'open' and a.archived_at is null
+				group by a.id, b.id, b.status
+				having coalesce(sum(le.delta),0) <> 0
+			`)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var acctID, betID, status string
+				var balance int64
+				if err := rows.Scan(&acctID, &betID, &status, &balance); err != nil {
+					return err
+				}
+				slog.Warn("jobs.escrow.nonzero", "account_id", acctID, "bet_id", betID, "status", status, "balance", balance)
+			}
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if !archive {
+				return nil
+			}
+			tag, err := db.Exec(ctx, `
+				update accounts a
+				set archived_at = now()
+				from bets b
+				where b.id = a.bet_id
+				  and b.status <> 'open'
+				  and a.archived_at is null
+				  and coalesce((select sum(le.delta) from ledger_entries le where le.account_id = a.id), 0) = 0
+			`)
+			if err != nil {
+				return err
+			}
+			if n := tag.RowsAffected(); n > 0 {
+				slog.Info("jobs.escrow.archived", "accounts", n)
+			}
+			return nil
+		},
+	}
+}
+
+// RefreshBalances keeps user_balances_mv, the cached balances read by display
+// paths, at most interval behind the ledger.
+func RefreshBalances(connURL string, interval time.Duration) Job {
+	return Job{
+		Name:     "refresh_user_balances",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, time.Minute)
+			defer cancel()
+			return dbinit.RefreshBalancesMatView(ctx, connURL)
+		},
+	}
+}
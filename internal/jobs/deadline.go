@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NotifyDeadlineReached tells every distinct bettor of a bet that crossed
+// its deadline that it now awaits resolution. Each bet is flagged with
+// notified_deadline before sending, so bettors are pinged at most once.
+func NotifyDeadlineReached(db *pgxpool.Pool, notifier notify.Notifier, baseURL string) Job {
+	return Job{
+		Name:     "notify_deadline_reached",
+		Interval: 5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, time.Minute)
+			defer cancel()
+
+			type reminder struct {
+				betID, title string
+				userIDs      []string
+			}
+			var reminders []reminder
+			err := pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+				rows, err := tx.Query(ctx, `
+					update bets b
+					set notified_deadline = true
+					where b.status = 'open'
+					  and b.deadline is not null
+					  and b.deadline <= now()
+					  and not b.notified_deadline
+					returning b.id::text, b.title,
+					  array(
+					    select distinct w.user_id::text
+					    from wagers w
+					    join users u on u.id = w.user_id
+					    where w.bet_id = b.id and u.disabled_at is null
+					  )
+				`)
+				if err != nil {
+					return err
+				}
+				defer rows.Close()
+				for rows.Next() {
+					var rm reminder
+					if err := rows.Scan(&rm.betID, &rm.title, &rm.userIDs); err != nil {
+						return err
+					}
+					reminders = append(reminders, rm)
+				}
+				return rows.Err()
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, rm := range reminders {
+				link := strings.TrimRight(baseURL, "/") + "/bets/" + rm.betID
+				msg := fmt.Sprintf("⏰ The bet \"%s\" you wagered on reached its deadline and is awaiting resolution.\n%s", rm.title, link)
+				for _, uid := range rm.userIDs {
+					notifier.NotifyUser(ctx, uid, msg)
+				}
+			}
+			return nil
+		},
+	}
+}
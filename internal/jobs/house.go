@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"betsandpedestres/internal/accounts"
+	"betsandpedestres/internal/coins"
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WatchHouseBalance alerts admins when the house balance falls below
+// threshold (minor units, <= 0). It alerts once per crossing and re-arms when
+// the balance recovers.
+func WatchHouseBalance(db *pgxpool.Pool, notifier notify.Notifier, threshold int64) Job {
+	alerted := false
+	return Job{
+		Name:     "watch_house_balance",
+		Interval: 15 * time.Minute,
+		Run: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			eco, err := accounts.LoadEconomy(ctx, db)
+			if err != nil {
+				return err
+			}
+			if eco.HouseBalance >= threshold {
+				alerted = false
+				return nil
+			}
+			if alerted {
+				return nil
+			}
+			alerted = true
+			slog.Warn("jobs.house.below_threshold", "balance", eco.HouseBalance, "threshold", threshold)
+			notifier.NotifyAdmins(ctx, fmt.Sprintf("⚠️ House balance is 🦶 %s PiedPièces (alert threshold %s); %s PiedPièces in circulation.",
+				coins.Format(eco.HouseBalance), coins.Format(threshold), coins.Format(eco.Circulating)))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is a periodic maintenance task.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs registered jobs on their interval until its context is done.
+type Scheduler struct {
+	jobs []Job
+}
+
+func NewScheduler() *Scheduler { return &Scheduler{} }
+
+func (s *Scheduler) Add(j Job) {
+	if j.Run == nil || j.Interval <= 0 {
+		return
+	}
+	s.jobs = append(s.jobs, j)
+}
+
+// Run starts every job (once immediately, then on each tick) and blocks until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	slog.Info("jobs.scheduler.start", "jobs", len(s.jobs))
+	defer slog.Info("jobs.scheduler.stop")
+	var wg sync.WaitGroup
+	for _, j := range s.jobs {
+		wg.Add(1)
+		go func(j Job) {
+			defer wg.Done()
+			runLoop(ctx, j)
+		}(j)
+	}
+	wg.Wait()
+}
+
+func runLoop(ctx context.Context, j Job) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+	for {
+		runOnce(ctx, j)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runOnce(ctx context.Context, j Job) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.Error("jobs.panic", "job", j.Name, "panic", rec)
+		}
+	}()
+	start := time.Now()
+	if err := j.Run(ctx); err != nil {
+		slog.Warn("jobs.failed", "job", j.Name, "err", err)
+		return
+	}
+	slog.Debug("jobs.done", "job", j.Name, "duration_ms", time.Since(start).Milliseconds())
+}
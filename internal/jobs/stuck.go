@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/metrics"
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// StuckBet is an open bet whose resolvers voted for different outcomes, so
+// only an admin override can close it.
+type StuckBet struct {
+	ID       string
+	Title    string
+	LastVote time.Time
+	Votes    int
+}
+
+// StuckBets lists bets waiting for an admin decision whose latest vote is
+// older than olderThan (0 lists them all), longest waiting first.
+func StuckBets(ctx context.Context, db *pgxpool.Pool, olderThan time.Duration) ([]StuckBet, error) {
+	rows, err := db.Query(ctx, `
+		select b.id::text, b.title, max(v.created_at), count(*)::int
+		from bets b
+		join bet_resolution_votes v on v.bet_id = b.id
+		where b.status = 'open' and b.resolution_option_id is null
+		group by b.id, b.title
+		having count(distinct v.option_id) > 1 and max(v.created_at) <= $1
+		order by max(v.created_at)
+	`, time.Now().Add(-olderThan))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []StuckBet
+	for rows.Next() {
+		var s StuckBet
+		if err := rows.Scan(&s.ID, &s.Title, &s.LastVote, &s.Votes); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// WatchStuckBets publishes how many bets have waited longer than after for
+// an admin decision, and pings the admins about each one once.
+func WatchStuckBets(db *pgxpool.Pool, notifier notify.Notifier, baseURL string, after time.Duration) Job {
+	alerted := map[string]bool{}
+	return Job{
+		Name:     "watch_stuck_bets",
+		Interval: 15 * time.Minute,
+		Run: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			stuck, err := StuckBets(ctx, db, after)
+			if err != nil {
+				return err
+			}
+			metrics.StuckBets.Set(float64(len(stuck)))
+
+			var fresh []string
+			still := make(map[string]bool, len(stuck))
+			for _, s := range stuck {
+				still[s.ID] = true
+				if !alerted[s.ID] {
+					fresh = append(fresh, fmt.Sprintf("• %s\n  %s/bets/%s", s.Title, strings.TrimRight(baseURL, "/"), s.ID))
+				}
+			}
+			alerted = still
+			if len(fresh) == 0 {
+				return nil
+			}
+			slog.Warn("jobs.stuck_bets", "new", len(fresh), "total", len(stuck))
+			notifier.NotifyAdmins(ctx, fmt.Sprintf("⚖️ %d bet(s) have waited over %s for an admin decision (moderators disagree):\n%s",
+				len(fresh), HumanDuration(after), strings.Join(fresh, "\n")))
+			return nil
+		},
+	}
+}
+
+// HumanDuration prints whole days or hours as "2d" or "6h".
+func HumanDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return d.String()
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// InFlightRequests counts HTTP requests currently being served.
+var InFlightRequests = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "bap_http_in_flight_requests",
+	Help: "Number of HTTP requests currently being served.",
+})
+
+// RequestDuration observes request latency by mux route pattern (e.g.
+// "GET /bets/{id}", "unmatched" when no route served it) and status class.
+var RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "bap_http_request_duration_seconds",
+	Help:    "HTTP request duration by route pattern and status class.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"route", "status"})
+
+// ShedRequests counts requests rejected because the in-flight cap was reached.
+var ShedRequests = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "bap_http_shed_requests_total",
+	Help: "Requests rejected with 503 because the in-flight cap was reached.",
+})
+
+// RequestsTotal counts served requests by route pattern and status class.
+var RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bap_http_requests_total",
+	Help: "HTTP requests served by route pattern and status class.",
+}, []string{"route", "status"})
+
+// BetsCreated counts bets created through the web form.
+var BetsCreated = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "bap_bets_created_total",
+	Help: "Bets created.",
+})
+
+// WagersPlaced counts accepted wagers.
+var WagersPlaced = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "bap_wagers_placed_total",
+	Help: "Wagers placed.",
+})
+
+// Resolutions counts bets paid out, by how the outcome was settled
+// ("consensus" of resolvers or an admin "override").
+var Resolutions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bap_bet_resolutions_total",
+	Help: "Bets resolved and paid out, by resolution path.",
+}, []string{"path"})
+
+// StuckBets is how many bets have waited too long for an admin decision;
+// see jobs.WatchStuckBets.
+var StuckBets = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "bap_bets_stuck_awaiting_admin",
+	Help: "Bets whose moderators disagree and that have waited past the alert threshold for an admin.",
+})
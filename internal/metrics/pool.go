@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolAcquiredDesc = prometheus.NewDesc("bap_db_pool_acquired_conns", "Connections currently checked out of the pool.", []string{"pool"}, nil)
+	poolIdleDesc     = prometheus.NewDesc("bap_db_pool_idle_conns", "Idle connections in the pool.", []string{"pool"}, nil)
+	poolTotalDesc    = prometheus.NewDesc("bap_db_pool_total_conns", "Open connections in the pool.", []string{"pool"}, nil)
+	poolMaxDesc      = prometheus.NewDesc("bap_db_pool_max_conns", "Configured maximum pool size.", []string{"pool"}, nil)
+	poolAcquiresDesc = prometheus.NewDesc("bap_db_pool_acquires_total", "Successful connection acquisitions.", []string{"pool"}, nil)
+	poolWaitsDesc    = prometheus.NewDesc("bap_db_pool_empty_acquires_total", "Acquisitions that had to wait for a connection.", []string{"pool"}, nil)
+	poolWaitDesc     = prometheus.NewDesc("bap_db_pool_acquire_wait_seconds_total", "Time spent waiting for a connection.", []string{"pool"}, nil)
+	poolCancelDesc   = prometheus.NewDesc("bap_db_pool_canceled_acquires_total", "Acquisitions cancelled by their context.", []string{"pool"}, nil)
+)
+
+// poolCollector reads pgxpool.Stat on every scrape.
+type poolCollector struct {
+	name string
+	pool *pgxpool.Pool
+}
+
+// RegisterPool exports p's connection stats labelled pool=name.
+func RegisterPool(name string, p *pgxpool.Pool) error {
+	return prometheus.Register(&poolCollector{name: name, pool: p})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredDesc
+	ch <- poolIdleDesc
+	ch <- poolTotalDesc
+	ch <- poolMaxDesc
+	ch <- poolAcquiresDesc
+	ch <- poolWaitsDesc
+	ch <- poolWaitDesc
+	ch <- poolCancelDesc
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquiredDesc, prometheus.GaugeValue, float64(s.AcquiredConns()), c.name)
+	ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(s.IdleConns()), c.name)
+	ch <- prometheus.MustNewConstMetric(poolTotalDesc, prometheus.GaugeValue, float64(s.TotalConns()), c.name)
+	ch <- prometheus.MustNewConstMetric(poolMaxDesc, prometheus.GaugeValue, float64(s.MaxConns()), c.name)
+	ch <- prometheus.MustNewConstMetric(poolAcquiresDesc, prometheus.CounterValue, float64(s.AcquireCount()), c.name)
+	ch <- prometheus.MustNewConstMetric(poolWaitsDesc, prometheus.CounterValue, float64(s.EmptyAcquireCount()), c.name)
+	ch <- prometheus.MustNewConstMetric(poolWaitDesc, prometheus.CounterValue, s.AcquireDuration().Seconds(), c.name)
+	ch <- prometheus.MustNewConstMetric(poolCancelDesc, prometheus.CounterValue, float64(s.CanceledAcquireCount()), c.name)
+}
@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// Coalescer groups items sharing a key that arrive within a window and hands
+// them to flush as one batch. The window starts with the first item for a
+// key; later items join that batch instead of extending it, so a busy key
+// still flushes at least once per window.
+type Coalescer[T any] struct {
+	window time.Duration
+	flush  func(key string, items []T)
+
+	mu      sync.Mutex
+	pending map[string]*batch[T]
+	closed  bool
+	running sync.WaitGroup // flushes started by a window's timer
+}
+
+type batch[T any] struct {
+	items []T
+	timer *time.Timer
+}
+
+func NewCoalescer[T any](window time.Duration, flush func(key string, items []T)) *Coalescer[T] {
+	return &Coalescer[T]{window: window, flush: flush, pending: make(map[string]*batch[T])}
+}
+
+// Add queues item under key. flush runs on its own goroutine. Once the
+// Coalescer is closed, the item is flushed at once on the caller's.
+func (c *Coalescer[T]) Add(key string, item T) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		c.flush(key, []T{item})
+		return
+	}
+	b, open := c.pending[key]
+	if !open {
+		b = &batch[T]{}
+		c.pending[key] = b
+		b.timer = time.AfterFunc(c.window, func() { c.fire(key, b) })
+	}
+	b.items = append(b.items, item)
+	c.mu.Unlock()
+}
+
+func (c *Coalescer[T]) fire(key string, b *batch[T]) {
+	c.mu.Lock()
+	if c.pending[key] != b { // already taken by Flush
+		c.mu.Unlock()
+		return
+	}
+	delete(c.pending, key)
+	c.running.Add(1)
+	c.mu.Unlock()
+	defer c.running.Done()
+	c.flush(key, b.items)
+}
+
+// Flush hands every pending batch to flush now, on the caller's goroutine,
+// without waiting for the end of their windows.
+func (c *Coalescer[T]) Flush() {
+	c.mu.Lock()
+	batches := c.pending
+	c.pending = make(map[string]*batch[T])
+	for _, b := range batches {
+		b.timer.Stop()
+	}
+	c.mu.Unlock()
+	for key, b := range batches {
+		c.flush(key, b.items)
+	}
+}
+
+// Close flushes the pending batches and waits for the flushes already
+// running, so that nothing queued is lost on shutdown. Later Adds are
+// flushed immediately.
+func (c *Coalescer[T]) Close() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.Flush()
+	c.running.Wait()
+}
@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects the batches a Coalescer flushes.
+type recorder struct {
+	mu      sync.Mutex
+	batches map[string][][]string
+}
+
+func (r *recorder) flush(key string, items []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.batches == nil {
+		r.batches = map[string][][]string{}
+	}
+	r.batches[key] = append(r.batches[key], items)
+}
+
+func (r *recorder) get(key string) [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.batches[key])
+}
+
+func TestCoalescerBatchesPerKey(t *testing.T) {
+	var rec recorder
+	c := NewCoalescer(50*time.Millisecond, rec.flush)
+	c.Add("bet1", "a")
+	c.Add("bet2", "x")
+	c.Add("bet1", "b")
+	c.Add("bet1", "c")
+	if got := rec.get("bet1"); len(got) != 0 {
+		t.Fatalf("flushed before the window ended: %q", got)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := rec.get("bet1"); len(got) != 1 || !slices.Equal(got[0], []string{"a", "b", "c"}) {
+		t.Errorf("bet1 batches = %q, want [[a b c]]", got)
+	}
+	if got := rec.get("bet2"); len(got) != 1 || !slices.Equal(got[0], []string{"x"}) {
+		t.Errorf("bet2 batches = %q, want [[x]]", got)
+	}
+}
+
+func TestCoalescerFlush(t *testing.T) {
+	var rec recorder
+	c := NewCoalescer(50*time.Millisecond, rec.flush)
+	c.Add("bet1", "a")
+	c.Add("bet1", "b")
+	c.Flush()
+	if got := rec.get("bet1"); len(got) != 1 || !slices.Equal(got[0], []string{"a", "b"}) {
+		t.Fatalf("after Flush: %q, want [[a b]]", got)
+	}
+
+	// The stopped window does not flush again, and a new one opens.
+	c.Add("bet1", "c")
+	time.Sleep(200 * time.Millisecond)
+	if got := rec.get("bet1"); len(got) != 2 || !slices.Equal(got[1], []string{"c"}) {
+		t.Errorf("batches = %q, want [[a b] [c]]", got)
+	}
+}
+
+func TestCoalescerClose(t *testing.T) {
+	var rec recorder
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := NewCoalescer(time.Millisecond, func(key string, items []string) {
+		if key == "slow" {
+			close(started)
+			<-release
+		}
+		rec.flush(key, items)
+	})
+	c.Add("slow", "a")
+	<-started            // its window ended and the flush is running
+	c.window = time.Hour // keep the next batch pending until Close
+	c.Add("bet1", "b")
+
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the running flush ended")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+	if got := rec.get("slow"); len(got) != 1 {
+		t.Errorf("slow batches = %q", got)
+	}
+	if got := rec.get("bet1"); len(got) != 1 || !slices.Equal(got[0], []string{"b"}) {
+		t.Errorf("pending batch = %q, want [[b]] flushed by Close", got)
+	}
+
+	// After Close, items go out at once.
+	c.Add("bet1", "c")
+	if got := rec.get("bet1"); len(got) != 2 || !slices.Equal(got[1], []string{"c"}) {
+		t.Errorf("after Close: %q, want [[b] [c]]", got)
+	}
+}
@@ -0,0 +1,168 @@
+// Package email delivers notifications as plaintext mail over SMTP, to the
+// address users set on their profile (users.email).
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"betsandpedestres/internal/notify"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Config is the SMTP relay. STARTTLS is used whenever the server offers it.
+type Config struct {
+	Host     string
+	Port     int
+	Username string // empty skips AUTH
+	Password string
+	From     string
+	// GroupAddress receives group announcements (e.g. a mailing list).
+	// Empty drops them.
+	GroupAddress string
+	// SiteName prefixes every subject.
+	SiteName string
+}
+
+type Notifier struct {
+	db  *pgxpool.Pool
+	cfg Config
+}
+
+// New returns notify.Noop when no SMTP host is configured.
+func New(db *pgxpool.Pool, cfg Config) notify.Notifier {
+	if strings.TrimSpace(cfg.Host) == "" {
+		return notify.Noop{}
+	}
+	return &Notifier{db: db, cfg: cfg}
+}
+
+func (n *Notifier) NotifyAdmins(ctx context.Context, msg string) {
+	rows, err := n.db.Query(ctx, `select email from users where role = 'admin' and email is not null`)
+	if err != nil {
+		slog.Warn("email.admin_query_failed", "err", err)
+		return
+	}
+	defer rows.Close()
+	var to []string
+	for rows.Next() {
+		var addr string
+		if err := rows.Scan(&addr); err != nil {
+			slog.Warn("email.scan_address", "err", err)
+			continue
+		}
+		to = append(to, addr)
+	}
+	for _, addr := range to {
+		n.send(addr, msg)
+	}
+}
+
+func (n *Notifier) NotifyGroup(ctx context.Context, msg string) {
+	if n.cfg.GroupAddress == "" {
+		return
+	}
+	n.send(n.cfg.GroupAddress, msg)
+}
+
+func (n *Notifier) NotifyUser(ctx context.Context, userID string, msg string) {
+	if userID == "" {
+		return
+	}
+	var addr *string
+	if err := n.db.QueryRow(ctx, `select email from users where id = $1::uuid`, userID).Scan(&addr); err != nil || addr == nil {
+		return
+	}
+	n.send(*addr, msg)
+}
+
+// NotifySubscribers is a no-op: a mail for every new bet and wager would
+// bury the inbox. Broadcasts reach email users through GroupAddress.
+func (n *Notifier) NotifySubscribers(context.Context, string) {}
+
+// send mails msg in the background, like the Telegram notifier it must not
+// hold up the request that triggered it for long.
+func (n *Notifier) send(to, msg string) {
+	subject, body := render(msg)
+	if n.cfg.SiteName != "" {
+		subject = "[" + n.cfg.SiteName + "] " + subject
+	}
+	go func() {
+		if err := n.deliver(to, subject, body); err != nil {
+			slog.Warn("email.send", "err", err)
+		}
+	}()
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// render turns a notification into a subject (its first line) and a
+// plaintext body. HTML-flagged messages lose their markup.
+func render(msg string) (subject, body string) {
+	if strings.HasPrefix(msg, notify.HTMLPrefix) {
+		msg = html.UnescapeString(htmlTag.ReplaceAllString(strings.TrimPrefix(msg, notify.HTMLPrefix), ""))
+	}
+	body = strings.TrimSpace(msg)
+	subject, _, _ = strings.Cut(body, "\n")
+	if r := []rune(subject); len(r) > 78 {
+		subject = string(r[:77]) + "…"
+	}
+	return subject, body
+}
+
+func (n *Notifier) deliver(to, subject, body string) error {
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+	c, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if n.cfg.Username != "" {
+		// PlainAuth refuses to send credentials over a non-TLS link (except
+		// to localhost).
+		if err := c.Auth(smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.cfg.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n%s\r\n",
+		n.cfg.From, to, mime.QEncoding.Encode("utf-8", subject), time.Now().Format(time.RFC1123Z),
+		strings.ReplaceAll(body, "\n", "\r\n"))
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -19,3 +19,48 @@ func (Noop) NotifyAdmins(context.Context, string)       {}
 func (Noop) NotifyGroup(context.Context, string)        {}
 func (Noop) NotifyUser(context.Context, string, string) {}
 func (Noop) NotifySubscribers(context.Context, string)  {}
+
+// fanout sends every notification through each of its notifiers in turn.
+type fanout []Notifier
+
+// Fanout combines notifiers, skipping Noop ones. It returns Noop when none
+// is left and the notifier itself when only one is.
+func Fanout(ns ...Notifier) Notifier {
+	var out fanout
+	for _, n := range ns {
+		if _, noop := n.(Noop); n != nil && !noop {
+			out = append(out, n)
+		}
+	}
+	switch len(out) {
+	case 0:
+		return Noop{}
+	case 1:
+		return out[0]
+	}
+	return out
+}
+
+func (f fanout) NotifyAdmins(ctx context.Context, msg string) {
+	for _, n := range f {
+		n.NotifyAdmins(ctx, msg)
+	}
+}
+
+func (f fanout) NotifyGroup(ctx context.Context, msg string) {
+	for _, n := range f {
+		n.NotifyGroup(ctx, msg)
+	}
+}
+
+func (f fanout) NotifyUser(ctx context.Context, userID string, msg string) {
+	for _, n := range f {
+		n.NotifyUser(ctx, userID, msg)
+	}
+}
+
+func (f fanout) NotifySubscribers(ctx context.Context, msg string) {
+	for _, n := range f {
+		n.NotifySubscribers(ctx, msg)
+	}
+}
@@ -0,0 +1,224 @@
+// Package settings holds admin-tunable values stored in app_settings, as
+// opposed to the file-based config read at startup. Values are cached
+// process-wide and reloaded at most every cacheTTL, so a change made on one
+// instance reaches the others within that delay.
+package settings
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const cacheTTL = 10 * time.Second
+
+// Definition describes a setting editable from the admin UI.
+type Definition struct {
+	Key         string
+	Description string
+	Default     string
+}
+
+// Entry is a definition with its current stored value, if any.
+type Entry struct {
+	Definition
+	Value     string
+	IsSet     bool
+	UpdatedAt time.Time
+}
+
+var (
+	mu        sync.Mutex
+	pool      *pgxpool.Pool
+	values    map[string]string
+	loaded    time.Time
+	gen       uint64 // bumped by Use and Invalidate, to drop reloads they overtook
+	reloading bool
+	defs      = map[string]Definition{}
+	updated   = map[string]time.Time{}
+)
+
+// Use sets the pool settings are read from and written to. Until it is
+// called, Get returns defaults.
+func Use(db *pgxpool.Pool) {
+	mu.Lock()
+	pool = db
+	loaded = time.Time{}
+	gen++
+	reloading = false
+	mu.Unlock()
+}
+
+// Define registers a setting so the admin UI lists it and Set accepts it.
+func Define(key, description, def string) {
+	mu.Lock()
+	defs[key] = Definition{Key: key, Description: description, Default: def}
+	mu.Unlock()
+}
+
+// Invalidate drops the cache; the next Get reloads from the database.
+func Invalidate() {
+	mu.Lock()
+	loaded = time.Time{}
+	gen++
+	reloading = false
+	mu.Unlock()
+}
+
+// Value is the set of types Get can parse a stored value into.
+type Value interface {
+	~string | ~bool | ~int | ~int64 | ~float64
+}
+
+// Get returns the setting parsed as T, or def when it is unset or does not
+// parse. Durations are int64 underneath and are parsed with time.ParseDuration.
+func Get[T Value](ctx context.Context, key string, def T) T {
+	raw, ok := lookup(ctx, key)
+	if !ok {
+		return def
+	}
+	v, err := parse(raw, def)
+	if err != nil {
+		slog.Warn("settings.parse", "key", key, "value", raw, "err", err)
+		return def
+	}
+	return v
+}
+
+func parse[T Value](raw string, def T) (T, error) {
+	var out any
+	var err error
+	switch any(def).(type) {
+	case time.Duration:
+		out, err = time.ParseDuration(raw)
+	case string:
+		out = raw
+	case bool:
+		out, err = parseBool(raw)
+	case int:
+		out, err = strconv.Atoi(raw)
+	case int64:
+		out, err = strconv.ParseInt(raw, 10, 64)
+	case float64:
+		out, err = strconv.ParseFloat(raw, 64)
+	default:
+		return def, fmt.Errorf("unsupported type %T", def)
+	}
+	if err != nil {
+		return def, err
+	}
+	return out.(T), nil
+}
+
+func parseBool(raw string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "on", "yes":
+		return true, nil
+	case "off", "no":
+		return false, nil
+	}
+	return strconv.ParseBool(raw)
+}
+
+func lookup(ctx context.Context, key string) (string, bool) {
+	refresh(ctx)
+	mu.Lock()
+	defer mu.Unlock()
+	v, ok := values[key]
+	return v, ok
+}
+
+// refresh reloads the cache when it is stale. The query runs without mu held,
+// so a slow database does not stall every reader: while one caller reloads,
+// the others keep serving the previous values.
+func refresh(ctx context.Context) {
+	mu.Lock()
+	if pool == nil || time.Since(loaded) < cacheTTL || (reloading && values != nil) {
+		mu.Unlock()
+		return
+	}
+	db, startGen := pool, gen
+	reloading = true
+	mu.Unlock()
+
+	next, nextUpdated, err := load(ctx, db)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if gen != startGen {
+		return // invalidated meanwhile: these values may predate the change
+	}
+	reloading = false
+	if err != nil {
+		// Keep serving the previous values rather than flapping to defaults.
+		slog.Warn("settings.reload", "err", err)
+		return
+	}
+	values, updated, loaded = next, nextUpdated, time.Now()
+}
+
+func load(ctx context.Context, db *pgxpool.Pool) (map[string]string, map[string]time.Time, error) {
+	rows, err := db.Query(ctx, `select key, value, updated_at from app_settings`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	next := map[string]string{}
+	nextUpdated := map[string]time.Time{}
+	for rows.Next() {
+		var k, v string
+		var at time.Time
+		if err := rows.Scan(&k, &v, &at); err != nil {
+			return nil, nil, err
+		}
+		next[k] = v
+		nextUpdated[k] = at
+	}
+	return next, nextUpdated, rows.Err()
+}
+
+// Set stores a defined setting and invalidates the cache.
+func Set(ctx context.Context, key, value, adminID string) error {
+	mu.Lock()
+	_, known := defs[key]
+	db := pool
+	mu.Unlock()
+	if !known {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if db == nil {
+		return fmt.Errorf("settings: no database")
+	}
+	_, err := db.Exec(ctx, `
+		insert into app_settings (key, value, updated_by)
+		values ($1, $2, nullif($3,'')::uuid)
+		on conflict (key) do update
+		  set value = excluded.value, updated_at = now(), updated_by = excluded.updated_by
+	`, key, value, adminID)
+	if err != nil {
+		return err
+	}
+	Invalidate()
+	return nil
+}
+
+// All lists the defined settings with their current values, sorted by key.
+func All(ctx context.Context) []Entry {
+	refresh(ctx)
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]Entry, 0, len(defs))
+	for _, d := range defs {
+		v, ok := values[d.Key]
+		out = append(out, Entry{Definition: d, Value: v, IsSet: ok, UpdatedAt: updated[d.Key]})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
@@ -0,0 +1,63 @@
+package settings_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"betsandpedestres/internal/dbtest"
+	"betsandpedestres/internal/settings"
+)
+
+// TestReloadDoesNotBlockReaders stalls a cache reload on a table lock and
+// checks that other readers keep getting the cached value meanwhile.
+func TestReloadDoesNotBlockReaders(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	settings.Use(pool)
+	t.Cleanup(func() { settings.Use(nil) })
+	settings.Define("test.greeting", "test setting", "hello")
+	if err := settings.Set(ctx, "test.greeting", "bonjour", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := settings.Get(ctx, "test.greeting", "hello"); got != "bonjour" {
+		t.Fatalf("Get = %q, want bonjour", got)
+	}
+
+	lock, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Rollback(ctx)
+	if _, err := lock.Exec(ctx, `lock table app_settings in access exclusive mode`); err != nil {
+		t.Fatal(err)
+	}
+
+	settings.Invalidate()
+	reloaded := make(chan string)
+	go func() { reloaded <- settings.Get(ctx, "test.greeting", "hello") }()
+	time.Sleep(100 * time.Millisecond) // let it start the reload and block
+
+	done := make(chan string)
+	go func() { done <- settings.Get(ctx, "test.greeting", "hello") }()
+	select {
+	case got := <-done:
+		if got != "bonjour" {
+			t.Errorf("Get during a reload = %q, want the cached bonjour", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Get blocked behind a reload")
+	}
+
+	if err := lock.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-reloaded:
+		if got != "bonjour" {
+			t.Errorf("reloading Get = %q, want bonjour", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the reload did not finish")
+	}
+}
@@ -3,6 +3,7 @@ package telegram
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -57,6 +59,10 @@ func (p *Poller) Run(ctx context.Context) {
 	}
 	slog.Info("telegram.poller.start")
 	defer slog.Info("telegram.poller.stop")
+	// A webhook left over from webhook mode would make getUpdates fail.
+	if err := deleteWebhook(ctx, p.botToken); err != nil {
+		slog.Warn("telegram.poller.delete_webhook", "err", err)
+	}
 	var offset int
 	for {
 		select {
@@ -119,11 +125,23 @@ func (p *Poller) handleUpdate(ctx context.Context, upd update) {
 	switch {
 	case strings.HasPrefix(lower, "/register"):
 		p.handleRegister(ctx, upd.Message, text)
+	case strings.HasPrefix(lower, "/start"):
+		p.handleStart(ctx, upd.Message, text)
 	case strings.HasPrefix(lower, "/chatid"):
 		p.reply(upd.Message.Chat.ID, fmt.Sprintf("Your chat ID is %d", upd.Message.Chat.ID))
 	}
 }
 
+// handleStart serves the profile's t.me deep link, which opens the chat with
+// "/start <user-id>": the payload registers like /register does.
+func (p *Poller) handleStart(ctx context.Context, msg *incomingMessage, original string) {
+	if len(strings.Fields(original)) == 1 {
+		p.reply(msg.Chat.ID, "Hi! Open the Telegram link on your profile page, or send /register <your-user-id>.")
+		return
+	}
+	p.handleRegister(ctx, msg, original)
+}
+
 func (p *Poller) handleRegister(ctx context.Context, msg *incomingMessage, original string) {
 	parts := strings.Fields(original)
 	if len(parts) != 2 {
@@ -137,18 +155,52 @@ func (p *Poller) handleRegister(ctx context.Context, msg *incomingMessage, origi
 	}
 	ctxDB, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	var displayName string
-	err := p.db.QueryRow(ctxDB, `
+	displayName, previous, err := p.linkChat(ctxDB, msg.Chat.ID, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		p.reply(msg.Chat.ID, "We couldn't find that user ID. Double-check and try again.")
+		return
+	}
+	if err != nil {
+		slog.Warn("telegram.register", "err", err)
+		p.reply(msg.Chat.ID, "Something went wrong linking this chat. Please try again.")
+		return
+	}
+	reply := fmt.Sprintf("Thanks %s! Telegram alerts are now enabled.", displayName)
+	if previous != "" {
+		reply += fmt.Sprintf("\nThis chat was linked to %s; it no longer is (one chat per account).", previous)
+	}
+	p.reply(msg.Chat.ID, reply)
+}
+
+// linkChat points userID at chatID. A chat links to a single account
+// (users.telegram_chat_id is unique) so that DMs and password recovery
+// reach the right person: registering again moves the link, and previous
+// names the account that lost it, if any.
+func (p *Poller) linkChat(ctx context.Context, chatID int64, userID string) (displayName, previous string, err error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback(ctx)
+	err = tx.QueryRow(ctx, `
+        update users
+        set telegram_chat_id = null, telegram_notify = false
+        where telegram_chat_id = $1 and id <> $2::uuid
+        returning display_name
+    `, chatID, userID).Scan(&previous)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", "", err
+	}
+	err = tx.QueryRow(ctx, `
         update users
         set telegram_chat_id = $1
         where id = $2::uuid
         returning display_name
-    `, msg.Chat.ID, userID).Scan(&displayName)
+    `, chatID, userID).Scan(&displayName)
 	if err != nil {
-		p.reply(msg.Chat.ID, "We couldn't find that user ID. Double-check and try again.")
-		return
+		return "", "", err
 	}
-	p.reply(msg.Chat.ID, fmt.Sprintf("Thanks %s! Telegram alerts are now enabled.", displayName))
+	return displayName, previous, tx.Commit(ctx)
 }
 
 func (p *Poller) reply(chatID int64, message string) {
@@ -0,0 +1,61 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"betsandpedestres/internal/dbtest"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestLinkChatMovesLink(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	alice, _ := dbtest.User(t, pool, "alice", "")
+	bob, _ := dbtest.User(t, pool, "bob", "")
+	p := &Poller{db: pool}
+	const chat = 4242
+
+	chatOf := func(uid string) (chatID *int64, notify bool) {
+		t.Helper()
+		if err := pool.QueryRow(ctx, `select telegram_chat_id, telegram_notify from users where id = $1::uuid`, uid).Scan(&chatID, &notify); err != nil {
+			t.Fatal(err)
+		}
+		return chatID, notify
+	}
+	link := func(uid, wantPrevious string) {
+		t.Helper()
+		name, previous, err := p.linkChat(ctx, chat, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if previous != wantPrevious {
+			t.Errorf("linking %s: previous = %q, want %q", name, previous, wantPrevious)
+		}
+		if id, _ := chatOf(uid); id == nil || *id != chat {
+			t.Errorf("%s is not linked to the chat", name)
+		}
+	}
+
+	link(alice, "")
+	if _, err := pool.Exec(ctx, `update users set telegram_notify = true where id = $1::uuid`, alice); err != nil {
+		t.Fatal(err)
+	}
+	link(alice, "")
+
+	// The chat moves to bob and alice loses it, notifications included.
+	link(bob, "alice")
+	if id, notify := chatOf(alice); id != nil || notify {
+		t.Errorf("alice still has chat %v, notify %v", id, notify)
+	}
+	link(bob, "")
+
+	// An unknown account changes nothing: bob keeps the chat.
+	if _, _, err := p.linkChat(ctx, chat, "00000000-0000-4000-8000-000000000000"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("unknown user: err = %v, want ErrNoRows", err)
+	}
+	if id, _ := chatOf(bob); id == nil || *id != chat {
+		t.Error("bob lost the chat to an unknown account")
+	}
+}
@@ -0,0 +1,98 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Webhook serves the updates Telegram pushes to
+// POST /telegram/webhook/{secret}, as an alternative to Poller.
+type Webhook struct {
+	bot    *Poller // reuses the poller's command handling
+	secret string
+}
+
+// NewWebhook returns nil when the bot token or the secret is missing.
+func NewWebhook(db *pgxpool.Pool, token, secret string) *Webhook {
+	bot := NewPoller(db, token)
+	if bot == nil || secret == "" {
+		return nil
+	}
+	return &Webhook{bot: bot, secret: secret}
+}
+
+func (h *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Both the URL and the header registered with setWebhook must match, so
+	// nobody can push fake /register commands.
+	if !h.matches(r.PathValue("secret")) || !h.matches(r.Header.Get("X-Telegram-Bot-Api-Secret-Token")) {
+		http.NotFound(w, r)
+		return
+	}
+	var upd update
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&upd); err != nil {
+		http.Error(w, "bad update", http.StatusBadRequest)
+		return
+	}
+	h.bot.handleUpdate(r.Context(), upd)
+	// Any non-2xx makes Telegram redeliver the update.
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Webhook) matches(s string) bool {
+	return subtle.ConstantTimeCompare([]byte(s), []byte(h.secret)) == 1
+}
+
+// SetWebhook points the bot's updates at webhookURL. Telegram echoes secret
+// back in the X-Telegram-Bot-Api-Secret-Token header of every delivery.
+func SetWebhook(ctx context.Context, token, webhookURL, secret string) error {
+	data := url.Values{}
+	data.Set("url", webhookURL)
+	data.Set("secret_token", secret)
+	data.Set("allowed_updates", `["message"]`)
+	return callAPI(ctx, token, "setWebhook", data)
+}
+
+// deleteWebhook switches the bot back to getUpdates, which Telegram refuses
+// while a webhook is registered.
+func deleteWebhook(ctx context.Context, token string) error {
+	return callAPI(ctx, token, "deleteWebhook", url.Values{})
+}
+
+func callAPI(ctx context.Context, token, method string, data url.Values) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("https://api.telegram.org/bot%s/%s", token, method), strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("telegram.api.close", "err", err)
+		}
+	}()
+	var res struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+	if !res.OK {
+		return fmt.Errorf("telegram %s: %s", method, res.Description)
+	}
+	return nil
+}
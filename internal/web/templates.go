@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"betsandpedestres/internal/coins"
 	"github.com/Masterminds/sprig/v3"
 )
 
@@ -21,7 +22,8 @@ func NewRenderer() (*Renderer, error) { return &Renderer{}, nil }
 func (r *Renderer) Render(w io.Writer, name string, data any) error {
 	funcs := template.FuncMap{
 		"nowUTC":      func() time.Time { return time.Now().UTC() },
-		"formatCoins": func(v int64) string { return strconvFormat(v) },
+		"formatCoins": coins.Format,
+		"coinStep":    coins.Step,
 	}
 	t := template.New("root").Funcs(funcs).Funcs(sprig.FuncMap())
 	if _, err := t.ParseFS(tplFS, "tpl/base.tmpl", "tpl/partials/*.tmpl"); err != nil {
@@ -33,25 +35,3 @@ func (r *Renderer) Render(w io.Writer, name string, data any) error {
 	}
 	return t.ExecuteTemplate(w, name, data)
 }
-
-func strconvFormat(v int64) string {
-	neg := v < 0
-	if neg {
-		v = -v
-	}
-	if v == 0 {
-		return "0"
-	}
-	var buf [20]byte
-	i := len(buf)
-	for v > 0 {
-		i--
-		buf[i] = byte('0' + (v % 10))
-		v /= 10
-	}
-	if neg {
-		i--
-		buf[i] = '-'
-	}
-	return string(buf[i:])
-}
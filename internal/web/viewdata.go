@@ -1,5 +1,30 @@
 package web
 
+import "html/template"
+
+// Branding is the operator-configured identity of the instance.
+type Branding struct {
+	Name       string
+	Tagline    string
+	FooterHTML template.HTML // from config, trusted
+}
+
+// Captcha describes the anti-bot widget forms embed; empty SiteKey means
+// none is configured.
+type Captcha struct {
+	ScriptURL string
+	Class     string
+	SiteKey   string
+}
+
+// Banner is a site-wide announcement shown under the header until the
+// visitor dismisses it for the session.
+type Banner struct {
+	ID       string
+	Message  string
+	Severity string // info, warning or critical
+}
+
 // HeaderData is rendered by the shared header partial on every page.
 type HeaderData struct {
 	LoggedIn    bool
@@ -7,10 +32,23 @@ type HeaderData struct {
 	Username    string
 	Balance     int64
 	Version     string
+	Brand       Branding
+	Captcha     Captcha
+	Banners     []Banner
+}
+
+// MetaData feeds the OpenGraph tags used for link previews. Pages leaving
+// Title empty emit none.
+type MetaData struct {
+	Title       string
+	Description string
+	URL         string
+	Image       string
 }
 
 // Page wraps shared Header + page-specific Content.
 type Page[T any] struct {
 	Header  HeaderData
+	Meta    MetaData
 	Content T
 }